@@ -25,3 +25,15 @@ func IsAllowed(ctx context.Context, p influxdb.Permission) error {
 
 	return nil
 }
+
+// IsAllowedAll checks to see if every action provided is authorized by the authorizer
+// on context.
+func IsAllowedAll(ctx context.Context, ps []influxdb.Permission) error {
+	for _, p := range ps {
+		if err := IsAllowed(ctx, p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
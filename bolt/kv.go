@@ -153,6 +153,19 @@ func (tx *Tx) createBucketIfNotExists(b []byte) (*Bucket, error) {
 	}, nil
 }
 
+// BucketNames returns the names of every bucket. It implements kv.BucketLister.
+func (tx *Tx) BucketNames() ([][]byte, error) {
+	var names [][]byte
+	err := tx.tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		names = append(names, append([]byte(nil), name...))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
 // Bucket retrieves the bucket named b.
 func (tx *Tx) Bucket(b []byte) (kv.Bucket, error) {
 	bkt := tx.tx.Bucket(b)
@@ -35,6 +35,7 @@ import (
 	"github.com/influxdata/influxdb/kit/tracing"
 	"github.com/influxdata/influxdb/kv"
 	influxlogger "github.com/influxdata/influxdb/logger"
+	"github.com/influxdata/influxdb/migration"
 	"github.com/influxdata/influxdb/nats"
 	infprom "github.com/influxdata/influxdb/prometheus"
 	"github.com/influxdata/influxdb/query"
@@ -176,6 +177,12 @@ func buildLauncherCommand(l *Launcher, cmd *cobra.Command) {
 			Default: false,
 			Desc:    "disable sending telemetry data to https://telemetry.influxdata.com every 8 hours",
 		},
+		{
+			DestP:   &l.documentAccessFlushInterval,
+			Flag:    "document-access-flush-interval",
+			Default: time.Duration(0),
+			Desc:    "how often the last time documents were read is written; 0 disables tracking document accesses",
+		},
 	}
 
 	cli.BindOptions(cmd, opts)
@@ -200,8 +207,11 @@ type Launcher struct {
 	enginePath      string
 	secretStore     string
 
+	documentAccessFlushInterval time.Duration
+
 	boltClient    *bolt.Client
 	kvService     *kv.Service
+	migrations    *migration.Coordinator
 	engine        *storage.Engine
 	StorageConfig storage.Config
 
@@ -219,6 +229,14 @@ type Launcher struct {
 	logger             *zap.Logger
 	reg                *prom.Registry
 
+	documentAccess              io.Closer
+	documentWebhooks            *http.DocumentWebhookNotifier
+	unsubscribeDocumentWebhooks func()
+
+	// DocumentNamespaces configure individual document namespaces, including the webhooks
+	// notified of the changes made to their documents.
+	DocumentNamespaces map[string]http.DocumentNamespaceConfig
+
 	Stdin      io.Reader
 	Stdout     io.Writer
 	Stderr     io.Writer
@@ -270,12 +288,27 @@ func (m *Launcher) Engine() *storage.Engine {
 func (m *Launcher) Shutdown(ctx context.Context) {
 	m.httpServer.Shutdown(ctx)
 
+	if m.documentWebhooks != nil {
+		m.logger.Info("Stopping", zap.String("service", "document-webhooks"))
+		m.unsubscribeDocumentWebhooks()
+		if err := m.documentWebhooks.Close(); err != nil {
+			m.logger.Info("Failed closing document webhooks", zap.Error(err))
+		}
+	}
+
 	m.logger.Info("Stopping", zap.String("service", "task"))
 	m.scheduler.Stop()
 
 	m.logger.Info("Stopping", zap.String("service", "nats"))
 	m.natsServer.Close()
 
+	if m.documentAccess != nil {
+		m.logger.Info("Stopping", zap.String("service", "document-access"))
+		if err := m.documentAccess.Close(); err != nil {
+			m.logger.Info("Failed closing document access tracker", zap.Error(err))
+		}
+	}
+
 	m.logger.Info("Stopping", zap.String("service", "bolt"))
 	if err := m.boltClient.Close(); err != nil {
 		m.logger.Info("failed closing bolt", zap.Error(err))
@@ -405,11 +438,21 @@ func (m *Launcher) run(ctx context.Context) (err error) {
 	}
 
 	m.kvService.Logger = m.logger.With(zap.String("store", "kv"))
+	if m.documentAccessFlushInterval > 0 {
+		m.documentAccess = m.kvService.TrackDocumentAccess(m.documentAccessFlushInterval)
+	}
 	if err := m.kvService.Initialize(ctx); err != nil {
 		m.logger.Error("failed to initialize kv service", zap.Error(err))
 		return err
 	}
 
+	m.migrations = migration.NewCoordinator()
+	m.migrations.Register("kv", m.kvService)
+	if err := m.migrations.ConvertToNew(ctx); err != nil {
+		m.logger.Error("failed to migrate kv data", zap.Error(err))
+		return err
+	}
+
 	m.reg = prom.NewRegistry()
 	m.reg.MustRegister(
 		prometheus.NewGoCollector(),
@@ -582,6 +625,11 @@ func (m *Launcher) run(ctx context.Context) (err error) {
 		Addr: m.httpBindAddress,
 	}
 
+	if hooks := http.DocumentWebhooks(m.DocumentNamespaces); len(hooks) > 0 {
+		m.documentWebhooks = http.NewDocumentWebhookNotifier(m.logger.With(zap.String("service", "document-webhooks")), hooks)
+		m.unsubscribeDocumentWebhooks = m.kvService.SubscribeDocumentEvents(m.documentWebhooks.Notify)
+	}
+
 	m.apibackend = &http.APIBackend{
 		AssetsPath:           m.assetsPath,
 		Logger:               m.logger,
@@ -614,7 +662,9 @@ func (m *Launcher) run(ctx context.Context) (err error) {
 		SecretService:                   secretSvc,
 		LookupService:                   lookupSvc,
 		DocumentService:                 m.kvService,
+		DocumentNamespaces:              m.DocumentNamespaces,
 		OrgLookupService:                m.kvService,
+		DataMigrationService:            m.migrations,
 	}
 
 	// HTTP server
@@ -624,6 +674,7 @@ func (m *Launcher) run(ctx context.Context) (err error) {
 
 	h := http.NewHandlerFromRegistry("platform", m.reg)
 	h.Handler = platformHandler
+	h.HealthHandler = http.NewHealthHandler(http.DocumentHealthCheck(m.kvService))
 	h.Logger = httpLogger
 	h.Tracer = opentracing.GlobalTracer()
 
@@ -0,0 +1,20 @@
+package context
+
+import (
+	"context"
+)
+
+const (
+	namespaceCtxKey = contextKey("influx/namespace/v1")
+)
+
+// WithNamespace sets the document namespace of a request on context.
+func WithNamespace(ctx context.Context, ns string) context.Context {
+	return context.WithValue(ctx, namespaceCtxKey, ns)
+}
+
+// NamespaceFromContext retrieves the document namespace from context; it is empty if none was set.
+func NamespaceFromContext(ctx context.Context) string {
+	ns, _ := ctx.Value(namespaceCtxKey).(string)
+	return ns
+}
@@ -0,0 +1,20 @@
+package context_test
+
+import (
+	"context"
+	"testing"
+
+	icontext "github.com/influxdata/influxdb/context"
+)
+
+func TestNamespaceFromContext(t *testing.T) {
+	ctx := context.Background()
+	if got := icontext.NamespaceFromContext(ctx); got != "" {
+		t.Errorf("NamespaceFromContext() want empty, got %s", got)
+	}
+
+	ctx = icontext.WithNamespace(ctx, "templates")
+	if got, want := icontext.NamespaceFromContext(ctx), "templates"; got != want {
+		t.Errorf("NamespaceFromContext() want %s, got %s", want, got)
+	}
+}
@@ -2,15 +2,28 @@ package influxdb
 
 import (
 	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
 )
 
 // ErrDocumentNotFound is the error msg for a missing document.
 const ErrDocumentNotFound = "document not found"
 
+// ErrDocumentContentCorrupt is the error msg for document content that does not match its checksum.
+const ErrDocumentContentCorrupt = "document content is corrupt"
+
+// ErrDocumentLocked is the error msg for a change to a document locked by another owner.
+const ErrDocumentLocked = "document is locked by another owner"
+
 // DocumentService is used to create/find instances of document stores.
 type DocumentService interface {
 	CreateDocumentStore(ctx context.Context, name string) (DocumentStore, error)
 	FindDocumentStore(ctx context.Context, name string) (DocumentStore, error)
+	// Ping performs a trivial operation on the storage backing the service to confirm
+	// that it is reachable.
+	Ping(ctx context.Context) error
 }
 
 // Document is a generic structure for stating data.
@@ -24,17 +37,84 @@ type Document struct {
 // DocumentMeta is information that is universal across documents. Ideally
 // data in the meta should be indexed and queryable.
 type DocumentMeta struct {
-	Name    string `json:"name"`
-	Version string `json:"version,omitempty"`
+	Name      string    `json:"name"`
+	Version   string    `json:"version,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// LastAccessedAt is the last time the content of the document was read. It is
+	// only recorded when the store has been configured to track document access.
+	LastAccessedAt *time.Time `json:"lastAccessedAt,omitempty"`
+	// Pinned documents are excluded from bulk deletes unless they are explicitly included.
+	// It is only changed by pinning or unpinning the document.
+	Pinned bool `json:"pinned,omitempty"`
+	// Variables are the default values of the {{name}} placeholders in the content, used
+	// when the document is rendered.
+	Variables map[string]string `json:"variables,omitempty"`
+	// LastWriterID is the user that last created or updated the document. Writes that do not
+	// set it keep the last writer recorded.
+	LastWriterID ID `json:"lastWriterID,omitempty"`
+	// ContentType is the media type of the content, set when the document is created.
+	// Documents without one hold JSON content.
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// SortDocuments sorts a slice of documents by a field.
+func SortDocuments(opts FindOptions, ds []*Document) {
+	var less func(i, j int) bool
+	switch opts.SortBy {
+	case "Name":
+		less = func(i, j int) bool {
+			return ds[i].Meta.Name < ds[j].Meta.Name
+		}
+	case "CreatedAt":
+		less = func(i, j int) bool {
+			return ds[i].Meta.CreatedAt.Before(ds[j].Meta.CreatedAt)
+		}
+	case "UpdatedAt":
+		less = func(i, j int) bool {
+			return ds[i].Meta.UpdatedAt.Before(ds[j].Meta.UpdatedAt)
+		}
+	case "LastAccessedAt":
+		less = func(i, j int) bool {
+			ti, tj := ds[i].Meta.LastAccessedAt, ds[j].Meta.LastAccessedAt
+			if ti == nil || tj == nil {
+				// documents that have never been accessed sort first.
+				return ti == nil && tj != nil
+			}
+			return ti.Before(*tj)
+		}
+	default:
+		less = func(i, j int) bool {
+			return ds[i].ID < ds[j].ID
+		}
+	}
+
+	sort.SliceStable(ds, func(i, j int) bool {
+		if opts.Descending {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
 }
 
 // DocumentStore is used to perform CRUD operations on documents. It follows an options
 // pattern that allows users to perform actions related to documents in a transactional way.
 type DocumentStore interface {
 	CreateDocument(ctx context.Context, d *Document, opts ...DocumentOptions) error
+	// UpdateDocument updates the document. Its labels are left untouched, and are changed by
+	// the options, such as WithExactLabelIDs.
 	UpdateDocument(ctx context.Context, d *Document, opts ...DocumentOptions) error
+	// AppendContent atomically appends data to the array content of the document with the id
+	// provided and returns the updated document.
+	AppendContent(ctx context.Context, id ID, data []interface{}, opts ...DocumentOptions) (*Document, error)
 
+	// FindDocuments retrieves the documents selected by the options. Options that only decorate
+	// or filter documents, such as IncludeContent or WhereName, must be combined with at least
+	// one option selecting documents, such as WhereID.
 	FindDocuments(ctx context.Context, opts ...DocumentFindOptions) ([]*Document, error)
+	// FindDocumentsByLabel retrieves every document carrying the label provided, including
+	// its content and labels, without authorizing the caller.
+	FindDocumentsByLabel(ctx context.Context, labelID ID) ([]*Document, error)
 	DeleteDocuments(ctx context.Context, opts ...DocumentFindOptions) error
 }
 
@@ -62,8 +142,174 @@ type DocumentIndex interface {
 
 	AddDocumentLabel(docID, labelID ID) error
 	RemoveDocumentLabel(docID, labelID ID) error
+	FindDocumentsByLabel(labelID ID) ([]ID, error)
+	FindDocumentsByName(name string) ([]ID, error)
+	// FindDocumentsByIDPrefix retrieves the IDs of the documents whose encoded ID starts with
+	// the prefix provided.
+	FindDocumentsByIDPrefix(prefix string) ([]ID, error)
+	// FindDocumentLock retrieves the lock held on the document, or nil if it is not locked.
+	// Expired locks are not returned.
+	FindDocumentLock(docID ID) (*DocumentLock, error)
+	// FindDocument retrieves the meta, content and labels of the document.
+	FindDocument(docID ID) (*Document, error)
+}
+
+// DocumentByIDFinder is implemented by document stores that can find a single document by ID
+// without listing documents.
+type DocumentByIDFinder interface {
+	// FindDocumentByID retrieves the document with the ID provided, with its content and labels.
+	// The options restrict access to the document, such as AuthorizedWhereID does; a document
+	// that an option does not select or that a filter does not match is not found.
+	FindDocumentByID(ctx context.Context, id ID, opts ...DocumentFindOptions) (*Document, error)
+}
+
+// DocumentLabeler is implemented by document stores that can attach and detach the labels of a
+// document without updating the document itself.
+type DocumentLabeler interface {
+	// UpdateDocumentLabels applies the options provided to the document in order, such as
+	// WithLabelID and WithoutLabelID, and returns the labels of the document once they are
+	// applied, all within a single transaction. The meta and content of the document are left
+	// untouched.
+	UpdateDocumentLabels(ctx context.Context, id ID, opts ...DocumentOptions) ([]*Label, error)
+}
+
+// DocumentTrasher is implemented by document stores that can move documents to the trash
+// rather than deleting them.
+type DocumentTrasher interface {
+	// TrashDocuments moves all documents returned by the options into the trash.
+	TrashDocuments(ctx context.Context, opts ...DocumentFindOptions) error
+}
+
+// DocumentIdempotentCreator is implemented by document stores that can deduplicate retried creates.
+type DocumentIdempotentCreator interface {
+	// CreateDocumentOnce creates the document unless a document was already created with the
+	// idempotency key provided, in which case d is replaced by that document and false is returned.
+	CreateDocumentOnce(ctx context.Context, key string, d *Document, opts ...DocumentOptions) (bool, error)
+}
+
+// DocumentDuplicateFinder is implemented by document stores that can find documents with
+// identical content, such as those created by repeated imports.
+type DocumentDuplicateFinder interface {
+	// FindDuplicates returns the sets of documents owned by the organization provided that
+	// share identical content. Each set holds at least two documents, without their content.
+	FindDuplicates(ctx context.Context, orgID ID) ([][]*Document, error)
+}
+
+// DocumentLabelAttachedAtFinder is implemented by document stores that record when labels
+// are attached to documents.
+type DocumentLabelAttachedAtFinder interface {
+	// FindDocumentLabelsAttachedAt returns when each label of the document was attached, keyed
+	// by label ID. Labels attached before times were recorded are omitted.
+	FindDocumentLabelsAttachedAt(ctx context.Context, id ID) (map[ID]time.Time, error)
+}
+
+// DocumentRestorer is implemented by document stores that can write a document read from
+// another store, keeping its ID and timestamps.
+type DocumentRestorer interface {
+	// RestoreDocument writes the document provided as is. It is a conflict if a document
+	// with the same ID already exists. Restoring a document for an organization adds it to the
+	// documents of that organization, so callers enforce WithDocumentQuota with the options.
+	RestoreDocument(ctx context.Context, d *Document, opts ...DocumentOptions) error
+}
+
+// DocumentMover is implemented by document stores that can move documents to another namespace.
+type DocumentMover interface {
+	// MoveDocument moves the document, keeping its ID, owners and labels, into the namespace
+	// provided, which must already exist. The options opts are applied before the document is
+	// moved, in its namespace, and targetOpts once it is moved, in the namespace provided.
+	MoveDocument(ctx context.Context, id ID, ns string, opts, targetOpts []DocumentOptions) error
+}
+
+// DocumentLock is an advisory lock held on a document, such as by a user editing it.
+type DocumentLock struct {
+	OwnerID   ID        `json:"ownerID"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// DocumentLocker is implemented by document stores that support advisory locks. Locks are
+// only enforced on writes made with the WithLockOwner option.
+type DocumentLocker interface {
+	// LockDocument acquires the lock of the document for the owner provided until ttl has
+	// elapsed, or renews it if the owner already holds it. It is a conflict if another owner
+	// holds the lock.
+	LockDocument(ctx context.Context, id, ownerID ID, ttl time.Duration, opts ...DocumentOptions) (*DocumentLock, error)
+	// UnlockDocument releases the lock held by the owner provided. It is a conflict if
+	// another owner holds the lock.
+	UnlockDocument(ctx context.Context, id, ownerID ID, opts ...DocumentOptions) error
+}
+
+// DocumentPinner is implemented by document stores that can pin documents to protect them
+// from bulk deletes.
+type DocumentPinner interface {
+	// PinDocument pins or unpins the document.
+	PinDocument(ctx context.Context, id ID, pinned bool, opts ...DocumentOptions) error
+}
+
+// DocumentOperation is a kind of change made to a document.
+type DocumentOperation string
+
+// Operations reported by a DocumentChangefeed.
+const (
+	DocumentCreated DocumentOperation = "create"
+	DocumentUpdated DocumentOperation = "update"
+	DocumentDeleted DocumentOperation = "delete"
+
+	// DocumentLabelAttached and DocumentLabelDetached report a label being added to or
+	// removed from a document. The event's LabelID holds the label.
+	DocumentLabelAttached DocumentOperation = "attach"
+	DocumentLabelDetached DocumentOperation = "detach"
+)
+
+// DocumentEvent reports a change made to a document.
+type DocumentEvent struct {
+	Namespace string            `json:"namespace"`
+	ID        ID                `json:"id"`
+	Operation DocumentOperation `json:"operation"`
+	// LabelID is the label attached or detached, and is only set for label events.
+	LabelID ID `json:"labelID,omitempty"`
+}
+
+// DocumentChangefeed is implemented by document services that publish the changes made to
+// documents, such as to keep external caches in sync.
+type DocumentChangefeed interface {
+	// SubscribeDocumentEvents calls fn with each change made to documents once it has been
+	// committed, in the order the changes were committed, until unsubscribe is called.
+	SubscribeDocumentEvents(fn func(DocumentEvent)) (unsubscribe func())
+}
+
+// NamespacedDocument is a document along with the namespace it belongs to.
+type NamespacedDocument struct {
+	Namespace string `json:"namespace"`
+	*Document
+}
 
-	// TODO(desa): support finding document by label
+// DocumentCrossNamespaceFinder finds documents by ID in whichever namespace they belong to.
+type DocumentCrossNamespaceFinder interface {
+	// FindDocumentsAcrossNamespaces returns the documents with the IDs provided, with their
+	// content and labels, in the order of the IDs. IDs that no document has are skipped.
+	FindDocumentsAcrossNamespaces(ctx context.Context, ids []ID) ([]*NamespacedDocument, error)
+}
+
+// DocumentLabelIndexer rebuilds the index used to find documents by label.
+type DocumentLabelIndexer interface {
+	// ReindexDocumentLabels rebuilds the label index of the namespace provided from the
+	// label mappings of its documents. It returns the number of index entries written.
+	ReindexDocumentLabels(ctx context.Context, ns string) (int, error)
+}
+
+// RawDocument is a document as it is stored, before it is decoded.
+type RawDocument struct {
+	ID      ID     `json:"id"`
+	Meta    []byte `json:"meta"`
+	Content []byte `json:"content"`
+}
+
+// DocumentRawFinder finds documents as they are stored, such as to diagnose documents that
+// cannot be decoded.
+type DocumentRawFinder interface {
+	// FindRawDocument returns the bytes stored for the meta and content of the document in
+	// the namespace provided.
+	FindRawDocument(ctx context.Context, ns string, id ID) (*RawDocument, error)
 }
 
 // DocumentDecorator passes information to the DocumentStore about the presentation
@@ -72,9 +318,114 @@ type DocumentIndex interface {
 type DocumentDecorator interface {
 	IncludeContent() error
 	IncludeLabels() error
+	// Filter restricts the documents retrieved to those matching the filter provided.
+	// All filters are applied in a single pass over the documents selected by the other options.
+	Filter(fn DocumentFilter) error
 	// TODO(desa): add support for including owners.
 }
 
+// DocumentFilter reports whether a document should be retrieved. The documents meta
+// and labels are populated when the filter is called.
+type DocumentFilter func(d *Document) bool
+
+// WhereName restricts the documents retrieved to those with the name provided.
+func WhereName(name string) func(DocumentIndex, DocumentDecorator) ([]ID, error) {
+	return func(_ DocumentIndex, dd DocumentDecorator) ([]ID, error) {
+		return nil, dd.Filter(func(d *Document) bool {
+			return d.Meta.Name == name
+		})
+	}
+}
+
+// WhereLabel restricts the documents retrieved to those that have the label provided.
+func WhereLabel(label string) func(DocumentIndex, DocumentDecorator) ([]ID, error) {
+	return func(_ DocumentIndex, dd DocumentDecorator) ([]ID, error) {
+		return nil, dd.Filter(func(d *Document) bool {
+			for _, l := range d.Labels {
+				if l.Name == label {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// WhereLabelNameMatches restricts the documents retrieved to those that have a label whose
+// name matches the regular expression provided.
+func WhereLabelNameMatches(re *regexp.Regexp) func(DocumentIndex, DocumentDecorator) ([]ID, error) {
+	return func(_ DocumentIndex, dd DocumentDecorator) ([]ID, error) {
+		return nil, dd.Filter(func(d *Document) bool {
+			for _, l := range d.Labels {
+				if re.MatchString(l.Name) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// WhereHasLabels restricts the documents retrieved to those that have at least one label
+// when has is true, and to those without any label otherwise.
+func WhereHasLabels(has bool) func(DocumentIndex, DocumentDecorator) ([]ID, error) {
+	return func(_ DocumentIndex, dd DocumentDecorator) ([]ID, error) {
+		return nil, dd.Filter(func(d *Document) bool {
+			return (len(d.Labels) > 0) == has
+		})
+	}
+}
+
+// WhereUpdatedAfter restricts the documents retrieved to those updated strictly after
+// the time provided.
+func WhereUpdatedAfter(t time.Time) func(DocumentIndex, DocumentDecorator) ([]ID, error) {
+	return func(_ DocumentIndex, dd DocumentDecorator) ([]ID, error) {
+		return nil, dd.Filter(func(d *Document) bool {
+			return d.Meta.UpdatedAt.After(t)
+		})
+	}
+}
+
+// WhereLabelID restricts the documents retrieved to those that have the label provided.
+// Unlike WhereLabel, the documents are found using the label index.
+func WhereLabelID(labelID ID) func(DocumentIndex, DocumentDecorator) ([]ID, error) {
+	return func(idx DocumentIndex, dd DocumentDecorator) ([]ID, error) {
+		ids, err := idx.FindDocumentsByLabel(labelID)
+		if err != nil {
+			return nil, err
+		}
+
+		labeled := make(map[ID]bool, len(ids))
+		for _, id := range ids {
+			labeled[id] = true
+		}
+
+		return nil, dd.Filter(func(d *Document) bool {
+			return labeled[d.ID]
+		})
+	}
+}
+
+// WhereAnyLabelID restricts the documents retrieved to those that have at least one of the
+// labels provided.
+func WhereAnyLabelID(labelIDs ...ID) func(DocumentIndex, DocumentDecorator) ([]ID, error) {
+	return func(_ DocumentIndex, dd DocumentDecorator) ([]ID, error) {
+		ids := make(map[ID]bool, len(labelIDs))
+		for _, id := range labelIDs {
+			ids[id] = true
+		}
+
+		return nil, dd.Filter(func(d *Document) bool {
+			for _, l := range d.Labels {
+				if ids[l.ID] {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
 // IncludeContent signals to the DocumentStore that the content of the document
 // should be included.
 func IncludeContent(_ DocumentIndex, dd DocumentDecorator) ([]ID, error) {
@@ -235,6 +586,57 @@ func WithLabel(label string) func(ID, DocumentIndex) error {
 	}
 }
 
+// WithLabelID adds the label with the provided id to the documents where it is applied.
+func WithLabelID(labelID ID) func(ID, DocumentIndex) error {
+	return func(id ID, idx DocumentIndex) error {
+		return idx.AddDocumentLabel(id, labelID)
+	}
+}
+
+// WithExactLabelIDs leaves the documents where it is applied carrying exactly the labels with
+// the provided ids. Only the mappings of the labels added or removed are changed, so that the
+// mappings of the labels the documents keep are preserved.
+func WithExactLabelIDs(labelIDs ...ID) func(ID, DocumentIndex) error {
+	return func(id ID, idx DocumentIndex) error {
+		d, err := idx.FindDocument(id)
+		if err != nil {
+			return err
+		}
+
+		want := make(map[ID]bool, len(labelIDs))
+		for _, labelID := range labelIDs {
+			if !labelID.Valid() {
+				return &Error{
+					Code: EInvalid,
+					Msg:  "document labels must have a valid id",
+				}
+			}
+			want[labelID] = true
+		}
+
+		have := make(map[ID]bool, len(d.Labels))
+		for _, l := range d.Labels {
+			have[l.ID] = true
+			if !want[l.ID] {
+				if err := idx.RemoveDocumentLabel(id, l.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, labelID := range labelIDs {
+			if !have[labelID] {
+				have[labelID] = true
+				if err := idx.AddDocumentLabel(id, labelID); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
 // WithoutLabel removes a label to the documents where it is applied.
 func WithoutLabel(label string) func(ID, DocumentIndex) error {
 	return func(id ID, idx DocumentIndex) error {
@@ -249,6 +651,189 @@ func WithoutLabel(label string) func(ID, DocumentIndex) error {
 	}
 }
 
+// WithoutLabelID removes the label with the provided id from the documents where it is applied.
+func WithoutLabelID(labelID ID) func(ID, DocumentIndex) error {
+	return func(id ID, idx DocumentIndex) error {
+		return idx.RemoveDocumentLabel(id, labelID)
+	}
+}
+
+// WithLockOwner ensures that the document is not locked by an owner other than the one
+// provided.
+func WithLockOwner(ownerID ID) func(ID, DocumentIndex) error {
+	return func(id ID, idx DocumentIndex) error {
+		l, err := idx.FindDocumentLock(id)
+		if err != nil {
+			return err
+		}
+
+		if l != nil && l.OwnerID != ownerID {
+			return &Error{
+				Code: EConflict,
+				Msg:  ErrDocumentLocked,
+			}
+		}
+
+		return nil
+	}
+}
+
+// WithMaxLabels ensures that the documents where it is applied carry no more than max labels.
+// It must be applied after the options attaching labels.
+func WithMaxLabels(max int) func(ID, DocumentIndex) error {
+	return func(id ID, idx DocumentIndex) error {
+		d, err := idx.FindDocument(id)
+		if err != nil {
+			return err
+		}
+
+		if len(d.Labels) > max {
+			return &Error{
+				Code: EUnprocessableEntity,
+				Msg:  fmt.Sprintf("document %s cannot have more than %d labels", id, max),
+			}
+		}
+
+		return nil
+	}
+}
+
+// WithRequiredLabels ensures that the documents where it is applied keep at least one label.
+// It must be applied after the options detaching labels.
+func WithRequiredLabels(id ID, idx DocumentIndex) error {
+	d, err := idx.FindDocument(id)
+	if err != nil {
+		return err
+	}
+
+	if len(d.Labels) == 0 {
+		return &Error{
+			Code: EConflict,
+			Msg:  fmt.Sprintf("document %s must keep at least one label", id),
+		}
+	}
+
+	return nil
+}
+
+// WhereLockOwner ensures that the document with the id provided is not locked by an owner
+// other than the one provided, in the transaction of the find, such as when deleting documents.
+// It selects no documents itself, and is combined with options that do, such as WhereID.
+func WhereLockOwner(ownerID, docID ID) func(DocumentIndex, DocumentDecorator) ([]ID, error) {
+	return func(idx DocumentIndex, _ DocumentDecorator) ([]ID, error) {
+		return nil, WithLockOwner(ownerID)(docID, idx)
+	}
+}
+
+// WhereNotPinned ensures that the document with the id provided is not pinned, in the
+// transaction of the find, such as when deleting documents in bulk. It selects no documents
+// itself, and is combined with options that do, such as WhereID.
+func WhereNotPinned(docID ID) func(DocumentIndex, DocumentDecorator) ([]ID, error) {
+	return func(idx DocumentIndex, _ DocumentDecorator) ([]ID, error) {
+		d, err := idx.FindDocument(docID)
+		if err != nil {
+			return nil, err
+		}
+		if d.Meta.Pinned {
+			return nil, &Error{
+				Code: EConflict,
+				Msg:  fmt.Sprintf("document %s is pinned", docID),
+			}
+		}
+		return nil, nil
+	}
+}
+
+// WithUniqueName ensures that no other document owned by an organization of the document
+// where it is applied has the name provided. When creating a document, it must be applied
+// after the options that set the owners of the document.
+func WithUniqueName(name string) func(ID, DocumentIndex) error {
+	return func(id ID, idx DocumentIndex) error {
+		dids, err := idx.FindDocumentsByName(name)
+		if err != nil {
+			return err
+		}
+
+		named := make(map[ID]bool, len(dids))
+		for _, did := range dids {
+			if did != id {
+				named[did] = true
+			}
+		}
+		if len(named) == 0 {
+			return nil
+		}
+
+		orgIDs, err := idx.GetDocumentsAccessors(id)
+		if err != nil {
+			return err
+		}
+
+		for _, orgID := range orgIDs {
+			oids, err := idx.GetAccessorsDocuments("org", orgID)
+			if err != nil {
+				return err
+			}
+
+			for _, oid := range oids {
+				if named[oid] {
+					return &Error{
+						Code: EConflict,
+						Msg:  fmt.Sprintf("a document named %q already exists in the organization", name),
+					}
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
+// WithUniqueDocumentName is WithUniqueName for the name the document where it is applied
+// already has, such as when importing documents.
+func WithUniqueDocumentName(id ID, idx DocumentIndex) error {
+	d, err := idx.FindDocument(id)
+	if err != nil {
+		return err
+	}
+
+	return WithUniqueName(d.Meta.Name)(id, idx)
+}
+
+// WithDocumentQuota ensures that no organization of the document where it is applied owns
+// more than max documents in the namespace. When creating a document, it must be applied
+// after the options that set the owners of the document.
+func WithDocumentQuota(max int) func(ID, DocumentIndex) error {
+	return func(id ID, idx DocumentIndex) error {
+		orgIDs, err := idx.GetDocumentsAccessors(id)
+		if err != nil {
+			return err
+		}
+
+		for _, orgID := range orgIDs {
+			oids, err := idx.GetAccessorsDocuments("org", orgID)
+			if err != nil {
+				return err
+			}
+
+			n := 0
+			for _, oid := range oids {
+				if oid != id {
+					n++
+				}
+			}
+			if n >= max {
+				return &Error{
+					Code: EForbidden,
+					Msg:  fmt.Sprintf("organization %s has reached its quota of %d documents", orgID, max),
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
 // Authorized checks to see if the user is authorized to access the document provided.
 // If the authorizer is a token, then it checks the tokens permissions. Otherwise,
 // it checks to see if the user associated with the authorizer is an accessor
@@ -500,6 +1085,94 @@ func AuthorizedWhereID(a Authorizer, docID ID) func(DocumentIndex, DocumentDecor
 	}
 }
 
+// HasAnyLabelID ensures that the document where it is applied carries at least one of the
+// labels provided. A document carrying none of them is not found, as it is not found by
+// WhereAnyLabelID.
+func HasAnyLabelID(labelIDs ...ID) func(ID, DocumentIndex) error {
+	return func(id ID, idx DocumentIndex) error {
+		for _, labelID := range labelIDs {
+			dids, err := idx.FindDocumentsByLabel(labelID)
+			if err != nil {
+				return err
+			}
+			for _, did := range dids {
+				if did == id {
+					return nil
+				}
+			}
+		}
+
+		return &Error{
+			Code: ENotFound,
+			Msg:  ErrDocumentNotFound,
+		}
+	}
+}
+
+// DocumentLabelScope returns the labels that the documents an authorizer may access are restricted
+// to. An authorization that may only read specific labels is restricted to the documents carrying
+// one of them. Authorizations that may read every label, or no label, and sessions are not
+// restricted, in which case false is returned.
+func DocumentLabelScope(a Authorizer) ([]ID, bool) {
+	t, ok := a.(*Authorization)
+	if !ok {
+		return nil, false
+	}
+
+	var ids []ID
+	for _, p := range t.Permissions {
+		if p.Action != ReadAction || p.Resource.Type != LabelsResourceType {
+			continue
+		}
+		if p.Resource.ID == nil {
+			return nil, false
+		}
+		ids = append(ids, *p.Resource.ID)
+	}
+
+	return ids, len(ids) > 0
+}
+
+// AuthorizedWhereIDPrefix selects the document whose ID starts with the prefix provided and ensures
+// that the authorizer provided may access it, as AuthorizedWhereID does. Only the documents that the
+// authorizer may access are matched, so that documents of other organizations are neither revealed
+// nor make a prefix ambiguous. A prefix matching more than one of them is a conflict.
+func AuthorizedWhereIDPrefix(a Authorizer, prefix string) func(DocumentIndex, DocumentDecorator) ([]ID, error) {
+	return func(idx DocumentIndex, dd DocumentDecorator) ([]ID, error) {
+		ids, err := idx.FindDocumentsByIDPrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		var authorized []ID
+		for _, id := range ids {
+			aids, err := AuthorizedWhereID(a, id)(idx, dd)
+			if ErrorCode(err) == EUnauthorized {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			authorized = append(authorized, aids...)
+		}
+
+		switch len(authorized) {
+		case 0:
+			return nil, &Error{
+				Code: ENotFound,
+				Msg:  ErrDocumentNotFound,
+			}
+		case 1:
+			return authorized, nil
+		default:
+			return nil, &Error{
+				Code: EConflict,
+				Msg:  fmt.Sprintf("document id prefix %q matches %d documents", prefix, len(authorized)),
+			}
+		}
+	}
+}
+
 // TokenAuthorizedWhereID ensures that the authorization provided has the permission to access the document.
 func TokenAuthorizedWhereID(a *Authorization, docID ID) func(DocumentIndex, DocumentDecorator) ([]ID, error) {
 	return func(idx DocumentIndex, _ DocumentDecorator) ([]ID, error) {
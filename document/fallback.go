@@ -0,0 +1,328 @@
+// Package document provides implementations of influxdb.DocumentService that are composed of
+// other document services.
+package document
+
+import (
+	"context"
+
+	"github.com/influxdata/influxdb"
+	"go.uber.org/zap"
+)
+
+var _ influxdb.DocumentService = (*FallbackService)(nil)
+var _ influxdb.DocumentLabeler = (*fallbackStore)(nil)
+
+// FallbackService is a DocumentService that reads documents from a primary service and, when
+// they are missing there, from a secondary one. It is used to migrate documents between
+// services without downtime: documents are written to the primary service only, and are
+// optionally copied forward from the secondary service as they are read.
+type FallbackService struct {
+	Primary   influxdb.DocumentService
+	Secondary influxdb.DocumentService
+
+	// Backfill copies documents only found in the secondary service into the primary one,
+	// together with their owners. It requires primary stores that implement
+	// influxdb.DocumentRestorer, so that documents keep their IDs and label mappings.
+	Backfill bool
+
+	Logger *zap.Logger
+}
+
+// NewFallbackService returns a FallbackService reading from secondary when documents are
+// missing from primary.
+func NewFallbackService(primary, secondary influxdb.DocumentService) *FallbackService {
+	return &FallbackService{
+		Primary:   primary,
+		Secondary: secondary,
+		Logger:    zap.NewNop(),
+	}
+}
+
+// CreateDocumentStore creates the document store in the primary service. Reads fall back to
+// the secondary service if it has a store of the same name.
+func (s *FallbackService) CreateDocumentStore(ctx context.Context, name string) (influxdb.DocumentStore, error) {
+	p, err := s.Primary.CreateDocumentStore(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.withSecondary(ctx, name, p), nil
+}
+
+// FindDocumentStore finds the document store in either service. A store only found in the
+// secondary service is created in the primary one.
+func (s *FallbackService) FindDocumentStore(ctx context.Context, name string) (influxdb.DocumentStore, error) {
+	sec, serr := s.Secondary.FindDocumentStore(ctx, name)
+
+	p, err := s.Primary.FindDocumentStore(ctx, name)
+	if err != nil {
+		if serr != nil {
+			return nil, err
+		}
+		if p, err = s.Primary.CreateDocumentStore(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+
+	if serr != nil {
+		return p, nil
+	}
+	return s.newStore(p, sec), nil
+}
+
+// Ping pings both services, as reads may be served by either of them.
+func (s *FallbackService) Ping(ctx context.Context) error {
+	if err := s.Primary.Ping(ctx); err != nil {
+		return err
+	}
+	return s.Secondary.Ping(ctx)
+}
+
+func (s *FallbackService) withSecondary(ctx context.Context, name string, p influxdb.DocumentStore) influxdb.DocumentStore {
+	sec, err := s.Secondary.FindDocumentStore(ctx, name)
+	if err != nil {
+		return p
+	}
+	return s.newStore(p, sec)
+}
+
+func (s *FallbackService) newStore(p, sec influxdb.DocumentStore) *fallbackStore {
+	logger := s.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &fallbackStore{
+		primary:   p,
+		secondary: sec,
+		backfill:  s.Backfill,
+		logger:    logger,
+	}
+}
+
+// fallbackStore writes to the primary store and reads from the secondary store when the
+// primary store misses.
+type fallbackStore struct {
+	primary   influxdb.DocumentStore
+	secondary influxdb.DocumentStore
+	backfill  bool
+	logger    *zap.Logger
+}
+
+func (s *fallbackStore) CreateDocument(ctx context.Context, d *influxdb.Document, opts ...influxdb.DocumentOptions) error {
+	return s.primary.CreateDocument(ctx, d, opts...)
+}
+
+// UpdateDocument updates the document in the primary store, copying it forward from the
+// secondary store first if it is only found there.
+func (s *fallbackStore) UpdateDocument(ctx context.Context, d *influxdb.Document, opts ...influxdb.DocumentOptions) error {
+	if err := s.migrateDocument(ctx, d.ID); err != nil {
+		return err
+	}
+	return s.primary.UpdateDocument(ctx, d, opts...)
+}
+
+// AppendContent appends to the content of the document in the primary store, copying it
+// forward from the secondary store first if it is only found there.
+func (s *fallbackStore) AppendContent(ctx context.Context, id influxdb.ID, data []interface{}, opts ...influxdb.DocumentOptions) (*influxdb.Document, error) {
+	if err := s.migrateDocument(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.primary.AppendContent(ctx, id, data, opts...)
+}
+
+// UpdateDocumentLabels changes the labels of the document in the primary store, copying it
+// forward from the secondary store first if it is only found there.
+func (s *fallbackStore) UpdateDocumentLabels(ctx context.Context, id influxdb.ID, opts ...influxdb.DocumentOptions) ([]*influxdb.Label, error) {
+	l, ok := s.primary.(influxdb.DocumentLabeler)
+	if !ok {
+		return nil, &influxdb.Error{
+			Code: influxdb.EMethodNotAllowed,
+			Msg:  "document store does not support changing labels",
+		}
+	}
+
+	if err := s.migrateDocument(ctx, id); err != nil {
+		return nil, err
+	}
+	return l.UpdateDocumentLabels(ctx, id, opts...)
+}
+
+// FindDocuments finds the documents in both stores. Documents found in both are those of the
+// primary store, which holds the latest version of them.
+func (s *fallbackStore) FindDocuments(ctx context.Context, opts ...influxdb.DocumentFindOptions) ([]*influxdb.Document, error) {
+	ds, err := s.primary.FindDocuments(ctx, opts...)
+	sds, serr := s.secondary.FindDocuments(ctx, opts...)
+	return s.mergeDocuments(ctx, ds, err, sds, serr)
+}
+
+// FindDocumentsByLabel finds the documents carrying the label in both stores, as FindDocuments
+// does.
+func (s *fallbackStore) FindDocumentsByLabel(ctx context.Context, labelID influxdb.ID) ([]*influxdb.Document, error) {
+	ds, err := s.primary.FindDocumentsByLabel(ctx, labelID)
+	sds, serr := s.secondary.FindDocumentsByLabel(ctx, labelID)
+	return s.mergeDocuments(ctx, ds, err, sds, serr)
+}
+
+// mergeDocuments merges the documents read from both stores by ID, keeping those of the
+// primary store. Documents only found in the secondary store are backfilled.
+func (s *fallbackStore) mergeDocuments(ctx context.Context, ds []*influxdb.Document, err error, sds []*influxdb.Document, serr error) ([]*influxdb.Document, error) {
+	if err != nil && !isMiss(ds, err) {
+		return nil, err
+	}
+	if isMiss(sds, serr) {
+		return ds, err
+	}
+	if serr != nil {
+		return nil, serr
+	}
+
+	found := make(map[influxdb.ID]bool, len(ds))
+	for _, d := range ds {
+		found[d.ID] = true
+	}
+
+	var missing []*influxdb.Document
+	for _, d := range sds {
+		if !found[d.ID] {
+			missing = append(missing, d)
+		}
+	}
+
+	s.backfillDocuments(ctx, missing)
+	return append(ds, missing...), nil
+}
+
+// DeleteDocuments deletes the documents from both stores, so that documents deleted from the
+// primary store are not read from the secondary store again.
+func (s *fallbackStore) DeleteDocuments(ctx context.Context, opts ...influxdb.DocumentFindOptions) error {
+	err := s.primary.DeleteDocuments(ctx, opts...)
+	if err != nil && !isMiss(nil, err) {
+		return err
+	}
+
+	serr := s.secondary.DeleteDocuments(ctx, opts...)
+	if serr != nil && !isMiss(nil, serr) {
+		return serr
+	}
+
+	// the documents must have been deleted from at least one of the stores.
+	if err != nil && serr != nil {
+		return err
+	}
+	return nil
+}
+
+// backfillDocuments copies the documents provided from the secondary store into the primary
+// store. The documents have already been read, so failures are logged rather than returned,
+// and the documents are backfilled again when they are next read.
+func (s *fallbackStore) backfillDocuments(ctx context.Context, ds []*influxdb.Document) {
+	if !s.backfill || len(ds) == 0 {
+		return
+	}
+
+	ids := make([]influxdb.ID, 0, len(ds))
+	for _, d := range ds {
+		ids = append(ids, d.ID)
+	}
+
+	if err := s.copyDocuments(ctx, ids); err != nil {
+		s.logger.Info("Failed to backfill documents", zap.Error(err))
+	}
+}
+
+// migrateDocument copies the document from the secondary store into the primary store if it
+// is only found in the secondary store, so that it can be written. Documents are copied forward
+// whether or not reads backfill them. Documents missing from both stores are left for the write
+// to report as missing.
+func (s *fallbackStore) migrateDocument(ctx context.Context, id influxdb.ID) error {
+	ds, err := s.primary.FindDocuments(ctx, influxdb.WhereID(id))
+	if !isMiss(ds, err) {
+		return err
+	}
+
+	sds, serr := s.secondary.FindDocuments(ctx, influxdb.WhereID(id))
+	if isMiss(sds, serr) {
+		return nil
+	}
+	if serr != nil {
+		return serr
+	}
+
+	return s.copyDocuments(ctx, []influxdb.ID{id})
+}
+
+// copyDocuments copies the documents with the IDs provided from the secondary store into the
+// primary store, together with their owners. It requires a primary store that implements
+// influxdb.DocumentRestorer, so that documents keep their IDs and label mappings.
+func (s *fallbackStore) copyDocuments(ctx context.Context, ids []influxdb.ID) error {
+	r, ok := s.primary.(influxdb.DocumentRestorer)
+	if !ok {
+		return &influxdb.Error{
+			Code: influxdb.EMethodNotAllowed,
+			Msg:  "document store does not support copying documents forward",
+		}
+	}
+
+	owners := map[influxdb.ID][]influxdb.ID{}
+	withOwners := func(idx influxdb.DocumentIndex, _ influxdb.DocumentDecorator) ([]influxdb.ID, error) {
+		for _, id := range ids {
+			oids, err := idx.GetDocumentsAccessors(id)
+			if err != nil {
+				return nil, err
+			}
+			owners[id] = oids
+		}
+		return ids, nil
+	}
+
+	full, err := s.secondary.FindDocuments(ctx, withOwners, influxdb.IncludeContent)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range full {
+		// labels are resolved from label mappings, which are kept by the document ID.
+		d.Labels = nil
+		if err := r.RestoreDocument(ctx, d, withDocumentOwners(owners[d.ID])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withDocumentOwners adds the organizations provided as owners of the document, unless they
+// already own it.
+func withDocumentOwners(orgIDs []influxdb.ID) influxdb.DocumentOptions {
+	return func(id influxdb.ID, idx influxdb.DocumentIndex) error {
+		existing, err := idx.GetDocumentsAccessors(id)
+		if err != nil {
+			return err
+		}
+
+	Owners:
+		for _, orgID := range orgIDs {
+			for _, e := range existing {
+				if e == orgID {
+					continue Owners
+				}
+			}
+			if err := idx.AddDocumentOwner(id, "org", orgID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// isMiss reports whether a read found no documents. Documents missing from a store may also be
+// reported as unauthorized, as their owners are unknown to it.
+func isMiss(ds []*influxdb.Document, err error) bool {
+	switch influxdb.ErrorCode(err) {
+	case influxdb.ENotFound, influxdb.EUnauthorized:
+		return true
+	case "":
+		return err == nil && len(ds) == 0
+	}
+	return false
+}
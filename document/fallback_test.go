@@ -0,0 +1,247 @@
+package document_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/document"
+	"github.com/influxdata/influxdb/inmem"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func newTestService(t *testing.T) *kv.Service {
+	t.Helper()
+
+	svc := kv.NewService(inmem.NewKVStore())
+	if err := svc.Initialize(context.Background()); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+	return svc
+}
+
+func TestFallbackService_FindDocuments(t *testing.T) {
+	tests := []struct {
+		name       string
+		backfill   bool
+		backfilled bool
+	}{
+		{
+			name: "primary miss falls back to secondary",
+		},
+		{
+			name:       "primary miss backfills primary",
+			backfill:   true,
+			backfilled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			primary, secondary := newTestService(t), newTestService(t)
+
+			// the organization must be known to both services, as it would be during a migration.
+			o := &influxdb.Organization{Name: "o1"}
+			if err := secondary.CreateOrganization(ctx, o); err != nil {
+				t.Fatal(err)
+			}
+			if err := primary.PutOrganization(ctx, o); err != nil {
+				t.Fatal(err)
+			}
+
+			old, err := secondary.CreateDocumentStore(ctx, "templates")
+			if err != nil {
+				t.Fatal(err)
+			}
+			d := &influxdb.Document{
+				Meta:    influxdb.DocumentMeta{Name: "d1"},
+				Content: "content1",
+			}
+			if err := old.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+				t.Fatal(err)
+			}
+
+			svc := document.NewFallbackService(primary, secondary)
+			svc.Backfill = tt.backfill
+
+			s, err := svc.FindDocumentStore(ctx, "templates")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ds, err := s.FindDocuments(ctx, influxdb.WhereOrg("o1"), influxdb.IncludeContent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ds) != 1 || ds[0].ID != d.ID || ds[0].Content != "content1" {
+				t.Fatalf("expected the document from the secondary service, got %v", ds)
+			}
+
+			p, err := primary.FindDocumentStore(ctx, "templates")
+			if err != nil {
+				t.Fatal(err)
+			}
+			pds, err := p.FindDocuments(ctx, influxdb.WhereOrg("o1"), influxdb.IncludeContent)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.backfilled {
+				if len(pds) != 0 {
+					t.Errorf("expected the primary service to be left empty, got %v", pds)
+				}
+				return
+			}
+			if len(pds) != 1 || pds[0].ID != d.ID || pds[0].Content != "content1" {
+				t.Fatalf("expected the document to be backfilled with its id, got %v", pds)
+			}
+			if !pds[0].Meta.CreatedAt.Equal(d.Meta.CreatedAt) {
+				t.Errorf("expected the backfilled document to keep its creation time %v, got %v", d.Meta.CreatedAt, pds[0].Meta.CreatedAt)
+			}
+		})
+	}
+}
+
+// newTestFallbackStores creates the organization o1 in both services, and the templates
+// document store of the fallback service built from them.
+func newTestFallbackStores(t *testing.T, primary, secondary *kv.Service) (*influxdb.Organization, influxdb.DocumentStore, influxdb.DocumentStore, influxdb.DocumentStore) {
+	t.Helper()
+
+	ctx := context.Background()
+	o := &influxdb.Organization{Name: "o1"}
+	if err := secondary.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.PutOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := primary.CreateDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := secondary.CreateDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := document.NewFallbackService(primary, secondary).FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o, p, old, s
+}
+
+func TestFallbackService_FindDocuments_Merge(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := newTestService(t), newTestService(t)
+	o, p, old, s := newTestFallbackStores(t, primary, secondary)
+
+	moved := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "old1"}
+	if err := old.CreateDocument(ctx, moved, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+	// the primary service holds the latest version of the document.
+	newer := &influxdb.Document{ID: moved.ID, Meta: moved.Meta, Content: "new1"}
+	if err := p.(influxdb.DocumentRestorer).RestoreDocument(ctx, newer, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+	only := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d2"}, Content: "old2"}
+	if err := old.CreateDocument(ctx, only, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := s.FindDocuments(ctx, influxdb.WhereOrg("o1"), influxdb.IncludeContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[influxdb.ID]interface{}{}
+	for _, d := range ds {
+		got[d.ID] = d.Content
+	}
+	want := map[influxdb.ID]interface{}{moved.ID: "new1", only.ID: "old2"}
+	if len(ds) != len(want) || !reflect.DeepEqual(got, want) {
+		t.Errorf("expected documents %v, got %v", want, got)
+	}
+}
+
+func TestFallbackService_MigrateOnWrite(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(ctx context.Context, s influxdb.DocumentStore, d *influxdb.Document) error
+		want  interface{}
+	}{
+		{
+			name: "update",
+			write: func(ctx context.Context, s influxdb.DocumentStore, d *influxdb.Document) error {
+				d.Content = "content2"
+				return s.UpdateDocument(ctx, d)
+			},
+			want: "content2",
+		},
+		{
+			name: "append",
+			write: func(ctx context.Context, s influxdb.DocumentStore, d *influxdb.Document) error {
+				_, err := s.AppendContent(ctx, d.ID, []interface{}{"b"})
+				return err
+			},
+			want: []interface{}{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			primary, secondary := newTestService(t), newTestService(t)
+			o, p, old, s := newTestFallbackStores(t, primary, secondary)
+
+			d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: []interface{}{"a"}}
+			if err := old.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.write(ctx, s, d); err != nil {
+				t.Fatalf("failed to write the document: %v", err)
+			}
+
+			pds, err := p.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeContent)
+			if err != nil {
+				t.Fatalf("expected the document to be migrated: %v", err)
+			}
+			if len(pds) != 1 || !reflect.DeepEqual(pds[0].Content, tt.want) {
+				t.Fatalf("expected the primary document to hold %v, got %v", tt.want, pds)
+			}
+
+			ods, err := p.FindDocuments(ctx, influxdb.WhereOrg("o1"))
+			if err != nil || len(ods) != 1 {
+				t.Errorf("expected the migrated document to keep its owner, got %v: %v", ods, err)
+			}
+		})
+	}
+
+	t.Run("labels", func(t *testing.T) {
+		ctx := context.Background()
+		primary, secondary := newTestService(t), newTestService(t)
+		o, p, old, s := newTestFallbackStores(t, primary, secondary)
+
+		l := &influxdb.Label{ID: influxdb.ID(0xaaaa), Name: "l1", OrganizationID: o.ID}
+		if err := primary.PutLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "content1"}
+		if err := old.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+			t.Fatal(err)
+		}
+
+		ls, err := s.(influxdb.DocumentLabeler).UpdateDocumentLabels(ctx, d.ID, influxdb.WithLabelID(l.ID))
+		if err != nil {
+			t.Fatalf("failed to attach the label: %v", err)
+		}
+		if len(ls) != 1 || ls[0].ID != l.ID {
+			t.Errorf("expected the document to carry l1, got %v", ls)
+		}
+		if _, err := p.FindDocuments(ctx, influxdb.WhereID(d.ID)); err != nil {
+			t.Errorf("expected the document to be migrated: %v", err)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package influxdb
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/ghodss/yaml"
+)
+
+// CanonicalDocumentContent returns the canonical JSON encoding of document content, so that
+// contents that only differ in insignificant whitespace or in the order of their keys encode,
+// and therefore hash, identically. Content that is a string holding a JSON object or array,
+// or a YAML mapping, is canonicalized as the value it holds.
+func CanonicalDocumentContent(content interface{}) ([]byte, error) {
+	b, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	if s, ok := v.(string); ok {
+		if t, ok := canonicalDocumentText(s); ok {
+			v = t
+		}
+	}
+
+	// maps are marshaled with their keys sorted and without whitespace.
+	return json.Marshal(v)
+}
+
+// canonicalDocumentText decodes the structured value held by s, if any.
+func canonicalDocumentText(s string) (interface{}, bool) {
+	text := bytes.TrimSpace([]byte(s))
+	if len(text) == 0 {
+		return nil, false
+	}
+
+	var v interface{}
+	if text[0] == '{' || text[0] == '[' {
+		if err := json.Unmarshal(text, &v); err == nil {
+			return v, true
+		}
+	}
+
+	// only YAML mappings are considered, as plain text such as a markdown list is often
+	// also a valid YAML sequence or scalar.
+	j, err := yaml.YAMLToJSON(text)
+	if err != nil || !bytes.HasPrefix(j, []byte("{")) {
+		return nil, false
+	}
+	if err := json.Unmarshal(j, &v); err != nil {
+		return nil, false
+	}
+
+	return v, true
+}
@@ -0,0 +1,74 @@
+package influxdb_test
+
+import (
+	"testing"
+
+	"github.com/influxdata/influxdb"
+)
+
+func TestCanonicalDocumentContent(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  interface{}
+		equal bool
+	}{
+		{
+			name:  "key order",
+			a:     map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": "d", "e": []interface{}{1, 2}}},
+			b:     map[string]interface{}{"b": map[string]interface{}{"e": []interface{}{1, 2}, "c": "d"}, "a": 1},
+			equal: true,
+		},
+		{
+			name:  "json text whitespace and key order",
+			a:     `{"a": 1, "b": [1, 2]}`,
+			b:     "{\n  \"b\": [1,2],\n  \"a\": 1\n}\n",
+			equal: true,
+		},
+		{
+			name:  "json text and json value",
+			a:     `{"b":[1,2],"a":1}`,
+			b:     map[string]interface{}{"a": 1, "b": []interface{}{1, 2}},
+			equal: true,
+		},
+		{
+			name:  "yaml text",
+			a:     "a: 1\nb:\n  - x\n  - y\n",
+			b:     "b: [x, y]\na:   1",
+			equal: true,
+		},
+		{
+			name:  "different values",
+			a:     map[string]interface{}{"a": 1},
+			b:     map[string]interface{}{"a": 2},
+			equal: false,
+		},
+		{
+			name:  "array order is significant",
+			a:     []interface{}{1, 2},
+			b:     []interface{}{2, 1},
+			equal: false,
+		},
+		{
+			name:  "plain text whitespace is significant",
+			a:     "- item one\n- item two",
+			b:     "-  item one\n-  item two",
+			equal: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := influxdb.CanonicalDocumentContent(tt.a)
+			if err != nil {
+				t.Fatalf("CanonicalDocumentContent(%v) failed: %v", tt.a, err)
+			}
+			b, err := influxdb.CanonicalDocumentContent(tt.b)
+			if err != nil {
+				t.Fatalf("CanonicalDocumentContent(%v) failed: %v", tt.b, err)
+			}
+			if equal := string(a) == string(b); equal != tt.equal {
+				t.Errorf("CanonicalDocumentContent() of %v and %v equal = %v, want %v: %s and %s", tt.a, tt.b, equal, tt.equal, a, b)
+			}
+		})
+	}
+}
@@ -32,6 +32,7 @@ type APIHandler struct {
 	DocumentHandler      *DocumentHandler
 	SetupHandler         *SetupHandler
 	SessionHandler       *SessionHandler
+	MigrationHandler     *MigrationHandler
 	SwaggerHandler       http.Handler
 }
 
@@ -71,6 +72,8 @@ type APIBackend struct {
 	ChronografService               *server.Service
 	OrgLookupService                authorizer.OrganizationService
 	DocumentService                 influxdb.DocumentService
+	DocumentNamespaces              map[string]DocumentNamespaceConfig
+	DataMigrationService            influxdb.DataMigrationService
 }
 
 // NewAPIHandler constructs all api handlers beneath it and returns an APIHandler
@@ -83,6 +86,9 @@ func NewAPIHandler(b *APIBackend) *APIHandler {
 	documentBackend := NewDocumentBackend(b)
 	h.DocumentHandler = NewDocumentHandler(documentBackend)
 
+	migrationBackend := NewMigrationBackend(b)
+	h.MigrationHandler = NewMigrationHandler(migrationBackend)
+
 	sessionBackend := NewSessionBackend(b)
 	h.SessionHandler = NewSessionHandler(sessionBackend)
 
@@ -155,7 +161,11 @@ var apiLinks = map[string]interface{}{
 	"labels":    "/api/v2/labels",
 	"variables": "/api/v2/variables",
 	"me":        "/api/v2/me",
-	"orgs":      "/api/v2/orgs",
+	"migrations": map[string]string{
+		"run":    "/api/v2/migrations/run",
+		"status": "/api/v2/migrations/status",
+	},
+	"orgs": "/api/v2/orgs",
 	"query": map[string]string{
 		"self":        "/api/v2/query",
 		"ast":         "/api/v2/query/ast",
@@ -286,6 +296,11 @@ func (h *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if strings.HasPrefix(r.URL.Path, "/api/v2/migrations") {
+		h.MigrationHandler.ServeHTTP(w, r)
+		return
+	}
+
 	if strings.HasPrefix(r.URL.Path, "/chronograf/") {
 		h.ChronografHandler.ServeHTTP(w, r)
 		return
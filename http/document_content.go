@@ -0,0 +1,63 @@
+package http
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/influxdb"
+)
+
+// inlineDocumentContentTypes are the media types that browsers cannot run scripts from, so that
+// content of these types is displayed rather than downloaded.
+var inlineDocumentContentTypes = map[string]bool{
+	"application/json":         true,
+	"application/octet-stream": true,
+	"image/gif":                true,
+	"image/jpeg":               true,
+	"image/png":                true,
+	"image/webp":               true,
+	"text/csv":                 true,
+	"text/plain":               true,
+}
+
+// isInlineDocumentContentType returns whether content of the content type provided may be
+// displayed by browsers.
+func isInlineDocumentContentType(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return inlineDocumentContentTypes[strings.ToLower(mt)]
+}
+
+// encodeDocumentContent writes the content of the document as is, with the content type it was
+// created with. String content is written as the string it holds, and any other content as JSON.
+// Binary content is written as it is stored, base64 encoded. Content of types that may run
+// scripts, such as html or svg, is sent as an attachment, and no content may run scripts in the
+// origin of the API, so that documents cannot be used for stored cross-site scripting.
+func encodeDocumentContent(w http.ResponseWriter, d *influxdb.Document) error {
+	var b []byte
+	switch c := d.Content.(type) {
+	case string:
+		b = []byte(c)
+	default:
+		var err error
+		if b, err = json.Marshal(c); err != nil {
+			return err
+		}
+	}
+
+	w.Header().Set("Content-Type", d.Meta.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	if !isInlineDocumentContentType(d.Meta.ContentType) {
+		w.Header().Set("Content-Disposition", "attachment")
+	}
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(b)
+	return err
+}
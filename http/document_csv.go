@@ -0,0 +1,84 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/influxdb"
+)
+
+// documentCSVPreviewLen is the number of characters of the content previewed in a CSV row.
+const documentCSVPreviewLen = 100
+
+// acceptsDocumentsCSV returns true if the request accepts documents listed as CSV rather
+// than JSON.
+func acceptsDocumentsCSV(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mt == "text/csv" {
+			return true
+		}
+	}
+	return false
+}
+
+// documentCSVLabelEscaper escapes the separator of the label names of a CSV row, and the
+// backslash escaping it, in each label name.
+var documentCSVLabelEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`)
+
+// csvSafeCell returns the value of a CSV cell so that spreadsheets do not evaluate it as a
+// formula. Values starting with a character that starts a formula are prefixed with a quote.
+func csvSafeCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// encodeDocumentsCSV writes a CSV row for each document with its id, name, label names
+// separated by semicolons, and the length of its JSON encoded content. The content itself is
+// omitted, unless preview is set, in which case its first characters are included. Semicolons
+// and backslashes within label names are escaped with a backslash, and cells that would start
+// a formula are prefixed with a quote.
+func encodeDocumentsCSV(w http.ResponseWriter, ds []*influxdb.Document, preview bool) error {
+	header := []string{"id", "name", "labels", "contentLength"}
+	if preview {
+		header = append(header, "preview")
+	}
+
+	rows := make([][]string, 0, len(ds)+1)
+	rows = append(rows, header)
+	for _, d := range ds {
+		content, err := json.Marshal(d.Content)
+		if err != nil {
+			return &influxdb.Error{
+				Code: influxdb.EInternal,
+				Msg:  "unable to encode document content",
+				Err:  err,
+			}
+		}
+
+		labels := make([]string, 0, len(d.Labels))
+		for _, l := range d.Labels {
+			labels = append(labels, documentCSVLabelEscaper.Replace(l.Name))
+		}
+
+		row := []string{d.ID.String(), csvSafeCell(d.Meta.Name), csvSafeCell(strings.Join(labels, ";")), strconv.Itoa(len(content))}
+		if preview {
+			p := []rune(string(content))
+			if len(p) > documentCSVPreviewLen {
+				p = p[:documentCSVPreviewLen]
+			}
+			row = append(row, csvSafeCell(string(p)))
+		}
+		rows = append(rows, row)
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	return csv.NewWriter(w).WriteAll(rows)
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/influxdata/influxdb"
+)
+
+// documentCursorChecksumLen is the number of checksum bytes prefixed to an encoded cursor.
+const documentCursorChecksumLen = 8
+
+// documentCursor is the paging state carried by the opaque cursor tokens of the document
+// routes. Clients are expected to pass tokens back as they were returned rather than build
+// them, so the encoding may change between releases.
+type documentCursor struct {
+	// Offset is the position of the first result of the page.
+	Offset int `json:"o"`
+	// Limit is the number of results in a page. Zero means pages are only bounded by the
+	// size of the response, which only routes capping the size of responses accept.
+	Limit int `json:"l"`
+	// After is the last result of the previous page. When it is still present the page
+	// starts right after it, so that results added or removed before it do not shift the page.
+	After influxdb.ID `json:"a,omitempty"`
+	// Filter is the encoded filter of the request the cursor was issued for.
+	Filter string `json:"f,omitempty"`
+}
+
+// documentCursorFilter encodes the paging filter provided for a documentCursor.
+func documentCursorFilter(f influxdb.PagingFilter) string {
+	return url.Values(f.QueryParams()).Encode()
+}
+
+// encodeDocumentCursor returns the opaque token of the cursor provided. The token carries a
+// checksum of the cursor so that a corrupted or hand edited token is rejected; it is not a
+// signature.
+func encodeDocumentCursor(c documentCursor) string {
+	payload, _ := json.Marshal(c)
+	sum := sha256.Sum256(payload)
+	return base64.RawURLEncoding.EncodeToString(append(sum[:documentCursorChecksumLen], payload...))
+}
+
+// decodeDocumentCursor decodes the token provided, rejecting it if it was not issued for a
+// request with the filter provided.
+func decodeDocumentCursor(token string, f influxdb.PagingFilter) (*documentCursor, error) {
+	invalid := func(msg string) error {
+		return &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "invalid cursor: " + msg,
+		}
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) <= documentCursorChecksumLen {
+		return nil, invalid("malformed token")
+	}
+
+	payload := raw[documentCursorChecksumLen:]
+	sum := sha256.Sum256(payload)
+	if !bytes.Equal(raw[:documentCursorChecksumLen], sum[:documentCursorChecksumLen]) {
+		return nil, invalid("malformed token")
+	}
+
+	c := &documentCursor{}
+	if err := json.Unmarshal(payload, c); err != nil {
+		return nil, invalid("malformed token")
+	}
+
+	if c.Offset < 0 {
+		return nil, invalid("offset must not be negative")
+	}
+	if c.Limit < 0 || c.Limit > influxdb.MaxPageSize {
+		return nil, invalid(fmt.Sprintf("limit must be between 0 and %d", influxdb.MaxPageSize))
+	}
+	if c.Filter != documentCursorFilter(f) {
+		return nil, invalid("cursor was issued for different filters")
+	}
+
+	return c, nil
+}
+
+// newDocumentCursorLinks returns the paging links of a page of num results out of total,
+// starting at offset, with each link carrying a cursor token. last is the last result of the
+// page.
+func newDocumentCursorLinks(basePath string, f influxdb.PagingFilter, offset, limit, num, total int, last influxdb.ID) *influxdb.PagingLinks {
+	filter := documentCursorFilter(f)
+	link := func(c documentCursor) string {
+		values := url.Values(f.QueryParams())
+		values.Set("cursor", encodeDocumentCursor(c))
+		u := url.URL{Path: basePath, RawQuery: values.Encode()}
+		return u.String()
+	}
+
+	links := &influxdb.PagingLinks{
+		Self: link(documentCursor{Offset: offset, Limit: limit, Filter: filter}),
+	}
+	if offset+num < total {
+		links.Next = link(documentCursor{Offset: offset + num, Limit: limit, After: last, Filter: filter})
+	}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		links.Prev = link(documentCursor{Offset: prev, Limit: limit, Filter: filter})
+	}
+
+	return links
+}
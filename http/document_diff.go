@@ -0,0 +1,179 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// documentDiffContext is the number of unchanged lines shown around each change.
+const documentDiffContext = 3
+
+type diffLine struct {
+	op   diffmatchpatch.Operation
+	text string
+}
+
+// documentDiffHunk is a range of lines that changed between the content of two documents, along
+// with the unchanged lines around the changes. Line numbers are 1-based unless the range is empty,
+// in which case they refer to the preceding line.
+type documentDiffHunk struct {
+	FromLine  int                `json:"fromLine"`
+	FromCount int                `json:"fromCount"`
+	ToLine    int                `json:"toLine"`
+	ToCount   int                `json:"toCount"`
+	Lines     []documentDiffLine `json:"lines"`
+}
+
+// documentDiffLine is a line of a hunk, without its line break.
+type documentDiffLine struct {
+	// Op is added or removed for the lines that changed, and context for the others.
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+var documentDiffOps = map[diffmatchpatch.Operation]string{
+	diffmatchpatch.DiffInsert: "added",
+	diffmatchpatch.DiffDelete: "removed",
+	diffmatchpatch.DiffEqual:  "context",
+}
+
+var documentDiffPrefixes = map[string]string{
+	"added":   "+",
+	"removed": "-",
+	"context": " ",
+}
+
+// documentContentHunks returns the hunks of the line diff of the content of two documents. The
+// content is rendered as indented JSON so that changes are reported per field. No hunks are
+// returned if the contents are identical.
+func documentContentHunks(from, to interface{}) ([]*documentDiffHunk, error) {
+	a, err := documentDiffText(from)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := documentDiffText(to)
+	if err != nil {
+		return nil, err
+	}
+
+	if a == b {
+		return []*documentDiffHunk{}, nil
+	}
+
+	return diffHunks(a, b), nil
+}
+
+func documentDiffText(content interface{}) (string, error) {
+	if content == nil {
+		return "", nil
+	}
+
+	b, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(b) + "\n", nil
+}
+
+// diffHunks returns the line diff of a and b as hunks.
+func diffHunks(a, b string) []*documentDiffHunk {
+	dmp := diffmatchpatch.New()
+	ca, cb, lines := dmp.DiffLinesToChars(a, b)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(ca, cb, false), lines)
+
+	var ls []diffLine
+	for _, d := range diffs {
+		for _, text := range strings.SplitAfter(d.Text, "\n") {
+			if text == "" {
+				continue
+			}
+			ls = append(ls, diffLine{op: d.Type, text: text})
+		}
+	}
+
+	hs := []*documentDiffHunk{}
+	for i := 0; i < len(ls); {
+		if ls[i].op == diffmatchpatch.DiffEqual {
+			i++
+			continue
+		}
+
+		// extend the hunk while the next change is within twice the context of the last.
+		start := i - documentDiffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ls) && j <= end+2*documentDiffContext; j++ {
+			if ls[j].op != diffmatchpatch.DiffEqual {
+				end = j
+			}
+		}
+		stop := end + documentDiffContext + 1
+		if stop > len(ls) {
+			stop = len(ls)
+		}
+
+		hs = append(hs, newDiffHunk(ls, start, stop))
+		i = stop
+	}
+
+	return hs
+}
+
+func newDiffHunk(ls []diffLine, start, stop int) *documentDiffHunk {
+	h := &documentDiffHunk{}
+	for _, l := range ls[:start] {
+		if l.op != diffmatchpatch.DiffInsert {
+			h.FromLine++
+		}
+		if l.op != diffmatchpatch.DiffDelete {
+			h.ToLine++
+		}
+	}
+
+	for _, l := range ls[start:stop] {
+		if l.op != diffmatchpatch.DiffInsert {
+			h.FromCount++
+		}
+		if l.op != diffmatchpatch.DiffDelete {
+			h.ToCount++
+		}
+		h.Lines = append(h.Lines, documentDiffLine{
+			Op:   documentDiffOps[l.op],
+			Text: strings.TrimSuffix(l.text, "\n"),
+		})
+	}
+
+	if h.FromCount > 0 {
+		h.FromLine++
+	}
+	if h.ToCount > 0 {
+		h.ToLine++
+	}
+
+	return h
+}
+
+// unifiedDiff returns the hunks of a diff in the unified format.
+func unifiedDiff(fromName, toName string, hs []*documentDiffHunk) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromName, toName)
+
+	for _, h := range hs {
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", h.FromLine, h.FromCount, h.ToLine, h.ToCount)
+		for _, l := range h.Lines {
+			buf.WriteString(documentDiffPrefixes[l.Op])
+			buf.WriteString(l.Text)
+			buf.WriteString("\n")
+		}
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/influxdata/influxdb"
+)
+
+// documentLabelResult is the outcome of attaching a single label when labels are not attached
+// atomically. Error is nil if the label was attached.
+type documentLabelResult struct {
+	LabelID influxdb.ID           `json:"labelID"`
+	Error   *documentLabelFailure `json:"error,omitempty"`
+}
+
+type documentLabelFailure struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type documentLabelResultsResponse struct {
+	*documentLabelsResponse
+	Results []documentLabelResult `json:"results"`
+}
+
+// attachDocumentLabels attaches each label of the request to the document on its own, so that
+// labels failing to attach do not prevent the others from being attached. It responds with the
+// labels of the document and the outcome for each label, with 207 Multi-Status if any failed.
+func (h *DocumentHandler) attachDocumentLabels(w http.ResponseWriter, r *http.Request, req *postDocumentLabelRequest, s influxdb.DocumentStore, a influxdb.Authorizer, d *influxdb.Document) {
+	ctx := r.Context()
+
+	// the etag expected by If-Match changes with each label attached by the request.
+	match := r.Header.Get("If-Match")
+
+	status := http.StatusCreated
+	results := make([]documentLabelResult, 0, len(req.LabelIDs))
+	for _, id := range req.LabelIDs {
+		err := h.attachDocumentLabel(r, req, s, a, d, id, match)
+		if err == nil {
+			if match != "" && match != "*" {
+				if match, err = documentETag(d); err != nil {
+					EncodeError(ctx, err, w)
+					return
+				}
+			}
+			results = append(results, documentLabelResult{LabelID: id})
+			continue
+		}
+
+		status = http.StatusMultiStatus
+		results = append(results, documentLabelResult{
+			LabelID: id,
+			Error: &documentLabelFailure{
+				Code:    influxdb.ErrorCode(err),
+				Message: influxdb.ErrorMessage(err),
+			},
+		})
+	}
+
+	// the labels of the document are those read by the last successful attach, if any.
+	if d.Labels == nil {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeLabels)
+		if err != nil {
+			EncodeError(ctx, err, w)
+			return
+		}
+		// the document may have been deleted since the labels were attached.
+		if len(ds) == 0 {
+			EncodeError(ctx, &influxdb.Error{
+				Code: influxdb.ENotFound,
+				Msg:  influxdb.ErrDocumentNotFound,
+			}, w)
+			return
+		}
+		d.Labels = ds[0].Labels
+	}
+
+	etag, err := documentETag(d)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+	w.Header().Set("ETag", etag)
+
+	res := &documentLabelResultsResponse{
+		documentLabelsResponse: newDocumentLabelsResponse(&getDocumentLabelRequest{Namespace: req.Namespace, ID: req.ID}, d.Labels),
+		Results:                results,
+	}
+	if err := encodeResponse(ctx, w, status, res); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+// attachDocumentLabel attaches a single label to the document if it still has the etag provided,
+// leaving the labels of the document as they are after the attach.
+func (h *DocumentHandler) attachDocumentLabel(r *http.Request, req *postDocumentLabelRequest, s influxdb.DocumentStore, a influxdb.Authorizer, d *influxdb.Document, id influxdb.ID, match string) error {
+	ctx := r.Context()
+
+	if err := ensureLabelsExist(ctx, h.LabelService, []influxdb.ID{id}); err != nil {
+		return err
+	}
+
+	opts := append(h.authorized(a), influxdb.WithLockOwner(a.GetUserID()))
+	opts = append(opts, ifDocumentMatch(match)...)
+	opts = append(opts, influxdb.WithLabelID(id))
+	ls, err := updateDocumentLabels(ctx, s, d.ID, append(opts, h.labelOptions(req.Namespace)...)...)
+	if err != nil {
+		return err
+	}
+	d.Labels = ls
+	return nil
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/influxdata/influxdb"
+)
+
+const (
+	// defaultDocumentLockTTL is how long a document lock is held when no ttl is requested.
+	defaultDocumentLockTTL = 5 * time.Minute
+	// maxDocumentLockTTL is the longest a document lock may be held before it must be renewed.
+	maxDocumentLockTTL = time.Hour
+)
+
+// handlePostDocumentLock is the HTTP handler for the POST /api/v2/documents/:ns/:id/lock route.
+// It acquires the lock of the document for the user making the request, or renews it if they
+// already hold it.
+func (h *DocumentHandler) handlePostDocumentLock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodePostDocumentLockRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	l, a, err := h.findDocumentLocker(ctx, req.Namespace)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	lock, err := l.LockDocument(ctx, req.ID, a.GetUserID(), req.TTL, h.authorized(a)...)
+	if err != nil {
+		encodeLockedError(ctx, err, w)
+		return
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, lock); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+type postDocumentLockRequest struct {
+	Namespace string
+	ID        influxdb.ID
+	TTL       time.Duration
+}
+
+func decodePostDocumentLockRequest(ctx context.Context, r *http.Request) (*postDocumentLockRequest, error) {
+	dr, err := decodeGetDocumentRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &postDocumentLockRequest{
+		Namespace: dr.Namespace,
+		ID:        dr.ID,
+		TTL:       defaultDocumentLockTTL,
+	}
+
+	var body struct {
+		TTL string `json:"ttl"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "failed to decode request body",
+				Err:  err,
+			}
+		}
+	}
+
+	if body.TTL != "" {
+		ttl, err := time.ParseDuration(body.TTL)
+		if err != nil || ttl <= 0 || ttl > maxDocumentLockTTL {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  fmt.Sprintf("ttl must be a positive duration of at most %s", maxDocumentLockTTL),
+			}
+		}
+		req.TTL = ttl
+	}
+
+	return req, nil
+}
+
+// handleDeleteDocumentLock is the HTTP handler for the DELETE /api/v2/documents/:ns/:id/lock route.
+// Only the user holding the lock may release it.
+func (h *DocumentHandler) handleDeleteDocumentLock(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodeGetDocumentRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	l, a, err := h.findDocumentLocker(ctx, req.Namespace)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if err := l.UnlockDocument(ctx, req.ID, a.GetUserID(), h.authorized(a)...); err != nil {
+		encodeLockedError(ctx, err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *DocumentHandler) findDocumentLocker(ctx context.Context, ns string) (influxdb.DocumentLocker, influxdb.Authorizer, error) {
+	s, err := h.DocumentService.FindDocumentStore(ctx, ns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	a, err := documentAuthorizer(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	l, ok := s.(influxdb.DocumentLocker)
+	if !ok {
+		return nil, nil, &influxdb.Error{
+			Code: influxdb.EMethodNotAllowed,
+			Msg:  "document store does not support locks",
+		}
+	}
+
+	return l, a, nil
+}
+
+// encodeLockedError encodes err with a 423 status if the document is locked by another owner.
+// Any other error is encoded by EncodeError.
+func encodeLockedError(ctx context.Context, err error, w http.ResponseWriter) {
+	if !isDocumentLocked(err) {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	w.Header().Set(PlatformErrorCodeHeader, influxdb.EConflict)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusLocked)
+	b, _ := json.Marshal(&influxdb.Error{
+		Code: influxdb.EConflict,
+		Msg:  influxdb.ErrDocumentLocked,
+	})
+	_, _ = w.Write(b)
+}
+
+func isDocumentLocked(err error) bool {
+	for err != nil {
+		e, ok := err.(*influxdb.Error)
+		if !ok {
+			return false
+		}
+		if e.Msg == influxdb.ErrDocumentLocked {
+			return true
+		}
+		err = e.Err
+	}
+	return false
+}
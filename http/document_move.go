@@ -0,0 +1,193 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/influxdata/influxdb"
+)
+
+// handlePostDocumentMove is the HTTP handler for the POST /api/v2/documents/:ns/:id/move route.
+// The document keeps its ID, owners and labels. It may only be moved to the namespaces its
+// namespace lists as move targets, and must be valid in the namespace it is moved to.
+func (h *DocumentHandler) handlePostDocumentMove(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodePostDocumentMoveRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	a, err := documentAuthorizer(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	m, ok := s.(influxdb.DocumentMover)
+	if !ok {
+		EncodeError(ctx, &influxdb.Error{
+			Code: influxdb.EMethodNotAllowed,
+			Msg:  "document store does not support moving documents",
+		}, w)
+		return
+	}
+
+	if _, err := findDocumentByID(ctx, s, req.ID, h.whereAuthorizedID(a, req.ID)...); err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if !h.isMoveTarget(req.Namespace, req.To) {
+		EncodeError(ctx, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  fmt.Sprintf("documents cannot be moved from namespace %q to namespace %q", req.Namespace, req.To),
+		}, w)
+		return
+	}
+
+	invalid := &documentValidationError{}
+	opts := append(h.authorized(a), influxdb.WithLockOwner(a.GetUserID()))
+	if err := m.MoveDocument(ctx, req.ID, req.To, opts, h.moveTargetOptions(req.Namespace, req.To, invalid)); err != nil {
+		if len(invalid.Problems) > 0 {
+			encodeDocumentValidationError(ctx, invalid, w)
+			return
+		}
+		encodeDocumentWriteError(ctx, err, w)
+		return
+	}
+
+	dst, err := h.DocumentService.FindDocumentStore(ctx, req.To)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	ds, err := dst.FindDocuments(ctx, influxdb.WhereID(req.ID), influxdb.IncludeLabels)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+	// the document may have been moved or deleted again since it was moved.
+	if len(ds) == 0 {
+		EncodeError(ctx, &influxdb.Error{
+			Code: influxdb.ENotFound,
+			Msg:  influxdb.ErrDocumentNotFound,
+		}, w)
+		return
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, newDocumentResponse(req.To, ds[0])); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+// isMoveTarget returns whether documents may be moved from the namespace from to the namespace to.
+func (h *DocumentHandler) isMoveTarget(from, to string) bool {
+	for _, ns := range h.namespaceConfig(from).MoveTargets {
+		if ns == to {
+			return true
+		}
+	}
+	return false
+}
+
+// moveTargetOptions returns the options enforcing the configuration of the namespace to on a
+// document moved there from the namespace from. They are applied once the document is moved,
+// in the transaction moving it, so that the document is checked as it is moved. The problems
+// found with the document are collected into invalid.
+func (h *DocumentHandler) moveTargetOptions(from, to string, invalid *documentValidationError) []influxdb.DocumentOptions {
+	c := h.namespaceConfig(to)
+
+	opts := []influxdb.DocumentOptions{
+		func(id influxdb.ID, idx influxdb.DocumentIndex) error {
+			d, err := idx.FindDocument(id)
+			if err != nil {
+				return err
+			}
+
+			// binary content is stored base64 encoded, like any other string, so documents
+			// that may hold it cannot be moved to a namespace that does not allow it.
+			if h.namespaceConfig(from).AllowBinaryContent && !c.AllowBinaryContent {
+				invalid.Problems = append(invalid.Problems, documentProblem{
+					Field:   "content",
+					Message: fmt.Sprintf("namespace %q does not allow binary document content", to),
+				})
+			}
+			if c.Schema != nil {
+				for _, v := range c.Schema.Violations(d.Content) {
+					invalid.Problems = append(invalid.Problems, documentProblem{Field: "content", Message: v})
+				}
+			}
+			if c.RequireLabels && len(d.Labels) == 0 {
+				invalid.Problems = append(invalid.Problems, documentProblem{
+					Field:   "labels",
+					Message: fmt.Sprintf("documents of namespace %q must have at least one label", to),
+				})
+			}
+			if max := c.MaxLabelsPerDocument; max > 0 && len(d.Labels) > max {
+				invalid.Problems = append(invalid.Problems, documentProblem{
+					Field:   "labels",
+					Message: fmt.Sprintf("documents of namespace %q cannot have more than %d labels", to, max),
+				})
+			}
+
+			if len(invalid.Problems) > 0 {
+				return invalid
+			}
+			return nil
+		},
+	}
+	if c.UniqueNames {
+		opts = append(opts, influxdb.WithUniqueDocumentName)
+	}
+	if c.MaxDocumentsPerOrg > 0 {
+		opts = append(opts, influxdb.WithDocumentQuota(c.MaxDocumentsPerOrg))
+	}
+	return opts
+}
+
+type postDocumentMoveRequest struct {
+	*getDocumentRequest
+	To string
+}
+
+func decodePostDocumentMoveRequest(ctx context.Context, r *http.Request) (*postDocumentMoveRequest, error) {
+	req, err := decodeGetDocumentRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	body := &struct {
+		Namespace string `json:"namespace"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "unable to decode move request body",
+			Err:  err,
+		}
+	}
+
+	if body.Namespace == "" {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "namespace to move the document to must be provided",
+		}
+	}
+
+	return &postDocumentMoveRequest{
+		getDocumentRequest: req,
+		To:                 body.Namespace,
+	}, nil
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/influxdata/influxdb"
+)
+
+// handlePutDocumentPin is the HTTP handler for the PUT /api/v2/documents/:ns/:id/pin route.
+// Pinned documents are excluded from bulk deletes unless includePinned=true is requested.
+func (h *DocumentHandler) handlePutDocumentPin(w http.ResponseWriter, r *http.Request) {
+	h.pinDocument(w, r, true)
+}
+
+// handleDeleteDocumentPin is the HTTP handler for the DELETE /api/v2/documents/:ns/:id/pin route.
+func (h *DocumentHandler) handleDeleteDocumentPin(w http.ResponseWriter, r *http.Request) {
+	h.pinDocument(w, r, false)
+}
+
+func (h *DocumentHandler) pinDocument(w http.ResponseWriter, r *http.Request, pinned bool) {
+	ctx := r.Context()
+
+	req, err := decodeGetDocumentRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	a, err := documentAuthorizer(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	p, ok := s.(influxdb.DocumentPinner)
+	if !ok {
+		EncodeError(ctx, &influxdb.Error{
+			Code: influxdb.EMethodNotAllowed,
+			Msg:  "document store does not support pinning",
+		}, w)
+		return
+	}
+
+	if err := p.PinDocument(ctx, req.ID, pinned, h.authorized(a)...); err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/influxdb"
+)
+
+// byteRange is an inclusive range of bytes.
+type byteRange struct {
+	start, end int
+}
+
+// parseByteRange parses a Range header of a single byte range against content of the size
+// provided. A nil range is returned for headers that are not a single byte range, which are
+// ignored as permitted by RFC 7233. An error is returned if the range cannot be satisfied.
+func parseByteRange(header string, size int) (*byteRange, error) {
+	spec := strings.TrimPrefix(header, "bytes=")
+	if spec == header || strings.Contains(spec, ",") {
+		return nil, nil
+	}
+
+	dash := strings.Index(spec, "-")
+	if dash < 0 {
+		return nil, nil
+	}
+	first, last := strings.TrimSpace(spec[:dash]), strings.TrimSpace(spec[dash+1:])
+
+	unsatisfiable := &influxdb.Error{
+		Code: influxdb.EInvalid,
+		Msg:  fmt.Sprintf("range %q cannot be satisfied by content of %d bytes", spec, size),
+	}
+
+	// a suffix range selects the last bytes of the content.
+	if first == "" {
+		n, err := strconv.Atoi(last)
+		if err != nil || n < 0 {
+			return nil, nil
+		}
+		if n == 0 || size == 0 {
+			return nil, unsatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return &byteRange{start: size - n, end: size - 1}, nil
+	}
+
+	start, err := strconv.Atoi(first)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.Atoi(last); err != nil || end < start {
+			return nil, nil
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	if start >= size {
+		return nil, unsatisfiable
+	}
+
+	return &byteRange{start: start, end: end}, nil
+}
+
+// encodeDocumentContentRange writes the bytes of the JSON encoded content selected by the Range
+// header. It returns false, having written nothing, if the header does not select a single byte range.
+func encodeDocumentContentRange(ctx context.Context, w http.ResponseWriter, header string, content interface{}) (bool, error) {
+	b, err := json.Marshal(content)
+	if err != nil {
+		return false, err
+	}
+
+	rg, err := parseByteRange(header, len(b))
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", len(b)))
+		w.Header().Set(PlatformErrorCodeHeader, influxdb.EInvalid)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		e, _ := json.Marshal(err)
+		_, _ = w.Write(e)
+		return true, nil
+	}
+	if rg == nil {
+		return false, nil
+	}
+
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rg.start, rg.end, len(b)))
+	w.Header().Set("Content-Length", strconv.Itoa(rg.end-rg.start+1))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusPartialContent)
+	_, err = w.Write(b[rg.start : rg.end+1])
+	return true, err
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb"
+	"golang.org/x/time/rate"
+)
+
+// DocumentWriteLimiter limits the rate at which documents are created, updated and deleted.
+type DocumentWriteLimiter interface {
+	// AllowDocumentWrite reports whether a write made with the authorizer may proceed and, if it
+	// may not, how long to wait before retrying.
+	AllowDocumentWrite(ctx context.Context, a influxdb.Authorizer) (bool, time.Duration)
+}
+
+// minDocumentLimiterIdle is the least time a token bucket is kept after its last write.
+const minDocumentLimiterIdle = 10 * time.Minute
+
+// DocumentTokenLimiter is a DocumentWriteLimiter that allows each authorization, or each session,
+// its own token bucket of writes. Buckets left idle long enough to refill are evicted.
+type DocumentTokenLimiter struct {
+	limit rate.Limit
+	burst int
+	idle  time.Duration
+	now   func() time.Time
+
+	mu        sync.Mutex
+	limiters  map[influxdb.ID]*documentLimiter
+	lastSweep time.Time
+}
+
+// documentLimiter is the token bucket of an authorizer and the time of its last write.
+type documentLimiter struct {
+	*rate.Limiter
+	lastUsed time.Time
+}
+
+// NewDocumentTokenLimiter returns a DocumentTokenLimiter allowing perSecond writes per second to
+// each authorization, in bursts of at most burst writes.
+func NewDocumentTokenLimiter(perSecond float64, burst int) *DocumentTokenLimiter {
+	// a bucket idle for as long as it takes to refill is the same as a new one, so evicting it
+	// does not grant more writes.
+	idle := minDocumentLimiterIdle
+	if perSecond > 0 {
+		if refill := time.Duration(float64(burst) / perSecond * float64(time.Second)); refill > idle {
+			idle = refill
+		}
+	}
+
+	return &DocumentTokenLimiter{
+		limit:    rate.Limit(perSecond),
+		burst:    burst,
+		idle:     idle,
+		now:      time.Now,
+		limiters: make(map[influxdb.ID]*documentLimiter),
+	}
+}
+
+// AllowDocumentWrite takes a token from the bucket of the authorizer, if one is available.
+func (l *DocumentTokenLimiter) AllowDocumentWrite(ctx context.Context, a influxdb.Authorizer) (bool, time.Duration) {
+	now := l.now()
+
+	l.mu.Lock()
+	l.evictIdle(now)
+	lim, ok := l.limiters[a.Identifier()]
+	if !ok {
+		lim = &documentLimiter{Limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[a.Identifier()] = lim
+	}
+	lim.lastUsed = now
+	l.mu.Unlock()
+
+	res := lim.ReserveN(now, 1)
+	if !res.OK() {
+		return false, time.Second
+	}
+	if d := res.DelayFrom(now); d > 0 {
+		res.CancelAt(now)
+		return false, d
+	}
+	return true, 0
+}
+
+// evictIdle removes the buckets unused for longer than the idle time. The buckets are swept at
+// most once per idle time so that writes do not each scan every bucket. It must be called with
+// the lock held.
+func (l *DocumentTokenLimiter) evictIdle(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idle {
+		return
+	}
+	l.lastSweep = now
+
+	for id, lim := range l.limiters {
+		if now.Sub(lim.lastUsed) > l.idle {
+			delete(l.limiters, id)
+		}
+	}
+}
+
+// limitDocumentWrites wraps a handler writing documents with the write limiter of the handler.
+// Requests over the limit are rejected with 429 Too Many Requests and a Retry-After header.
+// Requests that are not authorized are passed through to fail in the handler.
+func (h *DocumentHandler) limitDocumentWrites(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if h.WriteLimiter == nil {
+			next(w, r)
+			return
+		}
+
+		a, err := documentAuthorizer(ctx)
+		if err != nil {
+			next(w, r)
+			return
+		}
+
+		if ok, wait := h.WriteLimiter.AllowDocumentWrite(ctx, a); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+			EncodeError(ctx, &influxdb.Error{
+				Code: influxdb.ETooManyRequests,
+				Msg:  "too many document writes, retry later",
+			}, w)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// retryAfterSeconds rounds a wait up to the whole seconds of a Retry-After header.
+func retryAfterSeconds(wait time.Duration) int {
+	if s := int(math.Ceil(wait.Seconds())); s > 1 {
+		return s
+	}
+	return 1
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/influxdata/influxdb"
+)
+
+// handleGetDocumentRaw is the HTTP handler for the GET /api/v2/documents/:ns/:id/raw route. It
+// responds with the bytes stored for the document, base64 encoded, to diagnose documents that
+// are not stored as expected. It is restricted to operators, as the document is not authorized
+// against the organizations owning it.
+func (h *DocumentHandler) handleGetDocumentRaw(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodeGetDocumentRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if err := authorizeDocumentOperator(ctx); err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	f, ok := h.DocumentService.(influxdb.DocumentRawFinder)
+	if !ok {
+		EncodeError(ctx, &influxdb.Error{
+			Code: influxdb.EMethodNotAllowed,
+			Msg:  "document service does not support finding raw documents",
+		}, w)
+		return
+	}
+
+	d, err := f.FindRawDocument(ctx, req.Namespace, req.ID)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, d); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
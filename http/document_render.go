@@ -0,0 +1,205 @@
+package http
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/influxdata/influxdb"
+)
+
+// DocumentRenderer renders the content of a document as HTML for previews.
+// The HTML returned must be safe to embed in a page.
+type DocumentRenderer interface {
+	RenderHTML(content interface{}) (string, error)
+}
+
+// MarkdownRenderer renders document content holding a markdown string. Only a subset of
+// markdown is supported: headings, paragraphs, unordered lists, fenced code blocks, inline
+// code, emphasis and links. All text is escaped, so raw HTML in the markdown is shown
+// rather than interpreted, and links are only kept for http, https and mailto URLs.
+type MarkdownRenderer struct{}
+
+// RenderHTML renders the markdown content as HTML.
+func (MarkdownRenderer) RenderHTML(content interface{}) (string, error) {
+	md, ok := content.(string)
+	if !ok {
+		return "", &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "document content must be a markdown string to render",
+		}
+	}
+
+	var b strings.Builder
+	var para []string
+	var list []string
+	var code []string
+	inCode := false
+
+	flushPara := func() {
+		if len(para) > 0 {
+			fmt.Fprintf(&b, "<p>%s</p>\n", renderMarkdownInline(strings.Join(para, " ")))
+			para = nil
+		}
+	}
+	flushList := func() {
+		if len(list) > 0 {
+			b.WriteString("<ul>\n")
+			for _, item := range list {
+				fmt.Fprintf(&b, "<li>%s</li>\n", renderMarkdownInline(item))
+			}
+			b.WriteString("</ul>\n")
+			list = nil
+		}
+	}
+
+	for _, line := range strings.Split(strings.Replace(md, "\r\n", "\n", -1), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			if inCode {
+				fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n", html.EscapeString(strings.Join(code, "\n")))
+				code = nil
+			} else {
+				flushPara()
+				flushList()
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			code = append(code, line)
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			flushPara()
+			flushList()
+		case markdownHeading.MatchString(trimmed):
+			flushPara()
+			flushList()
+			m := markdownHeading.FindStringSubmatch(trimmed)
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", len(m[1]), renderMarkdownInline(m[2]), len(m[1]))
+		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* "):
+			flushPara()
+			list = append(list, strings.TrimSpace(trimmed[2:]))
+		default:
+			flushList()
+			para = append(para, trimmed)
+		}
+	}
+
+	// an unterminated code block runs to the end of the content.
+	if inCode {
+		fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n", html.EscapeString(strings.Join(code, "\n")))
+	}
+	flushPara()
+	flushList()
+
+	return b.String(), nil
+}
+
+var (
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	markdownCode    = regexp.MustCompile("`([^`]+)`")
+	markdownStrong  = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	markdownEm      = regexp.MustCompile(`\*([^*]+)\*`)
+	markdownLink    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+)
+
+// renderMarkdownInline escapes the text provided and then renders its inline markdown.
+func renderMarkdownInline(s string) string {
+	s = html.EscapeString(s)
+	s = markdownCode.ReplaceAllString(s, "<code>$1</code>")
+	s = markdownStrong.ReplaceAllString(s, "<strong>$1</strong>")
+	s = markdownEm.ReplaceAllString(s, "<em>$1</em>")
+	return markdownLink.ReplaceAllStringFunc(s, func(m string) string {
+		parts := markdownLink.FindStringSubmatch(m)
+		text, href := parts[1], parts[2]
+		if !isSafeMarkdownURL(html.UnescapeString(href)) {
+			return text
+		}
+		return fmt.Sprintf(`<a href="%s" rel="nofollow">%s</a>`, href, text)
+	})
+}
+
+func isSafeMarkdownURL(u string) bool {
+	u = strings.ToLower(strings.TrimSpace(u))
+	for _, scheme := range []string{"http://", "https://", "mailto:"} {
+		if strings.HasPrefix(u, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// documentVariablePrefix prefixes the query parameters overriding the variables of a
+// rendered document, as in ?render=true&var.host=example.com.
+const documentVariablePrefix = "var."
+
+var documentPlaceholder = regexp.MustCompile(`{{\s*([A-Za-z_][A-Za-z0-9_]*)\s*}}`)
+
+// renderDocumentVariables returns a copy of the content with the {{name}} placeholders of
+// its strings replaced by the values of the variables provided. It is invalid for a
+// placeholder to have no value.
+func renderDocumentVariables(content interface{}, vars map[string]string) (interface{}, error) {
+	switch c := content.(type) {
+	case string:
+		var missing string
+		s := documentPlaceholder.ReplaceAllStringFunc(c, func(m string) string {
+			name := documentPlaceholder.FindStringSubmatch(m)[1]
+			v, ok := vars[name]
+			if !ok && missing == "" {
+				missing = name
+			}
+			return v
+		})
+		if missing != "" {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  fmt.Sprintf("no value for document variable %q", missing),
+			}
+		}
+		return s, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(c))
+		for k, v := range c {
+			r, err := renderDocumentVariables(v, vars)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = r
+		}
+		return m, nil
+	case []interface{}:
+		a := make([]interface{}, len(c))
+		for i, v := range c {
+			r, err := renderDocumentVariables(v, vars)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = r
+		}
+		return a, nil
+	default:
+		return content, nil
+	}
+}
+
+// documentVariables returns the stored default variables of the document overridden by
+// the variables of the query provided.
+func documentVariables(d *influxdb.Document, qp url.Values) map[string]string {
+	vars := make(map[string]string, len(d.Meta.Variables))
+	for k, v := range d.Meta.Variables {
+		vars[k] = v
+	}
+	for k, v := range qp {
+		if name := strings.TrimPrefix(k, documentVariablePrefix); name != k && len(v) > 0 {
+			vars[name] = v[0]
+		}
+	}
+	return vars
+}
@@ -0,0 +1,192 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/influxdb"
+)
+
+// templateSchema is the JSON Schema that content in the templates namespace is validated against.
+const templateSchema = `{
+	"type": "object",
+	"required": ["data"],
+	"properties": {
+		"data": {
+			"type": "object",
+			"required": ["type", "attributes"],
+			"properties": {
+				"type": {"type": "string", "minLength": 1},
+				"attributes": {"type": "object"},
+				"relationships": {"type": "object"}
+			}
+		},
+		"included": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["type"],
+				"properties": {
+					"type": {"type": "string", "minLength": 1},
+					"attributes": {"type": "object"},
+					"relationships": {"type": "object"}
+				}
+			}
+		}
+	}
+}`
+
+// DocumentSchema is a JSON Schema used to validate the content of documents.
+// Only the subset of the specification required for document content is supported:
+// type, enum, required, properties, additionalProperties, items, minLength and minItems.
+type DocumentSchema struct {
+	Type                 string                     `json:"type,omitempty"`
+	Enum                 []interface{}              `json:"enum,omitempty"`
+	Required             []string                   `json:"required,omitempty"`
+	Properties           map[string]*DocumentSchema `json:"properties,omitempty"`
+	AdditionalProperties *bool                      `json:"additionalProperties,omitempty"`
+	Items                *DocumentSchema            `json:"items,omitempty"`
+	MinLength            *int                       `json:"minLength,omitempty"`
+	MinItems             *int                       `json:"minItems,omitempty"`
+}
+
+// NewDocumentSchema parses a JSON Schema document.
+func NewDocumentSchema(b []byte) (*DocumentSchema, error) {
+	s := &DocumentSchema{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DefaultDocumentSchemas returns the schemas bundled for each namespace.
+func DefaultDocumentSchemas() map[string]*DocumentSchema {
+	s, err := NewDocumentSchema([]byte(templateSchema))
+	if err != nil {
+		panic(fmt.Sprintf("invalid bundled template schema: %v", err))
+	}
+
+	return map[string]*DocumentSchema{
+		"templates": s,
+	}
+}
+
+// Validate ensures that the content provided matches the schema. The error returned
+// reports the schema path of the first violation found.
+func (s *DocumentSchema) Validate(content interface{}) error {
+	vs := s.Violations(content)
+	if len(vs) == 0 {
+		return nil
+	}
+
+	return &influxdb.Error{
+		Code: influxdb.EUnprocessableEntity,
+		Msg:  vs[0],
+	}
+}
+
+// Violations returns every violation of the schema by the content provided, each
+// reporting the schema path of the violation.
+func (s *DocumentSchema) Violations(content interface{}) []string {
+	var vs []string
+	s.validate("#", content, func(path, msg string) {
+		vs = append(vs, fmt.Sprintf("document content does not match schema at %s: %s", path, msg))
+	})
+	return vs
+}
+
+// validate reports each violation of the schema by v. Values that do not match the type
+// of the schema are not validated any further.
+func (s *DocumentSchema) validate(path string, v interface{}, report func(path, msg string)) {
+	if s.Type != "" && !schemaTypeMatches(s.Type, v) {
+		report(path+"/type", fmt.Sprintf("expected %s but found %s", s.Type, schemaTypeOf(v)))
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		var found bool
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report(path+"/enum", fmt.Sprintf("value %v is not one of the allowed values", v))
+		}
+	}
+
+	switch t := v.(type) {
+	case string:
+		if s.MinLength != nil && len(t) < *s.MinLength {
+			report(path+"/minLength", fmt.Sprintf("length must be at least %d", *s.MinLength))
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(t) < *s.MinItems {
+			report(path+"/minItems", fmt.Sprintf("must contain at least %d items", *s.MinItems))
+		}
+		if s.Items != nil {
+			for i, item := range t {
+				s.Items.validate(fmt.Sprintf("%s/items/%d", path, i), item, report)
+			}
+		}
+	case map[string]interface{}:
+		for _, r := range s.Required {
+			if _, ok := t[r]; !ok {
+				report(path+"/required", fmt.Sprintf("missing required property %q", r))
+			}
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			pv := t[k]
+			ps, ok := s.Properties[k]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					report(path+"/additionalProperties", fmt.Sprintf("property %q is not allowed", k))
+				}
+				continue
+			}
+			ps.validate(path+"/properties/"+k, pv, report)
+		}
+	}
+}
+
+func schemaTypeMatches(typ string, v interface{}) bool {
+	switch typ {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	default:
+		return strings.EqualFold(typ, schemaTypeOf(v))
+	}
+}
+
+func schemaTypeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
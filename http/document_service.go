@@ -2,11 +2,19 @@ package http
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/authorizer"
 	pcontext "github.com/influxdata/influxdb/context"
 	"github.com/julienschmidt/httprouter"
 	"go.uber.org/zap"
@@ -17,14 +25,94 @@ import (
 type DocumentBackend struct {
 	Logger *zap.Logger
 
-	DocumentService influxdb.DocumentService
+	DocumentService     influxdb.DocumentService
+	LabelService        influxdb.LabelService
+	OrganizationService influxdb.OrganizationService
+
+	// Schemas are the JSON Schemas that document content is validated against, keyed by namespace.
+	Schemas map[string]*DocumentSchema
+	// UniqueNames are the namespaces in which document names must be unique within an organization.
+	UniqueNames map[string]bool
+	// MaxLabelsPerDocument is the number of labels a document may carry. Zero means no limit.
+	MaxLabelsPerDocument int
+	// MaxDocumentsPerOrg is the number of documents an organization may own in a namespace.
+	// Zero means no limit.
+	MaxDocumentsPerOrg int
+	// MaxDocumentsResponseBytes caps the size of the documents listed in a single response.
+	// Longer lists are truncated and continued with links.next. Zero means no limit.
+	MaxDocumentsResponseBytes int
+	// MaxDocumentsPageSize is the largest number of documents a client may request to be
+	// listed in a single response. Larger limits are clamped to it. Zero means no maximum.
+	MaxDocumentsPageSize int
+	// Namespaces configure individual namespaces, in place of the settings above.
+	Namespaces map[string]DocumentNamespaceConfig
+	// LabelScopedAccess restricts authorizations that may only read specific labels to the
+	// documents carrying one of those labels.
+	LabelScopedAccess bool
+	// WriteLimiter, if set, limits the rate at which documents are created, updated and deleted.
+	WriteLimiter DocumentWriteLimiter
+	// ReadOnly rejects every request that would write documents or their labels with 503
+	// Service Unavailable, while documents can still be read, such as during maintenance.
+	ReadOnly bool
 }
 
+// DocumentNamespaceConfig is the configuration of the documents of a single namespace.
+type DocumentNamespaceConfig struct {
+	// Schema is the JSON Schema that document content is validated against. Content is not
+	// validated if it is nil.
+	Schema *DocumentSchema
+	// UniqueNames requires document names to be unique within an organization.
+	UniqueNames bool
+	// MaxLabelsPerDocument is the number of labels a document may carry. Zero means no limit.
+	MaxLabelsPerDocument int
+	// MaxDocumentsPerOrg is the number of documents an organization may own. Zero means no limit.
+	MaxDocumentsPerOrg int
+	// Renderer, if set, renders document content as HTML when a document is requested with
+	// format=html.
+	Renderer DocumentRenderer
+	// AllowBinaryContent accepts string content that is not valid UTF-8, which is stored
+	// base64 encoded. Content must otherwise be valid UTF-8.
+	AllowBinaryContent bool
+	// ServeContentType returns the content of a document created with a content type as is,
+	// with that content type, rather than the document as JSON. Such responses are marked
+	// nosniff so that browsers do not guess another type from the content.
+	ServeContentType bool
+	// RequireLabels requires documents to keep at least one label, so that the last label of a
+	// document cannot be removed.
+	RequireLabels bool
+	// MoveTargets are the namespaces the documents of the namespace may be moved to. Documents
+	// cannot be moved if it is empty.
+	MoveTargets []string
+	// Webhook, if set, is notified of the documents created, updated and deleted by the
+	// DocumentWebhookNotifier subscribed to the changes made to documents, which is owned by
+	// whoever serves the handler rather than by the handler.
+	Webhook *DocumentWebhook
+}
+
+// DefaultMaxLabelsPerDocument is the number of labels a document may carry by default.
+const DefaultMaxLabelsPerDocument = 100
+
+// DefaultMaxDocumentsResponseBytes is the size of the documents listed in a single response
+// by default.
+const DefaultMaxDocumentsResponseBytes = 16 << 20
+
+// DefaultMaxDocumentsPageSize is the largest number of documents listed in a single response
+// by default.
+const DefaultMaxDocumentsPageSize = 1000
+
 // NewDocumentBackend returns a new instance of DocumentBackend.
 func NewDocumentBackend(b *APIBackend) *DocumentBackend {
 	return &DocumentBackend{
-		Logger:          b.Logger.With(zap.String("handler", "document")),
-		DocumentService: b.DocumentService,
+		Logger:              b.Logger.With(zap.String("handler", "document")),
+		DocumentService:     b.DocumentService,
+		LabelService:        b.LabelService,
+		OrganizationService: b.OrganizationService,
+		Schemas:             DefaultDocumentSchemas(),
+		Namespaces:          b.DocumentNamespaces,
+
+		MaxLabelsPerDocument:      DefaultMaxLabelsPerDocument,
+		MaxDocumentsResponseBytes: DefaultMaxDocumentsResponseBytes,
+		MaxDocumentsPageSize:      DefaultMaxDocumentsPageSize,
 	}
 }
 
@@ -34,12 +122,37 @@ type DocumentHandler struct {
 
 	Logger *zap.Logger
 
-	DocumentService influxdb.DocumentService
+	DocumentService     influxdb.DocumentService
+	LabelService        influxdb.LabelService
+	OrganizationService influxdb.OrganizationService
+	Schemas             map[string]*DocumentSchema
+	UniqueNames         map[string]bool
+
+	MaxLabelsPerDocument      int
+	MaxDocumentsPerOrg        int
+	MaxDocumentsResponseBytes int
+	MaxDocumentsPageSize      int
+	Namespaces                map[string]DocumentNamespaceConfig
+	LabelScopedAccess         bool
+	WriteLimiter              DocumentWriteLimiter
+	ReadOnly                  bool
 }
 
 const (
-	documentsPath = "/api/v2/documents/:ns"
-	documentPath  = "/api/v2/documents/:ns/:id"
+	documentsPath      = "/api/v2/documents/:ns"
+	documentPath       = "/api/v2/documents/:ns/:id"
+	documentCopyPath   = "/api/v2/documents/:ns/:id/copy"
+	documentLabelsPath = "/api/v2/documents/:ns/:id/labels"
+	documentLabelPath  = "/api/v2/documents/:ns/:id/labels/:lid"
+	documentDiffPath   = "/api/v2/documents/:ns/:id/diff"
+	documentAppendPath = "/api/v2/documents/:ns/:id/append"
+	documentLockPath   = "/api/v2/documents/:ns/:id/lock"
+	documentPinPath    = "/api/v2/documents/:ns/:id/pin"
+	documentMovePath   = "/api/v2/documents/:ns/:id/move"
+	documentRawPath    = "/api/v2/documents/:ns/:id/raw"
+
+	// documentByNameSegment is the path segment of GET /api/v2/documents/:ns/by-name/:name.
+	documentByNameSegment = "by-name"
 )
 
 // TODO(desa): this should probably take a namespace
@@ -49,21 +162,195 @@ func NewDocumentHandler(b *DocumentBackend) *DocumentHandler {
 		Router: NewRouter(),
 		Logger: b.Logger,
 
-		DocumentService: b.DocumentService,
+		DocumentService:     b.DocumentService,
+		LabelService:        b.LabelService,
+		OrganizationService: b.OrganizationService,
+		Schemas:             b.Schemas,
+		UniqueNames:         b.UniqueNames,
+
+		MaxLabelsPerDocument:      b.MaxLabelsPerDocument,
+		MaxDocumentsPerOrg:        b.MaxDocumentsPerOrg,
+		MaxDocumentsResponseBytes: b.MaxDocumentsResponseBytes,
+		MaxDocumentsPageSize:      b.MaxDocumentsPageSize,
+		Namespaces:                b.Namespaces,
+		LabelScopedAccess:         b.LabelScopedAccess,
+		WriteLimiter:              b.WriteLimiter,
+		ReadOnly:                  b.ReadOnly,
 	}
 
-	h.HandlerFunc("POST", documentsPath, h.handlePostDocument)
+	h.HandlerFunc("POST", documentsPath, h.limitDocumentWrites(h.handlePostDocument))
 	h.HandlerFunc("GET", documentsPath, h.handleGetDocuments)
+	h.HandlerFunc("DELETE", documentsPath, h.limitDocumentWrites(h.handleDeleteDocuments))
 	h.HandlerFunc("GET", documentPath, h.handleGetDocument)
-	h.HandlerFunc("PUT", documentPath, h.handlePutDocument)
-	h.HandlerFunc("DELETE", documentPath, h.handleDeleteDocument)
+	h.HandlerFunc("PUT", documentPath, h.limitDocumentWrites(h.handlePutDocument))
+	h.HandlerFunc("DELETE", documentPath, h.limitDocumentWrites(h.handleDeleteDocument))
+	h.HandlerFunc("POST", documentCopyPath, h.limitDocumentWrites(h.handlePostDocumentCopy))
+	h.HandlerFunc("POST", documentAppendPath, h.limitDocumentWrites(h.handlePostDocumentAppend))
+	h.HandlerFunc("POST", documentLockPath, h.handlePostDocumentLock)
+	h.HandlerFunc("DELETE", documentLockPath, h.handleDeleteDocumentLock)
+	h.HandlerFunc("PUT", documentPinPath, h.handlePutDocumentPin)
+	h.HandlerFunc("DELETE", documentPinPath, h.handleDeleteDocumentPin)
+	h.HandlerFunc("POST", documentMovePath, h.limitDocumentWrites(h.handlePostDocumentMove))
+	h.HandlerFunc("GET", documentLabelsPath, h.handleGetDocumentLabel)
+	h.HandlerFunc("POST", documentLabelsPath, h.handlePostDocumentLabel)
+	h.HandlerFunc("DELETE", documentLabelPath, h.handleDeleteDocumentLabel)
+	h.HandlerFunc("GET", documentDiffPath, h.handleGetDocumentDiff)
+	h.HandlerFunc("GET", documentRawPath, h.handleGetDocumentRaw)
+	// httprouter does not allow static segments alongside the :id wildcard, so
+	// POST /api/v2/documents/:ns/reindex is dispatched from the document path.
+	h.HandlerFunc("POST", documentPath, h.handlePostDocumentAction)
 
 	return h
 }
 
+// ServeHTTP dispatches GET /api/v2/documents/:ns/by-name/:name, which httprouter does not allow
+// alongside the :id wildcard, and delegates every other request to the router. A trailing slash
+// is removed from the path first, so that every route is served the same with or without it
+// rather than redirected or not found depending on the route. Every route but those reading
+// documents writes them, so that only reads are served in read-only mode.
+func (h *DocumentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.ReadOnly && r.Method != "GET" && r.Method != "HEAD" {
+		EncodeError(r.Context(), &influxdb.Error{
+			Code: influxdb.EUnavailable,
+			Msg:  "documents are read-only, retry later",
+		}, w)
+		return
+	}
+
+	if p := r.URL.Path; len(p) > 1 && strings.HasSuffix(p, "/") {
+		u := *r.URL
+		u.Path = strings.TrimSuffix(p, "/")
+		u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+		r = r.WithContext(r.Context())
+		r.URL = &u
+	}
+
+	if ns := documentNamespace(r.URL.Path); ns != "" {
+		r = r.WithContext(pcontext.WithNamespace(r.Context(), ns))
+	}
+
+	if r.Method == "GET" {
+		if ns, name, ok := parseDocumentByNamePath(r.URL.EscapedPath()); ok {
+			params := httprouter.Params{
+				{Key: "ns", Value: ns},
+				{Key: "name", Value: name},
+			}
+			ctx := context.WithValue(r.Context(), httprouter.ParamsKey, params)
+			h.handleGetDocumentsByName(w, r.WithContext(ctx))
+			return
+		}
+	}
+
+	h.Router.ServeHTTP(w, r)
+}
+
+// documentNamespace returns the namespace of a document path, so that it is set on the
+// context for the logs and store calls of the request.
+func documentNamespace(p string) string {
+	return strings.SplitN(strings.TrimPrefix(p, "/api/v2/documents/"), "/", 2)[0]
+}
+
+// parseDocumentByNamePath returns the namespace and unescaped name of a by-name document path.
+func parseDocumentByNamePath(p string) (string, string, bool) {
+	parts := strings.Split(strings.TrimPrefix(p, "/api/v2/documents/"), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] != documentByNameSegment || parts[2] == "" {
+		return "", "", false
+	}
+
+	name, err := url.PathUnescape(parts[2])
+	if err != nil {
+		return "", "", false
+	}
+
+	return parts[0], name, true
+}
+
+// documentAuthorizer retrieves the authorizer of the request. The request is unauthorized if
+// none was set on the context, including when a nil authorizer was set.
+func documentAuthorizer(ctx context.Context) (influxdb.Authorizer, error) {
+	a, err := pcontext.GetAuthorizer(ctx)
+	if err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.EUnauthorized,
+			Msg:  "request is not authorized",
+			Err:  err,
+		}
+	}
+
+	var missing bool
+	switch t := a.(type) {
+	case *influxdb.Authorization:
+		missing = t == nil
+	case *influxdb.Session:
+		missing = t == nil
+	}
+	if missing {
+		return nil, &influxdb.Error{
+			Code: influxdb.EUnauthorized,
+			Msg:  "request is not authorized",
+		}
+	}
+
+	return a, nil
+}
+
+// labelScope returns the options restricting the documents found for the authorizer provided to
+// the labels it may read, when label scoped access is enabled.
+func (h *DocumentHandler) labelScope(a influxdb.Authorizer) []influxdb.DocumentFindOptions {
+	if !h.LabelScopedAccess {
+		return nil
+	}
+
+	ids, ok := influxdb.DocumentLabelScope(a)
+	if !ok {
+		return nil
+	}
+	return []influxdb.DocumentFindOptions{influxdb.WhereAnyLabelID(ids...)}
+}
+
+// whereAuthorizedID returns the options finding the document with the id provided if the
+// authorizer provided may access it, including within its label scope. Every lookup of a single
+// document uses them, so that no route reveals documents outside of the scope.
+func (h *DocumentHandler) whereAuthorizedID(a influxdb.Authorizer, id influxdb.ID) []influxdb.DocumentFindOptions {
+	return append([]influxdb.DocumentFindOptions{influxdb.AuthorizedWhereID(a, id)}, h.labelScope(a)...)
+}
+
+// authorized returns the options authorizing the authorizer provided to write a document,
+// including within its label scope. They are applied in the transaction of the write.
+func (h *DocumentHandler) authorized(a influxdb.Authorizer) []influxdb.DocumentOptions {
+	opts := []influxdb.DocumentOptions{influxdb.Authorized(a)}
+	if !h.LabelScopedAccess {
+		return opts
+	}
+
+	if ids, ok := influxdb.DocumentLabelScope(a); ok {
+		opts = append(opts, influxdb.HasAnyLabelID(ids...))
+	}
+	return opts
+}
+
+// namespaceConfig returns the configuration of the namespace provided. Namespaces without their
+// own configuration use the settings of the handler.
+func (h *DocumentHandler) namespaceConfig(ns string) DocumentNamespaceConfig {
+	if c, ok := h.Namespaces[ns]; ok {
+		return c
+	}
+
+	return DocumentNamespaceConfig{
+		Schema:               h.Schemas[ns],
+		UniqueNames:          h.UniqueNames[ns],
+		MaxLabelsPerDocument: h.MaxLabelsPerDocument,
+		MaxDocumentsPerOrg:   h.MaxDocumentsPerOrg,
+	}
+}
+
 type documentResponse struct {
 	Links map[string]string `json:"links"`
 	*influxdb.Document
+	// Labels shadows the labels of the document, which are omitted when empty, so that a
+	// document without labels is encoded with an empty list whether its labels are nil or
+	// empty. It is nil, and omitted, when the labels of the document were not resolved.
+	Labels *[]*influxdb.Label `json:"labels,omitempty"`
 }
 
 func newDocumentResponse(ns string, d *influxdb.Document) *documentResponse {
@@ -75,13 +362,26 @@ func newDocumentResponse(ns string, d *influxdb.Document) *documentResponse {
 			"self": fmt.Sprintf("/api/v2/documents/%s/%s", ns, d.ID),
 		},
 		Document: d,
+		Labels:   &d.Labels,
 	}
 }
 
 type documentsResponse struct {
-	Documents []*documentResponse `json:"documents"`
+	// Links are only set when the documents are listed in several responses.
+	Links *influxdb.PagingLinks `json:"links,omitempty"`
+	// Org is only set when the organization of the documents is requested with includeOrg.
+	Org       *documentsOrgResponse `json:"org,omitempty"`
+	Documents []*documentResponse   `json:"documents"`
+}
+
+// documentsOrgResponse is the organization that the documents listed belong to.
+type documentsOrgResponse struct {
+	ID   influxdb.ID `json:"id"`
+	Name string      `json:"name"`
 }
 
+// newDocumentsResponse returns the documents of a list, which are never nil so that a list
+// without documents is encoded as an empty array.
 func newDocumentsResponse(ns string, docs []*influxdb.Document) *documentsResponse {
 	ds := make([]*documentResponse, 0, len(docs))
 	for _, doc := range docs {
@@ -93,6 +393,100 @@ func newDocumentsResponse(ns string, docs []*influxdb.Document) *documentsRespon
 	}
 }
 
+// omitLabels omits the labels of the documents, for documents found without their labels.
+func (r *documentsResponse) omitLabels() *documentsResponse {
+	for _, d := range r.Documents {
+		d.Labels = nil
+	}
+	return r
+}
+
+// truncate continues the documents after the cursor provided, if any, and then keeps as many
+// whole documents as fit in max bytes, up to limit documents, linking to the next documents if
+// some do not fit. The first document is always kept so that every response makes progress.
+// Zero maxBytes or limit means no limit.
+func (r *documentsResponse) truncate(basePath string, f influxdb.PagingFilter, c *documentCursor, maxBytes, limit int) error {
+	offset := 0
+	if c != nil {
+		offset = c.Offset
+		// resume right after the last document of the previous response if it still exists.
+		for i, d := range r.Documents {
+			if c.After.Valid() && d.ID == c.After {
+				offset = i + 1
+				break
+			}
+		}
+		if offset > len(r.Documents) {
+			offset = len(r.Documents)
+		}
+	}
+	ds := r.Documents[offset:]
+
+	end, size := len(ds), 0
+	if limit > 0 && limit < end {
+		end = limit
+	}
+	for i, d := range ds[:end] {
+		b, err := json.Marshal(d)
+		if err != nil {
+			return &influxdb.Error{
+				Code: influxdb.EInternal,
+				Msg:  "unable to compute size of documents",
+				Err:  err,
+			}
+		}
+		size += len(b) + 1
+		if maxBytes > 0 && size > maxBytes && i > 0 {
+			end = i
+			break
+		}
+	}
+	r.Documents = ds[:end]
+	if c == nil && end == len(ds) {
+		return nil
+	}
+
+	filter := documentCursorFilter(f)
+	link := func(c documentCursor) string {
+		values := url.Values(f.QueryParams())
+		values.Set("cursor", encodeDocumentCursor(c))
+		u := url.URL{Path: basePath, RawQuery: values.Encode()}
+		return u.String()
+	}
+
+	r.Links = &influxdb.PagingLinks{
+		Self: link(documentCursor{Offset: offset, Filter: filter}),
+	}
+	if end < len(ds) {
+		r.Links.Next = link(documentCursor{Offset: offset + end, After: ds[end-1].ID, Filter: filter})
+	}
+
+	return nil
+}
+
+// documents returns the documents of the response.
+func (r *documentsResponse) documents() []*influxdb.Document {
+	ds := make([]*influxdb.Document, 0, len(r.Documents))
+	for _, d := range r.Documents {
+		ds = append(ds, d.Document)
+	}
+	return ds
+}
+
+// documentsFilter implements influxdb.PagingFilter for the documents route, whose filters are
+// every query parameter but the cursor.
+type documentsFilter url.Values
+
+func (f documentsFilter) QueryParams() map[string][]string {
+	qp := make(map[string][]string, len(f))
+	for k, v := range f {
+		if k != "cursor" {
+			qp[k] = v
+		}
+	}
+	return qp
+}
+
 // handlePostDocument is the HTTP handler for the POST /api/v2/documents/:ns route.
 func (h *DocumentHandler) handlePostDocument(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -103,13 +497,18 @@ func (h *DocumentHandler) handlePostDocument(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if err := h.validateDocument(ctx, req.Namespace, req.Document, req.rawContent, req.Labels); err != nil {
+		encodeDocumentValidationError(ctx, err, w)
+		return
+	}
+
 	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
 	if err != nil {
 		EncodeError(ctx, err, w)
 		return
 	}
 
-	a, err := pcontext.GetAuthorizer(ctx)
+	a, err := documentAuthorizer(ctx)
 	if err != nil {
 		EncodeError(ctx, err, w)
 		return
@@ -125,31 +524,73 @@ func (h *DocumentHandler) handlePostDocument(w http.ResponseWriter, r *http.Requ
 		// TODO(desa): make these AuthorizedWithLabel eventually
 		opts = append(opts, influxdb.WithLabel(label))
 	}
+	// the labels are attached in the transaction creating the document, so that a label
+	// that does not exist fails the creation as a whole.
+	for _, id := range req.LabelIDs {
+		opts = append(opts, influxdb.WithLabelID(id))
+	}
+	c := h.namespaceConfig(req.Namespace)
+	if c.UniqueNames {
+		opts = append(opts, influxdb.WithUniqueName(req.Meta.Name))
+	}
+	if c.MaxDocumentsPerOrg > 0 {
+		opts = append(opts, influxdb.WithDocumentQuota(c.MaxDocumentsPerOrg))
+	}
 
-	if err := s.CreateDocument(ctx, req.Document, opts...); err != nil {
-		EncodeError(ctx, err, w)
+	req.Meta.LastWriterID = a.GetUserID()
+	created, err := createDocument(ctx, s, r.Header.Get("Idempotency-Key"), req.Document, opts...)
+	if err != nil {
+		encodeConflictError(ctx, err, w)
 		return
 	}
 
-	if err := encodeResponse(ctx, w, http.StatusCreated, newDocumentResponse(req.Namespace, req.Document)); err != nil {
+	code := http.StatusCreated
+	if !created {
+		code = http.StatusOK
+	}
+
+	if err := encodeResponse(ctx, w, code, newDocumentResponse(req.Namespace, req.Document)); err != nil {
 		logEncodingError(h.Logger, r, err)
 		return
 	}
 }
 
+// createDocument creates the document in the store. If an idempotency key is provided and a document
+// was already created with it, d is replaced by that document and false is returned.
+func createDocument(ctx context.Context, s influxdb.DocumentStore, key string, d *influxdb.Document, opts ...influxdb.DocumentOptions) (bool, error) {
+	if key == "" {
+		return true, s.CreateDocument(ctx, d, opts...)
+	}
+
+	c, ok := s.(influxdb.DocumentIdempotentCreator)
+	if !ok {
+		return false, &influxdb.Error{
+			Code: influxdb.EMethodNotAllowed,
+			Msg:  "document store does not support idempotency keys",
+		}
+	}
+
+	return c.CreateDocumentOnce(ctx, key, d, opts...)
+}
+
 type postDocumentRequest struct {
 	*influxdb.Document
-	Namespace string      `json:"-"`
-	Org       string      `json:"org"`
-	OrgID     influxdb.ID `json:"orgID,omitempty"`
-	Labels    []string    `json:"labels"` // TODO(desa): should this be IDs or strings?
+	Namespace string        `json:"-"`
+	Org       string        `json:"org"`
+	OrgID     influxdb.ID   `json:"orgID,omitempty"`
+	Labels    []string      `json:"labels"` // TODO(desa): should this be IDs or strings?
+	LabelIDs  []influxdb.ID `json:"labelIDs"`
+
+	rawContent json.RawMessage
 }
 
 func decodePostDocumentRequest(ctx context.Context, r *http.Request) (*postDocumentRequest, error) {
 	req := &postDocumentRequest{}
-	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+	raw, err := decodeDocumentBody(r.Body, req)
+	if err != nil {
 		return nil, err
 	}
+	req.rawContent = raw
 
 	if req.Document == nil {
 		return nil, &influxdb.Error{
@@ -170,7 +611,8 @@ func decodePostDocumentRequest(ctx context.Context, r *http.Request) (*postDocum
 	return req, nil
 }
 
-// handleGetDocuments is the HTTP handler for the GET /api/v2/documents/:ns route.
+// handleGetDocuments is the HTTP handler for the GET /api/v2/documents/:ns route. Documents are
+// listed as an empty array, rather than null or not found, when none match.
 func (h *DocumentHandler) handleGetDocuments(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -180,54 +622,310 @@ func (h *DocumentHandler) handleGetDocuments(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
+	filter := documentsFilter(r.URL.Query())
+	var cursor *documentCursor
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		if cursor, err = decodeDocumentCursor(token, filter); err != nil {
+			EncodeError(ctx, err, w)
+			return
+		}
+	}
+
+	csv := acceptsDocumentsCSV(r)
+	// the content is only needed for its length in CSV rows.
+	req.IncludeContent = csv
+
+	ds, err := h.findDocuments(ctx, req)
 	if err != nil {
 		EncodeError(ctx, err, w)
 		return
 	}
 
-	a, err := pcontext.GetAuthorizer(ctx)
+	if csv {
+		if err := encodeDocumentsCSV(w, ds, req.Preview); err != nil {
+			logEncodingError(h.Logger, r, err)
+		}
+		return
+	}
+
+	res := newDocumentsResponse(req.Namespace, ds)
+	if req.ExcludeLabels {
+		res.omitLabels()
+	}
+	if req.IncludeOrg {
+		if res.Org, err = h.documentsOrg(ctx, req); err != nil {
+			EncodeError(ctx, err, w)
+			return
+		}
+	}
+	// a limit over the maximum page size is clamped rather than rejected, and the limit
+	// applied is reported so that clients know to page through the documents.
+	if max := h.MaxDocumentsPageSize; max > 0 && req.Limit > max {
+		req.Limit = max
+		w.Header().Set("X-Truncated-Limit", strconv.Itoa(max))
+	}
+	if cursor != nil || h.MaxDocumentsResponseBytes > 0 || req.Limit > 0 {
+		if err := res.truncate(r.URL.Path, filter, cursor, h.MaxDocumentsResponseBytes, req.Limit); err != nil {
+			EncodeError(ctx, err, w)
+			return
+		}
+	}
+
+	etag, err := documentsETag(res.documents())
 	if err != nil {
 		EncodeError(ctx, err, w)
 		return
 	}
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, res); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+// documentsETag computes an entity tag for a list of documents from their ids and the time
+// each was last updated, so that the tag changes whenever a document is added, removed or updated.
+func documentsETag(ds []*influxdb.Document) (string, error) {
+	type entry struct {
+		ID        influxdb.ID `json:"id"`
+		UpdatedAt time.Time   `json:"updatedAt"`
+	}
+
+	es := make([]entry, 0, len(ds))
+	for _, d := range ds {
+		es = append(es, entry{ID: d.ID, UpdatedAt: d.Meta.UpdatedAt})
+	}
+
+	b, err := json.Marshal(es)
+	if err != nil {
+		return "", &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  "unable to compute documents etag",
+			Err:  err,
+		}
+	}
+
+	return fmt.Sprintf(`"%x"`, sha256.Sum256(b)), nil
+}
+
+// findDocumentsOrg returns not found if the organization of the documents listed does not
+// exist, rather than listing no documents. Organization names are already resolved by the
+// document store, which returns not found for unknown names.
+func (h *DocumentHandler) findDocumentsOrg(ctx context.Context, orgID influxdb.ID) (*influxdb.Organization, error) {
+	if h.OrganizationService == nil {
+		return nil, nil
+	}
+
+	o, err := h.OrganizationService.FindOrganizationByID(ctx, orgID)
+	if err != nil {
+		if influxdb.ErrorCode(err) == influxdb.ENotFound {
+			return nil, &influxdb.Error{
+				Code: influxdb.ENotFound,
+				Msg:  "org not found",
+				Err:  err,
+			}
+		}
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// documentsOrg returns the organization of the documents listed, as requested with includeOrg.
+// Every document listed belongs to the organization of the request, so it is resolved once
+// per response, reusing the organization found when the documents were listed if any.
+func (h *DocumentHandler) documentsOrg(ctx context.Context, req *getDocumentsRequest) (*documentsOrgResponse, error) {
+	o := req.org
+	if o == nil {
+		if h.OrganizationService == nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EMethodNotAllowed,
+				Msg:  "organizations of documents cannot be resolved",
+			}
+		}
+
+		var err error
+		if req.OrgID != nil {
+			o, err = h.OrganizationService.FindOrganizationByID(ctx, *req.OrgID)
+		} else {
+			o, err = h.OrganizationService.FindOrganization(ctx, influxdb.OrganizationFilter{Name: &req.Org})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &documentsOrgResponse{ID: o.ID, Name: o.Name}, nil
+}
+
+// findDocuments returns the documents of the namespace matching the request, sorted as requested.
+func (h *DocumentHandler) findDocuments(ctx context.Context, req *getDocumentsRequest) ([]*influxdb.Document, error) {
+	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := documentAuthorizer(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	var opt func(influxdb.DocumentIndex, influxdb.DocumentDecorator) ([]influxdb.ID, error)
 
 	if req.Org != "" && req.OrgID != nil {
-		EncodeError(ctx, &influxdb.Error{
+		return nil, &influxdb.Error{
 			Code: influxdb.EInvalid,
 			Msg:  "Please provide either org or orgID, not both",
-		}, w)
-		return
+		}
 	} else if req.OrgID != nil && req.OrgID.Valid() {
+		o, err := h.findDocumentsOrg(ctx, *req.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		req.org = o
 		opt = influxdb.AuthorizedWhereOrgID(a, *req.OrgID)
 	} else if req.Org != "" {
 		opt = influxdb.AuthorizedWhereOrg(a, req.Org)
 	} else {
-		EncodeError(ctx, &influxdb.Error{
+		return nil, &influxdb.Error{
 			Code: influxdb.EInvalid,
 			Msg:  "Please provide either org or orgID",
-		}, w)
+		}
+	}
+
+	opts := []influxdb.DocumentFindOptions{opt}
+	if !req.ExcludeLabels {
+		opts = append(opts, influxdb.IncludeLabels)
+	}
+	if req.IncludeContent {
+		opts = append(opts, influxdb.IncludeContent)
+	}
+	if req.Name != "" {
+		opts = append(opts, influxdb.WhereName(req.Name))
+	}
+	for _, label := range req.Labels {
+		opts = append(opts, influxdb.WhereLabel(label))
+	}
+	if req.LabelNameRegex != nil {
+		opts = append(opts, influxdb.WhereLabelNameMatches(req.LabelNameRegex))
+	}
+	if req.HasLabels != nil {
+		opts = append(opts, influxdb.WhereHasLabels(*req.HasLabels))
+	}
+	if req.ModifiedSince != nil {
+		opts = append(opts, influxdb.WhereUpdatedAfter(*req.ModifiedSince))
+	}
+	opts = append(opts, h.labelScope(a)...)
+
+	ds, err := s.FindDocuments(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.SortBy != "" {
+		influxdb.SortDocuments(influxdb.FindOptions{SortBy: req.SortBy, Descending: req.Descending}, ds)
+	}
+
+	return ds, nil
+}
+
+// handleGetDocumentsByName is the HTTP handler for the GET /api/v2/documents/:ns/by-name/:name route.
+// Every document of the organization with the name is returned, as names are not unique. Like
+// every list of documents, it is empty rather than not found if no document has the name.
+func (h *DocumentHandler) handleGetDocumentsByName(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodeGetDocumentsByNameRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
 		return
 	}
 
-	ds, err := s.FindDocuments(ctx, opt, influxdb.IncludeLabels)
+	ds, err := h.findDocuments(ctx, req)
 	if err != nil {
 		EncodeError(ctx, err, w)
 		return
 	}
 
-	if err := encodeResponse(ctx, w, http.StatusOK, newDocumentsResponse(req.Namespace, ds)); err != nil {
+	res := newDocumentsResponse(req.Namespace, ds)
+	if req.ExcludeLabels {
+		res.omitLabels()
+	}
+	if req.IncludeOrg {
+		if res.Org, err = h.documentsOrg(ctx, req); err != nil {
+			EncodeError(ctx, err, w)
+			return
+		}
+	}
+	if err := encodeResponse(ctx, w, http.StatusOK, res); err != nil {
 		logEncodingError(h.Logger, r, err)
 		return
 	}
 }
 
+func decodeGetDocumentsByNameRequest(ctx context.Context, r *http.Request) (*getDocumentsRequest, error) {
+	req, err := decodeGetDocumentsRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	params := httprouter.ParamsFromContext(ctx)
+	name := params.ByName("name")
+	if name == "" {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "url missing name",
+		}
+	}
+	req.Name = name
+
+	return req, nil
+}
+
+// documentSortFields maps the sortBy query parameter to the field documents are sorted by.
+var documentSortFields = map[string]string{
+	"id":             "ID",
+	"name":           "Name",
+	"createdAt":      "CreatedAt",
+	"updatedAt":      "UpdatedAt",
+	"lastAccessedAt": "LastAccessedAt",
+}
+
 type getDocumentsRequest struct {
 	Namespace string
 	Org       string
 	OrgID     *influxdb.ID
+	Name      string
+	Labels    []string
+	// LabelNameRegex, when set, only keeps the documents with a label whose name matches it.
+	LabelNameRegex *regexp.Regexp
+	// HasLabels, when set, only keeps the documents with at least one label if true, or
+	// without any label if false.
+	HasLabels *bool
+	// ModifiedSince excludes documents that have not been updated after it.
+	ModifiedSince *time.Time
+	// ExcludeLabels skips resolving the labels of the documents.
+	ExcludeLabels bool
+	// IncludeContent includes the content of the documents.
+	IncludeContent bool
+	// Preview includes a preview of the content of documents listed as CSV.
+	Preview bool
+	// IncludeOrg includes the organization of the documents, with its name resolved.
+	IncludeOrg bool
+	// Limit is the number of documents listed in a single response. Zero means no limit.
+	Limit int
+
+	SortBy     string
+	Descending bool
+
+	// org is the organization of the documents, once it has been found.
+	org *influxdb.Organization
 }
 
 func decodeGetDocumentsRequest(ctx context.Context, r *http.Request) (*getDocumentsRequest, error) {
@@ -253,41 +951,192 @@ func decodeGetDocumentsRequest(ctx context.Context, r *http.Request) (*getDocume
 			}
 		}
 	}
-	return &getDocumentsRequest{
+	req := &getDocumentsRequest{
 		Namespace: ns,
 		Org:       qp.Get("org"),
 		OrgID:     oid,
-	}, nil
-}
-
-// handleGetDocument is the HTTP handler for the GET /api/v2/documents/:ns/:id route.
-func (h *DocumentHandler) handleGetDocument(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
-	req, err := decodeGetDocumentRequest(ctx, r)
-	if err != nil {
-		EncodeError(ctx, err, w)
-		return
+		Name:      qp.Get("name"),
+		Labels:    qp["label"],
 	}
 
-	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
-	if err != nil {
-		EncodeError(ctx, err, w)
-		return
+	if sortBy := qp.Get("sortBy"); sortBy != "" {
+		f, ok := documentSortFields[sortBy]
+		if !ok {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  fmt.Sprintf("cannot sort documents by %q", sortBy),
+			}
+		}
+		req.SortBy = f
 	}
 
-	a, err := pcontext.GetAuthorizer(ctx)
+	if descending := qp.Get("descending"); descending != "" {
+		desc, err := strconv.ParseBool(descending)
+		if err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "descending must be a boolean",
+			}
+		}
+		req.Descending = desc
+	}
+
+	if pattern := qp.Get("labelNameRegex"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "labelNameRegex must be a valid regular expression",
+				Err:  err,
+			}
+		}
+		req.LabelNameRegex = re
+	}
+
+	if hasLabels := qp.Get("hasLabels"); hasLabels != "" {
+		has, err := strconv.ParseBool(hasLabels)
+		if err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "hasLabels must be a boolean",
+			}
+		}
+		req.HasLabels = &has
+	}
+
+	if since := qp.Get("modifiedSince"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "modifiedSince must be an RFC3339 timestamp",
+				Err:  err,
+			}
+		}
+		req.ModifiedSince = &t
+	}
+
+	if preview := qp.Get("preview"); preview != "" {
+		p, err := strconv.ParseBool(preview)
+		if err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "preview must be a boolean",
+			}
+		}
+		req.Preview = p
+	}
+
+	if limit := qp.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 1 {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "limit must be a positive integer",
+			}
+		}
+		req.Limit = l
+	}
+
+	if includeOrg := qp.Get("includeOrg"); includeOrg != "" {
+		include, err := strconv.ParseBool(includeOrg)
+		if err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "includeOrg must be a boolean",
+			}
+		}
+		req.IncludeOrg = include
+	}
+
+	if includeLabels := qp.Get("includeLabels"); includeLabels != "" {
+		include, err := strconv.ParseBool(includeLabels)
+		if err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "includeLabels must be a boolean",
+			}
+		}
+		req.ExcludeLabels = !include
+	}
+
+	return req, nil
+}
+
+// handleGetDocument is the HTTP handler for the GET /api/v2/documents/:ns/:id route.
+func (h *DocumentHandler) handleGetDocument(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodeGetDocumentOrPrefixRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	qp := r.URL.Query()
+	var render bool
+	if v := qp.Get("render"); v != "" {
+		if render, err = strconv.ParseBool(v); err != nil {
+			EncodeError(ctx, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "render must be a boolean",
+			}, w)
+			return
+		}
+	}
+
+	var renderer DocumentRenderer
+	switch format := qp.Get("format"); format {
+	case "", "json":
+	case "html":
+		if renderer = h.namespaceConfig(req.Namespace).Renderer; renderer == nil {
+			EncodeError(ctx, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  fmt.Sprintf("documents in namespace %q cannot be rendered as html", req.Namespace),
+			}, w)
+			return
+		}
+	default:
+		EncodeError(ctx, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  fmt.Sprintf("unsupported document format %q", format),
+		}, w)
+		return
+	}
+
+	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
 	if err != nil {
 		EncodeError(ctx, err, w)
 		return
 	}
 
-	ds, err := s.FindDocuments(ctx, influxdb.AuthorizedWhereID(a, req.ID), influxdb.IncludeContent, influxdb.IncludeLabels)
+	a, err := documentAuthorizer(ctx)
 	if err != nil {
 		EncodeError(ctx, err, w)
 		return
 	}
 
+	where := h.whereAuthorizedID(a, req.ID)
+	if req.IDPrefix != "" {
+		where = append([]influxdb.DocumentFindOptions{influxdb.AuthorizedWhereIDPrefix(a, req.IDPrefix)}, h.labelScope(a)...)
+	}
+
+	opts := append(where, influxdb.IncludeContent, influxdb.IncludeLabels)
+	ds, err := s.FindDocuments(ctx, opts...)
+	if err != nil {
+		encodeConflictError(ctx, err, w)
+		return
+	}
+
+	// a document outside of the label scope of the authorizer is not found.
+	if len(ds) == 0 {
+		EncodeError(ctx, &influxdb.Error{
+			Code: influxdb.ENotFound,
+			Msg:  influxdb.ErrDocumentNotFound,
+		}, w)
+		return
+	}
+
 	if len(ds) != 1 {
 		err := &influxdb.Error{
 			Code: influxdb.EInternal,
@@ -299,15 +1148,179 @@ func (h *DocumentHandler) handleGetDocument(w http.ResponseWriter, r *http.Reque
 
 	d := ds[0]
 
+	// variables are filled in before the content is rendered as html or selected by a range.
+	if render {
+		if d.Content, err = renderDocumentVariables(d.Content, documentVariables(d, qp)); err != nil {
+			EncodeError(ctx, err, w)
+			return
+		}
+	}
+
+	if renderer != nil {
+		s, err := renderer.RenderHTML(d.Content)
+		if err != nil {
+			EncodeError(ctx, err, w)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte(s)); err != nil {
+			logEncodingError(h.Logger, r, err)
+		}
+		return
+	}
+
+	etag, err := documentETag(d)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+	w.Header().Set("ETag", etag)
+
+	if d.Meta.ContentType != "" && h.namespaceConfig(req.Namespace).ServeContentType {
+		if err := encodeDocumentContent(w, d); err != nil {
+			logEncodingError(h.Logger, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	// a range selects bytes of the JSON encoded content rather than of the whole document.
+	if rh := r.Header.Get("Range"); rh != "" {
+		ok, err := encodeDocumentContentRange(ctx, w, rh, d.Content)
+		if err != nil {
+			if !ok {
+				EncodeError(ctx, err, w)
+				return
+			}
+			logEncodingError(h.Logger, r, err)
+			return
+		}
+		if ok {
+			return
+		}
+	}
+
 	if err := encodeResponse(ctx, w, http.StatusOK, newDocumentResponse(req.Namespace, d)); err != nil {
 		logEncodingError(h.Logger, r, err)
 		return
 	}
 }
 
+// ifDocumentMatch returns the options ensuring that the document still has the entity tag
+// provided, as sent with If-Match, when it is written. The tag is compared within the transaction
+// of the write, so that the document cannot be modified in between. An empty tag or * matches
+// any document.
+func ifDocumentMatch(match string) []influxdb.DocumentOptions {
+	if match == "" || match == "*" {
+		return nil
+	}
+
+	return []influxdb.DocumentOptions{func(id influxdb.ID, idx influxdb.DocumentIndex) error {
+		d, err := idx.FindDocument(id)
+		if err != nil {
+			return err
+		}
+
+		etag, err := documentETag(d)
+		if err != nil {
+			return err
+		}
+
+		if etag != match {
+			return &influxdb.Error{
+				Code: influxdb.EConflict,
+				Msg:  fmt.Sprintf("document %s has been modified; its etag does not match If-Match", id),
+			}
+		}
+
+		return nil
+	}}
+}
+
+// documentETag computes an entity tag from the documents id, meta, content and labels. The time
+// the document was last accessed is excluded so that reading a document does not change its tag.
+// Labels are included as attaching or detaching them does not change the meta of the document.
+func documentETag(d *influxdb.Document) (string, error) {
+	meta := d.Meta
+	meta.LastAccessedAt = nil
+
+	labelIDs := make([]influxdb.ID, 0, len(d.Labels))
+	for _, l := range d.Labels {
+		labelIDs = append(labelIDs, l.ID)
+	}
+	sort.Slice(labelIDs, func(i, j int) bool { return labelIDs[i] < labelIDs[j] })
+
+	// content is canonicalized so that the etag does not change with its formatting.
+	content, err := influxdb.CanonicalDocumentContent(d.Content)
+	if err != nil {
+		return "", &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  "unable to compute document etag",
+			Err:  err,
+		}
+	}
+
+	b, err := json.Marshal(struct {
+		ID      influxdb.ID           `json:"id"`
+		Meta    influxdb.DocumentMeta `json:"meta"`
+		Content json.RawMessage       `json:"content"`
+		Labels  []influxdb.ID         `json:"labels"`
+	}{
+		ID:      d.ID,
+		Meta:    meta,
+		Content: content,
+		Labels:  labelIDs,
+	})
+	if err != nil {
+		return "", &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  "unable to compute document etag",
+			Err:  err,
+		}
+	}
+
+	return fmt.Sprintf(`"%x"`, sha256.Sum256(b)), nil
+}
+
 type getDocumentRequest struct {
 	Namespace string
 	ID        influxdb.ID
+	// IDPrefix is set instead of ID when the url holds a shortened document id.
+	IDPrefix string
+}
+
+// minDocumentIDPrefix is the shortest document id prefix that may be used in place of an id.
+const minDocumentIDPrefix = 4
+
+// isDocumentIDPrefix reports whether s is a shortened, lowercase hex encoded document id.
+func isDocumentIDPrefix(s string) bool {
+	if len(s) < minDocumentIDPrefix || len(s) >= influxdb.IDLength {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeGetDocumentOrPrefixRequest decodes a request for a single document that may be
+// identified by an unambiguous prefix of its id rather than the full id.
+func decodeGetDocumentOrPrefixRequest(ctx context.Context, r *http.Request) (*getDocumentRequest, error) {
+	params := httprouter.ParamsFromContext(ctx)
+	if ns, i := params.ByName("ns"), params.ByName("id"); ns != "" && isDocumentIDPrefix(i) {
+		return &getDocumentRequest{
+			Namespace: ns,
+			IDPrefix:  i,
+		}, nil
+	}
+
+	return decodeGetDocumentRequest(ctx, r)
 }
 
 func decodeGetDocumentRequest(ctx context.Context, r *http.Request) (*getDocumentRequest, error) {
@@ -358,20 +1371,154 @@ func (h *DocumentHandler) handleDeleteDocument(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	a, err := pcontext.GetAuthorizer(ctx)
+	a, err := documentAuthorizer(ctx)
 	if err != nil {
 		EncodeError(ctx, err, w)
 		return
 	}
 
-	if err := s.DeleteDocuments(ctx, influxdb.AuthorizedWhereID(a, req.ID)); err != nil {
+	// documents outside of the label scope are not found rather than silently left in place.
+	if _, err := h.findDocumentContent(ctx, s, a, req.ID); err != nil {
 		EncodeError(ctx, err, w)
 		return
 	}
 
+	opts := append(h.whereAuthorizedID(a, req.ID), influxdb.WhereLockOwner(a.GetUserID(), req.ID))
+	if err := s.DeleteDocuments(ctx, opts...); err != nil {
+		encodeLockedError(ctx, err, w)
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
+type deleteDocumentsResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// handleDeleteDocuments is the HTTP handler for the DELETE /api/v2/documents/:ns route.
+// It deletes every document of the organization matching the filters of the request, along
+// with their label mappings. The request must be confirmed with confirm=true, unless it is a
+// dry run with dryRun=true, which responds with the documents that would be deleted instead.
+func (h *DocumentHandler) handleDeleteDocuments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodeDeleteDocumentsRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	a, err := documentAuthorizer(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	ds, err := h.findDocuments(ctx, req.getDocumentsRequest)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if !req.IncludePinned {
+		unpinned := ds[:0]
+		for _, d := range ds {
+			if !d.Meta.Pinned {
+				unpinned = append(unpinned, d)
+			}
+		}
+		ds = unpinned
+	}
+
+	if req.DryRun {
+		res := newDocumentsResponse(req.Namespace, ds)
+		if req.ExcludeLabels {
+			res.omitLabels()
+		}
+		if err := encodeResponse(ctx, w, http.StatusOK, res); err != nil {
+			logEncodingError(h.Logger, r, err)
+		}
+		return
+	}
+
+	if len(ds) > 0 {
+		opts := make([]influxdb.DocumentFindOptions, 0, len(ds))
+		for _, d := range ds {
+			opts = append(opts, h.whereAuthorizedID(a, d.ID)...)
+			opts = append(opts, influxdb.WhereLockOwner(a.GetUserID(), d.ID))
+			// documents pinned since they were found fail the delete as a whole.
+			if !req.IncludePinned {
+				opts = append(opts, influxdb.WhereNotPinned(d.ID))
+			}
+		}
+		if err := s.DeleteDocuments(ctx, opts...); err != nil {
+			encodeDocumentWriteError(ctx, err, w)
+			return
+		}
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, &deleteDocumentsResponse{Deleted: len(ds)}); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+type deleteDocumentsRequest struct {
+	*getDocumentsRequest
+	DryRun bool
+	// IncludePinned deletes pinned documents too.
+	IncludePinned bool
+}
+
+func decodeDeleteDocumentsRequest(ctx context.Context, r *http.Request) (*deleteDocumentsRequest, error) {
+	greq, err := decodeGetDocumentsRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	req := &deleteDocumentsRequest{getDocumentsRequest: greq}
+
+	qp := r.URL.Query()
+	if dryRun := qp.Get("dryRun"); dryRun != "" {
+		if req.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "dryRun must be a boolean",
+			}
+		}
+	}
+	if includePinned := qp.Get("includePinned"); includePinned != "" {
+		if req.IncludePinned, err = strconv.ParseBool(includePinned); err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "includePinned must be a boolean",
+			}
+		}
+	}
+	if req.DryRun {
+		return req, nil
+	}
+
+	// labels are not needed to delete documents.
+	req.ExcludeLabels = true
+
+	confirm, err := strconv.ParseBool(qp.Get("confirm"))
+	if err != nil || !confirm {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "deleting documents by filter must be confirmed with confirm=true",
+		}
+	}
+
+	return req, nil
+}
+
 type deleteDocumentRequest struct {
 	Namespace string
 	ID        influxdb.ID
@@ -419,20 +1566,50 @@ func (h *DocumentHandler) handlePutDocument(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if err := h.validateDocument(ctx, req.Namespace, req.Document, req.rawContent, nil); err != nil {
+		encodeDocumentValidationError(ctx, err, w)
+		return
+	}
+
+	// labels provided only replace those of the document when asked to, so that documents
+	// read with their labels, or without them, can be written back as they were read.
+	var labelIDs []influxdb.ID
+	if req.ReplaceLabels {
+		for _, l := range req.Labels {
+			if l != nil {
+				labelIDs = append(labelIDs, l.ID)
+			}
+		}
+	}
+
 	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
 	if err != nil {
 		EncodeError(ctx, err, w)
 		return
 	}
 
-	a, err := pcontext.GetAuthorizer(ctx)
+	a, err := documentAuthorizer(ctx)
 	if err != nil {
 		EncodeError(ctx, err, w)
 		return
 	}
 
-	if err := s.UpdateDocument(ctx, req.Document, influxdb.Authorized(a)); err != nil {
-		EncodeError(ctx, err, w)
+	opts := append(h.authorized(a), influxdb.WithLockOwner(a.GetUserID()))
+	if h.namespaceConfig(req.Namespace).UniqueNames {
+		opts = append(opts, influxdb.WithUniqueName(req.Meta.Name))
+	}
+	if req.ReplaceLabels {
+		opts = append(opts, influxdb.WithExactLabelIDs(labelIDs...))
+		opts = append(opts, h.labelOptions(req.Namespace)...)
+	}
+
+	req.Meta.LastWriterID = a.GetUserID()
+	if err := s.UpdateDocument(ctx, req.Document, opts...); err != nil {
+		if isDocumentLocked(err) {
+			encodeLockedError(ctx, err, w)
+			return
+		}
+		encodeConflictError(ctx, err, w)
 		return
 	}
 
@@ -462,13 +1639,19 @@ func (h *DocumentHandler) handlePutDocument(w http.ResponseWriter, r *http.Reque
 type putDocumentRequest struct {
 	*influxdb.Document
 	Namespace string `json:"-"`
+	// ReplaceLabels replaces the labels of the document with those of the request.
+	ReplaceLabels bool `json:"-"`
+
+	rawContent json.RawMessage
 }
 
 func decodePutDocumentRequest(ctx context.Context, r *http.Request) (*putDocumentRequest, error) {
 	req := &putDocumentRequest{}
-	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+	raw, err := decodeDocumentBody(r.Body, req)
+	if err != nil {
 		return nil, err
 	}
+	req.rawContent = raw
 
 	params := httprouter.ParamsFromContext(ctx)
 	i := params.ByName("id")
@@ -494,5 +1677,906 @@ func decodePutDocumentRequest(ctx context.Context, r *http.Request) (*putDocumen
 		}
 	}
 
-	return req, nil
+	if replace := r.URL.Query().Get("replaceLabels"); replace != "" {
+		if req.ReplaceLabels, err = strconv.ParseBool(replace); err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "replaceLabels must be a boolean",
+			}
+		}
+	}
+
+	return req, nil
+}
+
+// handlePostDocumentAppend is the HTTP handler for the POST /api/v2/documents/:ns/:id/append route.
+// The data is appended to the array content of the document by the store, rather than by a
+// read-modify-write of the client, so that concurrent appends are all kept.
+func (h *DocumentHandler) handlePostDocumentAppend(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodePostDocumentAppendRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	a, err := documentAuthorizer(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	d, err := s.AppendContent(ctx, req.ID, req.Data, append(h.authorized(a), influxdb.WithLockOwner(a.GetUserID()))...)
+	if err != nil {
+		encodeLockedError(ctx, err, w)
+		return
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, newDocumentResponse(req.Namespace, d)); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+type postDocumentAppendRequest struct {
+	Namespace string        `json:"-"`
+	ID        influxdb.ID   `json:"-"`
+	Data      []interface{} `json:"data"`
+}
+
+func decodePostDocumentAppendRequest(ctx context.Context, r *http.Request) (*postDocumentAppendRequest, error) {
+	req := &postDocumentAppendRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "failed to decode request body",
+			Err:  err,
+		}
+	}
+	if len(req.Data) == 0 {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "data to append must not be empty",
+		}
+	}
+
+	params := httprouter.ParamsFromContext(ctx)
+	i := params.ByName("id")
+	if i == "" {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "url missing id",
+		}
+	}
+
+	if err := req.ID.DecodeFromString(i); err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Err:  err,
+		}
+	}
+
+	req.Namespace = params.ByName("ns")
+	if req.Namespace == "" {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "url missing namespace",
+		}
+	}
+
+	return req, nil
+}
+
+// handlePostDocumentCopy is the HTTP handler for the POST /api/v2/documents/:ns/:id/copy route.
+// The copy is created with the content and labels of the original document. Its label mappings
+// are created in the same transaction as the document so that a failed copy leaves nothing behind.
+func (h *DocumentHandler) handlePostDocumentCopy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodePostDocumentCopyRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	a, err := documentAuthorizer(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	src, err := findDocumentByID(ctx, s, req.ID, h.whereAuthorizedID(a, req.ID)...)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	d := &influxdb.Document{
+		Meta: influxdb.DocumentMeta{
+			Name:         src.Meta.Name,
+			Version:      src.Meta.Version,
+			LastWriterID: a.GetUserID(),
+		},
+		Content: src.Content,
+	}
+	if req.Name != "" {
+		d.Meta.Name = req.Name
+	}
+
+	opts := []influxdb.DocumentOptions{}
+	if req.OrgID.Valid() {
+		opts = append(opts, influxdb.AuthorizedWithOrgID(a, req.OrgID))
+	} else {
+		opts = append(opts, influxdb.AuthorizedWithOrg(a, req.Org))
+	}
+	for _, l := range src.Labels {
+		opts = append(opts, influxdb.WithLabelID(l.ID))
+	}
+	if c := h.namespaceConfig(req.Namespace); c.MaxDocumentsPerOrg > 0 {
+		opts = append(opts, influxdb.WithDocumentQuota(c.MaxDocumentsPerOrg))
+	}
+
+	if err := s.CreateDocument(ctx, d, opts...); err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusCreated, newDocumentResponse(req.Namespace, d)); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+type postDocumentCopyRequest struct {
+	Namespace string      `json:"-"`
+	ID        influxdb.ID `json:"-"`
+	Name      string      `json:"name"`
+	Org       string      `json:"org"`
+	OrgID     influxdb.ID `json:"orgID,omitempty"`
+}
+
+func decodePostDocumentCopyRequest(ctx context.Context, r *http.Request) (*postDocumentCopyRequest, error) {
+	req := &postDocumentCopyRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "unable to decode copy request body",
+			Err:  err,
+		}
+	}
+
+	if req.Org == "" && !req.OrgID.Valid() {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "Please provide either org or orgID",
+		}
+	}
+
+	params := httprouter.ParamsFromContext(ctx)
+	req.Namespace = params.ByName("ns")
+	if req.Namespace == "" {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "url missing namespace",
+		}
+	}
+
+	i := params.ByName("id")
+	if i == "" {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "url missing id",
+		}
+	}
+
+	if err := req.ID.DecodeFromString(i); err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "bad id in url",
+		}
+	}
+
+	return req, nil
+}
+
+type documentLabelsResponse struct {
+	Links  *influxdb.PagingLinks `json:"links"`
+	Labels []*documentLabel      `json:"labels"`
+}
+
+type documentLabel struct {
+	*influxdb.Label
+	// AttachedAt is only set when requested with includeAttachedAt.
+	AttachedAt *time.Time `json:"attachedAt,omitempty"`
+}
+
+func newDocumentLabels(ls []*influxdb.Label) []*documentLabel {
+	dls := make([]*documentLabel, 0, len(ls))
+	for _, l := range ls {
+		dls = append(dls, &documentLabel{Label: l})
+	}
+	return dls
+}
+
+// withAttachedAt sets when each label of the response was attached.
+func (r *documentLabelsResponse) withAttachedAt(times map[influxdb.ID]time.Time) *documentLabelsResponse {
+	for _, l := range r.Labels {
+		if t, ok := times[l.ID]; ok {
+			l.AttachedAt = &t
+		}
+	}
+	return r
+}
+
+// handleGetDocumentLabel is the HTTP handler for the GET /api/v2/documents/:ns/:id/labels route.
+// All labels are returned unless a limit, offset or cursor is provided. Labels are listed as an
+// empty array when the document has none, while a missing document is not found.
+func (h *DocumentHandler) handleGetDocumentLabel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodeGetDocumentLabelRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	a, err := documentAuthorizer(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	d, err := h.findDocumentContent(ctx, s, a, req.ID)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	res := newDocumentLabelsResponse(req, d.Labels)
+	if req.IncludeAttachedAt {
+		f, ok := s.(influxdb.DocumentLabelAttachedAtFinder)
+		if !ok {
+			EncodeError(ctx, &influxdb.Error{
+				Code: influxdb.EMethodNotAllowed,
+				Msg:  "document store does not record when labels are attached",
+			}, w)
+			return
+		}
+
+		times, err := f.FindDocumentLabelsAttachedAt(ctx, req.ID)
+		if err != nil {
+			EncodeError(ctx, err, w)
+			return
+		}
+		res.withAttachedAt(times)
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, res); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+func newDocumentLabelsResponse(req *getDocumentLabelRequest, ls []*influxdb.Label) *documentLabelsResponse {
+	basePath := fmt.Sprintf("/api/v2/documents/%s/%s/labels", req.Namespace, req.ID)
+	if req.FindOptions == nil {
+		return &documentLabelsResponse{
+			Links:  &influxdb.PagingLinks{Self: basePath},
+			Labels: newDocumentLabels(ls),
+		}
+	}
+
+	opts := *req.FindOptions
+	if req.After.Valid() {
+		for i, l := range ls {
+			if l.ID == req.After {
+				opts.Offset = i + 1
+				break
+			}
+		}
+	}
+
+	page := []*influxdb.Label{}
+	if opts.Offset < len(ls) {
+		end := opts.Offset + opts.Limit
+		if end > len(ls) {
+			end = len(ls)
+		}
+		page = append(page, ls[opts.Offset:end]...)
+	}
+
+	var last influxdb.ID
+	if len(page) > 0 {
+		last = page[len(page)-1].ID
+	}
+
+	return &documentLabelsResponse{
+		Links:  newDocumentCursorLinks(basePath, documentLabelsFilter{}, opts.Offset, opts.Limit, len(page), len(ls), last),
+		Labels: newDocumentLabels(page),
+	}
+}
+
+// documentLabelsFilter implements influxdb.PagingFilter for the document labels route, which
+// does not support any filters.
+type documentLabelsFilter struct{}
+
+func (documentLabelsFilter) QueryParams() map[string][]string {
+	return map[string][]string{}
+}
+
+type getDocumentLabelRequest struct {
+	Namespace   string
+	ID          influxdb.ID
+	FindOptions *influxdb.FindOptions
+	// After is the last label of the previous page when paging with a cursor.
+	After influxdb.ID
+	// IncludeAttachedAt includes when each label was attached.
+	IncludeAttachedAt bool
+}
+
+func decodeGetDocumentLabelRequest(ctx context.Context, r *http.Request) (*getDocumentLabelRequest, error) {
+	dr, err := decodeGetDocumentRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &getDocumentLabelRequest{
+		Namespace: dr.Namespace,
+		ID:        dr.ID,
+	}
+
+	qp := r.URL.Query()
+	if include := qp.Get("includeAttachedAt"); include != "" {
+		if req.IncludeAttachedAt, err = strconv.ParseBool(include); err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "includeAttachedAt must be a boolean",
+			}
+		}
+	}
+
+	if token := qp.Get("cursor"); token != "" {
+		if qp.Get("limit") != "" || qp.Get("offset") != "" {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "cursor cannot be combined with limit or offset",
+			}
+		}
+
+		c, err := decodeDocumentCursor(token, documentLabelsFilter{})
+		if err != nil {
+			return nil, err
+		}
+		if c.Limit == 0 {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "invalid cursor: cursor was not issued for labels",
+			}
+		}
+		req.FindOptions = &influxdb.FindOptions{Offset: c.Offset, Limit: c.Limit}
+		req.After = c.After
+
+		return req, nil
+	}
+
+	if qp.Get("limit") == "" && qp.Get("offset") == "" {
+		return req, nil
+	}
+
+	opts, err := decodeFindOptions(ctx, r)
+	if err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "invalid paging parameters",
+			Err:  err,
+		}
+	}
+	if opts.Offset < 0 {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "offset must not be negative",
+		}
+	}
+	req.FindOptions = opts
+
+	return req, nil
+}
+
+func (h *DocumentHandler) handlePostDocumentAction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	params := httprouter.ParamsFromContext(ctx)
+	switch params.ByName("id") {
+	case "reindex":
+		h.handlePostDocumentReindex(w, r)
+	default:
+		EncodeError(ctx, &influxdb.Error{
+			Code: influxdb.ENotFound,
+			Msg:  "path not found",
+		}, w)
+	}
+}
+
+type documentReindexResponse struct {
+	Reindexed int `json:"reindexed"`
+}
+
+// handlePostDocumentReindex is the HTTP handler for the POST /api/v2/documents/:ns/reindex route.
+// It rebuilds the label index of the namespace and is restricted to operators.
+func (h *DocumentHandler) handlePostDocumentReindex(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	ns := httprouter.ParamsFromContext(ctx).ByName("ns")
+	if ns == "" {
+		EncodeError(ctx, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "url missing namespace",
+		}, w)
+		return
+	}
+
+	if err := authorizeDocumentOperator(ctx); err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	indexer, ok := h.DocumentService.(influxdb.DocumentLabelIndexer)
+	if !ok {
+		EncodeError(ctx, &influxdb.Error{
+			Code: influxdb.EMethodNotAllowed,
+			Msg:  "document service does not support reindexing",
+		}, w)
+		return
+	}
+
+	n, err := indexer.ReindexDocumentLabels(ctx, ns)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, &documentReindexResponse{Reindexed: n}); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+// authorizeDocumentOperator ensures that the request is made by an operator, who holds every
+// permission across organizations, as migrations require.
+func authorizeDocumentOperator(ctx context.Context) error {
+	if _, err := documentAuthorizer(ctx); err != nil {
+		return err
+	}
+
+	return authorizer.IsAllowedAll(ctx, influxdb.OperPermissions())
+}
+
+// handleGetDocumentDiff is the HTTP handler for the GET /api/v2/documents/:ns/:id/diff route.
+// It responds with a unified diff from the content of the document to that of the document
+// provided by the against parameter.
+func (h *DocumentHandler) handleGetDocumentDiff(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodeGetDocumentDiffRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	a, err := documentAuthorizer(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	from, err := h.findDocumentContent(ctx, s, a, req.ID)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	to, err := h.findDocumentContent(ctx, s, a, req.Against)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	hs, err := documentContentHunks(from.Content, to.Content)
+	if err != nil {
+		EncodeError(ctx, &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  "failed to diff document content",
+			Err:  err,
+		}, w)
+		return
+	}
+
+	if req.Format == "json" {
+		res := &documentDiffResponse{From: req.ID, To: req.Against, Hunks: hs}
+		if err := encodeResponse(ctx, w, http.StatusOK, res); err != nil {
+			logEncodingError(h.Logger, r, err)
+		}
+		return
+	}
+
+	var diff string
+	if len(hs) > 0 {
+		diff = unifiedDiff(req.ID.String(), req.Against.String(), hs)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(diff)); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+// findDocumentContent finds the document with its content if the authorizer may access it,
+// naming the document if it does not exist.
+func (h *DocumentHandler) findDocumentContent(ctx context.Context, s influxdb.DocumentStore, a influxdb.Authorizer, id influxdb.ID) (*influxdb.Document, error) {
+	d, err := findDocumentByID(ctx, s, id, h.whereAuthorizedID(a, id)...)
+	if influxdb.ErrorCode(err) == influxdb.ENotFound {
+		return nil, &influxdb.Error{
+			Code: influxdb.ENotFound,
+			Msg:  fmt.Sprintf("document %s not found", id),
+			Err:  err,
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// findDocumentByID finds the document with its content and labels. Stores that cannot find a
+// single document by ID list it instead.
+func findDocumentByID(ctx context.Context, s influxdb.DocumentStore, id influxdb.ID, opts ...influxdb.DocumentFindOptions) (*influxdb.Document, error) {
+	if f, ok := s.(influxdb.DocumentByIDFinder); ok {
+		return f.FindDocumentByID(ctx, id, opts...)
+	}
+
+	opts = append(opts, influxdb.IncludeContent, influxdb.IncludeLabels)
+	ds, err := s.FindDocuments(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(ds) {
+	case 0:
+		return nil, &influxdb.Error{
+			Code: influxdb.ENotFound,
+			Msg:  influxdb.ErrDocumentNotFound,
+		}
+	case 1:
+		return ds[0], nil
+	default:
+		return nil, &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  fmt.Sprintf("found more than one document with id %s; please report this error", id),
+		}
+	}
+}
+
+type getDocumentDiffRequest struct {
+	Namespace string
+	ID        influxdb.ID
+	Against   influxdb.ID
+	// Format is text for a unified diff, or json for the hunks of the diff.
+	Format string
+}
+
+// documentDiffResponse is the diff of the content of two documents, as requested with format=json.
+type documentDiffResponse struct {
+	From  influxdb.ID         `json:"from"`
+	To    influxdb.ID         `json:"to"`
+	Hunks []*documentDiffHunk `json:"hunks"`
+}
+
+func decodeGetDocumentDiffRequest(ctx context.Context, r *http.Request) (*getDocumentDiffRequest, error) {
+	dr, err := decodeGetDocumentRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &getDocumentDiffRequest{
+		Namespace: dr.Namespace,
+		ID:        dr.ID,
+	}
+
+	against := r.URL.Query().Get("against")
+	if against == "" {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "url missing against",
+		}
+	}
+	if err := req.Against.DecodeFromString(against); err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "invalid against id",
+			Err:  err,
+		}
+	}
+
+	switch req.Format = r.URL.Query().Get("format"); req.Format {
+	case "", "text", "json":
+	default:
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  fmt.Sprintf("unsupported diff format %q", req.Format),
+		}
+	}
+
+	return req, nil
+}
+
+// handlePostDocumentLabel is the HTTP handler for the POST /api/v2/documents/:ns/:id/labels route.
+// Either a single label or a batch of labels may be attached. A batch is attached atomically.
+func (h *DocumentHandler) handlePostDocumentLabel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodePostDocumentLabelRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	a, err := documentAuthorizer(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	d, err := h.findDocumentContent(ctx, s, a, req.ID)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if !req.Atomic {
+		h.attachDocumentLabels(w, r, req, s, a, d)
+		return
+	}
+
+	// labels are only looked up once the document is known to be readable, so that whether a
+	// label exists is not revealed to requests that cannot access the document.
+	if err := ensureLabelsExist(ctx, h.LabelService, req.LabelIDs); err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	opts := append(h.authorized(a), influxdb.WithLockOwner(a.GetUserID()))
+	opts = append(opts, ifDocumentMatch(r.Header.Get("If-Match"))...)
+	for _, id := range req.LabelIDs {
+		opts = append(opts, influxdb.WithLabelID(id))
+	}
+	opts = append(opts, h.labelOptions(req.Namespace)...)
+	d.Labels, err = updateDocumentLabels(ctx, s, d.ID, opts...)
+	if err != nil {
+		encodeDocumentWriteError(ctx, err, w)
+		return
+	}
+
+	etag, err := documentETag(d)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+	w.Header().Set("ETag", etag)
+
+	res := newDocumentLabelsResponse(&getDocumentLabelRequest{Namespace: req.Namespace, ID: req.ID}, d.Labels)
+	if err := encodeResponse(ctx, w, http.StatusCreated, res); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+type postDocumentLabelRequest struct {
+	Namespace string
+	ID        influxdb.ID
+	LabelIDs  []influxdb.ID
+	// Atomic attaches either every label or none. Otherwise each label is attached on its
+	// own and the outcome of each is reported.
+	Atomic bool
+}
+
+func decodePostDocumentLabelRequest(ctx context.Context, r *http.Request) (*postDocumentLabelRequest, error) {
+	dr, err := decodeGetDocumentRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	body := struct {
+		LabelID  *influxdb.ID  `json:"labelID"`
+		LabelIDs []influxdb.ID `json:"labelIDs"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "invalid label request body",
+			Err:  err,
+		}
+	}
+
+	req := &postDocumentLabelRequest{
+		Namespace: dr.Namespace,
+		ID:        dr.ID,
+		LabelIDs:  body.LabelIDs,
+		Atomic:    true,
+	}
+	if atomic := r.URL.Query().Get("atomic"); atomic != "" {
+		b, err := strconv.ParseBool(atomic)
+		if err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "atomic must be a boolean",
+			}
+		}
+		req.Atomic = b
+	}
+	if body.LabelID != nil {
+		req.LabelIDs = append([]influxdb.ID{*body.LabelID}, req.LabelIDs...)
+	}
+
+	if len(req.LabelIDs) == 0 {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "one of labelID and labelIDs must be provided",
+		}
+	}
+
+	return req, nil
+}
+
+// encodeDocumentWriteError encodes an error writing a document, with 423 Locked if the
+// document is locked by another user, and 409 Conflict for any other conflict.
+func encodeDocumentWriteError(ctx context.Context, err error, w http.ResponseWriter) {
+	if isDocumentLocked(err) {
+		encodeLockedError(ctx, err, w)
+		return
+	}
+	encodeConflictError(ctx, err, w)
+}
+
+// updateDocumentLabels applies options attaching or detaching labels to the document, and
+// returns the labels of the document once they are applied. Only the label mappings are
+// changed, so that changes made to the document concurrently are not undone.
+func updateDocumentLabels(ctx context.Context, s influxdb.DocumentStore, id influxdb.ID, opts ...influxdb.DocumentOptions) ([]*influxdb.Label, error) {
+	ls, ok := s.(influxdb.DocumentLabeler)
+	if !ok {
+		return nil, &influxdb.Error{
+			Code: influxdb.EMethodNotAllowed,
+			Msg:  "document store does not support changing labels",
+		}
+	}
+
+	return ls.UpdateDocumentLabels(ctx, id, opts...)
+}
+
+// handleDeleteDocumentLabel is the HTTP handler for the DELETE /api/v2/documents/:ns/:id/labels/:lid route.
+func (h *DocumentHandler) handleDeleteDocumentLabel(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	req, err := decodeDeleteDocumentLabelRequest(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	s, err := h.DocumentService.FindDocumentStore(ctx, req.Namespace)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	a, err := documentAuthorizer(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	d, err := h.findDocumentContent(ctx, s, a, req.ID)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	opts := append(h.authorized(a), influxdb.WithLockOwner(a.GetUserID()))
+	opts = append(opts, ifDocumentMatch(r.Header.Get("If-Match"))...)
+	opts = append(opts, influxdb.WithoutLabelID(req.LabelID))
+	d.Labels, err = updateDocumentLabels(ctx, s, d.ID, append(opts, h.labelOptions(req.Namespace)...)...)
+	if err != nil {
+		encodeDocumentWriteError(ctx, err, w)
+		return
+	}
+
+	etag, err := documentETag(d)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+	w.Header().Set("ETag", etag)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type deleteDocumentLabelRequest struct {
+	Namespace string
+	ID        influxdb.ID
+	LabelID   influxdb.ID
+}
+
+func decodeDeleteDocumentLabelRequest(ctx context.Context, r *http.Request) (*deleteDocumentLabelRequest, error) {
+	dr, err := decodeGetDocumentRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	params := httprouter.ParamsFromContext(ctx)
+	lid := params.ByName("lid")
+	if lid == "" {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "url missing label id",
+		}
+	}
+
+	var labelID influxdb.ID
+	if err := labelID.DecodeFromString(lid); err != nil {
+		return nil, err
+	}
+
+	return &deleteDocumentLabelRequest{
+		Namespace: dr.Namespace,
+		ID:        dr.ID,
+		LabelID:   labelID,
+	}, nil
+}
+
+// labelOptions returns the options enforcing the label settings of the namespace provided on
+// a document, applied after the options attaching or detaching its labels, in the same
+// transaction, so that concurrent label changes cannot together exceed them.
+func (h *DocumentHandler) labelOptions(ns string) []influxdb.DocumentOptions {
+	c := h.namespaceConfig(ns)
+
+	var opts []influxdb.DocumentOptions
+	if c.MaxLabelsPerDocument > 0 {
+		opts = append(opts, influxdb.WithMaxLabels(c.MaxLabelsPerDocument))
+	}
+	if c.RequireLabels {
+		opts = append(opts, influxdb.WithRequiredLabels)
+	}
+	return opts
 }
@@ -2,13 +2,27 @@ package http
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/bolt"
 	pcontext "github.com/influxdata/influxdb/context"
+	"github.com/influxdata/influxdb/kv"
 	"github.com/influxdata/influxdb/mock"
 	influxtesting "github.com/influxdata/influxdb/testing"
 	"github.com/julienschmidt/httprouter"
@@ -21,6 +35,8 @@ func NewMockDocumentBackend() *DocumentBackend {
 		Logger: zap.NewNop().With(zap.String("handler", "document")),
 
 		DocumentService: mock.NewDocumentService(),
+		LabelService:    mock.NewLabelService(),
+		Schemas:         DefaultDocumentSchemas(),
 	}
 }
 
@@ -95,7 +111,9 @@ func TestService_handleGetDocuments(t *testing.T) {
 									{
 										ID: influxtesting.MustIDBase16("020f755c3c082010"),
 										Meta: influxdb.DocumentMeta{
-											Name: "doc1",
+											Name:      "doc1",
+											CreatedAt: time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC),
+											UpdatedAt: time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC),
 										},
 										Content: "content1",
 										Labels: []*influxdb.Label{
@@ -107,7 +125,9 @@ func TestService_handleGetDocuments(t *testing.T) {
 									{
 										ID: influxtesting.MustIDBase16("020f755c3c082011"),
 										Meta: influxdb.DocumentMeta{
-											Name: "doc2",
+											Name:      "doc2",
+											CreatedAt: time.Date(2019, 3, 2, 0, 0, 0, 0, time.UTC),
+											UpdatedAt: time.Date(2019, 3, 2, 0, 0, 0, 0, time.UTC),
 										},
 										Content: "content2",
 									},
@@ -140,7 +160,9 @@ func TestService_handleGetDocuments(t *testing.T) {
                     			}
                   			],
 							"meta": {
-								"name": "doc1"
+								"name": "doc1",
+								"createdAt": "2019-03-01T00:00:00Z",
+								"updatedAt": "2019-03-01T00:00:00Z"
 							}
 						},
 						{
@@ -148,9 +170,12 @@ func TestService_handleGetDocuments(t *testing.T) {
 							"links": {
 								"self": "/api/v2/documents/template/020f755c3c082011"
 							},
-							"content": "content2", 
+							"content": "content2",
+							"labels": [],
 							"meta": {
-								"name": "doc2"
+								"name": "doc2",
+								"createdAt": "2019-03-02T00:00:00Z",
+								"updatedAt": "2019-03-02T00:00:00Z"
 							}
 						}
 					]
@@ -168,7 +193,9 @@ func TestService_handleGetDocuments(t *testing.T) {
 									{
 										ID: influxtesting.MustIDBase16("020f755c3c082010"),
 										Meta: influxdb.DocumentMeta{
-											Name: "doc1",
+											Name:      "doc1",
+											CreatedAt: time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC),
+											UpdatedAt: time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC),
 										},
 										Content: "content1",
 										Labels: []*influxdb.Label{
@@ -180,7 +207,9 @@ func TestService_handleGetDocuments(t *testing.T) {
 									{
 										ID: influxtesting.MustIDBase16("020f755c3c082011"),
 										Meta: influxdb.DocumentMeta{
-											Name: "doc2",
+											Name:      "doc2",
+											CreatedAt: time.Date(2019, 3, 2, 0, 0, 0, 0, time.UTC),
+											UpdatedAt: time.Date(2019, 3, 2, 0, 0, 0, 0, time.UTC),
 										},
 										Content: "content2",
 									},
@@ -213,7 +242,9 @@ func TestService_handleGetDocuments(t *testing.T) {
                     			}
                   			],
 							"meta": {
-								"name": "doc1"
+								"name": "doc1",
+								"createdAt": "2019-03-01T00:00:00Z",
+								"updatedAt": "2019-03-01T00:00:00Z"
 							}
 						},
 						{
@@ -221,9 +252,12 @@ func TestService_handleGetDocuments(t *testing.T) {
 							"links": {
 								"self": "/api/v2/documents/template/020f755c3c082011"
 							},
-							"content": "content2", 
+							"content": "content2",
+							"labels": [],
 							"meta": {
-								"name": "doc2"
+								"name": "doc2",
+								"createdAt": "2019-03-02T00:00:00Z",
+								"updatedAt": "2019-03-02T00:00:00Z"
 							}
 						}
 					]
@@ -270,3 +304,6018 @@ func TestService_handleGetDocuments(t *testing.T) {
 		})
 	}
 }
+
+func TestService_handlePostDocument_Schema(t *testing.T) {
+	type wants struct {
+		statusCode int
+		body       string
+	}
+
+	tests := []struct {
+		name  string
+		body  string
+		wants wants
+	}{
+		{
+			name: "valid template",
+			body: `{
+				"meta": {"name": "t1"},
+				"orgID": "020f755c3c082002",
+				"content": {
+					"data": {"type": "dashboard", "attributes": {"name": "d1"}},
+					"included": [{"type": "cell", "attributes": {}}]
+				}
+			}`,
+			wants: wants{
+				statusCode: http.StatusCreated,
+			},
+		},
+		{
+			name: "template missing data type",
+			body: `{
+				"meta": {"name": "t1"},
+				"orgID": "020f755c3c082002",
+				"content": {
+					"data": {"attributes": {"name": "d1"}}
+				}
+			}`,
+			wants: wants{
+				statusCode: http.StatusUnprocessableEntity,
+				body:       `{"code":"unprocessable entity","message":"document content does not match schema at #/properties/data/required: missing required property \"type\"","errors":[{"field":"content","message":"document content does not match schema at #/properties/data/required: missing required property \"type\""}]}`,
+			},
+		},
+		{
+			name: "template with invalid included resource",
+			body: `{
+				"meta": {"name": "t1"},
+				"orgID": "020f755c3c082002",
+				"content": {
+					"data": {"type": "dashboard", "attributes": {}},
+					"included": [{"type": "cell"}, {"type": 1}]
+				}
+			}`,
+			wants: wants{
+				statusCode: http.StatusUnprocessableEntity,
+				body:       `{"code":"unprocessable entity","message":"document content does not match schema at #/properties/included/items/1/properties/type/type: expected string but found number","errors":[{"field":"content","message":"document content does not match schema at #/properties/included/items/1/properties/type/type: expected string but found number"}]}`,
+			},
+		},
+		{
+			name: "template with many problems",
+			body: `{
+				"meta": {"name": ""},
+				"orgID": "020f755c3c082002",
+				"labels": ["missing"],
+				"content": {
+					"data": {"attributes": 1},
+					"included": [{"type": 1}]
+				}
+			}`,
+			wants: wants{
+				statusCode: http.StatusUnprocessableEntity,
+				body: `{"code":"unprocessable entity","message":"document has 5 problems","errors":[
+					{"field":"meta.name","message":"document name must not be empty"},
+					{"field":"content","message":"document content does not match schema at #/properties/data/required: missing required property \"type\""},
+					{"field":"content","message":"document content does not match schema at #/properties/data/properties/attributes/type: expected object but found number"},
+					{"field":"content","message":"document content does not match schema at #/properties/included/items/0/properties/type/type: expected string but found number"},
+					{"field":"labels","message":"label \"missing\" not found"}
+				]}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created bool
+			documentBackend := NewMockDocumentBackend()
+			documentBackend.DocumentService = &mock.DocumentService{
+				FindDocumentStoreFn: func(context.Context, string) (influxdb.DocumentStore, error) {
+					return &mock.DocumentStore{
+						CreateDocumentFn: func(ctx context.Context, d *influxdb.Document, opts ...influxdb.DocumentOptions) error {
+							created = true
+							d.ID = influxtesting.MustIDBase16("020f755c3c082010")
+							return nil
+						},
+					}, nil
+				},
+			}
+			h := NewDocumentHandler(documentBackend)
+			r := httptest.NewRequest("POST", "http://any.url", strings.NewReader(tt.body))
+			r = r.WithContext(pcontext.SetAuthorizer(r.Context(), &influxdb.Session{UserID: influxtesting.MustIDBase16("020f755c3c082001")}))
+			r = r.WithContext(context.WithValue(r.Context(),
+				httprouter.ParamsKey,
+				httprouter.Params{
+					{
+						Key:   "ns",
+						Value: "templates",
+					}}))
+			w := httptest.NewRecorder()
+			h.handlePostDocument(w, r)
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+
+			if res.StatusCode != tt.wants.statusCode {
+				t.Errorf("%q. handlePostDocument() = %v, want %v", tt.name, res.StatusCode, tt.wants.statusCode)
+			}
+			if eq, diff, _ := jsonEqual(string(body), tt.wants.body); tt.wants.body != "" && !eq {
+				t.Errorf("%q. handlePostDocument() = ***%s***", tt.name, diff)
+			}
+			if exp := tt.wants.statusCode == http.StatusCreated; created != exp {
+				t.Errorf("%q. document created = %v, want %v", tt.name, created, exp)
+			}
+		})
+	}
+}
+
+func TestService_handlePostDocument_SchemaEnum(t *testing.T) {
+	schema, err := NewDocumentSchema([]byte(`{
+		"properties": {
+			"data": {"enum": [{"type": "dashboard"}, ["cell"]]}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		content    string
+		statusCode int
+	}{
+		{
+			name:       "allowed object",
+			content:    `{"data": {"type": "dashboard"}}`,
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "allowed array",
+			content:    `{"data": ["cell"]}`,
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "object that is not allowed",
+			content:    `{"data": {"type": "view"}}`,
+			statusCode: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documentBackend := NewMockDocumentBackend()
+			documentBackend.DocumentService = &mock.DocumentService{
+				FindDocumentStoreFn: func(context.Context, string) (influxdb.DocumentStore, error) {
+					return &mock.DocumentStore{
+						CreateDocumentFn: func(ctx context.Context, d *influxdb.Document, opts ...influxdb.DocumentOptions) error {
+							d.ID = influxtesting.MustIDBase16("020f755c3c082010")
+							return nil
+						},
+					}, nil
+				},
+			}
+			h := NewDocumentHandler(documentBackend)
+			h.Schemas = map[string]*DocumentSchema{"templates": schema}
+			body := `{"meta": {"name": "t1"}, "orgID": "020f755c3c082002", "content": ` + tt.content + `}`
+			r := httptest.NewRequest("POST", "http://any.url", strings.NewReader(body))
+			r = r.WithContext(pcontext.SetAuthorizer(r.Context(), &influxdb.Session{UserID: influxtesting.MustIDBase16("020f755c3c082001")}))
+			r = r.WithContext(context.WithValue(r.Context(),
+				httprouter.ParamsKey,
+				httprouter.Params{
+					{
+						Key:   "ns",
+						Value: "templates",
+					}}))
+			w := httptest.NewRecorder()
+			h.handlePostDocument(w, r)
+			res := w.Result()
+
+			if res.StatusCode != tt.statusCode {
+				body, _ := ioutil.ReadAll(res.Body)
+				t.Errorf("handlePostDocument() = %v, want %v: %s", res.StatusCode, tt.statusCode, body)
+			}
+		})
+	}
+}
+
+func TestService_documentETag(t *testing.T) {
+	accessed := time.Date(2019, 3, 2, 0, 0, 0, 0, time.UTC)
+	d1 := &influxdb.Document{
+		ID: influxtesting.MustIDBase16("020f755c3c082010"),
+		Meta: influxdb.DocumentMeta{
+			Name:      "doc1",
+			UpdatedAt: time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Content: "content1",
+	}
+	d2 := *d1
+	d2.Meta.LastAccessedAt = &accessed
+	d3 := *d1
+	d3.Content = "content2"
+
+	etag := func(d *influxdb.Document) string {
+		t.Helper()
+		e, err := documentETag(d)
+		if err != nil {
+			t.Fatalf("unexpected error computing etag: %v", err)
+		}
+		return e
+	}
+
+	if etag(d1) != etag(&d2) {
+		t.Errorf("expected last accessed at to be excluded from the etag")
+	}
+	if etag(d1) == etag(&d3) {
+		t.Errorf("expected content to change the etag")
+	}
+
+	d4 := *d1
+	d4.Content = "{\"b\": [1, 2], \"a\": {\"c\": true}}"
+	d5 := *d1
+	d5.Content = map[string]interface{}{"a": map[string]interface{}{"c": true}, "b": []interface{}{1, 2}}
+	if etag(&d4) != etag(&d5) {
+		t.Errorf("expected content that only differs in formatting to have the same etag")
+	}
+
+	l1 := &influxdb.Label{ID: influxtesting.MustIDBase16("020f755c3c082011")}
+	l2 := &influxdb.Label{ID: influxtesting.MustIDBase16("020f755c3c082012")}
+	d6 := *d1
+	d6.Labels = []*influxdb.Label{l1, l2}
+	d7 := *d1
+	d7.Labels = []*influxdb.Label{l2, l1}
+	if etag(d1) == etag(&d6) {
+		t.Errorf("expected labels to change the etag")
+	}
+	if etag(&d6) != etag(&d7) {
+		t.Errorf("expected the order of labels not to change the etag")
+	}
+}
+
+// newTestDocumentService returns an initialized kv service backed by a transactional bolt store.
+func newTestDocumentService(t *testing.T) (*kv.Service, func()) {
+	t.Helper()
+
+	f, err := ioutil.TempFile("", "influxdata-bolt-")
+	if err != nil {
+		t.Fatalf("unable to open temporary boltdb file: %v", err)
+	}
+	f.Close()
+
+	store := bolt.NewKVStore(f.Name())
+	if err := store.Open(context.Background()); err != nil {
+		t.Fatalf("failed to open bolt kv store: %v", err)
+	}
+	closeStore := func() {
+		store.Close()
+		os.Remove(f.Name())
+	}
+
+	svc := kv.NewService(store)
+	if err := svc.Initialize(context.Background()); err != nil {
+		closeStore()
+		t.Fatalf("failed to initialize kv service: %v", err)
+	}
+
+	return svc, closeStore
+}
+
+// newDocumentRequest returns a request for the document handler with the authorizer
+// and route params provided.
+func newDocumentRequest(method, target, body string, a influxdb.Authorizer, params ...httprouter.Param) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r = r.WithContext(pcontext.SetAuthorizer(r.Context(), a))
+	return r.WithContext(context.WithValue(r.Context(), httprouter.ParamsKey, httprouter.Params(params)))
+}
+
+func TestService_handlePostDocumentCopy(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l1 := &influxdb.Label{Name: "l1"}
+	l2 := &influxdb.Label{Name: "l2"}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &influxdb.Document{
+		Meta:    influxdb.DocumentMeta{Name: "src"},
+		Content: map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}},
+	}
+	if err := s.CreateDocument(ctx, src, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(l1.ID), influxdb.WithLabelID(l2.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	documentBackend := NewMockDocumentBackend()
+	documentBackend.DocumentService = svc
+	h := NewDocumentHandler(documentBackend)
+
+	t.Run("copy recreates label mappings", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("POST", "http://any.url", fmt.Sprintf(`{"orgID":%q,"name":"copy"}`, o.ID), auth,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: src.ID.String()})
+		h.handlePostDocumentCopy(w, r)
+
+		res := w.Result()
+		body, _ := ioutil.ReadAll(res.Body)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("handlePostDocumentCopy() = %v, want %v: %s", res.StatusCode, http.StatusCreated, body)
+		}
+
+		var resp documentResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resp.ID == src.ID {
+			t.Fatalf("expected copy to have a new id")
+		}
+		if resp.Meta.Name != "copy" {
+			t.Errorf("expected copy to be named %q, got %q", "copy", resp.Meta.Name)
+		}
+
+		ls, err := svc.FindResourceLabels(ctx, influxdb.LabelMappingFilter{
+			ResourceID:   resp.ID,
+			ResourceType: influxdb.DocumentsResourceType,
+		})
+		if err != nil {
+			t.Fatalf("failed to find copy labels: %v", err)
+		}
+		if len(ls) != 2 || ls[0].ID != l1.ID || ls[1].ID != l2.ID {
+			t.Errorf("expected copy to have label mappings for l1 and l2, got %v", ls)
+		}
+	})
+
+	t.Run("failed copy leaves nothing behind", func(t *testing.T) {
+		before, err := s.FindDocuments(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("POST", "http://any.url", `{"org":"missing"}`, auth,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: src.ID.String()})
+		h.handlePostDocumentCopy(w, r)
+
+		if res := w.Result(); res.StatusCode != http.StatusNotFound {
+			t.Errorf("handlePostDocumentCopy() = %v, want %v", res.StatusCode, http.StatusNotFound)
+		}
+
+		after, err := s.FindDocuments(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(after) != len(before) {
+			t.Errorf("expected failed copy to not create a document, had %d now %d", len(before), len(after))
+		}
+	})
+}
+
+func TestService_handleGetDocumentLabel(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	opts := []influxdb.DocumentOptions{influxdb.WithOrgID(o.ID)}
+	var labels []*influxdb.Label
+	for i := 0; i < 5; i++ {
+		l := &influxdb.Label{Name: fmt.Sprintf("l%d", i)}
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+		labels = append(labels, l)
+		opts = append(opts, influxdb.WithLabelID(l.ID))
+	}
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{
+		Meta:    influxdb.DocumentMeta{Name: "d"},
+		Content: map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}},
+	}
+	if err := s.CreateDocument(ctx, d, opts...); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	basePath := fmt.Sprintf("/api/v2/documents/templates/%s/labels", d.ID)
+	cursorLink := func(offset, limit int, after influxdb.ID) string {
+		return basePath + "?cursor=" + encodeDocumentCursor(documentCursor{Offset: offset, Limit: limit, After: after})
+	}
+	tests := []struct {
+		name   string
+		query  string
+		labels []*influxdb.Label
+		links  influxdb.PagingLinks
+	}{
+		{
+			name:   "all labels by default",
+			labels: labels,
+			links:  influxdb.PagingLinks{Self: basePath},
+		},
+		{
+			name:   "first page",
+			query:  "?limit=2",
+			labels: labels[:2],
+			links: influxdb.PagingLinks{
+				Self: cursorLink(0, 2, 0),
+				Next: cursorLink(2, 2, labels[1].ID),
+			},
+		},
+		{
+			name:   "middle page",
+			query:  "?limit=2&offset=2",
+			labels: labels[2:4],
+			links: influxdb.PagingLinks{
+				Prev: cursorLink(0, 2, 0),
+				Self: cursorLink(2, 2, 0),
+				Next: cursorLink(4, 2, labels[3].ID),
+			},
+		},
+		{
+			name:   "last page",
+			query:  "?limit=2&offset=4",
+			labels: labels[4:],
+			links: influxdb.PagingLinks{
+				Prev: cursorLink(2, 2, 0),
+				Self: cursorLink(4, 2, 0),
+			},
+		},
+		{
+			name:   "exactly full last page",
+			query:  "?limit=1&offset=4",
+			labels: labels[4:],
+			links: influxdb.PagingLinks{
+				Prev: cursorLink(3, 1, 0),
+				Self: cursorLink(4, 1, 0),
+			},
+		},
+		{
+			name:   "cursor",
+			query:  "?cursor=" + encodeDocumentCursor(documentCursor{Offset: 2, Limit: 2}),
+			labels: labels[2:4],
+			links: influxdb.PagingLinks{
+				Prev: cursorLink(0, 2, 0),
+				Self: cursorLink(2, 2, 0),
+				Next: cursorLink(4, 2, labels[3].ID),
+			},
+		},
+		{
+			name:   "cursor resumes after the last label of the previous page",
+			query:  "?cursor=" + encodeDocumentCursor(documentCursor{Offset: 1, Limit: 2, After: labels[2].ID}),
+			labels: labels[3:5],
+			links: influxdb.PagingLinks{
+				Prev: cursorLink(1, 2, 0),
+				Self: cursorLink(3, 2, 0),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := newDocumentRequest("GET", "http://any.url"+basePath+tt.query, "", auth,
+				httprouter.Param{Key: "ns", Value: "templates"},
+				httprouter.Param{Key: "id", Value: d.ID.String()})
+			h.handleGetDocumentLabel(w, r)
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("handleGetDocumentLabel() = %v, want %v: %s", res.StatusCode, http.StatusOK, body)
+			}
+
+			var resp documentLabelsResponse
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if *resp.Links != tt.links {
+				t.Errorf("handleGetDocumentLabel() links = %+v, want %+v", *resp.Links, tt.links)
+			}
+			if len(resp.Labels) != len(tt.labels) {
+				t.Fatalf("handleGetDocumentLabel() returned %d labels, want %d", len(resp.Labels), len(tt.labels))
+			}
+			for i := range tt.labels {
+				if resp.Labels[i].ID != tt.labels[i].ID {
+					t.Errorf("handleGetDocumentLabel() label %d = %s, want %s", i, resp.Labels[i].ID, tt.labels[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestService_handleGetDocumentLabel_AttachedAt(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc.WithTime(func() time.Time { return now })
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l1 := &influxdb.Label{Name: "l1"}
+	l2 := &influxdb.Label{Name: "l2"}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{
+		Meta:    influxdb.DocumentMeta{Name: "d"},
+		Content: map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}},
+	}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(l1.ID)); err != nil {
+		t.Fatal(err)
+	}
+	attached1 := now
+	now = now.Add(time.Hour)
+	if err := s.UpdateDocument(ctx, d, influxdb.WithLabelID(l2.ID)); err != nil {
+		t.Fatal(err)
+	}
+	attached2 := now
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	get := func(query string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("GET", "http://any.url"+query, "", auth,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: d.ID.String()})
+		h.handleGetDocumentLabel(w, r)
+
+		res := w.Result()
+		body, _ := ioutil.ReadAll(res.Body)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("handleGetDocumentLabel() = %v, want %v: %s", res.StatusCode, http.StatusOK, body)
+		}
+
+		var resp struct {
+			Labels []map[string]interface{} `json:"labels"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		attachedAt := map[string]interface{}{}
+		for _, l := range resp.Labels {
+			attachedAt[l["name"].(string)] = l["attachedAt"]
+		}
+		return attachedAt
+	}
+
+	t.Run("attachedAt is included when requested", func(t *testing.T) {
+		want := map[string]interface{}{
+			"l1": attached1.Format(time.RFC3339),
+			"l2": attached2.Format(time.RFC3339),
+		}
+		if got := get("?includeAttachedAt=true"); !reflect.DeepEqual(got, want) {
+			t.Errorf("handleGetDocumentLabel() attachedAt = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("attachedAt is omitted otherwise", func(t *testing.T) {
+		want := map[string]interface{}{"l1": nil, "l2": nil}
+		if got := get(""); !reflect.DeepEqual(got, want) {
+			t.Errorf("handleGetDocumentLabel() attachedAt = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestDocumentCursor(t *testing.T) {
+	c := documentCursor{Offset: 40, Limit: 20, After: influxdb.ID(42), Filter: documentCursorFilter(documentLabelsFilter{})}
+	token := encodeDocumentCursor(c)
+
+	got, err := decodeDocumentCursor(token, documentLabelsFilter{})
+	if err != nil {
+		t.Fatalf("decodeDocumentCursor() failed: %v", err)
+	}
+	if *got != c {
+		t.Errorf("decodeDocumentCursor() = %+v, want %+v", *got, c)
+	}
+
+	// changing the offset without updating the checksum.
+	raw, _ := base64.RawURLEncoding.DecodeString(token)
+	tampered := []byte(strings.Replace(string(raw), `"o":40`, `"o":41`, 1))
+	forged := encodeDocumentCursor(documentCursor{Offset: 0, Limit: influxdb.MaxPageSize + 1})
+
+	for name, token := range map[string]string{
+		"not base64":    "not a token!",
+		"tampered":      base64.RawURLEncoding.EncodeToString(tampered),
+		"invalid state": forged,
+		"empty":         "",
+	} {
+		if _, err := decodeDocumentCursor(token, documentLabelsFilter{}); influxdb.ErrorCode(err) != influxdb.EInvalid {
+			t.Errorf("decodeDocumentCursor(%s) error = %v, want %s", name, err, influxdb.EInvalid)
+		}
+	}
+}
+
+func TestService_handleGetDocumentLabel_InvalidCursor(t *testing.T) {
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	token := encodeDocumentCursor(documentCursor{Offset: 2, Limit: 2})
+	raw, _ := base64.RawURLEncoding.DecodeString(token)
+	raw[len(raw)-2] = '9'
+	tampered := base64.RawURLEncoding.EncodeToString(raw)
+
+	for name, query := range map[string]string{
+		"tampered cursor":          "?cursor=" + tampered,
+		"cursor with an offset":    "?offset=2&cursor=" + token,
+		"cursor without any limit": "?cursor=" + encodeDocumentCursor(documentCursor{Offset: 2}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := newDocumentRequest("GET", "http://any.url/api/v2/documents/templates/020f755c3c082000/labels"+query, "", auth,
+				httprouter.Param{Key: "ns", Value: "templates"},
+				httprouter.Param{Key: "id", Value: "020f755c3c082000"})
+			h.handleGetDocumentLabel(w, r)
+
+			if res := w.Result(); res.StatusCode != http.StatusBadRequest {
+				body, _ := ioutil.ReadAll(res.Body)
+				t.Errorf("handleGetDocumentLabel() = %v, want %v: %s", res.StatusCode, http.StatusBadRequest, body)
+			}
+		})
+	}
+}
+
+func TestService_handlePostDocumentReindex(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		d := &influxdb.Document{
+			Meta:    influxdb.DocumentMeta{Name: fmt.Sprintf("d%d", i)},
+			Content: map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}},
+		}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(l.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	tests := []struct {
+		name       string
+		auth       *influxdb.Authorization
+		statusCode int
+		body       string
+	}{
+		{
+			name: "operators can reindex",
+			auth: &influxdb.Authorization{
+				Status:      influxdb.Active,
+				Permissions: influxdb.OperPermissions(),
+			},
+			statusCode: http.StatusOK,
+			body:       `{"reindexed":3}`,
+		},
+		{
+			name: "org owners cannot reindex",
+			auth: &influxdb.Authorization{
+				Status:      influxdb.Active,
+				Permissions: influxdb.OwnerPermissions(o.ID),
+			},
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "writing every document is not enough to reindex",
+			auth: &influxdb.Authorization{
+				Status: influxdb.Active,
+				Permissions: []influxdb.Permission{
+					{Action: influxdb.WriteAction, Resource: influxdb.Resource{Type: influxdb.DocumentsResourceType}},
+				},
+			},
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := newDocumentRequest("POST", "http://any.url", "", tt.auth,
+				httprouter.Param{Key: "ns", Value: "templates"},
+				httprouter.Param{Key: "id", Value: "reindex"})
+			h.handlePostDocumentAction(w, r)
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != tt.statusCode {
+				t.Fatalf("handlePostDocumentAction() = %v, want %v: %s", res.StatusCode, tt.statusCode, body)
+			}
+			if tt.body != "" {
+				if eq, diff, _ := jsonEqual(string(body), tt.body); !eq {
+					t.Errorf("handlePostDocumentAction() = ***%s***", diff)
+				}
+			}
+		})
+	}
+
+	ds, err := s.FindDocuments(ctx, influxdb.WhereOrg(o.Name), influxdb.WhereLabelID(l.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 3 {
+		t.Errorf("expected 3 documents with label after reindexing, got %d", len(ds))
+	}
+}
+
+func TestService_handleGetDocumentRaw(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: map[string]interface{}{"a": "b"}}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := svc.FindRawDocument(ctx, "templates", d.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	tests := []struct {
+		name       string
+		auth       *influxdb.Authorization
+		id         influxdb.ID
+		statusCode int
+	}{
+		{
+			name: "operators can read raw documents",
+			auth: &influxdb.Authorization{
+				Status:      influxdb.Active,
+				Permissions: influxdb.OperPermissions(),
+			},
+			id:         d.ID,
+			statusCode: http.StatusOK,
+		},
+		{
+			name: "org owners cannot read raw documents",
+			auth: &influxdb.Authorization{
+				Status:      influxdb.Active,
+				Permissions: influxdb.OwnerPermissions(o.ID),
+			},
+			id:         d.ID,
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "missing documents are not found",
+			auth: &influxdb.Authorization{
+				Status:      influxdb.Active,
+				Permissions: influxdb.OperPermissions(),
+			},
+			id:         influxdb.ID(1000),
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, newDocumentRequest("GET", "http://any.url/api/v2/documents/templates/"+tt.id.String()+"/raw", "", tt.auth))
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != tt.statusCode {
+				t.Fatalf("handleGetDocumentRaw() = %v, want %v: %s", res.StatusCode, tt.statusCode, body)
+			}
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+
+			var got influxdb.RawDocument
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(&got, raw) {
+				t.Errorf("expected the stored document %q, got %q", raw, &got)
+			}
+		})
+	}
+}
+
+func TestService_handleGetDocuments_ModifiedSince(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t1 := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	t3 := t2.Add(time.Hour)
+	for i, now := range []time.Time{t1, t2, t3} {
+		now := now
+		svc.WithTime(func() time.Time { return now })
+		d := &influxdb.Document{
+			Meta:    influxdb.DocumentMeta{Name: fmt.Sprintf("d%d", i+1)},
+			Content: map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}},
+		}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	tests := []struct {
+		name          string
+		modifiedSince string
+		statusCode    int
+		names         []string
+	}{
+		{
+			name:          "older documents are excluded",
+			modifiedSince: t1.Add(time.Minute).Format(time.RFC3339),
+			statusCode:    http.StatusOK,
+			names:         []string{"d2", "d3"},
+		},
+		{
+			name:          "documents updated at the boundary are excluded",
+			modifiedSince: t2.Format(time.RFC3339),
+			statusCode:    http.StatusOK,
+			names:         []string{"d3"},
+		},
+		{
+			name:          "timezones are honored",
+			modifiedSince: t2.Add(-time.Minute).In(time.FixedZone("UTC-5", -5*60*60)).Format(time.RFC3339),
+			statusCode:    http.StatusOK,
+			names:         []string{"d2", "d3"},
+		},
+		{
+			name:          "invalid timestamps are rejected",
+			modifiedSince: "yesterday",
+			statusCode:    http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			target := fmt.Sprintf("http://any.url?orgID=%s&modifiedSince=%s", o.ID, url.QueryEscape(tt.modifiedSince))
+			r := newDocumentRequest("GET", target, "", auth,
+				httprouter.Param{Key: "ns", Value: "templates"})
+			h.handleGetDocuments(w, r)
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != tt.statusCode {
+				t.Fatalf("handleGetDocuments() = %v, want %v: %s", res.StatusCode, tt.statusCode, body)
+			}
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+
+			var resp documentsResponse
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var names []string
+			for _, d := range resp.Documents {
+				names = append(names, d.Meta.Name)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("handleGetDocuments() = %v, want %v", names, tt.names)
+			}
+		})
+	}
+}
+
+func TestService_handleGetDocuments_HasLabels(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"labeled", "untagged"} {
+		opts := []influxdb.DocumentOptions{influxdb.WithOrgID(o.ID)}
+		if name == "labeled" {
+			opts = append(opts, influxdb.WithLabelID(l.ID))
+		}
+		d := &influxdb.Document{
+			Meta:    influxdb.DocumentMeta{Name: name},
+			Content: map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}},
+		}
+		if err := s.CreateDocument(ctx, d, opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	tests := []struct {
+		name       string
+		query      string
+		statusCode int
+		names      []string
+	}{
+		{
+			name:       "documents with labels",
+			query:      "&hasLabels=true",
+			statusCode: http.StatusOK,
+			names:      []string{"labeled"},
+		},
+		{
+			name:       "documents without labels",
+			query:      "&hasLabels=false",
+			statusCode: http.StatusOK,
+			names:      []string{"untagged"},
+		},
+		{
+			name:       "labels are not required to be included",
+			query:      "&hasLabels=false&includeLabels=false",
+			statusCode: http.StatusOK,
+			names:      []string{"untagged"},
+		},
+		{
+			name:       "all documents by default",
+			statusCode: http.StatusOK,
+			names:      []string{"labeled", "untagged"},
+		},
+		{
+			name:       "invalid values are rejected",
+			query:      "&hasLabels=some",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			target := fmt.Sprintf("http://any.url?orgID=%s%s", o.ID, tt.query)
+			r := newDocumentRequest("GET", target, "", auth,
+				httprouter.Param{Key: "ns", Value: "templates"})
+			h.handleGetDocuments(w, r)
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != tt.statusCode {
+				t.Fatalf("handleGetDocuments() = %v, want %v: %s", res.StatusCode, tt.statusCode, body)
+			}
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+
+			var resp documentsResponse
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var names []string
+			for _, d := range resp.Documents {
+				names = append(names, d.Meta.Name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("handleGetDocuments() = %v, want %v", names, tt.names)
+			}
+		})
+	}
+}
+
+func TestService_handleGetDocuments_LabelNameRegex(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, label := range map[string]string{"infra": "team/infra", "web": "team/web", "misc": "misc"} {
+		l := &influxdb.Label{Name: label}
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+		d := &influxdb.Document{
+			Meta:    influxdb.DocumentMeta{Name: name},
+			Content: map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}},
+		}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(l.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	tests := []struct {
+		name       string
+		query      string
+		statusCode int
+		names      []string
+	}{
+		{
+			name:       "documents with a matching label",
+			query:      "&labelNameRegex=" + url.QueryEscape("^team/"),
+			statusCode: http.StatusOK,
+			names:      []string{"infra", "web"},
+		},
+		{
+			name:       "labels are not required to be included",
+			query:      "&labelNameRegex=" + url.QueryEscape("web$") + "&includeLabels=false",
+			statusCode: http.StatusOK,
+			names:      []string{"web"},
+		},
+		{
+			name:       "no matching label",
+			query:      "&labelNameRegex=" + url.QueryEscape("^ops/"),
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "invalid expressions are rejected",
+			query:      "&labelNameRegex=" + url.QueryEscape("team/(*"),
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			target := fmt.Sprintf("http://any.url?orgID=%s%s", o.ID, tt.query)
+			r := newDocumentRequest("GET", target, "", auth,
+				httprouter.Param{Key: "ns", Value: "templates"})
+			h.handleGetDocuments(w, r)
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != tt.statusCode {
+				t.Fatalf("handleGetDocuments() = %v, want %v: %s", res.StatusCode, tt.statusCode, body)
+			}
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+
+			var resp documentsResponse
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var names []string
+			for _, d := range resp.Documents {
+				names = append(names, d.Meta.Name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("handleGetDocuments() = %v, want %v", names, tt.names)
+			}
+		})
+	}
+}
+
+func TestService_handleGetDocuments_MaxResponseBytes(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("%02d-%s", i, strings.Repeat("x", 1000))
+		d := &influxdb.Document{
+			Meta:    influxdb.DocumentMeta{Name: name},
+			Content: map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}},
+		}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+			t.Fatal(err)
+		}
+		want[name] = true
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	get := func(target string) (int, *documentsResponse) {
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("GET", "http://any.url"+target, "", auth,
+			httprouter.Param{Key: "ns", Value: "templates"})
+		h.handleGetDocuments(w, r)
+
+		res := w.Result()
+		var resp documentsResponse
+		if res.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return res.StatusCode, &resp
+	}
+
+	// each document has a name of the same length, so that 3 documents fit in a response.
+	_, all := get(fmt.Sprintf("/api/v2/documents/templates?orgID=%s", o.ID))
+	b, err := json.Marshal(all.Documents[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.MaxDocumentsResponseBytes = 3*(len(b)+1) + 10
+
+	t.Run("large lists are truncated and continued", func(t *testing.T) {
+		got := map[string]bool{}
+		pages := 0
+		next := fmt.Sprintf("/api/v2/documents/templates?orgID=%s&sortBy=name", o.ID)
+		for next != "" {
+			code, resp := get(next)
+			if code != http.StatusOK {
+				t.Fatalf("handleGetDocuments() = %v, want %v", code, http.StatusOK)
+			}
+			pages++
+			if pages > len(want) {
+				t.Fatal("expected every page to list at least one document")
+			}
+			if n := len(resp.Documents); n != 3 && resp.Links != nil && resp.Links.Next != "" {
+				t.Errorf("expected truncated pages to hold 3 documents, got %d", n)
+			}
+			for _, d := range resp.Documents {
+				if got[d.Meta.Name] {
+					t.Errorf("document %s listed twice", d.Meta.Name[:2])
+				}
+				got[d.Meta.Name] = true
+			}
+
+			next = ""
+			if resp.Links != nil {
+				next = resp.Links.Next
+			}
+		}
+
+		if pages != 4 {
+			t.Errorf("expected the documents to be listed in 4 responses, got %d", pages)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected all %d documents to be listed, got %d", len(want), len(got))
+		}
+	})
+
+	t.Run("small lists are not linked", func(t *testing.T) {
+		maxBytes := h.MaxDocumentsResponseBytes
+		h.MaxDocumentsResponseBytes = 0
+		defer func() { h.MaxDocumentsResponseBytes = maxBytes }()
+
+		code, resp := get(fmt.Sprintf("/api/v2/documents/templates?orgID=%s", o.ID))
+		if code != http.StatusOK {
+			t.Fatalf("handleGetDocuments() = %v, want %v", code, http.StatusOK)
+		}
+		if len(resp.Documents) != len(want) || resp.Links != nil {
+			t.Errorf("expected all documents without links, got %d documents and links %v", len(resp.Documents), resp.Links)
+		}
+	})
+
+	t.Run("cursors are bound to their filters", func(t *testing.T) {
+		_, resp := get(fmt.Sprintf("/api/v2/documents/templates?orgID=%s&sortBy=name", o.ID))
+		if resp.Links == nil || resp.Links.Next == "" {
+			t.Fatal("expected a next link")
+		}
+		u, err := url.Parse(resp.Links.Next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qp := u.Query()
+		qp.Set("sortBy", "id")
+		if code, _ := get(u.Path + "?" + qp.Encode()); code != http.StatusBadRequest {
+			t.Errorf("handleGetDocuments() = %v, want %v", code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestDocumentHandler_TrailingSlash(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{
+		Meta:    influxdb.DocumentMeta{Name: "d1"},
+		Content: map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}},
+	}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(l.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	serve := func(method, path, query, body string) (int, string) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, newDocumentRequest(method, "http://any.url"+path+query, body, auth))
+		res := w.Result()
+		b, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, string(b)
+	}
+
+	docPath := "/api/v2/documents/templates/" + d.ID.String()
+	tests := []struct {
+		method string
+		path   string
+		query  string
+		status int
+	}{
+		{method: "GET", path: "/api/v2/documents/templates", query: "?orgID=" + o.ID.String(), status: http.StatusOK},
+		{method: "GET", path: docPath, status: http.StatusOK},
+		{method: "GET", path: docPath + "/labels", status: http.StatusOK},
+		{method: "GET", path: "/api/v2/documents/templates/by-name/d1", query: "?orgID=" + o.ID.String(), status: http.StatusOK},
+		{method: "GET", path: "/api/v2/documents/templates/" + influxdb.ID(1).String(), status: http.StatusNotFound},
+		{method: "PATCH", path: docPath, status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			code, body := serve(tt.method, tt.path, tt.query, "")
+			if code != tt.status {
+				t.Fatalf("ServeHTTP() = %v, want %v: %s", code, tt.status, body)
+			}
+			slashCode, slashBody := serve(tt.method, tt.path+"/", tt.query, "")
+			// the methods allowed are listed in no particular order.
+			if code == http.StatusMethodNotAllowed {
+				slashBody = body
+			}
+			if slashCode != code || slashBody != body {
+				t.Errorf("ServeHTTP() with a trailing slash = %v %s, want %v %s", slashCode, slashBody, code, body)
+			}
+		})
+	}
+
+	t.Run("POST with a trailing slash", func(t *testing.T) {
+		body := fmt.Sprintf(`{"orgID":%q,"meta":{"name":"d2"},"content":{"data":{"type":"dashboard","attributes":{}}}}`, o.ID)
+		if code, b := serve("POST", "/api/v2/documents/templates/", "", body); code != http.StatusCreated {
+			t.Errorf("ServeHTTP() = %v, want %v: %s", code, http.StatusCreated, b)
+		}
+	})
+}
+
+func TestService_handleGetDocumentDiff(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := func(name string) map[string]interface{} {
+		return map[string]interface{}{
+			"data": map[string]interface{}{
+				"type":       "dashboard",
+				"attributes": map[string]interface{}{"name": name},
+			},
+		}
+	}
+	d1 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: content("a")}
+	d2 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d2"}, Content: content("b")}
+	d3 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d3"}, Content: content("a")}
+	for _, d := range []*influxdb.Document{d1, d2, d3} {
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := influxtesting.MustIDBase16("020f755c3c082000")
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	tests := []struct {
+		name       string
+		id         influxdb.ID
+		against    influxdb.ID
+		statusCode int
+		body       string
+	}{
+		{
+			name:       "differing content",
+			id:         d1.ID,
+			against:    d2.ID,
+			statusCode: http.StatusOK,
+			body: fmt.Sprintf(`--- %s
++++ %s
+@@ -1,7 +1,7 @@
+ {
+   "data": {
+     "attributes": {
+-      "name": "a"
++      "name": "b"
+     },
+     "type": "dashboard"
+   }
+`, d1.ID, d2.ID),
+		},
+		{
+			name:       "identical content",
+			id:         d1.ID,
+			against:    d3.ID,
+			statusCode: http.StatusOK,
+			body:       "",
+		},
+		{
+			name:       "missing document",
+			id:         missing,
+			against:    d1.ID,
+			statusCode: http.StatusNotFound,
+			body:       fmt.Sprintf("document %s not found", missing),
+		},
+		{
+			name:       "missing against document",
+			id:         d1.ID,
+			against:    missing,
+			statusCode: http.StatusNotFound,
+			body:       fmt.Sprintf("document %s not found", missing),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := newDocumentRequest("GET", "http://any.url?against="+tt.against.String(), "", auth,
+				httprouter.Param{Key: "ns", Value: "templates"},
+				httprouter.Param{Key: "id", Value: tt.id.String()})
+			h.handleGetDocumentDiff(w, r)
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != tt.statusCode {
+				t.Fatalf("handleGetDocumentDiff() = %v, want %v: %s", res.StatusCode, tt.statusCode, body)
+			}
+			if tt.statusCode != http.StatusOK {
+				if !strings.Contains(string(body), tt.body) {
+					t.Errorf("handleGetDocumentDiff() = %s, want error containing %q", body, tt.body)
+				}
+				return
+			}
+			if string(body) != tt.body {
+				t.Errorf("handleGetDocumentDiff() = \n%s\nwant\n%s", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestService_handlePostDocumentLabel(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l1 := &influxdb.Label{Name: "l1"}
+	l2 := &influxdb.Label{Name: "l2"}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := influxtesting.MustIDBase16("020f755c3c082000")
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d"}, Content: content}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+
+	post := func(body string) (int, []byte) {
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("POST", "http://any.url", body, auth,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: d.ID.String()})
+		h.handlePostDocumentLabel(w, r)
+
+		res := w.Result()
+		b, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, b
+	}
+	documentLabels := func() []*influxdb.Label {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeLabels, influxdb.IncludeContent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ds[0].Content, content) {
+			t.Errorf("expected attaching labels to preserve the document content, got %v", ds[0].Content)
+		}
+		return ds[0].Labels
+	}
+
+	t.Run("missing label attaches nothing", func(t *testing.T) {
+		code, body := post(fmt.Sprintf(`{"labelIDs":[%q,%q]}`, l1.ID, missing))
+		if code != http.StatusNotFound {
+			t.Fatalf("handlePostDocumentLabel() = %v, want %v: %s", code, http.StatusNotFound, body)
+		}
+		if !strings.Contains(string(body), fmt.Sprintf("label %s not found", missing)) {
+			t.Errorf("expected error to name the missing label, got %s", body)
+		}
+		if ls := documentLabels(); len(ls) != 0 {
+			t.Errorf("expected no labels to be attached, got %v", ls)
+		}
+	})
+
+	t.Run("labels are not looked up for documents that cannot be read", func(t *testing.T) {
+		other := &influxdb.Authorization{
+			Status:      influxdb.Active,
+			Permissions: influxdb.OwnerPermissions(influxdb.ID(1)),
+		}
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("POST", "http://any.url", fmt.Sprintf(`{"labelIDs":[%q]}`, missing), other,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: d.ID.String()})
+		h.handlePostDocumentLabel(w, r)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("handlePostDocumentLabel() = %v, want %v: %s", w.Code, http.StatusUnauthorized, w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "label") {
+			t.Errorf("expected error not to reveal whether the label exists, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("batch attach", func(t *testing.T) {
+		code, body := post(fmt.Sprintf(`{"labelIDs":[%q,%q]}`, l1.ID, l2.ID))
+		if code != http.StatusCreated {
+			t.Fatalf("handlePostDocumentLabel() = %v, want %v: %s", code, http.StatusCreated, body)
+		}
+
+		var resp documentLabelsResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(resp.Labels) != 2 || resp.Labels[0].ID != l1.ID || resp.Labels[1].ID != l2.ID {
+			t.Errorf("expected response to contain l1 and l2, got %v", resp.Labels)
+		}
+		if ls := documentLabels(); len(ls) != 2 {
+			t.Errorf("expected 2 labels to be attached, got %v", ls)
+		}
+	})
+
+	t.Run("missing label id", func(t *testing.T) {
+		code, body := post(`{}`)
+		if code != http.StatusBadRequest {
+			t.Fatalf("handlePostDocumentLabel() = %v, want %v: %s", code, http.StatusBadRequest, body)
+		}
+	})
+}
+
+func TestService_handleDocumentLabel_IfMatch(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l1 := &influxdb.Label{Name: "l1"}
+	l2 := &influxdb.Label{Name: "l2"}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d"}, Content: content}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+
+	currentETag := func() string {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeContent, influxdb.IncludeLabels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		etag, err := documentETag(ds[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return etag
+	}
+	documentLabels := func() []*influxdb.Label {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeLabels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ds[0].Labels
+	}
+	post := func(etag string, labelID influxdb.ID) *http.Response {
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("POST", "http://any.url", fmt.Sprintf(`{"labelID":%q}`, labelID), auth,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: d.ID.String()})
+		r.Header.Set("If-Match", etag)
+		h.handlePostDocumentLabel(w, r)
+		return w.Result()
+	}
+	del := func(etag string, labelID influxdb.ID) *http.Response {
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("DELETE", "http://any.url", "", auth,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: d.ID.String()},
+			httprouter.Param{Key: "lid", Value: labelID.String()})
+		r.Header.Set("If-Match", etag)
+		h.handleDeleteDocumentLabel(w, r)
+		return w.Result()
+	}
+
+	stale := currentETag()
+
+	res := post(stale, l1.ID)
+	if res.StatusCode != http.StatusCreated {
+		b, _ := ioutil.ReadAll(res.Body)
+		t.Fatalf("handlePostDocumentLabel() with matching etag = %v, want %v: %s", res.StatusCode, http.StatusCreated, b)
+	}
+	etag := res.Header.Get("ETag")
+	if etag == stale {
+		t.Fatalf("expected attaching a label to change the etag")
+	}
+	if etag != currentETag() {
+		t.Errorf("expected response etag %s to match the document etag %s", etag, currentETag())
+	}
+
+	t.Run("stale etag on attach", func(t *testing.T) {
+		res := post(stale, l2.ID)
+		if res.StatusCode != http.StatusConflict {
+			t.Fatalf("handlePostDocumentLabel() with stale etag = %v, want %v", res.StatusCode, http.StatusConflict)
+		}
+		if ls := documentLabels(); len(ls) != 1 || ls[0].ID != l1.ID {
+			t.Errorf("expected only l1 to be attached, got %v", ls)
+		}
+	})
+
+	t.Run("stale etag on detach", func(t *testing.T) {
+		res := del(stale, l1.ID)
+		if res.StatusCode != http.StatusConflict {
+			t.Fatalf("handleDeleteDocumentLabel() with stale etag = %v, want %v", res.StatusCode, http.StatusConflict)
+		}
+		if ls := documentLabels(); len(ls) != 1 {
+			t.Errorf("expected l1 to remain attached, got %v", ls)
+		}
+	})
+
+	t.Run("matching etag on detach", func(t *testing.T) {
+		res := del(etag, l1.ID)
+		if res.StatusCode != http.StatusNoContent {
+			t.Fatalf("handleDeleteDocumentLabel() with matching etag = %v, want %v", res.StatusCode, http.StatusNoContent)
+		}
+		if ls := documentLabels(); len(ls) != 0 {
+			t.Errorf("expected no labels to be attached, got %v", ls)
+		}
+	})
+}
+
+func TestService_handleGetDocumentsByName(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o1 := &influxdb.Organization{Name: "o1"}
+	o2 := &influxdb.Organization{Name: "o2"}
+	for _, o := range []*influxdb.Organization{o1, o2} {
+		if err := svc.CreateOrganization(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(name string, orgID influxdb.ID) *influxdb.Document {
+		d := &influxdb.Document{
+			Meta:    influxdb.DocumentMeta{Name: name},
+			Content: map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}},
+		}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(orgID)); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	unique := create("unique template", o1.ID)
+	dup1 := create("dup", o1.ID)
+	dup2 := create("dup", o1.ID)
+	create("dup", o2.ID)
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	get := func(name string) (int, []influxdb.ID) {
+		target := fmt.Sprintf("http://any.url/api/v2/documents/templates/by-name/%s?orgID=%s", url.PathEscape(name), o1.ID)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, newDocumentRequest("GET", target, "", auth))
+
+		res := w.Result()
+		b, _ := ioutil.ReadAll(res.Body)
+		if res.StatusCode != http.StatusOK {
+			return res.StatusCode, nil
+		}
+
+		var resp documentsResponse
+		if err := json.Unmarshal(b, &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		var ids []influxdb.ID
+		for _, d := range resp.Documents {
+			ids = append(ids, d.ID)
+		}
+		return res.StatusCode, ids
+	}
+
+	t.Run("unique name", func(t *testing.T) {
+		code, ids := get(unique.Meta.Name)
+		if code != http.StatusOK {
+			t.Fatalf("handleGetDocumentsByName() = %v, want %v", code, http.StatusOK)
+		}
+		if !reflect.DeepEqual(ids, []influxdb.ID{unique.ID}) {
+			t.Errorf("expected only %s, got %v", unique.ID, ids)
+		}
+	})
+
+	t.Run("ambiguous name returns every match in the org", func(t *testing.T) {
+		code, ids := get("dup")
+		if code != http.StatusOK {
+			t.Fatalf("handleGetDocumentsByName() = %v, want %v", code, http.StatusOK)
+		}
+		if !reflect.DeepEqual(ids, []influxdb.ID{dup1.ID, dup2.ID}) {
+			t.Errorf("expected %s and %s, got %v", dup1.ID, dup2.ID, ids)
+		}
+	})
+
+	t.Run("missing name", func(t *testing.T) {
+		code, ids := get("missing")
+		if code != http.StatusOK {
+			t.Fatalf("handleGetDocumentsByName() = %v, want %v", code, http.StatusOK)
+		}
+		if len(ids) != 0 {
+			t.Errorf("expected no documents, got %v", ids)
+		}
+	})
+}
+
+func TestService_handleDocument_UniqueNames(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o1 := &influxdb.Organization{Name: "o1"}
+	o2 := &influxdb.Organization{Name: "o2"}
+	for _, o := range []*influxdb.Organization{o1, o2} {
+		if err := svc.CreateOrganization(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.Schemas = nil
+	h.UniqueNames = map[string]bool{"templates": true}
+
+	post := func(name string, orgID influxdb.ID) (int, influxdb.ID) {
+		body := fmt.Sprintf(`{"meta":{"name":%q},"content":{},"orgID":%q}`, name, orgID)
+		w := httptest.NewRecorder()
+		h.handlePostDocument(w, newDocumentRequest("POST", "http://any.url", body, auth,
+			httprouter.Param{Key: "ns", Value: "templates"}))
+
+		res := w.Result()
+		var d influxdb.Document
+		_ = json.NewDecoder(res.Body).Decode(&d)
+		return res.StatusCode, d.ID
+	}
+	put := func(id influxdb.ID, name string) int {
+		body := fmt.Sprintf(`{"meta":{"name":%q},"content":{}}`, name)
+		w := httptest.NewRecorder()
+		h.handlePutDocument(w, newDocumentRequest("PUT", "http://any.url", body, auth,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: id.String()}))
+		return w.Result().StatusCode
+	}
+
+	if code, _ := post("a", o1.ID); code != http.StatusCreated {
+		t.Fatalf("handlePostDocument() = %v, want %v", code, http.StatusCreated)
+	}
+	code, b := post("b", o1.ID)
+	if code != http.StatusCreated {
+		t.Fatalf("handlePostDocument() = %v, want %v", code, http.StatusCreated)
+	}
+
+	t.Run("duplicate name in the same org is rejected", func(t *testing.T) {
+		if code, _ := post("a", o1.ID); code != http.StatusConflict {
+			t.Errorf("handlePostDocument() = %v, want %v", code, http.StatusConflict)
+		}
+	})
+
+	t.Run("renaming to a duplicate name in the same org is rejected", func(t *testing.T) {
+		if code := put(b, "a"); code != http.StatusConflict {
+			t.Errorf("handlePutDocument() = %v, want %v", code, http.StatusConflict)
+		}
+		if code := put(b, "b"); code != http.StatusOK {
+			t.Errorf("handlePutDocument() keeping its own name = %v, want %v", code, http.StatusOK)
+		}
+	})
+
+	t.Run("same name in a different org is allowed", func(t *testing.T) {
+		if code, _ := post("a", o2.ID); code != http.StatusCreated {
+			t.Errorf("handlePostDocument() = %v, want %v", code, http.StatusCreated)
+		}
+	})
+
+	t.Run("duplicate names are allowed when not enforced", func(t *testing.T) {
+		h.UniqueNames = nil
+		if code, _ := post("a", o1.ID); code != http.StatusCreated {
+			t.Errorf("handlePostDocument() = %v, want %v", code, http.StatusCreated)
+		}
+	})
+}
+
+func TestService_handleGetDocuments_IfNoneMatch(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(name string) {
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: name}, Content: map[string]interface{}{}}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	create("d1")
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	get := func(etag string) *http.Response {
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("GET", fmt.Sprintf("http://any.url?orgID=%s", o.ID), "", auth,
+			httprouter.Param{Key: "ns", Value: "templates"})
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		h.handleGetDocuments(w, r)
+		return w.Result()
+	}
+
+	res := get("")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("handleGetDocuments() = %v, want %v", res.StatusCode, http.StatusOK)
+	}
+	etag := res.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an etag for the documents")
+	}
+
+	t.Run("unchanged collection is not modified", func(t *testing.T) {
+		res := get(etag)
+		if res.StatusCode != http.StatusNotModified {
+			t.Fatalf("handleGetDocuments() = %v, want %v", res.StatusCode, http.StatusNotModified)
+		}
+		if b, _ := ioutil.ReadAll(res.Body); len(b) != 0 {
+			t.Errorf("expected an empty body, got %s", b)
+		}
+	})
+
+	t.Run("new document changes the etag", func(t *testing.T) {
+		create("d2")
+
+		res := get(etag)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("handleGetDocuments() = %v, want %v", res.StatusCode, http.StatusOK)
+		}
+		if res.Header.Get("ETag") == etag {
+			t.Errorf("expected the etag to change after a document was created")
+		}
+	})
+}
+
+func TestService_handlePostDocumentLabel_MaxLabels(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	var ls []*influxdb.Label
+	for i := 0; i < 4; i++ {
+		l := &influxdb.Label{Name: fmt.Sprintf("l%d", i)}
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+		ls = append(ls, l)
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d"}, Content: map[string]interface{}{}}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+	h.MaxLabelsPerDocument = 3
+
+	post := func(ls ...*influxdb.Label) int {
+		ids := make([]string, 0, len(ls))
+		for _, l := range ls {
+			ids = append(ids, fmt.Sprintf("%q", l.ID))
+		}
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("POST", "http://any.url", fmt.Sprintf(`{"labelIDs":[%s]}`, strings.Join(ids, ",")), auth,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: d.ID.String()})
+		h.handlePostDocumentLabel(w, r)
+		return w.Result().StatusCode
+	}
+	documentLabels := func() []*influxdb.Label {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeLabels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ds[0].Labels
+	}
+
+	t.Run("attach up to the limit", func(t *testing.T) {
+		if code := post(ls[0], ls[1]); code != http.StatusCreated {
+			t.Fatalf("handlePostDocumentLabel() = %v, want %v", code, http.StatusCreated)
+		}
+		if code := post(ls[2]); code != http.StatusCreated {
+			t.Fatalf("handlePostDocumentLabel() = %v, want %v", code, http.StatusCreated)
+		}
+		if got := documentLabels(); len(got) != 3 {
+			t.Errorf("expected 3 labels, got %v", got)
+		}
+	})
+
+	t.Run("attach over the limit", func(t *testing.T) {
+		if code := post(ls[3]); code != http.StatusUnprocessableEntity {
+			t.Fatalf("handlePostDocumentLabel() = %v, want %v", code, http.StatusUnprocessableEntity)
+		}
+		if got := documentLabels(); len(got) != 3 {
+			t.Errorf("expected the labels to be unchanged, got %v", got)
+		}
+	})
+}
+
+func TestService_handlePostDocument_IdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	other := &influxdb.Organization{Name: "o2"}
+	for _, org := range []*influxdb.Organization{o, other} {
+		if err := svc.CreateOrganization(ctx, org); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.Schemas = nil
+
+	postAs := func(a *influxdb.Authorization, orgID influxdb.ID, key string) (int, *influxdb.Document) {
+		body := fmt.Sprintf(`{"meta":{"name":"d"},"content":{"a":"b"},"orgID":%q}`, orgID)
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("POST", "http://any.url", body, a,
+			httprouter.Param{Key: "ns", Value: "templates"})
+		r.Header.Set("Idempotency-Key", key)
+		h.handlePostDocument(w, r)
+
+		res := w.Result()
+		var d influxdb.Document
+		if err := json.NewDecoder(res.Body).Decode(&d); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return res.StatusCode, &d
+	}
+	post := func(key string) (int, influxdb.ID) {
+		code, d := postAs(auth, o.ID, key)
+		return code, d.ID
+	}
+
+	code, id := post("k1")
+	if code != http.StatusCreated {
+		t.Fatalf("handlePostDocument() = %v, want %v", code, http.StatusCreated)
+	}
+
+	t.Run("repeated key returns the same document", func(t *testing.T) {
+		code, again := post("k1")
+		if code != http.StatusOK {
+			t.Fatalf("handlePostDocument() = %v, want %v", code, http.StatusOK)
+		}
+		if again != id {
+			t.Errorf("expected document %s, got %s", id, again)
+		}
+	})
+
+	t.Run("new key creates a document", func(t *testing.T) {
+		code, other := post("k2")
+		if code != http.StatusCreated {
+			t.Fatalf("handlePostDocument() = %v, want %v", code, http.StatusCreated)
+		}
+		if other == id {
+			t.Errorf("expected a new document, got %s", other)
+		}
+	})
+
+	t.Run("another organization reusing the key creates its own document", func(t *testing.T) {
+		otherAuth := &influxdb.Authorization{
+			Status:      influxdb.Active,
+			Permissions: influxdb.OwnerPermissions(other.ID),
+		}
+		code, d := postAs(otherAuth, other.ID, "k1")
+		if code != http.StatusCreated {
+			t.Fatalf("handlePostDocument() = %v, want %v", code, http.StatusCreated)
+		}
+		if d.ID == id {
+			t.Errorf("expected a new document, got the document of %s", o.Name)
+		}
+	})
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := s.FindDocuments(ctx, influxdb.WhereOrg(o.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 2 {
+		t.Errorf("expected 2 documents to be created, got %d", len(ds))
+	}
+}
+
+func TestService_handleGetDocument_Range(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{
+		Meta:    influxdb.DocumentMeta{Name: "d1"},
+		Content: map[string]interface{}{"data": "0123456789"},
+	}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+	content := `{"data":"0123456789"}`
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	tests := []struct {
+		name         string
+		rng          string
+		status       int
+		contentRange string
+		body         string
+	}{
+		{
+			name:         "bounded range",
+			rng:          "bytes=0-7",
+			status:       http.StatusPartialContent,
+			contentRange: fmt.Sprintf("bytes 0-7/%d", len(content)),
+			body:         content[:8],
+		},
+		{
+			name:         "open ended range",
+			rng:          "bytes=9-",
+			status:       http.StatusPartialContent,
+			contentRange: fmt.Sprintf("bytes 9-%d/%d", len(content)-1, len(content)),
+			body:         content[9:],
+		},
+		{
+			name:         "suffix range",
+			rng:          "bytes=-2",
+			status:       http.StatusPartialContent,
+			contentRange: fmt.Sprintf("bytes %d-%d/%d", len(content)-2, len(content)-1, len(content)),
+			body:         `"}`,
+		},
+		{
+			name:         "unsatisfiable range",
+			rng:          "bytes=100-200",
+			status:       http.StatusRequestedRangeNotSatisfiable,
+			contentRange: fmt.Sprintf("bytes */%d", len(content)),
+		},
+		{
+			name:   "multiple ranges are ignored",
+			rng:    "bytes=0-1,4-5",
+			status: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := newDocumentRequest("GET", "http://any.url", "", auth,
+				httprouter.Param{Key: "ns", Value: "templates"},
+				httprouter.Param{Key: "id", Value: d.ID.String()})
+			r.Header.Set("Range", tt.rng)
+			h.handleGetDocument(w, r)
+
+			res := w.Result()
+			if res.StatusCode != tt.status {
+				t.Fatalf("handleGetDocument() = %v, want %v", res.StatusCode, tt.status)
+			}
+			if got := res.Header.Get("Content-Range"); got != tt.contentRange {
+				t.Errorf("handleGetDocument() Content-Range = %q, want %q", got, tt.contentRange)
+			}
+			if tt.body != "" {
+				if b, _ := ioutil.ReadAll(res.Body); string(b) != tt.body {
+					t.Errorf("handleGetDocument() body = %s, want %s", b, tt.body)
+				}
+			}
+		})
+	}
+}
+
+func TestService_handleGetDocument_IDPrefix(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	other := &influxdb.Organization{Name: "other"}
+	for _, org := range []*influxdb.Organization{o, other} {
+		if err := svc.CreateOrganization(ctx, org); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := []influxdb.ID{0xaaaa000000000001, 0xaaaa000000000002, 0xbbbb000000000001, 0xbbbb000000000002}
+	svc.IDGenerator = mock.IDGenerator{
+		IDFn: func() influxdb.ID {
+			id := ids[0]
+			ids = ids[1:]
+			return id
+		},
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// d4 belongs to another organization, so that o1 alone matches d3 with the prefix bbbb.
+	for _, name := range []string{"d1", "d2", "d3", "d4"} {
+		d := &influxdb.Document{
+			Meta:    influxdb.DocumentMeta{Name: name},
+			Content: map[string]interface{}{"name": name},
+		}
+		orgID := o.ID
+		if name == "d4" {
+			orgID = other.ID
+		}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(orgID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	auth := &influxdb.Authorization{
+		Status: influxdb.Active,
+		Permissions: []influxdb.Permission{
+			{Action: influxdb.WriteAction, Resource: influxdb.Resource{Type: influxdb.DocumentsResourceType, OrgID: &o.ID}},
+		},
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	tests := []struct {
+		name   string
+		id     string
+		status int
+		doc    string
+	}{
+		{
+			name:   "unique prefix",
+			id:     "bbbb",
+			status: http.StatusOK,
+			doc:    "d3",
+		},
+		{
+			name:   "ambiguous prefix",
+			id:     "aaaa",
+			status: http.StatusConflict,
+		},
+		{
+			name:   "full id",
+			id:     "aaaa000000000002",
+			status: http.StatusOK,
+			doc:    "d2",
+		},
+		{
+			name:   "unmatched prefix",
+			id:     "cccc",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "prefix shared with a document of another organization",
+			id:     "bbbb00000000000",
+			status: http.StatusOK,
+			doc:    "d3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := newDocumentRequest("GET", "http://any.url", "", auth,
+				httprouter.Param{Key: "ns", Value: "templates"},
+				httprouter.Param{Key: "id", Value: tt.id})
+			h.handleGetDocument(w, r)
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != tt.status {
+				t.Fatalf("handleGetDocument() = %v, want %v: %s", res.StatusCode, tt.status, body)
+			}
+			if tt.doc == "" {
+				return
+			}
+
+			var doc documentResponse
+			if err := json.Unmarshal(body, &doc); err != nil {
+				t.Fatal(err)
+			}
+			if doc.Meta.Name != tt.doc {
+				t.Errorf("handleGetDocument() document = %q, want %q", doc.Meta.Name, tt.doc)
+			}
+		})
+	}
+}
+
+func TestService_handlePutDocument_Labels(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	kept := &influxdb.Label{Name: "kept"}
+	added := &influxdb.Label{Name: "added"}
+	for _, l := range []*influxdb.Label{kept, added} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: map[string]interface{}{}}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(kept.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.Schemas = nil
+
+	put := func(query, labels string) []influxdb.ID {
+		t.Helper()
+
+		body := fmt.Sprintf(`{"meta":{"name":"d1"},"content":{},"labels":%s}`, labels)
+		w := httptest.NewRecorder()
+		h.handlePutDocument(w, newDocumentRequest("PUT", "http://any.url"+query, body, auth,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: d.ID.String()}))
+		if w.Code != http.StatusOK {
+			t.Fatalf("handlePutDocument() = %v, want %v: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeLabels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []influxdb.ID
+		for _, l := range ds[0].Labels {
+			ids = append(ids, l.ID)
+		}
+		return ids
+	}
+
+	t.Run("labels of the request are ignored by default", func(t *testing.T) {
+		if ids := put("", `[]`); !reflect.DeepEqual(ids, []influxdb.ID{kept.ID}) {
+			t.Errorf("expected the document to keep its labels, got %v", ids)
+		}
+	})
+
+	t.Run("labels of the request replace those of the document when asked to", func(t *testing.T) {
+		if ids := put("?replaceLabels=true", fmt.Sprintf(`[{"id":%q}]`, added.ID)); !reflect.DeepEqual(ids, []influxdb.ID{added.ID}) {
+			t.Errorf("expected the document to carry the labels of the request, got %v", ids)
+		}
+	})
+}
+
+func TestService_handlePostDocument_Quota(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o1 := &influxdb.Organization{Name: "o1"}
+	o2 := &influxdb.Organization{Name: "o2"}
+	for _, o := range []*influxdb.Organization{o1, o2} {
+		if err := svc.CreateOrganization(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.Schemas = nil
+	h.MaxDocumentsPerOrg = 2
+
+	post := func(name string, orgID influxdb.ID) *http.Response {
+		body := fmt.Sprintf(`{"meta":{"name":%q},"content":{},"orgID":%q}`, name, orgID)
+		w := httptest.NewRecorder()
+		h.handlePostDocument(w, newDocumentRequest("POST", "http://any.url", body, auth,
+			httprouter.Param{Key: "ns", Value: "templates"}))
+		return w.Result()
+	}
+
+	t.Run("creation under quota succeeds", func(t *testing.T) {
+		for _, name := range []string{"a", "b"} {
+			if res := post(name, o1.ID); res.StatusCode != http.StatusCreated {
+				t.Fatalf("handlePostDocument() = %v, want %v", res.StatusCode, http.StatusCreated)
+			}
+		}
+	})
+
+	t.Run("creation at quota is forbidden", func(t *testing.T) {
+		res := post("c", o1.ID)
+		if res.StatusCode != http.StatusForbidden {
+			t.Fatalf("handlePostDocument() = %v, want %v", res.StatusCode, http.StatusForbidden)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		want := fmt.Sprintf(`{"code":"forbidden","message":"organization %s has reached its quota of 2 documents"}`, o1.ID)
+		if eq, diff, _ := jsonEqual(string(body), want); !eq {
+			t.Errorf("handlePostDocument() = ***%s***", diff)
+		}
+
+		ds, err := svc.FindDocumentStore(ctx, "templates")
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs, err := ds.FindDocuments(ctx, influxdb.WhereOrg(o1.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(docs) != 2 {
+			t.Errorf("expected the rejected document not to be stored, got %d documents", len(docs))
+		}
+	})
+
+	t.Run("quota is per organization", func(t *testing.T) {
+		if res := post("c", o2.ID); res.StatusCode != http.StatusCreated {
+			t.Errorf("handlePostDocument() = %v, want %v", res.StatusCode, http.StatusCreated)
+		}
+	})
+
+	t.Run("copies and moves count against the quota", func(t *testing.T) {
+		ds, err := svc.FindDocumentStore(ctx, "templates")
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs, err := ds.FindDocuments(ctx, influxdb.WhereOrg(o1.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, err := svc.CreateDocumentStore(ctx, "other")
+		if err != nil {
+			t.Fatal(err)
+		}
+		moved := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "m"}, Content: map[string]interface{}{}}
+		if err := other.CreateDocument(ctx, moved, influxdb.WithOrgID(o1.ID)); err != nil {
+			t.Fatal(err)
+		}
+
+		do := func(target, body string) int {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, newDocumentRequest("POST", target, body, auth))
+			return w.Result().StatusCode
+		}
+
+		copyTarget := fmt.Sprintf("http://any.url/api/v2/documents/templates/%s/copy", docs[0].ID)
+		if code := do(copyTarget, fmt.Sprintf(`{"orgID":%q}`, o2.ID)); code != http.StatusCreated {
+			t.Fatalf("handlePostDocumentCopy() = %v, want %v", code, http.StatusCreated)
+		}
+		if code := do(copyTarget, fmt.Sprintf(`{"orgID":%q}`, o2.ID)); code != http.StatusForbidden {
+			t.Errorf("handlePostDocumentCopy() = %v, want %v", code, http.StatusForbidden)
+		}
+
+		h.Namespaces = map[string]DocumentNamespaceConfig{"other": {MoveTargets: []string{"templates"}}}
+		defer func() { h.Namespaces = nil }()
+
+		moveTarget := fmt.Sprintf("http://any.url/api/v2/documents/other/%s/move", moved.ID)
+		if code := do(moveTarget, `{"namespace":"templates"}`); code != http.StatusForbidden {
+			t.Errorf("handlePostDocumentMove() = %v, want %v", code, http.StatusForbidden)
+		}
+		if _, err := other.FindDocuments(ctx, influxdb.WhereID(moved.ID)); err != nil {
+			t.Errorf("expected the rejected document to stay in its namespace: %v", err)
+		}
+	})
+}
+
+func TestService_handlePostDocumentAppend(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "log"}, Content: []interface{}{}}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	appendData := func(body string) *http.Response {
+		w := httptest.NewRecorder()
+		h.handlePostDocumentAppend(w, newDocumentRequest("POST", "http://any.url", body, auth,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: d.ID.String()}))
+		return w.Result()
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	codes := make(chan int, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes <- appendData(fmt.Sprintf(`{"data":[%d]}`, i)).StatusCode
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("handlePostDocumentAppend() = %v, want %v", code, http.StatusOK)
+		}
+	}
+
+	ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := ds[0].Content.([]interface{})
+	seen := map[float64]bool{}
+	for _, e := range entries {
+		seen[e.(float64)] = true
+	}
+	if len(entries) != n || len(seen) != n {
+		t.Errorf("expected %d distinct appended entries, got %v", n, entries)
+	}
+
+	t.Run("empty data is invalid", func(t *testing.T) {
+		if res := appendData(`{"data":[]}`); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("handlePostDocumentAppend() = %v, want %v", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestService_handleDocumentLock(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{
+		Meta:    influxdb.DocumentMeta{Name: "d"},
+		Content: map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}},
+	}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := &influxdb.Authorization{
+		UserID:      influxdb.ID(1),
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	bob := &influxdb.Authorization{
+		UserID:      influxdb.ID(2),
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CreateDocumentStore(ctx, "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+	h.Namespaces = map[string]DocumentNamespaceConfig{
+		"templates": {Schema: h.Schemas["templates"], MoveTargets: []string{"other"}},
+	}
+
+	params := []httprouter.Param{
+		{Key: "ns", Value: "templates"},
+		{Key: "id", Value: d.ID.String()},
+	}
+	lock := func(a influxdb.Authorizer, body string) (int, []byte) {
+		w := httptest.NewRecorder()
+		h.handlePostDocumentLock(w, newDocumentRequest("POST", "http://any.url", body, a, params...))
+		res := w.Result()
+		b, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, b
+	}
+	unlock := func(a influxdb.Authorizer) int {
+		w := httptest.NewRecorder()
+		h.handleDeleteDocumentLock(w, newDocumentRequest("DELETE", "http://any.url", "", a, params...))
+		return w.Result().StatusCode
+	}
+	update := func(a influxdb.Authorizer) int {
+		w := httptest.NewRecorder()
+		body := `{"meta":{"name":"d"},"content":{"data":{"type":"dashboard","attributes":{}}}}`
+		h.handlePutDocument(w, newDocumentRequest("PUT", "http://any.url", body, a, params...))
+		return w.Result().StatusCode
+	}
+
+	t.Run("acquire", func(t *testing.T) {
+		code, body := lock(alice, `{"ttl":"10m"}`)
+		if code != http.StatusOK {
+			t.Fatalf("handlePostDocumentLock() = %v, want %v: %s", code, http.StatusOK, body)
+		}
+		var l influxdb.DocumentLock
+		if err := json.Unmarshal(body, &l); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if l.OwnerID != alice.UserID {
+			t.Errorf("handlePostDocumentLock() owner = %s, want %s", l.OwnerID, alice.UserID)
+		}
+
+		// the owner may renew the lock.
+		if code, body := lock(alice, ""); code != http.StatusOK {
+			t.Errorf("handlePostDocumentLock() renew = %v, want %v: %s", code, http.StatusOK, body)
+		}
+	})
+
+	t.Run("blocked by another owner", func(t *testing.T) {
+		if code, _ := lock(bob, ""); code != http.StatusLocked {
+			t.Errorf("handlePostDocumentLock() = %v, want %v", code, http.StatusLocked)
+		}
+		if code := update(bob); code != http.StatusLocked {
+			t.Errorf("handlePutDocument() = %v, want %v", code, http.StatusLocked)
+		}
+		if code := unlock(bob); code != http.StatusLocked {
+			t.Errorf("handleDeleteDocumentLock() = %v, want %v", code, http.StatusLocked)
+		}
+
+		docPath := "http://any.url/api/v2/documents/templates/" + d.ID.String()
+		writes := []struct {
+			method string
+			target string
+			body   string
+		}{
+			{method: "DELETE", target: docPath},
+			{method: "DELETE", target: "http://any.url/api/v2/documents/templates?org=o1&confirm=true"},
+			{method: "POST", target: docPath + "/labels", body: fmt.Sprintf(`{"labelID":%q}`, l.ID)},
+			{method: "POST", target: docPath + "/labels", body: fmt.Sprintf(`{"labelIDs":[%q]}`, l.ID)},
+			{method: "DELETE", target: docPath + "/labels/" + l.ID.String()},
+			{method: "POST", target: docPath + "/move", body: `{"namespace":"other"}`},
+		}
+		for _, tt := range writes {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, newDocumentRequest(tt.method, tt.target, tt.body, bob))
+			code := w.Code
+			if code == http.StatusMultiStatus && strings.Contains(w.Body.String(), influxdb.ErrDocumentLocked) {
+				code = http.StatusLocked
+			}
+			if code != http.StatusLocked {
+				t.Errorf("%s %s = %v, want %v: %s", tt.method, tt.target, w.Code, http.StatusLocked, w.Body.String())
+			}
+		}
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeLabels)
+		if err != nil || len(ds[0].Labels) != 0 {
+			t.Fatalf("expected the locked document to be left as it was, got %v: %v", ds, err)
+		}
+
+		if code := update(alice); code != http.StatusOK {
+			t.Errorf("handlePutDocument() by the owner = %v, want %v", code, http.StatusOK)
+		}
+	})
+
+	t.Run("release", func(t *testing.T) {
+		if code := unlock(alice); code != http.StatusNoContent {
+			t.Fatalf("handleDeleteDocumentLock() = %v, want %v", code, http.StatusNoContent)
+		}
+		if code := update(bob); code != http.StatusOK {
+			t.Errorf("handlePutDocument() = %v, want %v", code, http.StatusOK)
+		}
+		if code := unlock(alice); code != http.StatusNotFound {
+			t.Errorf("handleDeleteDocumentLock() of an unlocked document = %v, want %v", code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("invalid ttl", func(t *testing.T) {
+		if code, _ := lock(alice, `{"ttl":"2h"}`); code != http.StatusBadRequest {
+			t.Errorf("handlePostDocumentLock() = %v, want %v", code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("expired locks do not block", func(t *testing.T) {
+		now := time.Now()
+		svc.WithTime(func() time.Time { return now })
+		if code, body := lock(alice, `{"ttl":"1m"}`); code != http.StatusOK {
+			t.Fatalf("handlePostDocumentLock() = %v, want %v: %s", code, http.StatusOK, body)
+		}
+		svc.WithTime(func() time.Time { return now.Add(2 * time.Minute) })
+		if code := update(bob); code != http.StatusOK {
+			t.Errorf("handlePutDocument() = %v, want %v", code, http.StatusOK)
+		}
+	})
+}
+
+func TestService_handlePostDocumentLabel_Properties(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l := &influxdb.Label{
+		Name:           "l1",
+		OrganizationID: o.ID,
+		Properties:     map[string]string{"color": "ffb3b3", "description": "a label"},
+	}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d"}, Content: map[string]interface{}{}}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+
+	w := httptest.NewRecorder()
+	r := newDocumentRequest("POST", "http://any.url", fmt.Sprintf(`{"labelID":%q}`, l.ID), auth,
+		httprouter.Param{Key: "ns", Value: "templates"},
+		httprouter.Param{Key: "id", Value: d.ID.String()})
+	h.handlePostDocumentLabel(w, r)
+
+	res := w.Result()
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("handlePostDocumentLabel() = %v, want %v", res.StatusCode, http.StatusCreated)
+	}
+	var body struct {
+		Labels []*influxdb.Label `json:"labels"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Labels) != 1 {
+		t.Fatalf("expected 1 label, got %v", body.Labels)
+	}
+	if got := body.Labels[0].Properties; !reflect.DeepEqual(got, l.Properties) {
+		t.Errorf("expected attached label properties %v, got %v", l.Properties, got)
+	}
+
+	t.Run("labels reflect later changes", func(t *testing.T) {
+		if _, err := svc.UpdateLabel(ctx, l.ID, influxdb.LabelUpdate{Properties: map[string]string{"color": "000000"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeLabels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ds[0].Labels[0].Properties["color"]; got != "000000" {
+			t.Errorf("expected the current label color, got %q", got)
+		}
+	})
+}
+
+// labelSpyDocumentIndex is an index that owns no documents.
+type labelSpyDocumentIndex struct {
+	influxdb.DocumentIndex
+}
+
+func (labelSpyDocumentIndex) FindOrganizationByID(influxdb.ID) error { return nil }
+
+func (labelSpyDocumentIndex) GetAccessorsDocuments(string, influxdb.ID) ([]influxdb.ID, error) {
+	return nil, nil
+}
+
+// labelSpyDocumentDecorator records whether labels were requested.
+type labelSpyDocumentDecorator struct {
+	labels bool
+}
+
+func (d *labelSpyDocumentDecorator) IncludeContent() error { return nil }
+
+func (d *labelSpyDocumentDecorator) IncludeLabels() error {
+	d.labels = true
+	return nil
+}
+
+func (d *labelSpyDocumentDecorator) Filter(influxdb.DocumentFilter) error { return nil }
+
+func TestService_handleGetDocuments_ExcludeLabels(t *testing.T) {
+	orgID := influxtesting.MustIDBase16("020f755c3c082000")
+	labelsRequested := false
+
+	ds := mock.NewDocumentService()
+	ds.FindDocumentStoreFn = func(context.Context, string) (influxdb.DocumentStore, error) {
+		s := mock.NewDocumentStore()
+		s.FindDocumentsFn = func(ctx context.Context, opts ...influxdb.DocumentFindOptions) ([]*influxdb.Document, error) {
+			dd := &labelSpyDocumentDecorator{}
+			for _, opt := range opts {
+				if _, err := opt(labelSpyDocumentIndex{}, dd); err != nil {
+					return nil, err
+				}
+			}
+			labelsRequested = dd.labels
+
+			d := &influxdb.Document{
+				ID:   influxtesting.MustIDBase16("020f755c3c082001"),
+				Meta: influxdb.DocumentMeta{Name: "d1"},
+			}
+			if dd.labels {
+				d.Labels = []*influxdb.Label{{ID: influxtesting.MustIDBase16("020f755c3c082002"), Name: "l1"}}
+			}
+			return []*influxdb.Document{d}, nil
+		}
+		return s, nil
+	}
+
+	ls := mock.NewLabelService()
+	ls.FindLabelsFn = func(context.Context, influxdb.LabelFilter) ([]*influxdb.Label, error) {
+		t.Error("unexpected call to FindLabels")
+		return nil, nil
+	}
+	ls.FindResourceLabelsFn = func(context.Context, influxdb.LabelMappingFilter) ([]*influxdb.Label, error) {
+		t.Error("unexpected call to FindResourceLabels")
+		return nil, nil
+	}
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = ds
+	h.LabelService = ls
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		labels bool
+		status int
+	}{
+		{name: "labels are included by default", query: "", labels: true, status: http.StatusOK},
+		{name: "labels are included when asked", query: "&includeLabels=true", labels: true, status: http.StatusOK},
+		{name: "labels are excluded when asked", query: "&includeLabels=false", labels: false, status: http.StatusOK},
+		{name: "includeLabels must be a boolean", query: "&includeLabels=nope", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labelsRequested = false
+
+			w := httptest.NewRecorder()
+			r := newDocumentRequest("GET", fmt.Sprintf("http://any.url?orgID=%s%s", orgID, tt.query), "", auth,
+				httprouter.Param{Key: "ns", Value: "templates"})
+			h.handleGetDocuments(w, r)
+
+			res := w.Result()
+			if res.StatusCode != tt.status {
+				t.Fatalf("handleGetDocuments() = %v, want %v", res.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if labelsRequested != tt.labels {
+				t.Errorf("labels requested from the store = %v, want %v", labelsRequested, tt.labels)
+			}
+
+			body, _ := ioutil.ReadAll(res.Body)
+			if got := strings.Contains(string(body), `"labels"`); got != tt.labels {
+				t.Errorf("labels in the response = %v, want %v: %s", got, tt.labels, body)
+			}
+		})
+	}
+}
+
+func TestService_handleDocument_NilLabels(t *testing.T) {
+	orgID := influxtesting.MustIDBase16("020f755c3c082000")
+	withLabels := &influxdb.Document{
+		ID:     influxtesting.MustIDBase16("020f755c3c082001"),
+		Meta:   influxdb.DocumentMeta{Name: "d1"},
+		Labels: []*influxdb.Label{{ID: influxtesting.MustIDBase16("020f755c3c082004"), Name: "l1"}},
+	}
+	emptyLabels := &influxdb.Document{
+		ID:     influxtesting.MustIDBase16("020f755c3c082002"),
+		Meta:   influxdb.DocumentMeta{Name: "d2"},
+		Labels: []*influxdb.Label{},
+	}
+	nilLabels := &influxdb.Document{
+		ID:   influxtesting.MustIDBase16("020f755c3c082003"),
+		Meta: influxdb.DocumentMeta{Name: "d3"},
+	}
+
+	var found []*influxdb.Document
+	ds := mock.NewDocumentService()
+	ds.FindDocumentStoreFn = func(context.Context, string) (influxdb.DocumentStore, error) {
+		s := mock.NewDocumentStore()
+		s.FindDocumentsFn = func(context.Context, ...influxdb.DocumentFindOptions) ([]*influxdb.Document, error) {
+			return found, nil
+		}
+		return s, nil
+	}
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = ds
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	t.Run("listing encodes nil labels as an empty list", func(t *testing.T) {
+		found = []*influxdb.Document{withLabels, emptyLabels, nilLabels}
+
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("GET", fmt.Sprintf("http://any.url?orgID=%s", orgID), "", auth,
+			httprouter.Param{Key: "ns", Value: "templates"})
+		h.handleGetDocuments(w, r)
+
+		res := w.Result()
+		body, _ := ioutil.ReadAll(res.Body)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("handleGetDocuments() = %v, want %v: %s", res.StatusCode, http.StatusOK, body)
+		}
+
+		var resp struct {
+			Documents []map[string]json.RawMessage `json:"documents"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(resp.Documents) != 3 {
+			t.Fatalf("expected 3 documents, got %d", len(resp.Documents))
+		}
+		if got := string(resp.Documents[0]["labels"]); !strings.Contains(got, `"l1"`) {
+			t.Errorf("expected the labels of d1, got %s", got)
+		}
+		for _, d := range resp.Documents[1:] {
+			if got := string(d["labels"]); got != "[]" {
+				t.Errorf("expected labels to be encoded as [], got %q", got)
+			}
+		}
+	})
+
+	for _, d := range []*influxdb.Document{emptyLabels, nilLabels} {
+		t.Run(fmt.Sprintf("labels of %s are an empty list", d.Meta.Name), func(t *testing.T) {
+			found = []*influxdb.Document{d}
+
+			w := httptest.NewRecorder()
+			r := newDocumentRequest("GET", "http://any.url", "", auth,
+				httprouter.Param{Key: "ns", Value: "templates"},
+				httprouter.Param{Key: "id", Value: d.ID.String()})
+			h.handleGetDocumentLabel(w, r)
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("handleGetDocumentLabel() = %v, want %v: %s", res.StatusCode, http.StatusOK, body)
+			}
+
+			var resp map[string]json.RawMessage
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if got := string(resp["labels"]); got != "[]" {
+				t.Errorf("expected labels to be encoded as [], got %q", got)
+			}
+		})
+	}
+}
+
+func TestService_handleGetDocuments_LabelScopedAccess(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	teamA := &influxdb.Label{Name: "team-a", OrganizationID: o.ID}
+	teamB := &influxdb.Label{Name: "team-b", OrganizationID: o.ID}
+	for _, l := range []*influxdb.Label{teamA, teamB} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := []struct {
+		name   string
+		labels []influxdb.ID
+	}{
+		{name: "a", labels: []influxdb.ID{teamA.ID}},
+		{name: "b", labels: []influxdb.ID{teamB.ID}},
+		{name: "ab", labels: []influxdb.ID{teamA.ID, teamB.ID}},
+		{name: "none"},
+	}
+	for _, doc := range docs {
+		opts := []influxdb.DocumentOptions{influxdb.WithOrgID(o.ID)}
+		for _, id := range doc.labels {
+			opts = append(opts, influxdb.WithLabelID(id))
+		}
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: doc.name}, Content: doc.name}
+		if err := s.CreateDocument(ctx, d, opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restricted := &influxdb.Authorization{
+		Status: influxdb.Active,
+		Permissions: []influxdb.Permission{
+			{Action: influxdb.ReadAction, Resource: influxdb.Resource{Type: influxdb.OrgsResourceType, OrgID: &o.ID}},
+			{Action: influxdb.ReadAction, Resource: influxdb.Resource{Type: influxdb.LabelsResourceType, ID: &teamA.ID}},
+		},
+	}
+	unrestricted := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	tests := []struct {
+		name        string
+		labelScoped bool
+		auth        influxdb.Authorizer
+		names       []string
+	}{
+		{
+			name:        "restricted authorizer only receives documents with its labels",
+			labelScoped: true,
+			auth:        restricted,
+			names:       []string{"a", "ab"},
+		},
+		{
+			name:        "authorizer that may read every label is not restricted",
+			labelScoped: true,
+			auth:        unrestricted,
+			names:       []string{"a", "ab", "b", "none"},
+		},
+		{
+			name:  "label permissions are ignored unless label scoped access is enabled",
+			auth:  restricted,
+			names: []string{"a", "ab", "b", "none"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewDocumentHandler(NewMockDocumentBackend())
+			h.DocumentService = svc
+			h.LabelScopedAccess = tt.labelScoped
+
+			w := httptest.NewRecorder()
+			r := newDocumentRequest("GET", fmt.Sprintf("http://any.url?orgID=%s&sortBy=name", o.ID), "", tt.auth,
+				httprouter.Param{Key: "ns", Value: "templates"})
+			h.handleGetDocuments(w, r)
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("handleGetDocuments() = %v, want %v: %s", res.StatusCode, http.StatusOK, body)
+			}
+
+			var resp documentsResponse
+			if err := json.Unmarshal(body, &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var names []string
+			for _, d := range resp.Documents {
+				names = append(names, d.Meta.Name)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("handleGetDocuments() = %v, want %v", names, tt.names)
+			}
+		})
+	}
+}
+
+func TestDocumentHandler_LabelScopedAccess_SingleDocument(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	teamA := &influxdb.Label{Name: "team-a", OrganizationID: o.ID}
+	teamB := &influxdb.Label{Name: "team-b", OrganizationID: o.ID}
+	for _, l := range []*influxdb.Label{teamA, teamB} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := svc.CreateDocumentStore(ctx, "notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "a"}, Content: []interface{}{"a"}}
+	b := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "b"}, Content: []interface{}{"b"}}
+	if err := s.CreateDocument(ctx, a, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(teamA.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateDocument(ctx, b, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(teamB.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+	h.Schemas = nil
+	h.LabelScopedAccess = true
+
+	restricted := &influxdb.Authorization{
+		Status: influxdb.Active,
+		UserID: influxdb.ID(1),
+		Permissions: []influxdb.Permission{
+			{Action: influxdb.ReadAction, Resource: influxdb.Resource{Type: influxdb.OrgsResourceType, OrgID: &o.ID}},
+			{Action: influxdb.WriteAction, Resource: influxdb.Resource{Type: influxdb.DocumentsResourceType, OrgID: &o.ID}},
+			{Action: influxdb.ReadAction, Resource: influxdb.Resource{Type: influxdb.LabelsResourceType, ID: &teamA.ID}},
+		},
+	}
+	docPath := func(d *influxdb.Document) string {
+		return "http://any.url/api/v2/documents/templates/" + d.ID.String()
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, newDocumentRequest("GET", docPath(a), "", restricted))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET of a document within the scope = %v, want %v: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	tests := []struct {
+		method string
+		target string
+		body   string
+	}{
+		{method: "GET", target: docPath(b)},
+		{method: "GET", target: docPath(a) + "/diff?against=" + b.ID.String()},
+		{method: "POST", target: docPath(b) + "/copy", body: fmt.Sprintf(`{"orgID":%q}`, o.ID)},
+		{method: "GET", target: docPath(b) + "/labels"},
+		{method: "POST", target: docPath(b) + "/labels", body: fmt.Sprintf(`{"labelID":%q}`, teamA.ID)},
+		{method: "DELETE", target: docPath(b) + "/labels/" + teamB.ID.String()},
+		{method: "PUT", target: docPath(b), body: `{"meta":{"name":"b"},"content":["changed"]}`},
+		{method: "POST", target: docPath(b) + "/append", body: `{"data":["changed"]}`},
+		{method: "POST", target: docPath(b) + "/move", body: `{"namespace":"notes"}`},
+		{method: "POST", target: docPath(b) + "/lock", body: `{"ttl":"1m"}`},
+		{method: "DELETE", target: docPath(b)},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, newDocumentRequest(tt.method, tt.target, tt.body, restricted))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s %s outside of the scope = %v, want %v: %s", tt.method, tt.target, w.Code, http.StatusNotFound, w.Body.String())
+		}
+	}
+
+	ds, err := s.FindDocuments(ctx, influxdb.WhereID(b.ID), influxdb.IncludeContent, influxdb.IncludeLabels)
+	if err != nil {
+		t.Fatalf("expected the document outside of the scope to remain: %v", err)
+	}
+	if !reflect.DeepEqual(ds[0].Content, []interface{}{"b"}) || len(ds[0].Labels) != 1 || ds[0].Labels[0].ID != teamB.ID {
+		t.Errorf("expected the document outside of the scope to be unchanged, got %v", ds[0])
+	}
+}
+
+func TestService_handlePostDocument_NamespaceConfig(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CreateDocumentStore(ctx, "notes"); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.Namespaces = map[string]DocumentNamespaceConfig{
+		"templates": {Schema: DefaultDocumentSchemas()["templates"]},
+		"notes":     {UniqueNames: true},
+	}
+
+	post := func(ns, name, content string) int {
+		body := fmt.Sprintf(`{"meta":{"name":%q},"content":%s,"orgID":%q}`, name, content, o.ID)
+		w := httptest.NewRecorder()
+		h.handlePostDocument(w, newDocumentRequest("POST", "http://any.url", body, auth,
+			httprouter.Param{Key: "ns", Value: ns}))
+		return w.Result().StatusCode
+	}
+
+	tests := []struct {
+		name    string
+		ns      string
+		doc     string
+		content string
+		want    int
+	}{
+		{name: "templates validate content", ns: "templates", doc: "t1", content: `"free text"`, want: http.StatusUnprocessableEntity},
+		{name: "templates accept valid content", ns: "templates", doc: "t1", content: `{"data":{"type":"dashboard","attributes":{}}}`, want: http.StatusCreated},
+		{name: "templates allow duplicate names", ns: "templates", doc: "t1", content: `{"data":{"type":"dashboard","attributes":{}}}`, want: http.StatusCreated},
+		{name: "notes accept free content", ns: "notes", doc: "n1", content: `"free text"`, want: http.StatusCreated},
+		{name: "notes require unique names", ns: "notes", doc: "n1", content: `"more text"`, want: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := post(tt.ns, tt.doc, tt.content); code != tt.want {
+				t.Errorf("handlePostDocument() = %v, want %v", code, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_handlePostDocument_ContentEncoding(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CreateDocumentStore(ctx, "blobs"); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.Namespaces = map[string]DocumentNamespaceConfig{
+		"templates": {},
+		"blobs":     {AllowBinaryContent: true},
+	}
+
+	tests := []struct {
+		name    string
+		ns      string
+		content string
+		status  int
+		stored  interface{}
+	}{
+		{
+			name:    "valid UTF-8 is accepted",
+			ns:      "templates",
+			content: `"caf\u00e9 ☃"`,
+			status:  http.StatusCreated,
+			stored:  "café ☃",
+		},
+		{
+			name:    "invalid UTF-8 is rejected",
+			ns:      "templates",
+			content: "\"bad \xff\xfe\"",
+			status:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:    "invalid UTF-8 nested in content is rejected",
+			ns:      "templates",
+			content: "{\"a\":\"\xff\"}",
+			status:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:    "binary namespace stores invalid UTF-8 base64 encoded",
+			ns:      "blobs",
+			content: "\"\xff\xfe\\u00e9\\n\"",
+			status:  http.StatusCreated,
+			stored:  base64.StdEncoding.EncodeToString([]byte("\xff\xfeé\n")),
+		},
+		{
+			name:    "binary namespace keeps valid UTF-8 as is",
+			ns:      "blobs",
+			content: `"text"`,
+			status:  http.StatusCreated,
+			stored:  "text",
+		},
+		{
+			name:    "binary namespace only accepts binary strings",
+			ns:      "blobs",
+			content: "{\"a\":\"\xff\"}",
+			status:  http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"meta":{"name":"d1"},"content":%s,"orgID":%q}`, tt.content, o.ID)
+			w := httptest.NewRecorder()
+			h.handlePostDocument(w, newDocumentRequest("POST", "http://any.url", body, auth,
+				httprouter.Param{Key: "ns", Value: tt.ns}))
+
+			res := w.Result()
+			b, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != tt.status {
+				t.Fatalf("handlePostDocument() = %v, want %v: %s", res.StatusCode, tt.status, b)
+			}
+			if tt.status != http.StatusCreated {
+				return
+			}
+
+			var resp documentResponse
+			if err := json.Unmarshal(b, &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			s, err := svc.FindDocumentStore(ctx, tt.ns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ds, err := s.FindDocuments(ctx, influxdb.WhereID(resp.ID), influxdb.IncludeContent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ds[0].Content != tt.stored {
+				t.Errorf("stored content = %q, want %q", ds[0].Content, tt.stored)
+			}
+		})
+	}
+
+	t.Run("update with invalid UTF-8 is rejected", func(t *testing.T) {
+		s, err := svc.FindDocumentStore(ctx, "templates")
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d2"}, Content: "text"}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		h.handlePutDocument(w, newDocumentRequest("PUT", "http://any.url", "{\"meta\":{\"name\":\"d2\"},\"content\":\"\xff\"}", auth,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: d.ID.String()}))
+		if res := w.Result(); res.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("handlePutDocument() = %v, want %v", res.StatusCode, http.StatusUnprocessableEntity)
+		}
+	})
+}
+
+func TestService_handleDocument_MissingAuthorizer(t *testing.T) {
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.Schemas = nil
+
+	id := influxtesting.MustIDBase16("020f755c3c082000").String()
+	labelID := influxtesting.MustIDBase16("020f755c3c082001").String()
+	ns := httprouter.Param{Key: "ns", Value: "templates"}
+	idParam := httprouter.Param{Key: "id", Value: id}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		body    string
+		params  []httprouter.Param
+	}{
+		{name: "create", handler: h.handlePostDocument, method: "POST", body: `{"meta":{"name":"d"},"content":{},"org":"o1"}`, params: []httprouter.Param{ns}},
+		{name: "list", handler: h.handleGetDocuments, method: "GET", target: "?org=o1", params: []httprouter.Param{ns}},
+		{name: "list by name", handler: h.handleGetDocumentsByName, method: "GET", target: "?org=o1", params: []httprouter.Param{ns, {Key: "name", Value: "d"}}},
+		{name: "get", handler: h.handleGetDocument, method: "GET", params: []httprouter.Param{ns, idParam}},
+		{name: "update", handler: h.handlePutDocument, method: "PUT", body: `{"meta":{"name":"d"},"content":{}}`, params: []httprouter.Param{ns, idParam}},
+		{name: "delete", handler: h.handleDeleteDocument, method: "DELETE", params: []httprouter.Param{ns, idParam}},
+		{name: "append", handler: h.handlePostDocumentAppend, method: "POST", body: `{"data":[1]}`, params: []httprouter.Param{ns, idParam}},
+		{name: "copy", handler: h.handlePostDocumentCopy, method: "POST", body: `{"org":"o1"}`, params: []httprouter.Param{ns, idParam}},
+		{name: "diff", handler: h.handleGetDocumentDiff, method: "GET", target: "?against=" + labelID, params: []httprouter.Param{ns, idParam}},
+		{name: "reindex", handler: h.handlePostDocumentReindex, method: "POST", params: []httprouter.Param{ns}},
+		{name: "raw", handler: h.handleGetDocumentRaw, method: "GET", params: []httprouter.Param{ns, idParam}},
+		{name: "get labels", handler: h.handleGetDocumentLabel, method: "GET", params: []httprouter.Param{ns, idParam}},
+		{name: "add label", handler: h.handlePostDocumentLabel, method: "POST", body: fmt.Sprintf(`{"labelID":%q}`, labelID), params: []httprouter.Param{ns, idParam}},
+		{name: "remove label", handler: h.handleDeleteDocumentLabel, method: "DELETE", params: []httprouter.Param{ns, idParam, {Key: "lid", Value: labelID}}},
+	}
+
+	authorizers := map[string]influxdb.Authorizer{
+		"no authorizer":     nil,
+		"nil authorization": (*influxdb.Authorization)(nil),
+		"nil session":       (*influxdb.Session)(nil),
+	}
+	for _, tt := range tests {
+		for desc, a := range authorizers {
+			t.Run(tt.name+" with "+desc, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				tt.handler(w, newDocumentRequest(tt.method, "http://any.url"+tt.target, tt.body, a, tt.params...))
+
+				if res := w.Result(); res.StatusCode != http.StatusUnauthorized {
+					body, _ := ioutil.ReadAll(res.Body)
+					t.Errorf("status code = %v, want %v: %s", res.StatusCode, http.StatusUnauthorized, body)
+				}
+			})
+		}
+	}
+}
+
+func TestService_handleGetDocument_FormatHTML(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := "# Notes <script>alert(1)</script>\n\n" +
+		"Some **bold** and *em* text with `code`.\n" +
+		"[docs](https://docs.example.com) and [bad](JavaScript:void)\n\n" +
+		"- one\n- <img src=x onerror=alert(1)>\n\n" +
+		"```\n<b>raw</b>\n```"
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "n1"}, Content: md}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.Namespaces = map[string]DocumentNamespaceConfig{
+		"notes":     {Renderer: MarkdownRenderer{}},
+		"templates": {Schema: DefaultDocumentSchemas()["templates"]},
+	}
+
+	get := func(ns, format string) *http.Response {
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("GET", "http://any.url?format="+format, "", auth,
+			httprouter.Param{Key: "ns", Value: ns},
+			httprouter.Param{Key: "id", Value: d.ID.String()})
+		h.handleGetDocument(w, r)
+		return w.Result()
+	}
+
+	t.Run("markdown is rendered and sanitized", func(t *testing.T) {
+		res := get("notes", "html")
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("handleGetDocument() = %v, want %v", res.StatusCode, http.StatusOK)
+		}
+		if ct := res.Header.Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("handleGetDocument() Content-Type = %q", ct)
+		}
+
+		body, _ := ioutil.ReadAll(res.Body)
+		want := "<h1>Notes &lt;script&gt;alert(1)&lt;/script&gt;</h1>\n" +
+			"<p>Some <strong>bold</strong> and <em>em</em> text with <code>code</code>. " +
+			`<a href="https://docs.example.com" rel="nofollow">docs</a> and bad</p>` + "\n" +
+			"<ul>\n<li>one</li>\n<li>&lt;img src=x onerror=alert(1)&gt;</li>\n</ul>\n" +
+			"<pre><code>&lt;b&gt;raw&lt;/b&gt;</code></pre>\n"
+		if string(body) != want {
+			t.Errorf("handleGetDocument() body =\n%s\nwant\n%s", body, want)
+		}
+	})
+
+	t.Run("json remains the default", func(t *testing.T) {
+		if res := get("notes", ""); res.Header.Get("Content-Type") != "application/json; charset=utf-8" {
+			t.Errorf("handleGetDocument() Content-Type = %q", res.Header.Get("Content-Type"))
+		}
+	})
+
+	t.Run("namespaces without a renderer cannot be rendered", func(t *testing.T) {
+		if res := get("templates", "html"); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("handleGetDocument() = %v, want %v", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("unknown formats are rejected", func(t *testing.T) {
+		if res := get("notes", "pdf"); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("handleGetDocument() = %v, want %v", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestService_handleDeleteDocuments(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o1 := &influxdb.Organization{Name: "o1"}
+	o2 := &influxdb.Organization{Name: "o2"}
+	for _, o := range []*influxdb.Organization{o1, o2} {
+		if err := svc.CreateOrganization(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the label belongs to no organization, so that documents of both may carry it.
+	deprecated := &influxdb.Label{Name: "deprecated"}
+	if err := svc.CreateLabel(ctx, deprecated); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(name string, orgID influxdb.ID, opts ...influxdb.DocumentOptions) *influxdb.Document {
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: name}, Content: map[string]interface{}{}}
+		if err := s.CreateDocument(ctx, d, append([]influxdb.DocumentOptions{influxdb.WithOrgID(orgID)}, opts...)...); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	old1 := create("old1", o1.ID, influxdb.WithLabelID(deprecated.ID))
+	old2 := create("old2", o1.ID, influxdb.WithLabelID(deprecated.ID))
+	current := create("current", o1.ID)
+	other := create("other", o2.ID, influxdb.WithLabelID(deprecated.ID))
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	del := func(query string) *http.Response {
+		w := httptest.NewRecorder()
+		h.handleDeleteDocuments(w, newDocumentRequest("DELETE", "http://any.url?"+query, "", auth,
+			httprouter.Param{Key: "ns", Value: "templates"}))
+		return w.Result()
+	}
+	exists := func(d *influxdb.Document) bool {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID))
+		return err == nil && len(ds) == 1
+	}
+
+	t.Run("deletion must be confirmed", func(t *testing.T) {
+		if res := del("org=o1&label=deprecated"); res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("handleDeleteDocuments() = %v, want %v", res.StatusCode, http.StatusBadRequest)
+		}
+		if !exists(old1) || !exists(old2) {
+			t.Error("expected no documents to be deleted without confirmation")
+		}
+	})
+
+	t.Run("dry run lists matching documents without deleting them", func(t *testing.T) {
+		res := del("org=o1&label=deprecated&dryRun=true")
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("handleDeleteDocuments() = %v, want %v", res.StatusCode, http.StatusOK)
+		}
+
+		var body struct {
+			Documents []*influxdb.Document `json:"documents"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		got := map[influxdb.ID]bool{}
+		for _, d := range body.Documents {
+			got[d.ID] = true
+		}
+		if len(got) != 2 || !got[old1.ID] || !got[old2.ID] {
+			t.Errorf("expected the dry run to list old1 and old2, got %v", body.Documents)
+		}
+
+		for _, d := range []*influxdb.Document{old1, old2, current, other} {
+			if !exists(d) {
+				t.Errorf("expected document %s to survive a dry run", d.Meta.Name)
+			}
+		}
+	})
+
+	t.Run("matching documents are deleted", func(t *testing.T) {
+		res := del("org=o1&label=deprecated&confirm=true")
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("handleDeleteDocuments() = %v, want %v", res.StatusCode, http.StatusOK)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		if eq, diff, _ := jsonEqual(string(body), `{"deleted":2}`); !eq {
+			t.Errorf("handleDeleteDocuments() = ***%s***", diff)
+		}
+
+		for _, d := range []*influxdb.Document{old1, old2} {
+			if exists(d) {
+				t.Errorf("expected document %s to be deleted", d.Meta.Name)
+			}
+			ls, err := svc.FindResourceLabels(ctx, influxdb.LabelMappingFilter{ResourceID: d.ID, ResourceType: influxdb.DocumentsResourceType})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ls) != 0 {
+				t.Errorf("expected the label mappings of document %s to be deleted, got %v", d.Meta.Name, ls)
+			}
+		}
+		for _, d := range []*influxdb.Document{current, other} {
+			if !exists(d) {
+				t.Errorf("expected non matching document %s to survive", d.Meta.Name)
+			}
+		}
+	})
+}
+
+func TestService_handleDeleteDocuments_Pinned(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := func(name string) *influxdb.Document {
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: name}, Content: map[string]interface{}{}}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	pinned := create("pinned")
+	unpinned := create("unpinned")
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	pin := func(d *influxdb.Document, method string) int {
+		w := httptest.NewRecorder()
+		r := newDocumentRequest(method, "http://any.url", "", auth,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: d.ID.String()})
+		if method == "PUT" {
+			h.handlePutDocumentPin(w, r)
+		} else {
+			h.handleDeleteDocumentPin(w, r)
+		}
+		return w.Result().StatusCode
+	}
+	del := func(query string) string {
+		w := httptest.NewRecorder()
+		h.handleDeleteDocuments(w, newDocumentRequest("DELETE", "http://any.url?"+query, "", auth,
+			httprouter.Param{Key: "ns", Value: "templates"}))
+		res := w.Result()
+		body, _ := ioutil.ReadAll(res.Body)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("handleDeleteDocuments() = %v, want %v: %s", res.StatusCode, http.StatusOK, body)
+		}
+		return string(body)
+	}
+	exists := func(d *influxdb.Document) bool {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID))
+		return err == nil && len(ds) == 1
+	}
+
+	if code := pin(pinned, "PUT"); code != http.StatusNoContent {
+		t.Fatalf("handlePutDocumentPin() = %v, want %v", code, http.StatusNoContent)
+	}
+
+	t.Run("pinned documents survive a bulk delete", func(t *testing.T) {
+		if eq, diff, _ := jsonEqual(del("org=o1&confirm=true"), `{"deleted":1}`); !eq {
+			t.Errorf("handleDeleteDocuments() = ***%s***", diff)
+		}
+		if exists(unpinned) {
+			t.Error("expected the unpinned document to be deleted")
+		}
+		if !exists(pinned) {
+			t.Error("expected the pinned document to survive")
+		}
+	})
+
+	t.Run("pinned documents are deleted when included", func(t *testing.T) {
+		if eq, diff, _ := jsonEqual(del("org=o1&confirm=true&includePinned=true"), `{"deleted":1}`); !eq {
+			t.Errorf("handleDeleteDocuments() = ***%s***", diff)
+		}
+		if exists(pinned) {
+			t.Error("expected the pinned document to be deleted")
+		}
+	})
+
+	t.Run("unpinned documents are deleted", func(t *testing.T) {
+		d := create("unpinned again")
+		if code := pin(d, "PUT"); code != http.StatusNoContent {
+			t.Fatalf("handlePutDocumentPin() = %v, want %v", code, http.StatusNoContent)
+		}
+		if code := pin(d, "DELETE"); code != http.StatusNoContent {
+			t.Fatalf("handleDeleteDocumentPin() = %v, want %v", code, http.StatusNoContent)
+		}
+		if eq, diff, _ := jsonEqual(del("org=o1&confirm=true"), `{"deleted":1}`); !eq {
+			t.Errorf("handleDeleteDocuments() = ***%s***", diff)
+		}
+		if exists(d) {
+			t.Error("expected the unpinned document to be deleted")
+		}
+	})
+
+	t.Run("documents pinned during the delete are kept", func(t *testing.T) {
+		d1 := create("pinned during delete")
+		d2 := create("deleted with it")
+		store := &interleavingDocumentStore{
+			DocumentStore: s,
+			interleave: func() {
+				if err := s.(influxdb.DocumentPinner).PinDocument(ctx, d1.ID, true); err != nil {
+					t.Fatal(err)
+				}
+			},
+		}
+		h := NewDocumentHandler(NewMockDocumentBackend())
+		h.DocumentService = &interleavingDocumentService{DocumentService: svc, store: store}
+
+		w := httptest.NewRecorder()
+		h.handleDeleteDocuments(w, newDocumentRequest("DELETE", "http://any.url?org=o1&confirm=true", "", auth,
+			httprouter.Param{Key: "ns", Value: "templates"}))
+		if res := w.Result(); res.StatusCode != http.StatusConflict {
+			body, _ := ioutil.ReadAll(res.Body)
+			t.Fatalf("handleDeleteDocuments() = %v, want %v: %s", res.StatusCode, http.StatusConflict, body)
+		}
+		if !exists(d1) || !exists(d2) {
+			t.Error("expected no document to be deleted")
+		}
+	})
+}
+
+func TestService_handleGetDocument_RenderVariables(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{
+		Meta: influxdb.DocumentMeta{
+			Name:      "n1",
+			Variables: map[string]string{"host": "localhost", "port": "8086"},
+		},
+		Content: map[string]interface{}{
+			"url":   "http://{{host}}:{{ port }}",
+			"tags":  []interface{}{"{{host}}", "static"},
+			"count": 1,
+		},
+	}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	get := func(query string) (int, interface{}) {
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("GET", "http://any.url?"+query, "", auth,
+			httprouter.Param{Key: "ns", Value: "notes"},
+			httprouter.Param{Key: "id", Value: d.ID.String()})
+		h.handleGetDocument(w, r)
+		res := w.Result()
+
+		var body struct {
+			Content interface{} `json:"content"`
+		}
+		if res.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return res.StatusCode, body.Content
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  interface{}
+	}{
+		{
+			name:  "placeholders are kept unless rendered",
+			query: "",
+			want: map[string]interface{}{
+				"url":   "http://{{host}}:{{ port }}",
+				"tags":  []interface{}{"{{host}}", "static"},
+				"count": float64(1),
+			},
+		},
+		{
+			name:  "stored defaults fill placeholders",
+			query: "render=true",
+			want: map[string]interface{}{
+				"url":   "http://localhost:8086",
+				"tags":  []interface{}{"localhost", "static"},
+				"count": float64(1),
+			},
+		},
+		{
+			name:  "query params override stored defaults",
+			query: "render=true&var.host=influx.example.com",
+			want: map[string]interface{}{
+				"url":   "http://influx.example.com:8086",
+				"tags":  []interface{}{"influx.example.com", "static"},
+				"count": float64(1),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, got := get(tt.query)
+			if code != http.StatusOK {
+				t.Fatalf("handleGetDocument() = %v, want %v", code, http.StatusOK)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("handleGetDocument() content = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("placeholders without a value are invalid", func(t *testing.T) {
+		d.Meta.Variables = map[string]string{"host": "localhost"}
+		if err := s.UpdateDocument(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+		if code, _ := get("render=true"); code != http.StatusBadRequest {
+			t.Errorf("handleGetDocument() = %v, want %v", code, http.StatusBadRequest)
+		}
+		if code, _ := get("render=true&var.port=9999"); code != http.StatusOK {
+			t.Errorf("handleGetDocument() = %v, want %v", code, http.StatusOK)
+		}
+	})
+}
+
+func TestService_handleGetDocuments_CSV(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l1 := &influxdb.Label{Name: "l1"}
+	l2 := &influxdb.Label{Name: "l2"}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	docs := map[string]*influxdb.Document{}
+	for name, labels := range map[string][]influxdb.ID{"d1": {l1.ID, l2.ID}, "d2": nil} {
+		d := &influxdb.Document{
+			Meta:    influxdb.DocumentMeta{Name: name},
+			Content: map[string]interface{}{"description": strings.Repeat(name, 100)},
+		}
+		opts := []influxdb.DocumentOptions{influxdb.WithOrgID(o.ID)}
+		for _, id := range labels {
+			opts = append(opts, influxdb.WithLabelID(id))
+		}
+		if err := s.CreateDocument(ctx, d, opts...); err != nil {
+			t.Fatal(err)
+		}
+		docs[d.ID.String()] = d
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	for _, preview := range []bool{false, true} {
+		t.Run(fmt.Sprintf("preview=%t", preview), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			target := fmt.Sprintf("http://any.url?orgID=%s&preview=%t", o.ID, preview)
+			r := newDocumentRequest("GET", target, "", auth,
+				httprouter.Param{Key: "ns", Value: "templates"})
+			r.Header.Set("Accept", "text/csv, application/json;q=0.5")
+			h.handleGetDocuments(w, r)
+
+			res := w.Result()
+			if res.StatusCode != http.StatusOK {
+				body, _ := ioutil.ReadAll(res.Body)
+				t.Fatalf("handleGetDocuments() = %v, want %v: %s", res.StatusCode, http.StatusOK, body)
+			}
+			if ct := res.Header.Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+				t.Errorf("handleGetDocuments() Content-Type = %q", ct)
+			}
+
+			rows, err := csv.NewReader(res.Body).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := []string{"id", "name", "labels", "contentLength"}
+			if preview {
+				header = append(header, "preview")
+			}
+			if len(rows) != len(docs)+1 {
+				t.Fatalf("handleGetDocuments() returned %d rows, want %d", len(rows), len(docs)+1)
+			}
+			if !reflect.DeepEqual(rows[0], header) {
+				t.Errorf("handleGetDocuments() header = %v, want %v", rows[0], header)
+			}
+
+			for _, row := range rows[1:] {
+				d, ok := docs[row[0]]
+				if !ok {
+					t.Fatalf("handleGetDocuments() returned unexpected document %s", row[0])
+				}
+				content, _ := json.Marshal(d.Content)
+				want := []string{d.ID.String(), d.Meta.Name, "", strconv.Itoa(len(content))}
+				if d.Meta.Name == "d1" {
+					want[2] = "l1;l2"
+				}
+				if preview {
+					want = append(want, string(content[:documentCSVPreviewLen]))
+				}
+				if !reflect.DeepEqual(row, want) {
+					t.Errorf("handleGetDocuments() row = %v, want %v", row, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeDocumentsCSV_Escaping(t *testing.T) {
+	ds := []*influxdb.Document{
+		{
+			ID:      influxdb.ID(1),
+			Meta:    influxdb.DocumentMeta{Name: "=HYPERLINK(\"http://evil\")"},
+			Labels:  []*influxdb.Label{{Name: "a;b"}, {Name: `c\`}, {Name: "d"}},
+			Content: "x",
+		},
+		{
+			ID:      influxdb.ID(2),
+			Meta:    influxdb.DocumentMeta{Name: "@SUM(A1)"},
+			Labels:  []*influxdb.Label{{Name: "+1"}},
+			Content: -1,
+		},
+	}
+
+	w := httptest.NewRecorder()
+	if err := encodeDocumentsCSV(w, ds, true); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"id", "name", "labels", "contentLength", "preview"},
+		{influxdb.ID(1).String(), `'=HYPERLINK("http://evil")`, `a\;b;c\\;d`, "3", `"x"`},
+		{influxdb.ID(2).String(), "'@SUM(A1)", "'+1", "2", "'-1"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("encodeDocumentsCSV() = %q, want %q", rows, want)
+	}
+}
+
+func TestDocumentHandler_NamespaceContext(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{
+		Meta:    influxdb.DocumentMeta{Name: "d1"},
+		Content: map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}},
+	}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	var namespaces []string
+	ds := mock.NewDocumentService()
+	ds.FindDocumentStoreFn = func(ctx context.Context, name string) (influxdb.DocumentStore, error) {
+		namespaces = append(namespaces, pcontext.NamespaceFromContext(ctx))
+		return svc.FindDocumentStore(ctx, name)
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = ds
+
+	for _, path := range []string{
+		"/api/v2/documents/templates?orgID=" + o.ID.String(),
+		"/api/v2/documents/templates/" + d.ID.String(),
+		"/api/v2/documents/templates/" + d.ID.String() + "/labels",
+		"/api/v2/documents/templates/by-name/d1?orgID=" + o.ID.String(),
+	} {
+		t.Run(path, func(t *testing.T) {
+			namespaces = nil
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, newDocumentRequest("GET", "http://any.url"+path, "", auth))
+
+			res := w.Result()
+			if res.StatusCode != http.StatusOK {
+				body, _ := ioutil.ReadAll(res.Body)
+				t.Fatalf("ServeHTTP() = %v, want %v: %s", res.StatusCode, http.StatusOK, body)
+			}
+			if len(namespaces) == 0 {
+				t.Fatal("ServeHTTP() did not find the document store")
+			}
+			for _, ns := range namespaces {
+				if ns != "templates" {
+					t.Errorf("namespace on the context of FindDocumentStore() = %q, want %q", ns, "templates")
+				}
+			}
+		})
+	}
+}
+
+func TestService_handleGetDocuments_UnknownOrg(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.FindDocumentStore(ctx, "templates"); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.OrganizationService = svc
+
+	tests := []struct {
+		name       string
+		query      string
+		statusCode int
+	}{
+		{
+			name:       "known org",
+			query:      "orgID=" + o.ID.String(),
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "unknown org name",
+			query:      "org=o2",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "unknown orgID",
+			query:      "orgID=020f755c3c082000",
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := newDocumentRequest("GET", "http://any.url?"+tt.query, "", auth,
+				httprouter.Param{Key: "ns", Value: "templates"})
+			h.handleGetDocuments(w, r)
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != tt.statusCode {
+				t.Fatalf("handleGetDocuments() = %v, want %v: %s", res.StatusCode, tt.statusCode, body)
+			}
+			if tt.statusCode != http.StatusNotFound {
+				return
+			}
+			var perr influxdb.Error
+			if err := json.Unmarshal(body, &perr); err != nil {
+				t.Fatal(err)
+			}
+			if perr.Code != influxdb.ENotFound {
+				t.Errorf("handleGetDocuments() error code = %s, want %s", perr.Code, influxdb.ENotFound)
+			}
+		})
+	}
+}
+
+func TestService_handlePostDocumentMove(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := svc.CreateDocumentStore(ctx, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{
+		Meta:    influxdb.DocumentMeta{Name: "d1"},
+		Content: map[string]interface{}{"data": map[string]interface{}{"type": "dashboard", "attributes": map[string]interface{}{}}},
+	}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(l.ID)); err != nil {
+		t.Fatal(err)
+	}
+	invalid := &influxdb.Document{
+		Meta:    influxdb.DocumentMeta{Name: "invalid"},
+		Content: "not a template",
+	}
+	if err := other.CreateDocument(ctx, invalid, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+	h.Namespaces = map[string]DocumentNamespaceConfig{
+		"templates": {Schema: h.Schemas["templates"], MoveTargets: []string{"other", "trash", "missing"}},
+		"other":     {MoveTargets: []string{"templates"}},
+	}
+
+	move := func(ns string, id influxdb.ID, body string) (int, []byte) {
+		w := httptest.NewRecorder()
+		target := fmt.Sprintf("http://any.url/api/v2/documents/%s/%s/move", ns, id)
+		h.ServeHTTP(w, newDocumentRequest("POST", target, body, auth))
+		res := w.Result()
+		b, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, b
+	}
+
+	t.Run("documents are rejected by the schema of the namespace", func(t *testing.T) {
+		if code, body := move("other", invalid.ID, `{"namespace": "templates"}`); code != http.StatusUnprocessableEntity {
+			t.Fatalf("handlePostDocumentMove() = %v, want %v: %s", code, http.StatusUnprocessableEntity, body)
+		}
+	})
+
+	t.Run("documents cannot be moved to disallowed namespaces", func(t *testing.T) {
+		for _, body := range []string{`{}`, `{"namespace": "templates"}`, `{"namespace": "trash"}`, `{"namespace": "missing"}`} {
+			if code, b := move("templates", d.ID, body); code != http.StatusBadRequest {
+				t.Errorf("handlePostDocumentMove(%s) = %v, want %v: %s", body, code, http.StatusBadRequest, b)
+			}
+		}
+	})
+
+	t.Run("documents gone from the target namespace are not found", func(t *testing.T) {
+		gone := &influxdb.Document{
+			Meta:    influxdb.DocumentMeta{Name: "gone"},
+			Content: d.Content,
+		}
+		if err := s.CreateDocument(ctx, gone, influxdb.WithOrgID(o.ID)); err != nil {
+			t.Fatal(err)
+		}
+
+		h.DocumentService = &emptyDocumentService{DocumentService: svc, ns: "other"}
+		defer func() { h.DocumentService = svc }()
+
+		if code, body := move("templates", gone.ID, `{"namespace": "other"}`); code != http.StatusNotFound {
+			t.Fatalf("handlePostDocumentMove() = %v, want %v: %s", code, http.StatusNotFound, body)
+		}
+		if err := other.DeleteDocuments(ctx, influxdb.WhereID(gone.ID)); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("documents are moved with their labels", func(t *testing.T) {
+		code, body := move("templates", d.ID, `{"namespace": "other"}`)
+		if code != http.StatusOK {
+			t.Fatalf("handlePostDocumentMove() = %v, want %v: %s", code, http.StatusOK, body)
+		}
+		want := fmt.Sprintf(`{
+			"id": "%s",
+			"links": {"self": "/api/v2/documents/other/%s"},
+			"meta": {"name": "d1", "createdAt": "%s", "updatedAt": "%s"},
+			"labels": [{"id": "%s", "name": "l1"}]
+		}`, d.ID, d.ID, d.Meta.CreatedAt.Format(time.RFC3339Nano), d.Meta.UpdatedAt.Format(time.RFC3339Nano), l.ID)
+		if eq, diff, _ := jsonEqual(string(body), want); !eq {
+			t.Errorf("handlePostDocumentMove() = ***%s***", diff)
+		}
+
+		if ds, err := s.FindDocuments(ctx, influxdb.WhereOrg("o1")); err != nil || len(ds) != 0 {
+			t.Errorf("expected the document to be gone from the source namespace, got %v, %v", ds, err)
+		}
+		ds, err := other.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeContent, influxdb.IncludeLabels)
+		if err != nil || len(ds) != 1 {
+			t.Fatalf("expected the document in the target namespace, got %v, %v", ds, err)
+		}
+		if !reflect.DeepEqual(ds[0].Content, d.Content) || len(ds[0].Labels) != 1 || ds[0].Labels[0].ID != l.ID {
+			t.Errorf("expected the moved document to keep its content and labels, got %v", ds[0])
+		}
+	})
+}
+
+func TestService_handlePostDocumentMove_TargetConfig(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]influxdb.DocumentStore{}
+	for _, ns := range []string{"src", "binary", "unique", "labelled", "open"} {
+		s, err := svc.CreateDocumentStore(ctx, ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stores[ns] = s
+	}
+	create := func(ns, name string, opts ...influxdb.DocumentOptions) *influxdb.Document {
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: name}, Content: "content"}
+		if err := stores[ns].CreateDocument(ctx, d, append([]influxdb.DocumentOptions{influxdb.WithOrgID(o.ID)}, opts...)...); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	create("unique", "taken")
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+	h.Namespaces = map[string]DocumentNamespaceConfig{
+		"src":      {MoveTargets: []string{"unique", "labelled", "open"}},
+		"binary":   {AllowBinaryContent: true, MoveTargets: []string{"open"}},
+		"unique":   {UniqueNames: true},
+		"labelled": {RequireLabels: true},
+	}
+
+	move := func(ns string, id influxdb.ID, to string) (int, []byte) {
+		w := httptest.NewRecorder()
+		target := fmt.Sprintf("http://any.url/api/v2/documents/%s/%s/move", ns, id)
+		h.ServeHTTP(w, newDocumentRequest("POST", target, fmt.Sprintf(`{"namespace":%q}`, to), auth))
+		res := w.Result()
+		b, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, b
+	}
+
+	tests := []struct {
+		name string
+		from string
+		doc  *influxdb.Document
+		to   string
+		code int
+	}{
+		{name: "targets must be allowed", from: "src", doc: create("src", "d1"), to: "binary", code: http.StatusBadRequest},
+		{name: "names must be unique in the target", from: "src", doc: create("src", "taken"), to: "unique", code: http.StatusConflict},
+		{name: "labels are required by the target", from: "src", doc: create("src", "d2"), to: "labelled", code: http.StatusUnprocessableEntity},
+		{name: "binary content must be allowed by the target", from: "binary", doc: create("binary", "d3"), to: "open", code: http.StatusUnprocessableEntity},
+		{name: "labelled documents are moved", from: "src", doc: create("src", "d4", influxdb.WithLabelID(l.ID)), to: "labelled", code: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := move(tt.from, tt.doc.ID, tt.to)
+			if code != tt.code {
+				t.Fatalf("handlePostDocumentMove() = %v, want %v: %s", code, tt.code, body)
+			}
+			if code == http.StatusOK {
+				return
+			}
+			if _, err := stores[tt.from].FindDocuments(ctx, influxdb.WhereID(tt.doc.ID)); err != nil {
+				t.Errorf("expected the rejected document to stay in its namespace: %v", err)
+			}
+		})
+	}
+}
+
+func TestService_handlePostDocumentLabel_NotAtomic(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	var ls []*influxdb.Label
+	for i := 0; i < 3; i++ {
+		l := &influxdb.Label{Name: fmt.Sprintf("l%d", i)}
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+		ls = append(ls, l)
+	}
+	missing := influxdb.ID(1)
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d"}, Content: map[string]interface{}{}}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+	h.MaxLabelsPerDocument = 2
+
+	post := func(query string) (int, []byte) {
+		body := fmt.Sprintf(`{"labelIDs":["%s","%s","%s","%s"]}`, ls[0].ID, missing, ls[1].ID, ls[2].ID)
+		w := httptest.NewRecorder()
+		r := newDocumentRequest("POST", "http://any.url"+query, body, auth,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: d.ID.String()})
+		h.handlePostDocumentLabel(w, r)
+		res := w.Result()
+		b, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, b
+	}
+	documentLabels := func() []*influxdb.Label {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeLabels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ds[0].Labels
+	}
+
+	t.Run("labels are attached atomically by default", func(t *testing.T) {
+		if code, body := post(""); code != http.StatusNotFound {
+			t.Fatalf("handlePostDocumentLabel() = %v, want %v: %s", code, http.StatusNotFound, body)
+		}
+		if got := documentLabels(); len(got) != 0 {
+			t.Errorf("expected no labels to be attached, got %v", got)
+		}
+	})
+
+	t.Run("labels are attached on their own with a report", func(t *testing.T) {
+		code, body := post("?atomic=false")
+		if code != http.StatusMultiStatus {
+			t.Fatalf("handlePostDocumentLabel() = %v, want %v: %s", code, http.StatusMultiStatus, body)
+		}
+
+		var res struct {
+			Labels  []*influxdb.Label     `json:"labels"`
+			Results []documentLabelResult `json:"results"`
+		}
+		if err := json.Unmarshal(body, &res); err != nil {
+			t.Fatal(err)
+		}
+		want := []documentLabelResult{
+			{LabelID: ls[0].ID},
+			{LabelID: missing, Error: &documentLabelFailure{Code: influxdb.ENotFound, Message: fmt.Sprintf("label %s not found", missing)}},
+			{LabelID: ls[1].ID},
+			{LabelID: ls[2].ID, Error: &documentLabelFailure{Code: influxdb.EUnprocessableEntity, Message: fmt.Sprintf("document %s cannot have more than 2 labels", d.ID)}},
+		}
+		if !reflect.DeepEqual(res.Results, want) {
+			t.Errorf("handlePostDocumentLabel() results = %s", body)
+		}
+		if len(res.Labels) != 2 || res.Labels[0].ID != ls[0].ID || res.Labels[1].ID != ls[1].ID {
+			t.Errorf("handlePostDocumentLabel() labels = %v, want l0 and l1", res.Labels)
+		}
+		if got := documentLabels(); len(got) != 2 {
+			t.Errorf("expected 2 labels to be attached, got %v", got)
+		}
+	})
+
+	t.Run("invalid atomic", func(t *testing.T) {
+		if code, body := post("?atomic=maybe"); code != http.StatusBadRequest {
+			t.Fatalf("handlePostDocumentLabel() = %v, want %v: %s", code, http.StatusBadRequest, body)
+		}
+	})
+}
+
+func TestService_handlePostDocument_DecodeError(t *testing.T) {
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+
+	tests := []struct {
+		name     string
+		body     string
+		position string
+		snippet  string
+	}{
+		{
+			name:     "syntax error",
+			body:     "{\n  \"meta\": {\"name\": \"t1\"},\n  \"content\": {\"a\" 1}\n}",
+			position: "line 3, column 19",
+			snippet:  `near ",\n  \"content\": {\"a\" 1}\n}"`,
+		},
+		{
+			name:     "truncated body",
+			body:     `{"meta": {"name": "t1"}`,
+			position: "line 1, column 23",
+			snippet:  `near "meta\": {\"name\": \"t1\"}"`,
+		},
+		{
+			name:     "type error",
+			body:     `{"meta": {"name": 1}}`,
+			position: "line 1, column 19",
+			snippet:  `near "{\"meta\": {\"name\": 1}}"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := newDocumentRequest("POST", "http://any.url", tt.body, auth,
+				httprouter.Param{Key: "ns", Value: "templates"})
+			h.handlePostDocument(w, r)
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != http.StatusBadRequest {
+				t.Fatalf("handlePostDocument() = %v, want %v: %s", res.StatusCode, http.StatusBadRequest, body)
+			}
+
+			var perr influxdb.Error
+			if err := json.Unmarshal(body, &perr); err != nil {
+				t.Fatal(err)
+			}
+			if perr.Code != influxdb.EInvalid {
+				t.Errorf("handlePostDocument() error code = %s, want %s", perr.Code, influxdb.EInvalid)
+			}
+			if !strings.Contains(perr.Msg, tt.position) || !strings.Contains(perr.Msg, tt.snippet) {
+				t.Errorf("handlePostDocument() error = %s, want %s and %s", perr.Msg, tt.position, tt.snippet)
+			}
+		})
+	}
+}
+
+func TestService_handlePostDocument_LastWriter(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+	h.Schemas = nil
+
+	author := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		UserID:      influxdb.ID(10),
+		Permissions: influxdb.OperPermissions(),
+	}
+	editor := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		UserID:      influxdb.ID(11),
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	decode := func(w *httptest.ResponseRecorder, code int) *influxdb.Document {
+		t.Helper()
+		res := w.Result()
+		body, _ := ioutil.ReadAll(res.Body)
+		if res.StatusCode != code {
+			t.Fatalf("got status %v, want %v: %s", res.StatusCode, code, body)
+		}
+		var d influxdb.Document
+		if err := json.Unmarshal(body, &d); err != nil {
+			t.Fatal(err)
+		}
+		return &d
+	}
+	stored := func(id influxdb.ID) influxdb.ID {
+		t.Helper()
+		s, err := svc.FindDocumentStore(ctx, "templates")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ds[0].Meta.LastWriterID
+	}
+
+	// the last writer sent by the client is ignored.
+	body := fmt.Sprintf(`{"meta":{"name":"d1","lastWriterID":"%s"},"content":{},"orgID":"%s"}`, editor.UserID, o.ID)
+	w := httptest.NewRecorder()
+	h.handlePostDocument(w, newDocumentRequest("POST", "http://any.url", body, author,
+		httprouter.Param{Key: "ns", Value: "templates"}))
+	d := decode(w, http.StatusCreated)
+
+	t.Run("create records the last writer", func(t *testing.T) {
+		if d.Meta.LastWriterID != author.UserID || stored(d.ID) != author.UserID {
+			t.Errorf("expected last writer %s, got %s in the response and %s stored", author.UserID, d.Meta.LastWriterID, stored(d.ID))
+		}
+	})
+
+	t.Run("update records the last writer", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.handlePutDocument(w, newDocumentRequest("PUT", "http://any.url", `{"meta":{"name":"d1"},"content":{"a":1}}`, editor,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: d.ID.String()}))
+		updated := decode(w, http.StatusOK)
+		if updated.Meta.LastWriterID != editor.UserID || stored(d.ID) != editor.UserID {
+			t.Errorf("expected last writer %s, got %s in the response and %s stored", editor.UserID, updated.Meta.LastWriterID, stored(d.ID))
+		}
+	})
+
+	t.Run("attaching labels keeps the last writer", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.handlePostDocumentLabel(w, newDocumentRequest("POST", "http://any.url", fmt.Sprintf(`{"labelID":"%s"}`, l.ID), author,
+			httprouter.Param{Key: "ns", Value: "templates"},
+			httprouter.Param{Key: "id", Value: d.ID.String()}))
+		if w.Result().StatusCode != http.StatusCreated {
+			t.Fatalf("handlePostDocumentLabel() = %v, want %v", w.Result().StatusCode, http.StatusCreated)
+		}
+		if got := stored(d.ID); got != editor.UserID {
+			t.Errorf("expected last writer %s, got %s", editor.UserID, got)
+		}
+	})
+}
+
+func TestDocumentHandler_WriteLimiter(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+	h.Schemas = nil
+	h.WriteLimiter = NewDocumentTokenLimiter(0.001, 2)
+
+	auth := &influxdb.Authorization{
+		ID:          influxdb.ID(1),
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	other := &influxdb.Authorization{
+		ID:          influxdb.ID(2),
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	post := func(a influxdb.Authorizer, name string) *http.Response {
+		body := fmt.Sprintf(`{"meta":{"name":%q},"content":{},"orgID":"%s"}`, name, o.ID)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, newDocumentRequest("POST", "http://any.url/api/v2/documents/templates", body, a))
+		return w.Result()
+	}
+
+	for _, name := range []string{"d1", "d2"} {
+		if res := post(auth, name); res.StatusCode != http.StatusCreated {
+			body, _ := ioutil.ReadAll(res.Body)
+			t.Fatalf("POST %s = %v, want %v: %s", name, res.StatusCode, http.StatusCreated, body)
+		}
+	}
+
+	t.Run("writes over the limit are rejected", func(t *testing.T) {
+		res := post(auth, "d3")
+		if res.StatusCode != http.StatusTooManyRequests {
+			body, _ := ioutil.ReadAll(res.Body)
+			t.Fatalf("POST = %v, want %v: %s", res.StatusCode, http.StatusTooManyRequests, body)
+		}
+		if ra, err := strconv.Atoi(res.Header.Get("Retry-After")); err != nil || ra < 1 {
+			t.Errorf("expected a Retry-After of at least one second, got %q", res.Header.Get("Retry-After"))
+		}
+
+		var e influxdb.Error
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Code != influxdb.ETooManyRequests {
+			t.Errorf("expected code %q, got %q", influxdb.ETooManyRequests, e.Code)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, newDocumentRequest("DELETE", "http://any.url/api/v2/documents/templates/"+influxdb.ID(100).String(), "", auth))
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("DELETE = %v, want %v", w.Code, http.StatusTooManyRequests)
+		}
+	})
+
+	t.Run("reads are not limited", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, newDocumentRequest("GET", "http://any.url/api/v2/documents/templates?orgID="+o.ID.String(), "", auth))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET = %v, want %v: %s", w.Code, http.StatusOK, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("each authorization is limited separately", func(t *testing.T) {
+		if res := post(other, "d4"); res.StatusCode != http.StatusCreated {
+			body, _ := ioutil.ReadAll(res.Body)
+			t.Fatalf("POST = %v, want %v: %s", res.StatusCode, http.StatusCreated, body)
+		}
+	})
+}
+
+func TestDocumentTokenLimiter_EvictsIdle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	l := NewDocumentTokenLimiter(1, 10)
+	l.now = func() time.Time { return now }
+
+	a1 := &influxdb.Authorization{ID: influxdb.ID(1)}
+	a2 := &influxdb.Authorization{ID: influxdb.ID(2)}
+	for i := 0; i < 10; i++ {
+		if ok, _ := l.AllowDocumentWrite(ctx, a1); !ok {
+			t.Fatalf("expected write %d to be allowed", i)
+		}
+	}
+	if ok, _ := l.AllowDocumentWrite(ctx, a1); ok {
+		t.Fatalf("expected write over the burst to be rejected")
+	}
+
+	// a1 stays active while a2 writes only once.
+	if ok, _ := l.AllowDocumentWrite(ctx, a2); !ok {
+		t.Fatalf("expected write to be allowed")
+	}
+	now = now.Add(minDocumentLimiterIdle / 2)
+	l.AllowDocumentWrite(ctx, a1)
+
+	now = now.Add(minDocumentLimiterIdle/2 + time.Second)
+	l.AllowDocumentWrite(ctx, a1)
+
+	if _, ok := l.limiters[a2.ID]; ok {
+		t.Errorf("expected the idle limiter to be evicted")
+	}
+	if _, ok := l.limiters[a1.ID]; !ok {
+		t.Errorf("expected the active limiter to be kept")
+	}
+}
+
+func TestService_handlePostDocument_LabelIDs(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l1 := &influxdb.Label{Name: "l1"}
+	l2 := &influxdb.Label{Name: "l2"}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+	h.Schemas = nil
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("labels are attached on creation", func(t *testing.T) {
+		body := fmt.Sprintf(`{"meta":{"name":"d1"},"content":{},"orgID":"%s","labelIDs":["%s","%s"]}`, o.ID, l1.ID, l2.ID)
+		w := httptest.NewRecorder()
+		h.handlePostDocument(w, newDocumentRequest("POST", "http://any.url", body, auth,
+			httprouter.Param{Key: "ns", Value: "templates"}))
+
+		res := w.Result()
+		b, _ := ioutil.ReadAll(res.Body)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("got status %v, want %v: %s", res.StatusCode, http.StatusCreated, b)
+		}
+		var d influxdb.Document
+		if err := json.Unmarshal(b, &d); err != nil {
+			t.Fatal(err)
+		}
+		if len(d.Labels) != 2 {
+			t.Errorf("expected the response to carry 2 labels, got %d", len(d.Labels))
+		}
+
+		ls, err := svc.FindResourceLabels(ctx, influxdb.LabelMappingFilter{
+			ResourceID:   d.ID,
+			ResourceType: influxdb.DocumentsResourceType,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[influxdb.ID]bool{}
+		for _, l := range ls {
+			got[l.ID] = true
+		}
+		if len(got) != 2 || !got[l1.ID] || !got[l2.ID] {
+			t.Errorf("expected mappings to labels %s and %s, got %v", l1.ID, l2.ID, got)
+		}
+	})
+
+	t.Run("a missing label fails the creation", func(t *testing.T) {
+		id := influxdb.ID(2000)
+		svc.IDGenerator = mock.NewIDGenerator(id.String(), t)
+		body := fmt.Sprintf(`{"meta":{"name":"d2"},"content":{},"orgID":"%s","labelIDs":["%s","%s"]}`, o.ID, l1.ID, influxdb.ID(1000))
+		w := httptest.NewRecorder()
+		h.handlePostDocument(w, newDocumentRequest("POST", "http://any.url", body, auth,
+			httprouter.Param{Key: "ns", Value: "templates"}))
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("got status %v, want %v: %s", w.Code, http.StatusNotFound, w.Body.String())
+		}
+
+		ds, err := s.FindDocuments(ctx, influxdb.WhereOrg("o1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range ds {
+			if d.Meta.Name == "d2" {
+				t.Fatalf("expected the creation of d2 to be rolled back")
+			}
+		}
+
+		ls, err := svc.FindResourceLabels(ctx, influxdb.LabelMappingFilter{
+			ResourceID:   id,
+			ResourceType: influxdb.DocumentsResourceType,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ls) != 0 {
+			t.Errorf("expected the label mappings of d2 to be rolled back, got %d", len(ls))
+		}
+	})
+}
+
+func TestService_handleGetDocuments_IncludeOrg(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"d1", "d2", "d3"} {
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: name}, Content: map[string]interface{}{}}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var lookups int
+	orgs := mock.NewOrganizationService()
+	orgs.FindOrganizationByIDF = func(ctx context.Context, id influxdb.ID) (*influxdb.Organization, error) {
+		lookups++
+		return svc.FindOrganizationByID(ctx, id)
+	}
+	orgs.FindOrganizationF = func(ctx context.Context, filter influxdb.OrganizationFilter) (*influxdb.Organization, error) {
+		lookups++
+		return svc.FindOrganization(ctx, filter)
+	}
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.OrganizationService = orgs
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantOrg *documentsOrgResponse
+	}{
+		{
+			name:    "org is resolved by id",
+			query:   "orgID=" + o.ID.String() + "&includeOrg=true",
+			wantOrg: &documentsOrgResponse{ID: o.ID, Name: "o1"},
+		},
+		{
+			name:    "org is resolved by name",
+			query:   "org=o1&includeOrg=true",
+			wantOrg: &documentsOrgResponse{ID: o.ID, Name: "o1"},
+		},
+		{
+			name:  "org is omitted unless requested",
+			query: "orgID=" + o.ID.String(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookups = 0
+			w := httptest.NewRecorder()
+			h.handleGetDocuments(w, newDocumentRequest("GET", "http://any.url?"+tt.query, "", auth,
+				httprouter.Param{Key: "ns", Value: "templates"}))
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("got status %v, want %v: %s", res.StatusCode, http.StatusOK, body)
+			}
+
+			var got struct {
+				Org       *documentsOrgResponse `json:"org"`
+				Documents []json.RawMessage     `json:"documents"`
+			}
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Documents) != 3 {
+				t.Errorf("expected 3 documents, got %d", len(got.Documents))
+			}
+			if !reflect.DeepEqual(got.Org, tt.wantOrg) {
+				t.Errorf("expected org %+v, got %+v", tt.wantOrg, got.Org)
+			}
+			if lookups > 1 {
+				t.Errorf("expected the org to be looked up at most once, got %d lookups", lookups)
+			}
+		})
+	}
+}
+
+func TestService_handleGetDocument_ContentType(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	for _, ns := range []string{"files", "notes"} {
+		if _, err := svc.CreateDocumentStore(ctx, ns); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.Namespaces = map[string]DocumentNamespaceConfig{
+		"files": {ServeContentType: true},
+		"notes": {},
+	}
+
+	post := func(ns, contentType string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"meta":{"name":"d1","contentType":%q},"content":"a,b\n1,2\n","orgID":%q}`, contentType, o.ID)
+		w := httptest.NewRecorder()
+		h.handlePostDocument(w, newDocumentRequest("POST", "http://any.url", body, auth,
+			httprouter.Param{Key: "ns", Value: ns}))
+		return w
+	}
+	get := func(ns string, w *httptest.ResponseRecorder) *http.Response {
+		t.Helper()
+		if w.Code != http.StatusCreated {
+			t.Fatalf("handlePostDocument() = %v, want %v: %s", w.Code, http.StatusCreated, w.Body.String())
+		}
+		var d influxdb.Document
+		if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+			t.Fatal(err)
+		}
+
+		w = httptest.NewRecorder()
+		h.handleGetDocument(w, newDocumentRequest("GET", "http://any.url", "", auth,
+			httprouter.Param{Key: "ns", Value: ns},
+			httprouter.Param{Key: "id", Value: d.ID.String()}))
+		return w.Result()
+	}
+
+	t.Run("content is returned with its content type", func(t *testing.T) {
+		res := get("files", post("files", "text/csv"))
+		body, _ := ioutil.ReadAll(res.Body)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("handleGetDocument() = %v, want %v: %s", res.StatusCode, http.StatusOK, body)
+		}
+		if ct := res.Header.Get("Content-Type"); ct != "text/csv" {
+			t.Errorf("expected content type text/csv, got %q", ct)
+		}
+		if nosniff := res.Header.Get("X-Content-Type-Options"); nosniff != "nosniff" {
+			t.Errorf("expected nosniff, got %q", nosniff)
+		}
+		if string(body) != "a,b\n1,2\n" {
+			t.Errorf("expected the content as is, got %q", body)
+		}
+		if csp := res.Header.Get("Content-Security-Policy"); csp != "sandbox" {
+			t.Errorf("expected the content to be sandboxed, got %q", csp)
+		}
+		if cd := res.Header.Get("Content-Disposition"); cd != "" {
+			t.Errorf("expected csv content to be displayed, got Content-Disposition %q", cd)
+		}
+	})
+
+	t.Run("content that may run scripts is an attachment", func(t *testing.T) {
+		for _, contentType := range []string{"text/html", "image/svg+xml", "TEXT/HTML; charset=utf-8"} {
+			res := get("files", post("files", contentType))
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("handleGetDocument() = %v, want %v", res.StatusCode, http.StatusOK)
+			}
+			if cd := res.Header.Get("Content-Disposition"); cd != "attachment" {
+				t.Errorf("expected %s content to be an attachment, got Content-Disposition %q", contentType, cd)
+			}
+			if csp := res.Header.Get("Content-Security-Policy"); csp != "sandbox" {
+				t.Errorf("expected %s content to be sandboxed, got %q", contentType, csp)
+			}
+		}
+	})
+
+	t.Run("documents are returned as json in other namespaces", func(t *testing.T) {
+		res := get("notes", post("notes", "text/csv"))
+		body, _ := ioutil.ReadAll(res.Body)
+		if ct := res.Header.Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("expected content type application/json, got %q", ct)
+		}
+		var d influxdb.Document
+		if err := json.Unmarshal(body, &d); err != nil {
+			t.Fatal(err)
+		}
+		if d.Meta.ContentType != "text/csv" {
+			t.Errorf("expected the content type to be echoed in meta, got %q", d.Meta.ContentType)
+		}
+	})
+
+	t.Run("documents without a content type are returned as json", func(t *testing.T) {
+		res := get("files", post("files", ""))
+		if ct := res.Header.Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("expected content type application/json, got %q", ct)
+		}
+	})
+
+	t.Run("content type must be a media type", func(t *testing.T) {
+		if w := post("files", "not a type"); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("handlePostDocument() = %v, want %v: %s", w.Code, http.StatusUnprocessableEntity, w.Body.String())
+		}
+	})
+}
+
+func TestService_handleDeleteDocumentLabel_RequireLabels(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l1 := &influxdb.Label{Name: "l1"}
+	l2 := &influxdb.Label{Name: "l2"}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, ns := range []string{"required", "optional"} {
+		if _, err := svc.CreateDocumentStore(ctx, ns); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+	h.Namespaces = map[string]DocumentNamespaceConfig{
+		"required": {RequireLabels: true},
+		"optional": {},
+	}
+
+	create := func(ns string) *influxdb.Document {
+		t.Helper()
+		s, err := svc.FindDocumentStore(ctx, ns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "content"}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(l1.ID), influxdb.WithLabelID(l2.ID)); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	remove := func(ns string, d *influxdb.Document, l *influxdb.Label) int {
+		w := httptest.NewRecorder()
+		h.handleDeleteDocumentLabel(w, newDocumentRequest("DELETE", "http://any.url", "", auth,
+			httprouter.Param{Key: "ns", Value: ns},
+			httprouter.Param{Key: "id", Value: d.ID.String()},
+			httprouter.Param{Key: "lid", Value: l.ID.String()}))
+		return w.Code
+	}
+
+	t.Run("the last label cannot be removed when labels are required", func(t *testing.T) {
+		d := create("required")
+		if code := remove("required", d, l1); code != http.StatusNoContent {
+			t.Fatalf("removing the first label = %v, want %v", code, http.StatusNoContent)
+		}
+		if code := remove("required", d, l2); code != http.StatusConflict {
+			t.Fatalf("removing the last label = %v, want %v", code, http.StatusConflict)
+		}
+
+		ls, err := svc.FindResourceLabels(ctx, influxdb.LabelMappingFilter{
+			ResourceID:   d.ID,
+			ResourceType: influxdb.DocumentsResourceType,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ls) != 1 || ls[0].ID != l2.ID {
+			t.Errorf("expected the document to keep label l2, got %v", ls)
+		}
+	})
+
+	t.Run("the last label can be removed otherwise", func(t *testing.T) {
+		d := create("optional")
+		for _, l := range []*influxdb.Label{l1, l2} {
+			if code := remove("optional", d, l); code != http.StatusNoContent {
+				t.Fatalf("removing label %s = %v, want %v", l.Name, code, http.StatusNoContent)
+			}
+		}
+	})
+}
+
+func TestService_handleGetDocumentDiff_JSON(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.CreateDocumentStore(ctx, "notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d1 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: map[string]interface{}{"a": "1", "b": "2"}}
+	d2 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d2"}, Content: map[string]interface{}{"a": "x", "b": "y"}}
+	for _, d := range []*influxdb.Document{d1, d2} {
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+
+	diff := func(format string, against influxdb.ID) *http.Response {
+		w := httptest.NewRecorder()
+		h.handleGetDocumentDiff(w, newDocumentRequest("GET", "http://any.url?format="+format+"&against="+against.String(), "", auth,
+			httprouter.Param{Key: "ns", Value: "notes"},
+			httprouter.Param{Key: "id", Value: d1.ID.String()}))
+		return w.Result()
+	}
+
+	t.Run("hunks are returned as json", func(t *testing.T) {
+		res := diff("json", d2.ID)
+		body, _ := ioutil.ReadAll(res.Body)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("handleGetDocumentDiff() = %v, want %v: %s", res.StatusCode, http.StatusOK, body)
+		}
+
+		want := fmt.Sprintf(`{
+  "from": "%s",
+  "to": "%s",
+  "hunks": [
+    {
+      "fromLine": 1,
+      "fromCount": 4,
+      "toLine": 1,
+      "toCount": 4,
+      "lines": [
+        {"op": "context", "text": "{"},
+        {"op": "removed", "text": "  \"a\": \"1\","},
+        {"op": "removed", "text": "  \"b\": \"2\""},
+        {"op": "added", "text": "  \"a\": \"x\","},
+        {"op": "added", "text": "  \"b\": \"y\""},
+        {"op": "context", "text": "}"}
+      ]
+    }
+  ]
+}`, d1.ID, d2.ID)
+		if eq, diff, err := jsonEqual(string(body), want); err != nil || !eq {
+			t.Errorf("handleGetDocumentDiff() = ***%s***\n%v", diff, err)
+		}
+	})
+
+	t.Run("identical content has no hunks", func(t *testing.T) {
+		res := diff("json", d1.ID)
+		body, _ := ioutil.ReadAll(res.Body)
+		want := fmt.Sprintf(`{"from": "%s", "to": "%s", "hunks": []}`, d1.ID, d1.ID)
+		if eq, diff, err := jsonEqual(string(body), want); err != nil || !eq {
+			t.Errorf("handleGetDocumentDiff() = ***%s***\n%v", diff, err)
+		}
+	})
+
+	t.Run("unknown formats are rejected", func(t *testing.T) {
+		if res := diff("html", d2.ID); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("handleGetDocumentDiff() = %v, want %v", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestService_handleGetDocuments_Limit(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"d1", "d2", "d3"} {
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: name}, Content: map[string]interface{}{}}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.MaxDocumentsPageSize = 2
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	list := func(target string) (*http.Response, *documentsResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.handleGetDocuments(w, newDocumentRequest("GET", "http://any.url"+target, "", auth,
+			httprouter.Param{Key: "ns", Value: "templates"}))
+
+		res := w.Result()
+		body, _ := ioutil.ReadAll(res.Body)
+		if res.StatusCode != http.StatusOK {
+			return res, nil
+		}
+		var got documentsResponse
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatal(err)
+		}
+		return res, &got
+	}
+
+	t.Run("limits over the maximum are clamped", func(t *testing.T) {
+		res, got := list("/api/v2/documents/templates?orgID=" + o.ID.String() + "&limit=10")
+		if got == nil {
+			t.Fatalf("handleGetDocuments() = %v, want %v", res.StatusCode, http.StatusOK)
+		}
+		if h := res.Header.Get("X-Truncated-Limit"); h != "2" {
+			t.Errorf("expected X-Truncated-Limit 2, got %q", h)
+		}
+		if len(got.Documents) != 2 {
+			t.Fatalf("expected 2 documents, got %d", len(got.Documents))
+		}
+		if got.Links == nil || got.Links.Next == "" {
+			t.Fatalf("expected a link to the next documents")
+		}
+
+		_, next := list(got.Links.Next)
+		if next == nil || len(next.Documents) != 1 || next.Documents[0].Meta.Name != "d3" {
+			t.Errorf("expected the next documents to be d3, got %+v", next)
+		}
+	})
+
+	t.Run("limits within the maximum are kept", func(t *testing.T) {
+		res, got := list("/api/v2/documents/templates?orgID=" + o.ID.String() + "&limit=1")
+		if got == nil {
+			t.Fatalf("handleGetDocuments() = %v, want %v", res.StatusCode, http.StatusOK)
+		}
+		if h, ok := res.Header["X-Truncated-Limit"]; ok {
+			t.Errorf("expected no X-Truncated-Limit, got %q", h)
+		}
+		if len(got.Documents) != 1 {
+			t.Errorf("expected 1 document, got %d", len(got.Documents))
+		}
+	})
+
+	t.Run("limits must be positive", func(t *testing.T) {
+		res, _ := list("/api/v2/documents/templates?orgID=" + o.ID.String() + "&limit=0")
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("handleGetDocuments() = %v, want %v", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
+
+// interleavingDocumentService serves a single document store that runs interleave before the
+// first update made through it, so that another request can be served in between the reads and
+// the update of a request.
+type interleavingDocumentService struct {
+	influxdb.DocumentService
+	store *interleavingDocumentStore
+}
+
+func (s *interleavingDocumentService) FindDocumentStore(ctx context.Context, ns string) (influxdb.DocumentStore, error) {
+	return s.store, nil
+}
+
+type interleavingDocumentStore struct {
+	influxdb.DocumentStore
+	interleaved bool
+	interleave  func()
+}
+
+func (s *interleavingDocumentStore) UpdateDocumentLabels(ctx context.Context, id influxdb.ID, opts ...influxdb.DocumentOptions) ([]*influxdb.Label, error) {
+	if !s.interleaved {
+		s.interleaved = true
+		s.interleave()
+	}
+	return s.DocumentStore.(influxdb.DocumentLabeler).UpdateDocumentLabels(ctx, id, opts...)
+}
+
+func (s *interleavingDocumentStore) DeleteDocuments(ctx context.Context, opts ...influxdb.DocumentFindOptions) error {
+	if !s.interleaved {
+		s.interleaved = true
+		s.interleave()
+	}
+	return s.DocumentStore.DeleteDocuments(ctx, opts...)
+}
+
+func TestService_handleDocumentLabels_InterleavedLimits(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	l1 := &influxdb.Label{Name: "l1"}
+	l2 := &influxdb.Label{Name: "l2"}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := svc.CreateDocumentStore(ctx, "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	tests := []struct {
+		name   string
+		labels []influxdb.ID
+		config DocumentNamespaceConfig
+		// interleaved is the label change made after the request has read the document.
+		interleaved influxdb.DocumentOptions
+		method      string
+		target      string
+		body        string
+		code        int
+		want        []influxdb.ID
+	}{
+		{
+			name:        "attach over the limit",
+			config:      DocumentNamespaceConfig{MaxLabelsPerDocument: 1},
+			interleaved: influxdb.WithLabelID(l2.ID),
+			method:      "POST",
+			target:      "/labels?atomic=true",
+			body:        fmt.Sprintf(`{"labelID": %q}`, l1.ID),
+			code:        http.StatusUnprocessableEntity,
+			want:        []influxdb.ID{l2.ID},
+		},
+		{
+			name:        "attach one by one over the limit",
+			config:      DocumentNamespaceConfig{MaxLabelsPerDocument: 1},
+			interleaved: influxdb.WithLabelID(l2.ID),
+			method:      "POST",
+			target:      "/labels?atomic=false",
+			body:        fmt.Sprintf(`{"labelID": %q}`, l1.ID),
+			code:        http.StatusMultiStatus,
+			want:        []influxdb.ID{l2.ID},
+		},
+		{
+			name:        "detach the last label",
+			labels:      []influxdb.ID{l1.ID, l2.ID},
+			config:      DocumentNamespaceConfig{RequireLabels: true},
+			interleaved: influxdb.WithoutLabelID(l2.ID),
+			method:      "DELETE",
+			target:      "/labels/" + l1.ID.String(),
+			code:        http.StatusConflict,
+			want:        []influxdb.ID{l1.ID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []influxdb.DocumentOptions{}
+			for _, id := range tt.labels {
+				opts = append(opts, influxdb.WithLabelID(id))
+			}
+			d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "content"}
+			if err := s.CreateDocument(ctx, d, opts...); err != nil {
+				t.Fatal(err)
+			}
+
+			store := &interleavingDocumentStore{DocumentStore: s}
+			store.interleave = func() {
+				if _, err := s.(influxdb.DocumentLabeler).UpdateDocumentLabels(ctx, d.ID, tt.interleaved); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			h := NewDocumentHandler(NewMockDocumentBackend())
+			h.LabelService = svc
+			h.DocumentService = &interleavingDocumentService{DocumentService: svc, store: store}
+			h.Namespaces = map[string]DocumentNamespaceConfig{"template": tt.config}
+
+			w := httptest.NewRecorder()
+			target := fmt.Sprintf("http://any.url/api/v2/documents/template/%s%s", d.ID, tt.target)
+			h.ServeHTTP(w, newDocumentRequest(tt.method, target, tt.body, auth))
+			if w.Code != tt.code {
+				t.Fatalf("%s %s = %v, want %v: %s", tt.method, tt.target, w.Code, tt.code, w.Body.String())
+			}
+
+			ls, err := svc.FindResourceLabels(ctx, influxdb.LabelMappingFilter{
+				ResourceID:   d.ID,
+				ResourceType: influxdb.DocumentsResourceType,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []influxdb.ID
+			for _, l := range ls {
+				got = append(got, l.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected the document to be mapped to %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestService_handlePostDocumentLabel_NotAtomicDeleted(t *testing.T) {
+	id := influxtesting.MustIDBase16("020f755c3c082010")
+	labelID := influxtesting.MustIDBase16("020f755c3c082020")
+
+	// the document is found by the request, and is deleted before its labels are read back.
+	var finds int
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.LabelService = &mock.LabelService{
+		FindLabelByIDFn: func(ctx context.Context, id influxdb.ID) (*influxdb.Label, error) {
+			return &influxdb.Label{ID: id, Name: "l1"}, nil
+		},
+	}
+	h.DocumentService = &mock.DocumentService{
+		FindDocumentStoreFn: func(context.Context, string) (influxdb.DocumentStore, error) {
+			return &mock.DocumentStore{
+				FindDocumentsFn: func(ctx context.Context, opts ...influxdb.DocumentFindOptions) ([]*influxdb.Document, error) {
+					finds++
+					if finds > 1 {
+						return nil, nil
+					}
+					return []*influxdb.Document{{ID: id, Meta: influxdb.DocumentMeta{Name: "d1"}}}, nil
+				},
+			}, nil
+		},
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	w := httptest.NewRecorder()
+	target := fmt.Sprintf("http://any.url/api/v2/documents/template/%s/labels?atomic=false", id)
+	h.ServeHTTP(w, newDocumentRequest("POST", target, fmt.Sprintf(`{"labelID": %q}`, labelID), auth))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("handlePostDocumentLabel() = %v, want %v: %s", w.Code, http.StatusNotFound, w.Body.String())
+	}
+}
+
+// emptyDocumentService serves the document stores of a document service, except that no
+// documents are found in the namespace ns, as if they were removed as soon as they were written.
+type emptyDocumentService struct {
+	influxdb.DocumentService
+	ns string
+}
+
+func (s *emptyDocumentService) FindDocumentStore(ctx context.Context, ns string) (influxdb.DocumentStore, error) {
+	if ns != s.ns {
+		return s.DocumentService.FindDocumentStore(ctx, ns)
+	}
+	return &mock.DocumentStore{
+		FindDocumentsFn: func(ctx context.Context, opts ...influxdb.DocumentFindOptions) ([]*influxdb.Document, error) {
+			return nil, nil
+		},
+	}, nil
+}
+
+func TestService_handleDocumentLabels_Interleaved(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	l1 := &influxdb.Label{Name: "l1"}
+	l2 := &influxdb.Label{Name: "l2"}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := svc.CreateDocumentStore(ctx, "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+
+	tests := []struct {
+		name   string
+		atomic string
+	}{
+		{name: "atomic attach", atomic: "true"},
+		{name: "attach one by one", atomic: "false"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "content"}
+			if err := s.CreateDocument(ctx, d, influxdb.WithLabelID(l1.ID)); err != nil {
+				t.Fatal(err)
+			}
+
+			h := NewDocumentHandler(NewMockDocumentBackend())
+			h.LabelService = svc
+			params := []httprouter.Param{
+				{Key: "ns", Value: "template"},
+				{Key: "id", Value: d.ID.String()},
+			}
+
+			// l1 is detached and the content of the document is updated after the attach of l2
+			// has read the document, but before it attaches the label.
+			var detached int
+			store := &interleavingDocumentStore{DocumentStore: s}
+			store.interleave = func() {
+				w := httptest.NewRecorder()
+				h.handleDeleteDocumentLabel(w, newDocumentRequest("DELETE", "http://any.url", "", auth,
+					append(params, httprouter.Param{Key: "lid", Value: l1.ID.String()})...))
+				detached = w.Code
+
+				u := &influxdb.Document{ID: d.ID, Meta: d.Meta, Content: "updated"}
+				if err := s.UpdateDocument(ctx, u); err != nil {
+					t.Fatal(err)
+				}
+			}
+			h.DocumentService = &interleavingDocumentService{DocumentService: svc, store: store}
+
+			w := httptest.NewRecorder()
+			h.handlePostDocumentLabel(w, newDocumentRequest("POST", "http://any.url?atomic="+tt.atomic,
+				fmt.Sprintf(`{"labelID": %q}`, l2.ID), auth, params...))
+			if detached != http.StatusNoContent {
+				t.Fatalf("detaching l1 = %v, want %v", detached, http.StatusNoContent)
+			}
+			if w.Code != http.StatusCreated {
+				t.Fatalf("attaching l2 = %v, want %v: %s", w.Code, http.StatusCreated, w.Body.String())
+			}
+
+			ls, err := svc.FindResourceLabels(ctx, influxdb.LabelMappingFilter{
+				ResourceID:   d.ID,
+				ResourceType: influxdb.DocumentsResourceType,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ls) != 1 || ls[0].ID != l2.ID {
+				t.Errorf("expected the document to be mapped to l2 only, got %v", ls)
+			}
+
+			res := struct {
+				Labels []*influxdb.Label `json:"labels"`
+			}{}
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Labels) != len(ls) || len(res.Labels) == 1 && res.Labels[0].ID != ls[0].ID {
+				t.Errorf("expected the labels returned to match the mappings %v, got %v", ls, res.Labels)
+			}
+
+			ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeContent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ds[0].Content != "updated" {
+				t.Errorf("expected the updated content to be kept, got %v", ds[0].Content)
+			}
+		})
+	}
+}
+
+func TestService_handlePostDocumentLabel_IfMatchInterleaved(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.CreateDocumentStore(ctx, "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "content"}
+	if err := s.CreateDocument(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	etag, err := documentETag(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.LabelService = svc
+
+	// the document is updated after the attach has read it, but before it attaches the label.
+	store := &interleavingDocumentStore{DocumentStore: s}
+	store.interleave = func() {
+		u := &influxdb.Document{ID: d.ID, Meta: d.Meta, Content: "updated"}
+		if err := s.UpdateDocument(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.DocumentService = &interleavingDocumentService{DocumentService: svc, store: store}
+
+	w := httptest.NewRecorder()
+	r := newDocumentRequest("POST", "http://any.url", fmt.Sprintf(`{"labelID": %q}`, l.ID), auth,
+		httprouter.Param{Key: "ns", Value: "template"},
+		httprouter.Param{Key: "id", Value: d.ID.String()})
+	r.Header.Set("If-Match", etag)
+	h.handlePostDocumentLabel(w, r)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("handlePostDocumentLabel() = %v, want %v: %s", w.Code, http.StatusConflict, w.Body.String())
+	}
+
+	ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeLabels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds[0].Labels) != 0 {
+		t.Errorf("expected no label to be attached to the modified document, got %v", ds[0].Labels)
+	}
+}
+
+func TestDocumentHandler_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.CreateDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: map[string]interface{}{}}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(l.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := NewMockDocumentBackend()
+	backend.ReadOnly = true
+	h := NewDocumentHandler(backend)
+	h.DocumentService = svc
+	h.LabelService = svc
+	h.Schemas = nil
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	docPath := "http://any.url/api/v2/documents/templates/" + d.ID.String()
+
+	t.Run("writes are rejected", func(t *testing.T) {
+		tests := []struct {
+			method string
+			target string
+			body   string
+		}{
+			{method: "POST", target: "http://any.url/api/v2/documents/templates", body: fmt.Sprintf(`{"meta":{"name":"d2"},"content":{},"orgID":"%s"}`, o.ID)},
+			{method: "PUT", target: docPath, body: `{"meta":{"name":"d1"},"content":{"a":1}}`},
+			{method: "DELETE", target: docPath},
+			{method: "POST", target: docPath + "/labels", body: fmt.Sprintf(`{"labelID":"%s"}`, l.ID)},
+			{method: "DELETE", target: docPath + "/labels/" + l.ID.String()},
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, newDocumentRequest(tt.method, tt.target, tt.body, auth))
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("%s %s = %v, want %v: %s", tt.method, tt.target, w.Code, http.StatusServiceUnavailable, w.Body.String())
+				continue
+			}
+
+			var e influxdb.Error
+			if err := json.NewDecoder(w.Body).Decode(&e); err != nil {
+				t.Fatal(err)
+			}
+			if e.Code != influxdb.EUnavailable {
+				t.Errorf("%s %s: expected code %q, got %q", tt.method, tt.target, influxdb.EUnavailable, e.Code)
+			}
+		}
+
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeContent, influxdb.IncludeLabels)
+		if err != nil {
+			t.Fatalf("expected the document to remain: %v", err)
+		}
+		if len(ds) != 1 || len(ds[0].Labels) != 1 || !reflect.DeepEqual(ds[0].Content, map[string]interface{}{}) {
+			t.Errorf("expected the document to be unchanged, got %v", ds)
+		}
+	})
+
+	t.Run("reads are served", func(t *testing.T) {
+		for _, target := range []string{
+			"http://any.url/api/v2/documents/templates?orgID=" + o.ID.String(),
+			docPath,
+			docPath + "/labels",
+		} {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, newDocumentRequest("GET", target, "", auth))
+			if w.Code != http.StatusOK {
+				t.Errorf("GET %s = %v, want %v: %s", target, w.Code, http.StatusOK, w.Body.String())
+			}
+		}
+	})
+}
+
+// TestDocumentHandler_EmptyResults pins the contract of empty results: lists are encoded as empty
+// arrays, never null or not found, when nothing matches, while single resources are not found.
+func TestDocumentHandler_EmptyResults(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+	h.OrganizationService = svc
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	get := func(target string) (int, []byte) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, newDocumentRequest("GET", "http://any.url"+target, "", auth))
+		return w.Code, w.Body.Bytes()
+	}
+	missing := influxdb.ID(1000).String()
+
+	lists := []struct {
+		name   string
+		target string
+		field  string
+	}{
+		{name: "documents of an org without documents", target: "/api/v2/documents/templates?orgID=" + o.ID.String(), field: "documents"},
+	}
+	t.Run("empty namespace", func(t *testing.T) {
+		for _, tt := range lists {
+			assertEmptyList(t, tt.name, tt.field, get, tt.target)
+		}
+	})
+
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: map[string]interface{}{}}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatal(err)
+	}
+	docPath := "/api/v2/documents/templates/" + d.ID.String()
+
+	lists = []struct {
+		name   string
+		target string
+		field  string
+	}{
+		{name: "documents not matching a label", target: "/api/v2/documents/templates?orgID=" + o.ID.String() + "&label=l1", field: "documents"},
+		{name: "documents without labels", target: "/api/v2/documents/templates?orgID=" + o.ID.String() + "&hasLabels=true", field: "documents"},
+		{name: "documents not matching a name", target: "/api/v2/documents/templates/by-name/missing?orgID=" + o.ID.String(), field: "documents"},
+		{name: "labels of a document without labels", target: docPath + "/labels", field: "labels"},
+		{name: "labels past the last label", target: docPath + "/labels?offset=10&limit=5", field: "labels"},
+	}
+	t.Run("lists are empty arrays", func(t *testing.T) {
+		for _, tt := range lists {
+			assertEmptyList(t, tt.name, tt.field, get, tt.target)
+		}
+	})
+
+	t.Run("single resources are not found", func(t *testing.T) {
+		for _, target := range []string{
+			"/api/v2/documents/templates/" + missing,
+			"/api/v2/documents/templates/" + missing + "/labels",
+		} {
+			if code, body := get(target); code != http.StatusNotFound {
+				t.Errorf("GET %s = %v, want %v: %s", target, code, http.StatusNotFound, body)
+			}
+		}
+	})
+}
+
+func assertEmptyList(t *testing.T, name, field string, get func(string) (int, []byte), target string) {
+	t.Helper()
+
+	code, body := get(target)
+	if code != http.StatusOK {
+		t.Errorf("%s: GET %s = %v, want %v: %s", name, target, code, http.StatusOK, body)
+		return
+	}
+
+	var res map[string]json.RawMessage
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatalf("%s: failed to decode response: %v", name, err)
+	}
+	if got := string(res[field]); got != "[]" {
+		t.Errorf("%s: expected %q to be an empty array, got %s", name, field, body)
+	}
+}
+
+func TestDocumentWebhookNotifier(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+
+	// the webhook fails the first time each event is posted, so that every event is retried.
+	events := make(chan influxdb.DocumentEvent, 10)
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		attempts[string(body)]++
+		n := attempts[string(body)]
+		mu.Unlock()
+		if n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var e influxdb.DocumentEvent
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Errorf("failed to decode event: %v", err)
+		}
+		events <- e
+	}))
+	defer srv.Close()
+
+	n := NewDocumentWebhookNotifier(zap.NewNop(), map[string]DocumentWebhook{
+		"templates": {URL: srv.URL, Backoff: time.Millisecond},
+	})
+	defer n.Close()
+	unsubscribe := svc.SubscribeDocumentEvents(n.Notify)
+	defer unsubscribe()
+
+	h := NewDocumentHandler(NewMockDocumentBackend())
+	h.DocumentService = svc
+	h.LabelService = svc
+	h.Schemas = nil
+
+	auth := &influxdb.Authorization{
+		Status:      influxdb.Active,
+		Permissions: influxdb.OperPermissions(),
+	}
+	serve := func(method, target, body string, status int) []byte {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, newDocumentRequest(method, "http://any.url"+target, body, auth))
+		if w.Code != status {
+			t.Fatalf("%s %s = %v, want %v: %s", method, target, w.Code, status, w.Body.String())
+		}
+		return w.Body.Bytes()
+	}
+	next := func() influxdb.DocumentEvent {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the webhook to be posted to")
+			return influxdb.DocumentEvent{}
+		}
+	}
+
+	var d documentResponse
+	body := serve("POST", "/api/v2/documents/templates", fmt.Sprintf(`{"meta":{"name":"d1"},"content":{},"orgID":"%s"}`, o.ID), http.StatusCreated)
+	if err := json.Unmarshal(body, &d); err != nil {
+		t.Fatal(err)
+	}
+	docPath := "/api/v2/documents/templates/" + d.ID.String()
+	serve("PUT", docPath, `{"meta":{"name":"d1"},"content":{"a":1}}`, http.StatusOK)
+	serve("DELETE", docPath, "", http.StatusNoContent)
+
+	for _, op := range []influxdb.DocumentOperation{influxdb.DocumentCreated, influxdb.DocumentUpdated, influxdb.DocumentDeleted} {
+		want := influxdb.DocumentEvent{Namespace: "templates", ID: d.ID, Operation: op}
+		if got := next(); got != want {
+			t.Errorf("expected event %+v, got %+v", want, got)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for e, n := range attempts {
+		if n != 2 {
+			t.Errorf("expected event %s to be posted twice, got %d", e, n)
+		}
+	}
+}
+
+func TestDocumentWebhooks(t *testing.T) {
+	ctx := context.Background()
+	svc, closeSvc := newTestDocumentService(t)
+	defer closeSvc()
+
+	events := make(chan influxdb.DocumentEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e influxdb.DocumentEvent
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Errorf("failed to decode event: %v", err)
+		}
+		events <- e
+	}))
+	defer srv.Close()
+
+	hooks := DocumentWebhooks(map[string]DocumentNamespaceConfig{
+		"templates": {Webhook: &DocumentWebhook{URL: srv.URL}},
+		"notes":     {UniqueNames: true},
+	})
+	if len(hooks) != 1 {
+		t.Fatalf("expected the webhook of templates only, got %v", hooks)
+	}
+
+	n := NewDocumentWebhookNotifier(zap.NewNop(), hooks)
+	defer n.Close()
+	unsubscribe := svc.SubscribeDocumentEvents(n.Notify)
+	defer unsubscribe()
+
+	s, err := svc.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: map[string]interface{}{}}
+	if err := s.CreateDocument(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-events:
+		want := influxdb.DocumentEvent{Namespace: "templates", ID: d.ID, Operation: influxdb.DocumentCreated}
+		if e != want {
+			t.Errorf("expected event %+v, got %+v", want, e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the webhook of the namespace to be posted to")
+	}
+}
@@ -0,0 +1,275 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/influxdata/influxdb"
+)
+
+// documentProblem is a single reason a document is invalid, scoped to the field at fault.
+type documentProblem struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// documentValidationError reports every problem found when validating a document.
+type documentValidationError struct {
+	Problems []documentProblem
+}
+
+func (e *documentValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", p.Field, p.Message))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// message summarizes the problems. A single problem is reported as is.
+func (e *documentValidationError) message() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].Message
+	}
+	return fmt.Sprintf("document has %d problems", len(e.Problems))
+}
+
+// validateDocument validates the name and content of the document and the names of the labels
+// it is created with, collecting every problem rather than stopping at the first. The raw content
+// is the content as it was sent, as decoding it replaces bytes that are not valid UTF-8. In
+// namespaces allowing binary content, such content replaces that of the document, base64 encoded.
+func (h *DocumentHandler) validateDocument(ctx context.Context, ns string, d *influxdb.Document, raw json.RawMessage, labels []string) error {
+	e := &documentValidationError{}
+
+	if d.Meta.Name == "" {
+		e.Problems = append(e.Problems, documentProblem{
+			Field:   "meta.name",
+			Message: "document name must not be empty",
+		})
+	}
+
+	if ct := d.Meta.ContentType; ct != "" {
+		if _, _, err := mime.ParseMediaType(ct); err != nil {
+			e.Problems = append(e.Problems, documentProblem{
+				Field:   "meta.contentType",
+				Message: fmt.Sprintf("document content type %q is not a valid media type", ct),
+			})
+		}
+	}
+
+	if !utf8.Valid(raw) {
+		b, ok := unquoteJSONBytes(raw)
+		switch {
+		case !h.namespaceConfig(ns).AllowBinaryContent:
+			e.Problems = append(e.Problems, documentProblem{
+				Field:   "content",
+				Message: "document content must be valid UTF-8",
+			})
+		case !ok:
+			e.Problems = append(e.Problems, documentProblem{
+				Field:   "content",
+				Message: "binary document content must be a string",
+			})
+		default:
+			d.Content = base64.StdEncoding.EncodeToString(b)
+		}
+	}
+
+	if schema := h.namespaceConfig(ns).Schema; schema != nil {
+		for _, v := range schema.Violations(d.Content) {
+			e.Problems = append(e.Problems, documentProblem{Field: "content", Message: v})
+		}
+	}
+
+	for _, label := range labels {
+		ls, err := h.LabelService.FindLabels(ctx, influxdb.LabelFilter{Name: label})
+		if err != nil {
+			return err
+		}
+		if len(ls) == 0 {
+			e.Problems = append(e.Problems, documentProblem{
+				Field:   "labels",
+				Message: fmt.Sprintf("label %q not found", label),
+			})
+		}
+	}
+
+	if len(e.Problems) > 0 {
+		return e
+	}
+
+	return nil
+}
+
+// rawDocumentContent is the content of a document request as it was sent.
+type rawDocumentContent struct {
+	Content json.RawMessage `json:"content"`
+}
+
+// decodeDocumentBody decodes the body of a document request into v and returns the raw content
+// of the document it holds.
+func decodeDocumentBody(r io.Reader, v interface{}) (json.RawMessage, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return nil, documentDecodeError(b, err)
+	}
+
+	var raw rawDocumentContent
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, documentDecodeError(b, err)
+	}
+	return raw.Content, nil
+}
+
+// documentDecodeSnippetLen is the number of bytes of the body quoted on either side of the
+// position of a decode error.
+const documentDecodeSnippetLen = 20
+
+// documentDecodeError describes where decoding the document body failed, with the line and
+// column of the error and a snippet of the body around it, so that bad payloads can be fixed.
+// Errors other than JSON syntax and type errors are returned as is.
+func documentDecodeError(b []byte, err error) error {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return err
+	}
+
+	// offsets count the bytes read up to and including the byte at fault.
+	pos := int(offset) - 1
+	if pos < 0 {
+		pos = 0
+	}
+	if pos > len(b) {
+		pos = len(b)
+	}
+	line := 1 + bytes.Count(b[:pos], []byte("\n"))
+	col := pos - bytes.LastIndexByte(b[:pos], '\n')
+
+	start, end := pos-documentDecodeSnippetLen, pos+documentDecodeSnippetLen
+	if start < 0 {
+		start = 0
+	}
+	if end > len(b) {
+		end = len(b)
+	}
+
+	return &influxdb.Error{
+		Code: influxdb.EInvalid,
+		Msg:  fmt.Sprintf("invalid document body at line %d, column %d: %v, near %q", line, col, err, b[start:end]),
+		Err:  err,
+	}
+}
+
+// unquoteJSONBytes returns the bytes of the JSON string provided, keeping bytes that are not
+// valid UTF-8 rather than replacing them. It reports false if raw is not a JSON string.
+func unquoteJSONBytes(raw json.RawMessage) ([]byte, bool) {
+	if len(raw) < 2 || raw[0] != '"' || raw[len(raw)-1] != '"' {
+		return nil, false
+	}
+	s := raw[1 : len(raw)-1]
+
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			return nil, false
+		}
+		if c != '\\' {
+			b = append(b, c)
+			continue
+		}
+
+		i++
+		if i == len(s) {
+			return nil, false
+		}
+		switch s[i] {
+		case '"', '\\', '/':
+			b = append(b, s[i])
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'u':
+			r, ok := unquoteJSONRune(s[i+1:])
+			if !ok {
+				return nil, false
+			}
+			i += 4
+			// runes outside of the basic multilingual plane are escaped as a surrogate pair.
+			if utf16.IsSurrogate(r) && i+2 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+				if r2, ok := unquoteJSONRune(s[i+3:]); ok {
+					if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+						r = dec
+						i += 6
+					}
+				}
+			}
+			b = append(b, string(r)...)
+		default:
+			return nil, false
+		}
+	}
+	return b, true
+}
+
+// unquoteJSONRune decodes the four hex digits of a \u escape.
+func unquoteJSONRune(s []byte) (rune, bool) {
+	if len(s) < 4 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(string(s[:4]), 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return rune(n), true
+}
+
+// encodeDocumentValidationError encodes a documentValidationError as an unprocessable entity
+// listing every problem. Any other error is encoded by EncodeError.
+func encodeDocumentValidationError(ctx context.Context, err error, w http.ResponseWriter) {
+	e, ok := err.(*documentValidationError)
+	if !ok {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	w.Header().Set(PlatformErrorCodeHeader, influxdb.EUnprocessableEntity)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	b, _ := json.Marshal(struct {
+		Code    string            `json:"code"`
+		Message string            `json:"message"`
+		Errors  []documentProblem `json:"errors"`
+	}{
+		Code:    influxdb.EUnprocessableEntity,
+		Message: e.message(),
+		Errors:  e.Problems,
+	})
+	_, _ = w.Write(b)
+}
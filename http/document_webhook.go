@@ -0,0 +1,185 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb"
+	"go.uber.org/zap"
+)
+
+// DocumentWebhook is an HTTP endpoint notified of the documents created, updated and deleted in
+// a namespace. Each change is posted as a JSON document event.
+type DocumentWebhook struct {
+	// URL is the endpoint that events are posted to.
+	URL string
+	// MaxAttempts is the number of times an event is posted before it is dropped. Zero means
+	// DefaultDocumentWebhookAttempts.
+	MaxAttempts int
+	// Backoff is the delay before an event is posted again, doubled for every further attempt.
+	// Zero means DefaultDocumentWebhookBackoff.
+	Backoff time.Duration
+}
+
+// DefaultDocumentWebhookAttempts is the number of times an event is posted to a webhook by default.
+const DefaultDocumentWebhookAttempts = 3
+
+// DefaultDocumentWebhookBackoff is the delay before an event is posted to a webhook again by default.
+const DefaultDocumentWebhookBackoff = time.Second
+
+// documentWebhookQueueSize is the number of events that may wait to be posted to a webhook.
+// Events are dropped once it is full rather than holding up the writes that made them.
+const documentWebhookQueueSize = 1000
+
+// DocumentWebhookNotifier posts the changes made to documents to the webhooks of their
+// namespaces. Events are posted in the order they are notified, asynchronously, so that writes
+// are not held up by webhooks.
+type DocumentWebhookNotifier struct {
+	Logger *zap.Logger
+	Client *http.Client
+
+	queues map[string]chan influxdb.DocumentEvent
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewDocumentWebhookNotifier returns a DocumentWebhookNotifier posting to the webhooks of the
+// namespaces provided. It posts until it is closed.
+func NewDocumentWebhookNotifier(logger *zap.Logger, hooks map[string]DocumentWebhook) *DocumentWebhookNotifier {
+	n := &DocumentWebhookNotifier{
+		Logger: logger,
+		Client: &http.Client{Timeout: 10 * time.Second},
+		queues: make(map[string]chan influxdb.DocumentEvent, len(hooks)),
+		stop:   make(chan struct{}),
+	}
+
+	for ns, hook := range hooks {
+		if hook.MaxAttempts == 0 {
+			hook.MaxAttempts = DefaultDocumentWebhookAttempts
+		}
+		if hook.Backoff == 0 {
+			hook.Backoff = DefaultDocumentWebhookBackoff
+		}
+
+		q := make(chan influxdb.DocumentEvent, documentWebhookQueueSize)
+		n.queues[ns] = q
+
+		n.wg.Add(1)
+		go func(hook DocumentWebhook) {
+			defer n.wg.Done()
+			n.post(hook, q)
+		}(hook)
+	}
+
+	return n
+}
+
+// DocumentWebhooks returns the webhooks configured for the namespaces provided.
+func DocumentWebhooks(nss map[string]DocumentNamespaceConfig) map[string]DocumentWebhook {
+	hooks := map[string]DocumentWebhook{}
+	for ns, c := range nss {
+		if c.Webhook != nil {
+			hooks[ns] = *c.Webhook
+		}
+	}
+	return hooks
+}
+
+// Notify queues the event to be posted to the webhook of its namespace, if it has one. Only
+// documents being created, updated and deleted are posted. It does not block, and is meant to
+// be subscribed to an influxdb.DocumentChangefeed.
+func (n *DocumentWebhookNotifier) Notify(e influxdb.DocumentEvent) {
+	switch e.Operation {
+	case influxdb.DocumentCreated, influxdb.DocumentUpdated, influxdb.DocumentDeleted:
+	default:
+		return
+	}
+
+	q, ok := n.queues[e.Namespace]
+	if !ok {
+		return
+	}
+
+	select {
+	case q <- e:
+	default:
+		n.Logger.Warn("Dropping document event, too many events are waiting for the webhook",
+			zap.String("namespace", e.Namespace),
+			zap.Stringer("id", e.ID),
+			zap.String("operation", string(e.Operation)))
+	}
+}
+
+// Close stops posting events, dropping those that have not been posted yet.
+func (n *DocumentWebhookNotifier) Close() error {
+	close(n.stop)
+	n.wg.Wait()
+	return nil
+}
+
+// post posts the events of the queue to the webhook until the notifier is closed.
+func (n *DocumentWebhookNotifier) post(hook DocumentWebhook, q <-chan influxdb.DocumentEvent) {
+	for {
+		select {
+		case <-n.stop:
+			return
+		case e := <-q:
+			if !n.postEvent(hook, e) {
+				return
+			}
+		}
+	}
+}
+
+// postEvent posts the event to the webhook, trying again after a backoff if it fails. It
+// returns false if the notifier was closed while waiting to try again.
+func (n *DocumentWebhookNotifier) postEvent(hook DocumentWebhook, e influxdb.DocumentEvent) bool {
+	backoff := hook.Backoff
+	for attempt := 1; ; attempt++ {
+		err := n.postEventOnce(hook, e)
+		if err == nil {
+			return true
+		}
+
+		log := n.Logger.With(
+			zap.String("namespace", e.Namespace),
+			zap.Stringer("id", e.ID),
+			zap.String("operation", string(e.Operation)),
+			zap.Int("attempt", attempt),
+			zap.Error(err))
+		if attempt >= hook.MaxAttempts {
+			log.Error("Failed to post document event to webhook")
+			return true
+		}
+		log.Info("Failed to post document event to webhook, retrying", zap.Duration("backoff", backoff))
+
+		select {
+		case <-n.stop:
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (n *DocumentWebhookNotifier) postEventOnce(hook DocumentWebhook, e influxdb.DocumentEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	res, err := n.Client.Post(hook.URL, "application/json; charset=utf-8", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
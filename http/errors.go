@@ -99,6 +99,25 @@ func EncodeError(ctx context.Context, err error, w http.ResponseWriter) {
 	_, _ = w.Write(b)
 }
 
+// encodeConflictError encodes err with a 409 status if it is a conflict, such as a stale etag
+// or an operation that is already in progress, as conflicts are otherwise reported as
+// unprocessable entities. Any other error is encoded by EncodeError.
+func encodeConflictError(ctx context.Context, err error, w http.ResponseWriter) {
+	if platform.ErrorCode(err) != platform.EConflict {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	w.Header().Set(PlatformErrorCodeHeader, platform.EConflict)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusConflict)
+	b, _ := json.Marshal(&platform.Error{
+		Code: platform.EConflict,
+		Msg:  platform.ErrorMessage(err),
+	})
+	_, _ = w.Write(b)
+}
+
 // UnauthorizedError encodes a error message and status code for unauthorized access.
 func UnauthorizedError(ctx context.Context, w http.ResponseWriter) {
 	EncodeError(ctx, &platform.Error{
@@ -119,4 +138,5 @@ var statusCodePlatformError = map[string]int{
 	platform.EForbidden:           http.StatusForbidden,
 	platform.EUnauthorized:        http.StatusUnauthorized,
 	platform.EMethodNotAllowed:    http.StatusMethodNotAllowed,
+	platform.ETooManyRequests:     http.StatusTooManyRequests,
 }
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	pcontext "github.com/influxdata/influxdb/context"
 	"github.com/influxdata/influxdb/kit/prom"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
@@ -205,10 +206,15 @@ func logEncodingError(logger *zap.Logger, r *http.Request, err error) {
 	// If we encounter an error while encoding the response to an http request
 	// the best thing we can do is log that error, as we may have already written
 	// the headers for the http request in question.
-	logger.Info("error encoding response",
+	fields := []zap.Field{
 		zap.String("path", r.URL.Path),
 		zap.String("method", r.Method),
-		zap.Error(err))
+		zap.Error(err),
+	}
+	if ns := pcontext.NamespaceFromContext(r.Context()); ns != "" {
+		fields = append(fields, zap.String("namespace", ns))
+	}
+	logger.Info("error encoding response", fields...)
 }
 
 // InjectTrace writes any span from the request's context into the request headers.
@@ -1,8 +1,11 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+
+	"github.com/influxdata/influxdb"
 )
 
 // HealthHandler returns the status of the process.
@@ -12,3 +15,67 @@ func HealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintln(w, msg)
 }
+
+// HealthCheck checks a dependency of the process, such as a storage backend.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// DocumentHealthCheck returns a HealthCheck pinging the document service provided.
+func DocumentHealthCheck(s influxdb.DocumentService) HealthCheck {
+	return HealthCheck{
+		Name:  "documents",
+		Check: s.Ping,
+	}
+}
+
+type healthCheckResponse struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+type healthResponse struct {
+	Name    string                `json:"name"`
+	Message string                `json:"message"`
+	Status  string                `json:"status"`
+	Checks  []healthCheckResponse `json:"checks"`
+}
+
+// NewHealthHandler returns a handler reporting the status of the process together with the
+// result of each of the checks provided. The process fails when any of the checks fails, so
+// that a storage outage can be told apart from the process being unable to serve requests.
+func NewHealthHandler(checks ...HealthCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		res := healthResponse{
+			Name:    "influxdb",
+			Message: "ready for queries and writes",
+			Status:  "pass",
+			Checks:  []healthCheckResponse{},
+		}
+		code := http.StatusOK
+
+		for _, c := range checks {
+			check := healthCheckResponse{
+				Name:   c.Name,
+				Status: "pass",
+			}
+			if err := c.Check(ctx); err != nil {
+				check.Status = "fail"
+				check.Message = influxdb.ErrorMessage(err)
+
+				res.Status = "fail"
+				res.Message = "one or more health checks failed"
+				code = http.StatusServiceUnavailable
+			}
+			res.Checks = append(res.Checks, check)
+		}
+
+		if err := encodeResponse(ctx, w, code, res); err != nil {
+			fmt.Fprintf(w, "Error encoding health status: %v\n", err)
+		}
+	})
+}
@@ -1,10 +1,14 @@
 package http
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/mock"
 )
 
 func TestHealthHandler(t *testing.T) {
@@ -49,3 +53,45 @@ func TestHealthHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestNewHealthHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		ping       func(ctx context.Context) error
+		statusCode int
+		body       string
+	}{
+		{
+			name:       "reachable document store passes",
+			ping:       func(ctx context.Context) error { return nil },
+			statusCode: http.StatusOK,
+			body:       `{"name":"influxdb", "message":"ready for queries and writes", "status":"pass", "checks":[{"name":"documents","status":"pass"}]}`,
+		},
+		{
+			name: "failing document store fails",
+			ping: func(ctx context.Context) error {
+				return &influxdb.Error{Code: influxdb.EUnavailable, Msg: "document store is unreachable"}
+			},
+			statusCode: http.StatusServiceUnavailable,
+			body:       `{"name":"influxdb", "message":"one or more health checks failed", "status":"fail", "checks":[{"name":"documents","status":"fail","message":"document store is unreachable"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := mock.NewDocumentService()
+			svc.PingFn = tt.ping
+
+			w := httptest.NewRecorder()
+			NewHealthHandler(DocumentHealthCheck(svc)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			res := w.Result()
+			body, _ := ioutil.ReadAll(res.Body)
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("NewHealthHandler() = %v, want %v", res.StatusCode, tt.statusCode)
+			}
+			if eq, diff, _ := jsonEqual(string(body), tt.body); !eq {
+				t.Errorf("NewHealthHandler() = ***%s***", diff)
+			}
+		})
+	}
+}
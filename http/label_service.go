@@ -394,6 +394,24 @@ func newPostLabelHandler(b *LabelBackend) http.HandlerFunc {
 	}
 }
 
+// ensureLabelsExist returns an ENotFound error naming the first of the labels that does not exist.
+func ensureLabelsExist(ctx context.Context, s platform.LabelService, ids []platform.ID) error {
+	for _, id := range ids {
+		if _, err := s.FindLabelByID(ctx, id); err != nil {
+			if platform.ErrorCode(err) == platform.ENotFound {
+				return &platform.Error{
+					Code: platform.ENotFound,
+					Msg:  fmt.Sprintf("label %s not found", id),
+					Err:  err,
+				}
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 type postLabelMappingRequest struct {
 	Mapping platform.LabelMapping
 }
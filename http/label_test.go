@@ -577,3 +577,56 @@ func TestService_handlePatchLabel(t *testing.T) {
 		})
 	}
 }
+
+func Test_ensureLabelsExist(t *testing.T) {
+	l1 := platformtesting.MustIDBase16("020f755c3c082000")
+	l2 := platformtesting.MustIDBase16("020f755c3c082001")
+	missing := platformtesting.MustIDBase16("020f755c3c082002")
+
+	labelService := &mock.LabelService{
+		FindLabelByIDFn: func(ctx context.Context, id platform.ID) (*platform.Label, error) {
+			if id == l1 || id == l2 {
+				return &platform.Label{ID: id}, nil
+			}
+			return nil, &platform.Error{
+				Code: platform.ENotFound,
+				Err:  platform.ErrLabelNotFound,
+			}
+		},
+	}
+
+	tests := []struct {
+		name string
+		ids  []platform.ID
+		err  string
+	}{
+		{
+			name: "all labels exist",
+			ids:  []platform.ID{l1, l2},
+		},
+		{
+			name: "one label missing",
+			ids:  []platform.ID{l1, missing, l2},
+			err:  fmt.Sprintf("label %s not found", missing),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ensureLabelsExist(context.Background(), labelService, tt.ids)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("ensureLabelsExist() unexpected error: %v", err)
+				}
+				return
+			}
+
+			if code := platform.ErrorCode(err); code != platform.ENotFound {
+				t.Errorf("ensureLabelsExist() error code = %q, want %q", code, platform.ENotFound)
+			}
+			if msg := platform.ErrorMessage(err); msg != tt.err {
+				t.Errorf("ensureLabelsExist() error message = %q, want %q", msg, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/authorizer"
+	pcontext "github.com/influxdata/influxdb/context"
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+)
+
+// MigrationBackend is all services and associated parameters required to construct
+// the MigrationHandler.
+type MigrationBackend struct {
+	Logger *zap.Logger
+
+	DataMigrationService influxdb.DataMigrationService
+}
+
+// NewMigrationBackend returns a new instance of MigrationBackend.
+func NewMigrationBackend(b *APIBackend) *MigrationBackend {
+	return &MigrationBackend{
+		Logger: b.Logger.With(zap.String("handler", "migration")),
+
+		DataMigrationService: b.DataMigrationService,
+	}
+}
+
+// MigrationHandler represents an HTTP API handler for data migrations.
+type MigrationHandler struct {
+	*httprouter.Router
+
+	Logger *zap.Logger
+
+	DataMigrationService influxdb.DataMigrationService
+}
+
+const (
+	migrationsStatusPath = "/api/v2/migrations/status"
+	migrationsRunPath    = "/api/v2/migrations/run"
+)
+
+// NewMigrationHandler returns a new instance of MigrationHandler.
+func NewMigrationHandler(b *MigrationBackend) *MigrationHandler {
+	h := &MigrationHandler{
+		Router: NewRouter(),
+		Logger: b.Logger,
+
+		DataMigrationService: b.DataMigrationService,
+	}
+
+	h.HandlerFunc("GET", migrationsStatusPath, h.handleGetMigrationsStatus)
+	h.HandlerFunc("POST", migrationsRunPath, h.handlePostMigrationsRun)
+
+	return h
+}
+
+type migrationsStatusResponse struct {
+	Links      map[string]string           `json:"links"`
+	Migrated   bool                        `json:"migrated"`
+	Migrations []*influxdb.MigrationStatus `json:"migrations"`
+}
+
+func newMigrationsStatusResponse(ss []*influxdb.MigrationStatus) *migrationsStatusResponse {
+	res := &migrationsStatusResponse{
+		Links: map[string]string{
+			"self": migrationsStatusPath,
+		},
+		Migrated:   true,
+		Migrations: ss,
+	}
+	for _, s := range ss {
+		if !s.Applied {
+			res.Migrated = false
+		}
+	}
+
+	return res
+}
+
+// handleGetMigrationsStatus is the HTTP handler for the GET /api/v2/migrations/status route.
+func (h *MigrationHandler) handleGetMigrationsStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if _, err := pcontext.GetAuthorizer(ctx); err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	ss, err := h.DataMigrationService.FindMigrationStatus(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, newMigrationsStatusResponse(ss)); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+// handlePostMigrationsRun is the HTTP handler for the POST /api/v2/migrations/run route.
+// Only operators, who are allowed every action on every resource, may run the migrations.
+func (h *MigrationHandler) handlePostMigrationsRun(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := authorizer.IsAllowedAll(ctx, influxdb.OperPermissions()); err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if err := h.DataMigrationService.ConvertToNew(ctx); err != nil {
+		encodeConflictError(ctx, err, w)
+		return
+	}
+
+	ss, err := h.DataMigrationService.FindMigrationStatus(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, newMigrationsStatusResponse(ss)); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
@@ -0,0 +1,169 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb"
+	pcontext "github.com/influxdata/influxdb/context"
+	"github.com/influxdata/influxdb/inmem"
+	"github.com/influxdata/influxdb/kv"
+	"go.uber.org/zap"
+)
+
+func TestMigrationHandler_handleGetMigrationsStatus(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	svc := kv.NewService(inmem.NewKVStore())
+	svc.WithTime(func() time.Time { return now })
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize kv service: %v", err)
+	}
+	svc.RegisterMigration(kv.Migration{
+		Name: "counted",
+		Up: func(ctx context.Context, tx kv.Tx) (int, error) {
+			return 3, nil
+		},
+	})
+
+	h := NewMigrationHandler(&MigrationBackend{
+		Logger:               zap.NewNop(),
+		DataMigrationService: svc,
+	})
+
+	getStatus := func() *migrationsStatusResponse {
+		t.Helper()
+
+		r := httptest.NewRequest("GET", "http://any.url/api/v2/migrations/status", nil)
+		r = r.WithContext(pcontext.SetAuthorizer(r.Context(), &influxdb.Authorization{Status: influxdb.Active}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		res := w.Result()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("handleGetMigrationsStatus() = %v, want %v", res.StatusCode, http.StatusOK)
+		}
+
+		var status migrationsStatusResponse
+		if err := json.NewDecoder(res.Body).Decode(&status); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return &status
+	}
+
+	t.Run("not migrated", func(t *testing.T) {
+		status := getStatus()
+		if status.Migrated {
+			t.Errorf("expected the store not to be migrated")
+		}
+		if len(status.Migrations) == 0 {
+			t.Fatalf("expected the migrations to be listed")
+		}
+		for _, m := range status.Migrations {
+			if m.Applied || m.AppliedAt != nil || m.Processed != 0 {
+				t.Errorf("expected migration %q not to be applied, got %+v", m.Name, m)
+			}
+		}
+	})
+
+	if err := svc.ConvertToNew(ctx); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	t.Run("migrated", func(t *testing.T) {
+		status := getStatus()
+		if !status.Migrated {
+			t.Errorf("expected the store to be migrated")
+		}
+		for _, m := range status.Migrations {
+			if !m.Applied || m.AppliedAt == nil || !m.AppliedAt.Equal(now) {
+				t.Errorf("expected migration %q to be applied at %v, got %+v", m.Name, now, m)
+			}
+		}
+
+		processed := map[string]int{}
+		for _, m := range status.Migrations {
+			processed[m.Name] = m.Processed
+		}
+		if n := processed["counted"]; n != 3 {
+			t.Errorf("expected migration %q to have processed 3 records, got %d", "counted", n)
+		}
+		if n := processed["document timestamps"]; n != 0 {
+			t.Errorf("expected migration %q to have processed no records, got %d", "document timestamps", n)
+		}
+	})
+}
+
+func TestMigrationHandler_handlePostMigrationsRun(t *testing.T) {
+	ctx := context.Background()
+
+	svc := kv.NewService(inmem.NewKVStore())
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize kv service: %v", err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	svc.RegisterMigration(kv.Migration{
+		Name: "blocking",
+		Up: func(ctx context.Context, tx kv.Tx) (int, error) {
+			close(started)
+			<-release
+			return 0, nil
+		},
+	})
+
+	h := NewMigrationHandler(&MigrationBackend{
+		Logger:               zap.NewNop(),
+		DataMigrationService: svc,
+	})
+
+	run := func(a influxdb.Authorizer) int {
+		r := httptest.NewRequest("POST", "http://any.url/api/v2/migrations/run", nil)
+		r = r.WithContext(pcontext.SetAuthorizer(r.Context(), a))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result().StatusCode
+	}
+	operator := &influxdb.Authorization{Status: influxdb.Active, Permissions: influxdb.OperPermissions()}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		owner := &influxdb.Authorization{Status: influxdb.Active, Permissions: influxdb.OwnerPermissions(influxdb.ID(1))}
+		if code := run(owner); code != http.StatusUnauthorized {
+			t.Errorf("handlePostMigrationsRun() = %v, want %v", code, http.StatusUnauthorized)
+		}
+		if migrated, err := svc.IsMigrated(ctx); err != nil || migrated {
+			t.Errorf("expected the migrations not to run, got migrated %v: %v", migrated, err)
+		}
+	})
+
+	t.Run("already running", func(t *testing.T) {
+		done := make(chan error)
+		go func() { done <- svc.ConvertToNew(ctx) }()
+		<-started
+
+		code := run(operator)
+		close(release)
+		if err := <-done; err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+
+		if code != http.StatusConflict {
+			t.Errorf("handlePostMigrationsRun() = %v, want %v", code, http.StatusConflict)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		if code := run(operator); code != http.StatusOK {
+			t.Errorf("handlePostMigrationsRun() = %v, want %v", code, http.StatusOK)
+		}
+		if migrated, err := svc.IsMigrated(ctx); err != nil || !migrated {
+			t.Errorf("expected the migrations to have run, got migrated %v: %v", migrated, err)
+		}
+	})
+}
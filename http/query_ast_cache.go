@@ -0,0 +1,92 @@
+package http
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/influxdata/flux/ast"
+	"github.com/influxdata/flux/parser"
+	platform "github.com/influxdata/influxdb"
+)
+
+// defaultFluxASTCacheSize is the number of parsed scripts kept by the FluxHandler.
+const defaultFluxASTCacheSize = 256
+
+// fluxASTCache is an LRU cache of the ASTs of flux scripts, keyed by a hash of the script
+// text. It is safe for concurrent use. Cached ASTs are shared between requests and must not
+// be modified.
+type fluxASTCache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[[sha256.Size]byte]*list.Element
+	evictor  *list.List
+
+	// parse parses a script on a cache miss.
+	parse func(string) *ast.Package
+}
+
+type fluxASTCacheEntry struct {
+	key [sha256.Size]byte
+	pkg *ast.Package
+}
+
+// newFluxASTCache returns a fluxASTCache holding at most capacity ASTs.
+func newFluxASTCache(capacity int) *fluxASTCache {
+	return &fluxASTCache{
+		capacity: capacity,
+		items:    map[[sha256.Size]byte]*list.Element{},
+		evictor:  list.New(),
+		parse:    parser.ParseSource,
+	}
+}
+
+// Parse returns the AST of the script, parsing it unless it is cached. Scripts that do not
+// parse are not cached. A nil cache parses every script.
+func (c *fluxASTCache) Parse(script string) (*ast.Package, error) {
+	if c == nil {
+		return checkFluxAST(parser.ParseSource(script))
+	}
+
+	key := sha256.Sum256([]byte(script))
+	c.mu.Lock()
+	if ele, ok := c.items[key]; ok {
+		c.evictor.MoveToFront(ele)
+		c.mu.Unlock()
+		return ele.Value.(*fluxASTCacheEntry).pkg, nil
+	}
+	c.mu.Unlock()
+
+	// scripts are parsed without holding the lock so that a slow parse does not block
+	// other requests; a script parsed concurrently is cached once.
+	pkg, err := checkFluxAST(c.parse(script))
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ele, ok := c.items[key]; ok {
+		c.evictor.MoveToFront(ele)
+		return ele.Value.(*fluxASTCacheEntry).pkg, nil
+	}
+	c.items[key] = c.evictor.PushFront(&fluxASTCacheEntry{key: key, pkg: pkg})
+	for c.evictor.Len() > c.capacity {
+		last := c.evictor.Back()
+		c.evictor.Remove(last)
+		delete(c.items, last.Value.(*fluxASTCacheEntry).key)
+	}
+
+	return pkg, nil
+}
+
+func checkFluxAST(pkg *ast.Package) (*ast.Package, error) {
+	if ast.Check(pkg) > 0 {
+		return nil, &platform.Error{
+			Code: platform.EInvalid,
+			Msg:  "invalid AST",
+			Err:  ast.GetError(pkg),
+		}
+	}
+	return pkg, nil
+}
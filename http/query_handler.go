@@ -39,6 +39,7 @@ type FluxBackend struct {
 
 	OrganizationService platform.OrganizationService
 	ProxyQueryService   query.ProxyQueryService
+	BucketService       platform.BucketService
 }
 
 // NewFluxBackend returns a new instance of FluxBackend.
@@ -48,6 +49,7 @@ func NewFluxBackend(b *APIBackend) *FluxBackend {
 
 		ProxyQueryService:   b.FluxService,
 		OrganizationService: b.OrganizationService,
+		BucketService:       b.BucketService,
 	}
 }
 
@@ -60,6 +62,12 @@ type FluxHandler struct {
 	Now                 func() time.Time
 	OrganizationService platform.OrganizationService
 	ProxyQueryService   query.ProxyQueryService
+	// BucketService finds the IDs of the buckets a query reads and writes. It is not
+	// authorized, since the permissions the query requires are checked separately.
+	BucketService platform.BucketService
+
+	// astCache caches the parsed scripts of the permission endpoints.
+	astCache *fluxASTCache
 }
 
 // NewFluxHandler returns a new handler at /api/v2/query for flux queries.
@@ -71,12 +79,17 @@ func NewFluxHandler(b *FluxBackend) *FluxHandler {
 
 		ProxyQueryService:   b.ProxyQueryService,
 		OrganizationService: b.OrganizationService,
+		BucketService:       b.BucketService,
+
+		astCache: newFluxASTCache(defaultFluxASTCacheSize),
 	}
 
 	h.HandlerFunc("POST", fluxPath, h.handleQuery)
 	h.HandlerFunc("POST", "/api/v2/query/ast", h.postFluxAST)
 	h.HandlerFunc("POST", "/api/v2/query/analyze", h.postQueryAnalyze)
 	h.HandlerFunc("POST", "/api/v2/query/spec", h.postFluxSpec)
+	h.HandlerFunc("POST", "/api/v2/query/permissions", h.postFluxPermissions)
+	h.HandlerFunc("POST", "/api/v2/query/preauthorize", h.postFluxPreauthorize)
 	h.HandlerFunc("GET", "/api/v2/query/suggestions", h.getFluxSuggestions)
 	h.HandlerFunc("GET", "/api/v2/query/suggestions/:name", h.getFluxSuggestion)
 	return h
@@ -241,6 +254,124 @@ func (h *FluxHandler) postFluxSpec(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+type postFluxPermissionsRequest struct {
+	Query string      `json:"query"`
+	OrgID platform.ID `json:"orgID"`
+}
+
+type postFluxPermissionsResponse struct {
+	Permissions []platform.Permission `json:"permissions"`
+}
+
+// postFluxPermissions returns the permissions required to run the provided flux string
+// in an organization.
+func (h *FluxHandler) postFluxPermissions(w http.ResponseWriter, r *http.Request) {
+	span, r := tracing.ExtractFromHTTPRequest(r, "FluxHandler")
+	defer span.Finish()
+
+	ctx := r.Context()
+
+	ps, err := h.requiredFluxPermissions(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	if err := encodeResponse(ctx, w, http.StatusOK, postFluxPermissionsResponse{Permissions: ps}); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+type postFluxPreauthorizeResponse struct {
+	Allowed bool                  `json:"allowed"`
+	Missing []platform.Permission `json:"missing"`
+}
+
+// postFluxPreauthorize checks the provided flux string against the authorizer of the
+// request, responding with the permissions it is missing if it may not run the query.
+// Like PreAuthorize this is a pre-check; the query may still be denied when it runs.
+func (h *FluxHandler) postFluxPreauthorize(w http.ResponseWriter, r *http.Request) {
+	span, r := tracing.ExtractFromHTTPRequest(r, "FluxHandler")
+	defer span.Finish()
+
+	ctx := r.Context()
+
+	a, err := pcontext.GetAuthorizer(ctx)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	ps, err := h.requiredFluxPermissions(ctx, r)
+	if err != nil {
+		EncodeError(ctx, err, w)
+		return
+	}
+
+	res := postFluxPreauthorizeResponse{Missing: []platform.Permission{}}
+	for _, p := range ps {
+		if !a.Allowed(p) {
+			res.Missing = append(res.Missing, p)
+		}
+	}
+	res.Allowed = len(res.Missing) == 0
+
+	code := http.StatusOK
+	if !res.Allowed {
+		code = http.StatusForbidden
+	}
+	if err := encodeResponse(ctx, w, code, res); err != nil {
+		logEncodingError(h.Logger, r, err)
+		return
+	}
+}
+
+// requiredFluxPermissions decodes a postFluxPermissionsRequest and returns the permissions
+// required to run its query.
+func (h *FluxHandler) requiredFluxPermissions(ctx context.Context, r *http.Request) ([]platform.Permission, error) {
+	var req postFluxPermissionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, &platform.Error{
+			Code: platform.EInvalid,
+			Msg:  "invalid json",
+			Err:  err,
+		}
+	}
+
+	if !req.OrgID.Valid() {
+		return nil, &platform.Error{
+			Code: platform.EInvalid,
+			Msg:  "orgID is required",
+		}
+	}
+
+	pkg, err := h.astCache.Parse(req.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := flux.CompileAST(ctx, pkg, h.Now())
+	if err != nil {
+		return nil, &platform.Error{
+			Code: platform.EUnprocessableEntity,
+			Msg:  "invalid spec",
+			Err:  err,
+		}
+	}
+
+	ps, err := query.NewPreAuthorizer(h.BucketService).RequiredPermissions(ctx, spec, &req.OrgID)
+	if err != nil {
+		return nil, &platform.Error{
+			Code: platform.ErrorCode(errors.Cause(err)),
+			Msg:  "could not determine required permissions",
+			Err:  err,
+		}
+	}
+
+	return ps, nil
+}
+
 // fluxParams contain flux funciton parameters as defined by the semantic graph
 type fluxParams map[string]string
 
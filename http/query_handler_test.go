@@ -3,21 +3,28 @@ package http
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"regexp"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/influxdata/flux"
+	"github.com/influxdata/flux/ast"
 	"github.com/influxdata/flux/csv"
 	"github.com/influxdata/flux/lang"
+	"github.com/influxdata/flux/parser"
 	platform "github.com/influxdata/influxdb"
+	pcontext "github.com/influxdata/influxdb/context"
 	"github.com/influxdata/influxdb/kit/check"
+	"github.com/influxdata/influxdb/mock"
 	"github.com/influxdata/influxdb/query"
+	"go.uber.org/zap"
 )
 
 func TestFluxService_Query(t *testing.T) {
@@ -292,6 +299,250 @@ func TestFluxHandler_postFluxSpec(t *testing.T) {
 	}
 }
 
+// newQueryPermissionsBucketService returns a bucket service finding the buckets "src" and
+// "dst" of the organization 0000000000000001 by name.
+func newQueryPermissionsBucketService() *mock.BucketService {
+	orgID := platform.ID(1)
+	buckets := map[string]*platform.Bucket{
+		"src": {ID: platform.ID(10), OrganizationID: orgID, Name: "src"},
+		"dst": {ID: platform.ID(11), OrganizationID: orgID, Name: "dst"},
+	}
+	bucketService := mock.NewBucketService()
+	bucketService.FindBucketFn = func(ctx context.Context, f platform.BucketFilter) (*platform.Bucket, error) {
+		if f.Name != nil {
+			if b, ok := buckets[*f.Name]; ok {
+				return b, nil
+			}
+		}
+		return nil, &platform.Error{Code: platform.ENotFound, Msg: "bucket not found"}
+	}
+	return bucketService
+}
+
+func TestFluxHandler_postFluxPermissions(t *testing.T) {
+	bucketService := newQueryPermissionsBucketService()
+
+	tests := []struct {
+		name   string
+		body   string
+		want   string
+		status int
+	}{
+		{
+			name:   "read and write",
+			body:   `{"orgID":"0000000000000001","query":"from(bucket:\"src\") |> range(start:-1h) |> to(bucket:\"dst\", orgID:\"0000000000000001\")"}`,
+			want:   `{"permissions":[{"action":"read","resource":{"type":"buckets","id":"000000000000000a","orgID":"0000000000000001"}},{"action":"write","resource":{"type":"buckets","id":"000000000000000b","orgID":"0000000000000001"}}]}`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "unknown bucket",
+			body:   `{"orgID":"0000000000000001","query":"from(bucket:\"missing\") |> range(start:-1h)"}`,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "invalid query",
+			body:   `{"orgID":"0000000000000001","query":"from(bucket:"}`,
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "missing org",
+			body:   `{"query":"from(bucket:\"src\") |> range(start:-1h)"}`,
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &FluxHandler{
+				Now:           func() time.Time { return time.Unix(0, 0).UTC() },
+				BucketService: bucketService,
+			}
+			w := httptest.NewRecorder()
+			h.postFluxPermissions(w, httptest.NewRequest("POST", "/api/v2/query/permissions", bytes.NewBufferString(tt.body)))
+
+			if got := w.Code; got != tt.status {
+				t.Fatalf("http.postFluxPermissions = got %d\nwant %d: %s", got, tt.status, w.Body.String())
+			}
+			if tt.want == "" {
+				return
+			}
+			if eq, diff, _ := jsonEqual(w.Body.String(), tt.want); !eq {
+				t.Errorf("http.postFluxPermissions = ***%s***", diff)
+			}
+		})
+	}
+}
+
+func TestFluxHandler_postFluxPreauthorize(t *testing.T) {
+	orgID := platform.ID(1)
+	readSrc, err := platform.NewPermissionAtID(platform.ID(10), platform.ReadAction, platform.BucketsResourceType, orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readAll, err := platform.NewPermission(platform.ReadAction, platform.BucketsResourceType, orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"orgID":"0000000000000001","query":"from(bucket:\"src\") |> range(start:-1h) |> to(bucket:\"dst\", orgID:\"0000000000000001\")"}`
+
+	tests := []struct {
+		name        string
+		permissions []platform.Permission
+		want        string
+		status      int
+	}{
+		{
+			name:        "allowed",
+			permissions: platform.OperPermissions(),
+			want:        `{"allowed":true,"missing":[]}`,
+			status:      http.StatusOK,
+		},
+		{
+			name:        "denied",
+			permissions: []platform.Permission{*readSrc},
+			want:        `{"allowed":false,"missing":[{"action":"write","resource":{"type":"buckets","id":"000000000000000b","orgID":"0000000000000001"}}]}`,
+			status:      http.StatusForbidden,
+		},
+		{
+			name:        "denied with organization wide permission",
+			permissions: []platform.Permission{*readAll},
+			want:        `{"allowed":false,"missing":[{"action":"write","resource":{"type":"buckets","id":"000000000000000b","orgID":"0000000000000001"}}]}`,
+			status:      http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &FluxHandler{
+				Now:           func() time.Time { return time.Unix(0, 0).UTC() },
+				BucketService: newQueryPermissionsBucketService(),
+			}
+			auth := &platform.Authorization{
+				OrgID:       orgID,
+				Status:      platform.Active,
+				Permissions: tt.permissions,
+			}
+			r := httptest.NewRequest("POST", "/api/v2/query/preauthorize", bytes.NewBufferString(body))
+			r = r.WithContext(pcontext.SetAuthorizer(r.Context(), auth))
+			w := httptest.NewRecorder()
+			h.postFluxPreauthorize(w, r)
+
+			if got := w.Code; got != tt.status {
+				t.Fatalf("http.postFluxPreauthorize = got %d\nwant %d: %s", got, tt.status, w.Body.String())
+			}
+			if eq, diff, _ := jsonEqual(w.Body.String(), tt.want); !eq {
+				t.Errorf("http.postFluxPreauthorize = ***%s***", diff)
+			}
+		})
+	}
+}
+
+// TestFluxHandler_postFluxPreauthorize_APIHandler ensures that the API handler lets the
+// preauthorizer find the buckets the caller cannot read, so that it reports them as missing.
+func TestFluxHandler_postFluxPreauthorize_APIHandler(t *testing.T) {
+	orgID := platform.ID(1)
+	readSrc, err := platform.NewPermissionAtID(platform.ID(10), platform.ReadAction, platform.BucketsResourceType, orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &APIBackend{
+		Logger:        zap.NewNop(),
+		BucketService: newQueryPermissionsBucketService(),
+	}
+	h := NewAPIHandler(b)
+
+	body := `{"orgID":"0000000000000001","query":"from(bucket:\"src\") |> range(start:-1h) |> to(bucket:\"dst\", orgID:\"0000000000000001\")"}`
+	auth := &platform.Authorization{
+		OrgID:       orgID,
+		Status:      platform.Active,
+		Permissions: []platform.Permission{*readSrc},
+	}
+	r := httptest.NewRequest("POST", "/api/v2/query/preauthorize", bytes.NewBufferString(body))
+	r = r.WithContext(pcontext.SetAuthorizer(r.Context(), auth))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if got := w.Code; got != http.StatusForbidden {
+		t.Fatalf("http.postFluxPreauthorize = got %d\nwant %d: %s", got, http.StatusForbidden, w.Body.String())
+	}
+	want := `{"allowed":false,"missing":[{"action":"write","resource":{"type":"buckets","id":"000000000000000b","orgID":"0000000000000001"}}]}`
+	if eq, diff, _ := jsonEqual(w.Body.String(), want); !eq {
+		t.Errorf("http.postFluxPreauthorize = ***%s***", diff)
+	}
+}
+
+func TestFluxHandler_postFluxPermissions_ASTCache(t *testing.T) {
+	var mu sync.Mutex
+	parsed := map[string]int{}
+	cache := newFluxASTCache(2)
+	cache.parse = func(script string) *ast.Package {
+		mu.Lock()
+		parsed[script]++
+		mu.Unlock()
+		return parser.ParseSource(script)
+	}
+
+	h := &FluxHandler{
+		Now:           func() time.Time { return time.Unix(0, 0).UTC() },
+		BucketService: newQueryPermissionsBucketService(),
+		astCache:      cache,
+	}
+	scripts := []string{
+		`from(bucket:"src") |> range(start:-1h)`,
+		`from(bucket:"dst") |> range(start:-1h)`,
+		`from(bucket:"src") |> range(start:-2h)`,
+	}
+	permissions := func(script string) {
+		t.Helper()
+		b, _ := json.Marshal(postFluxPermissionsRequest{Query: script, OrgID: platform.ID(1)})
+		w := httptest.NewRecorder()
+		h.postFluxPermissions(w, httptest.NewRequest("POST", "/api/v2/query/permissions", bytes.NewReader(b)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("http.postFluxPermissions = got %d\nwant %d: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+	}
+
+	t.Run("repeated scripts are parsed once", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				b, _ := json.Marshal(postFluxPermissionsRequest{Query: scripts[0], OrgID: platform.ID(1)})
+				w := httptest.NewRecorder()
+				h.postFluxPermissions(w, httptest.NewRequest("POST", "/api/v2/query/permissions", bytes.NewReader(b)))
+			}()
+		}
+		wg.Wait()
+
+		before := parsed[scripts[0]]
+		permissions(scripts[0])
+		permissions(scripts[0])
+		if got := parsed[scripts[0]]; got != before {
+			t.Errorf("expected a cached script not to be parsed again, parsed %d times after %d", got, before)
+		}
+	})
+
+	t.Run("least recently used scripts are evicted", func(t *testing.T) {
+		permissions(scripts[1])
+		permissions(scripts[0])
+		permissions(scripts[2]) // evicts scripts[1]
+
+		before := map[string]int{}
+		for _, s := range scripts {
+			before[s] = parsed[s]
+		}
+		permissions(scripts[0])
+		permissions(scripts[2])
+		permissions(scripts[1])
+		if parsed[scripts[0]] != before[scripts[0]] || parsed[scripts[2]] != before[scripts[2]] {
+			t.Error("expected recently used scripts to remain cached")
+		}
+		if parsed[scripts[1]] != before[scripts[1]]+1 {
+			t.Error("expected the least recently used script to be parsed again")
+		}
+	})
+}
+
 func TestFluxService_Check(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(HealthHandler))
 	defer ts.Close()
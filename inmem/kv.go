@@ -114,6 +114,15 @@ func (t *Tx) createBucketIfNotExists(b []byte) (kv.Bucket, error) {
 	return nil, kv.ErrTxNotWritable
 }
 
+// BucketNames returns the names of every bucket. It implements kv.BucketLister.
+func (t *Tx) BucketNames() ([][]byte, error) {
+	names := make([][]byte, 0, len(t.kv.buckets))
+	for b := range t.kv.buckets {
+		names = append(names, []byte(b))
+	}
+	return names, nil
+}
+
 // Bucket retrieves the bucket at the provided key.
 func (t *Tx) Bucket(b []byte) (kv.Bucket, error) {
 	bkt, ok := t.kv.buckets[string(b)]
@@ -0,0 +1,188 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/influxdata/influxdb"
+)
+
+// backupVersion is the version of the backup stream format written by Backup.
+const backupVersion = 1
+
+// backupHeader is the first record of a backup stream.
+type backupHeader struct {
+	Version int `json:"version"`
+}
+
+// backupEntry is a single key in a backup stream.
+type backupEntry struct {
+	Bucket []byte `json:"bucket"`
+	Key    []byte `json:"key"`
+	Value  []byte `json:"value"`
+}
+
+// backupBuckets returns every bucket owned by the service.
+func (s *Service) backupBuckets(ctx context.Context, tx Tx) ([][]byte, error) {
+	bs := [][]byte{
+		authBucket,
+		authIndex,
+		bucketBucket,
+		bucketIndex,
+		dashboardBucket,
+		orgDashboardIndex,
+		dashboardCellViewBucket,
+		documentNamespaceBucket,
+		documentIDSequenceBucket,
+		kvlogBucket,
+		kvlogIndex,
+		labelBucket,
+		labelMappingBucket,
+		migrationBucket,
+		onboardingBucket,
+		organizationBucket,
+		organizationIndex,
+		userpasswordBucket,
+		scrapersBucket,
+		secretBucket,
+		sessionBucket,
+		sourceBucket,
+		taskBucket,
+		taskRunBucket,
+		taskIndexBucket,
+		telegrafBucket,
+		urmBucket,
+		userBucket,
+		userIndex,
+		variableBucket,
+		variableOrgsIndex,
+	}
+
+	nss, err := s.documentNamespaces(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ns := range nss {
+		bs = append(bs,
+			[]byte(path.Join(ns, documentContentBucket)),
+			[]byte(path.Join(ns, documentMetaBucket)),
+			[]byte(path.Join(ns, documentLabelIndexBucket)),
+			[]byte(path.Join(ns, documentOrgIndexBucket)),
+			[]byte(path.Join(ns, documentIdempotencyBucket)),
+			[]byte(path.Join(ns, documentChecksumBucket)),
+			[]byte(path.Join(ns, documentLockBucket)),
+		)
+	}
+
+	return bs, nil
+}
+
+// Backup writes every key of every bucket owned by the service to w. The backup is
+// taken within a single transaction and so is a consistent snapshot of the store.
+func (s *Service) Backup(ctx context.Context, w io.Writer) error {
+	err := s.kv.View(ctx, func(tx Tx) error {
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(&backupHeader{Version: backupVersion}); err != nil {
+			return err
+		}
+
+		bs, err := s.backupBuckets(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range bs {
+			b, err := tx.Bucket(name)
+			if err != nil {
+				return err
+			}
+
+			cur, err := b.Cursor()
+			if err != nil {
+				return err
+			}
+
+			for k, v := cur.First(); len(k) != 0; k, v = cur.Next() {
+				if err := enc.Encode(&backupEntry{Bucket: name, Key: k, Value: v}); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  "failed to back up kv store",
+			Op:   OpPrefix + "Backup",
+			Err:  err,
+		}
+	}
+
+	return nil
+}
+
+// Restore writes every key of a backup written by Backup into the store. The restore
+// happens within a single transaction. Keys in the store that are not in the backup
+// are left untouched, so backups are typically restored into an empty store.
+func (s *Service) Restore(ctx context.Context, r io.Reader) error {
+	dec := json.NewDecoder(r)
+
+	h := &backupHeader{}
+	if err := dec.Decode(h); err != nil {
+		return &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "invalid backup header",
+			Op:   OpPrefix + "Restore",
+			Err:  err,
+		}
+	}
+	if h.Version != backupVersion {
+		return &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  fmt.Sprintf("unsupported backup version %d", h.Version),
+			Op:   OpPrefix + "Restore",
+		}
+	}
+
+	err := s.kv.Update(ctx, func(tx Tx) error {
+		for {
+			e := &backupEntry{}
+			err := dec.Decode(e)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return &influxdb.Error{
+					Code: influxdb.EInvalid,
+					Msg:  "invalid backup entry",
+					Err:  err,
+				}
+			}
+
+			b, err := tx.Bucket(e.Bucket)
+			if err != nil {
+				return err
+			}
+
+			if err := b.Put(e.Key, e.Value); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return &influxdb.Error{
+			Code: influxdb.ErrorCode(err),
+			Msg:  "failed to restore kv store",
+			Op:   OpPrefix + "Restore",
+			Err:  err,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package kv_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestService_BackupRestore(t *testing.T) {
+	ctx := context.Background()
+
+	srcStore, closeSrc, err := NewTestBoltStore()
+	if err != nil {
+		t.Fatalf("failed to create new bolt kv store: %v", err)
+	}
+	defer closeSrc()
+
+	src := kv.NewService(srcStore)
+	if err := src.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := src.CreateOrganization(ctx, o); err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+	u := &influxdb.User{Name: "u1"}
+	if err := src.CreateUser(ctx, u); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	l := &influxdb.Label{Name: "l1", Properties: map[string]string{"color": "blue"}}
+	if err := src.CreateLabel(ctx, l); err != nil {
+		t.Fatalf("failed to create label: %v", err)
+	}
+	ds, err := src.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+	d := &influxdb.Document{
+		Meta:    influxdb.DocumentMeta{Name: "d1"},
+		Content: map[string]interface{}{"hello": "world"},
+	}
+	if err := ds.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(l.ID)); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	if _, err := ds.(influxdb.DocumentLocker).LockDocument(ctx, d.ID, u.ID, time.Hour); err != nil {
+		t.Fatalf("failed to lock document: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.Backup(ctx, &buf); err != nil {
+		t.Fatalf("failed to back up: %v", err)
+	}
+
+	dstStore, closeDst, err := NewTestBoltStore()
+	if err != nil {
+		t.Fatalf("failed to create new bolt kv store: %v", err)
+	}
+	defer closeDst()
+
+	dst := kv.NewService(dstStore)
+	if err := dst.Restore(ctx, &buf); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+
+	if _, err := dst.FindOrganizationByID(ctx, o.ID); err != nil {
+		t.Errorf("failed to find restored organization: %v", err)
+	}
+	if _, err := dst.FindUserByID(ctx, u.ID); err != nil {
+		t.Errorf("failed to find restored user: %v", err)
+	}
+	if rl, err := dst.FindLabelByID(ctx, l.ID); err != nil {
+		t.Errorf("failed to find restored label: %v", err)
+	} else if !reflect.DeepEqual(rl, l) {
+		t.Errorf("expected restored label %v, got %v", l, rl)
+	}
+
+	rds, err := dst.FindDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to find restored document store: %v", err)
+	}
+	docs, err := rds.FindDocuments(ctx, influxdb.WhereOrg(o.Name), influxdb.WhereLabelID(l.ID), influxdb.IncludeContent, influxdb.IncludeLabels)
+	if err != nil {
+		t.Fatalf("failed to find restored documents: %v", err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("expected 1 restored document, got %d", len(docs))
+	}
+	if got := docs[0]; got.ID != d.ID || !reflect.DeepEqual(got.Content, d.Content) || len(got.Labels) != 1 || got.Labels[0].ID != l.ID {
+		t.Errorf("expected restored document %v, got %v", d, got)
+	}
+	if _, err := rds.(influxdb.DocumentLocker).LockDocument(ctx, d.ID, influxdb.ID(42), time.Hour); influxdb.ErrorCode(err) != influxdb.EConflict {
+		t.Errorf("expected the restored document to stay locked, got %v", err)
+	}
+}
+
+func TestService_Restore_InvalidVersion(t *testing.T) {
+	store, closeStore, err := NewTestBoltStore()
+	if err != nil {
+		t.Fatalf("failed to create new bolt kv store: %v", err)
+	}
+	defer closeStore()
+
+	svc := kv.NewService(store)
+	err = svc.Restore(context.Background(), bytes.NewBufferString(`{"version":0}`))
+	if influxdb.ErrorCode(err) != influxdb.EInvalid {
+		t.Errorf("expected restoring an unsupported version to be invalid, got %v", err)
+	}
+}
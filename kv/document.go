@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"path"
+	"strings"
 
 	"github.com/influxdata/influxdb"
 )
@@ -14,11 +15,40 @@ const (
 	documentMetaBucket    = "/documents/meta"
 )
 
+var (
+	documentNamespaceBucket = []byte("documentnamespacesv1")
+)
+
+var _ influxdb.DocumentByIDFinder = (*DocumentStore)(nil)
+
 func (s *Service) initializeDocuments(ctx context.Context, tx Tx) error {
+	if _, err := tx.Bucket(documentNamespaceBucket); err != nil {
+		return err
+	}
+
+	if _, err := tx.Bucket(documentIDSequenceBucket); err != nil {
+		return err
+	}
+
 	if _, err := s.createDocumentStore(ctx, tx, "templates"); err != nil {
 		return err
 	}
 
+	if err := s.registerDocumentNamespaces(ctx, tx); err != nil {
+		return err
+	}
+
+	// namespaces created by earlier versions may be missing buckets added since.
+	nss, err := s.documentNamespaces(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, ns := range nss {
+		if _, err := s.createDocumentStore(ctx, tx, ns); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -53,6 +83,35 @@ func (s *Service) createDocumentStore(ctx context.Context, tx Tx, ns string) (in
 		return nil, err
 	}
 
+	if _, err := tx.Bucket([]byte(path.Join(ns, documentLabelIndexBucket))); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Bucket([]byte(path.Join(ns, documentOrgIndexBucket))); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Bucket([]byte(path.Join(ns, documentIdempotencyBucket))); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Bucket([]byte(path.Join(ns, documentLockBucket))); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Bucket([]byte(path.Join(ns, documentChecksumBucket))); err != nil {
+		return nil, err
+	}
+
+	b, err := tx.Bucket(documentNamespaceBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.Put([]byte(ns), []byte(ns)); err != nil {
+		return nil, err
+	}
+
 	return &DocumentStore{
 		namespace: ns,
 		service:   s,
@@ -87,6 +146,35 @@ func (s *Service) FindDocumentStore(ctx context.Context, ns string) (influxdb.Do
 	return ds, nil
 }
 
+// Ping reads the document namespaces in a read only transaction to confirm the store is
+// reachable.
+func (s *Service) Ping(ctx context.Context) error {
+	err := s.kv.View(ctx, func(tx Tx) error {
+		b, err := tx.Bucket(documentNamespaceBucket)
+		if err != nil {
+			return err
+		}
+
+		c, err := b.Cursor()
+		if err != nil {
+			return err
+		}
+		c.First()
+
+		return nil
+	})
+	if err != nil {
+		return &influxdb.Error{
+			Code: influxdb.EUnavailable,
+			Msg:  "document store is unreachable",
+			Op:   "kv/PingDocuments",
+			Err:  err,
+		}
+	}
+
+	return nil
+}
+
 // DocumentStore implements influxdb.DocumentStore.
 type DocumentStore struct {
 	service   *Service
@@ -95,54 +183,102 @@ type DocumentStore struct {
 
 // CreateDocument creates an instance of a document and sets the ID. After which it applies each of the options provided.
 func (s *DocumentStore) CreateDocument(ctx context.Context, d *influxdb.Document, opts ...influxdb.DocumentOptions) error {
-	return s.service.kv.Update(ctx, func(tx Tx) error {
-		err := s.service.createDocument(ctx, tx, s.namespace, d)
-		if err != nil {
-			return err
-		}
+	return s.service.updateDocuments(ctx, func(tx Tx) error {
+		return s.createDocument(ctx, tx, d, opts...)
+	})
+}
 
-		idx := &DocumentIndex{
-			service:  s.service,
-			tx:       tx,
-			ctx:      ctx,
-			writable: true,
-		}
-		for _, opt := range opts {
-			if err := opt(d.ID, idx); err != nil {
-				return err
-			}
-		}
+func (s *DocumentStore) createDocument(ctx context.Context, tx Tx, d *influxdb.Document, opts ...influxdb.DocumentOptions) error {
+	err := s.service.createDocument(ctx, tx, s.namespace, d)
+	if err != nil {
+		return err
+	}
 
-		if err := s.decorateDocumentWithLabels(ctx, tx, d); err != nil {
+	idx := &DocumentIndex{
+		service:   s.service,
+		namespace: s.namespace,
+		tx:        tx,
+		ctx:       ctx,
+		writable:  true,
+	}
+	for _, opt := range opts {
+		if err := opt(d.ID, idx); err != nil {
 			return err
 		}
+	}
 
-		return nil
-	})
+	if err := s.decorateDocumentWithLabels(ctx, tx, d); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 // DocumentIndex implements influxdb.DocumentIndex. It is used to access labels/owners of documents.
 type DocumentIndex struct {
-	service  *Service
-	ctx      context.Context
-	tx       Tx
-	writable bool
+	service   *Service
+	namespace string
+	ctx       context.Context
+	tx        Tx
+	writable  bool
 }
 
-// AddDocumentLabel creates a label mapping for the label provided.
+// AddDocumentLabel creates a label mapping for the label provided. A label belonging to an
+// organization may only be attached to documents owned by that organization.
 func (i *DocumentIndex) AddDocumentLabel(docID, labelID influxdb.ID) error {
+	if err := i.labelBelongsToDocumentOrg(docID, labelID); err != nil {
+		return err
+	}
+
+	now := i.service.time()
 	m := &influxdb.LabelMapping{
 		LabelID:      labelID,
 		ResourceType: influxdb.DocumentsResourceType,
 		ResourceID:   docID,
+		AttachedAt:   &now,
 	}
 	if err := i.service.createLabelMapping(i.ctx, i.tx, m); err != nil {
 		return err
 	}
 
+	if err := i.service.indexDocumentLabel(i.ctx, i.tx, i.namespace, docID, labelID); err != nil {
+		return err
+	}
+
+	recordDocumentLabelEvent(i.tx, i.namespace, docID, labelID, influxdb.DocumentLabelAttached)
 	return nil
 }
 
+func (i *DocumentIndex) labelBelongsToDocumentOrg(docID, labelID influxdb.ID) error {
+	l, err := i.service.findLabelByID(i.ctx, i.tx, labelID)
+	if err != nil {
+		return err
+	}
+	if !l.OrganizationID.Valid() {
+		return nil
+	}
+
+	orgIDs, err := i.service.documentOrgIDs(i.ctx, i.tx, docID)
+	if err != nil {
+		return err
+	}
+	if len(orgIDs) == 0 {
+		return nil
+	}
+	for _, orgID := range orgIDs {
+		if orgID == l.OrganizationID {
+			return nil
+		}
+	}
+
+	// labels of other organizations are reported as missing, as they are not visible to the
+	// organizations owning the document.
+	return &influxdb.Error{
+		Code: influxdb.ENotFound,
+		Msg:  fmt.Sprintf("label %s not found", labelID),
+	}
+}
+
 // RemoveDocumentLabel removes a label mapping for the label provided.
 func (i *DocumentIndex) RemoveDocumentLabel(docID, labelID influxdb.ID) error {
 	m := &influxdb.LabelMapping{
@@ -154,9 +290,106 @@ func (i *DocumentIndex) RemoveDocumentLabel(docID, labelID influxdb.ID) error {
 		return err
 	}
 
+	if err := i.service.deindexDocumentLabel(i.ctx, i.tx, i.namespace, docID, labelID); err != nil {
+		return err
+	}
+
+	recordDocumentLabelEvent(i.tx, i.namespace, docID, labelID, influxdb.DocumentLabelDetached)
 	return nil
 }
 
+// FindDocument retrieves the meta, content and labels of the document.
+func (i *DocumentIndex) FindDocument(docID influxdb.ID) (*influxdb.Document, error) {
+	d, err := i.service.findDocumentByID(i.ctx, i.tx, i.namespace, docID)
+	if IsNotFound(err) {
+		return nil, &influxdb.Error{
+			Code: influxdb.ENotFound,
+			Msg:  influxdb.ErrDocumentNotFound,
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d.Content, err = i.service.findDocumentContentByID(i.ctx, i.tx, i.namespace, docID)
+	if err != nil && !IsNotFound(err) {
+		return nil, err
+	}
+
+	d.Labels = []*influxdb.Label{}
+	f := influxdb.LabelMappingFilter{
+		ResourceID:   docID,
+		ResourceType: influxdb.DocumentsResourceType,
+	}
+	if err := i.service.findResourceLabels(i.ctx, i.tx, f, &d.Labels); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// FindDocumentsByLabel retrieves the IDs of the documents carrying the label provided.
+func (i *DocumentIndex) FindDocumentsByLabel(labelID influxdb.ID) ([]influxdb.ID, error) {
+	return i.service.findDocumentIDsByLabel(i.ctx, i.tx, i.namespace, labelID)
+}
+
+// FindDocumentsByIDPrefix retrieves the IDs of the documents in the namespace whose encoded ID
+// starts with the prefix provided.
+func (i *DocumentIndex) FindDocumentsByIDPrefix(prefix string) ([]influxdb.ID, error) {
+	b, err := i.tx.Bucket([]byte(path.Join(i.namespace, documentMetaBucket)))
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := b.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []influxdb.ID{}
+	for k, _ := cur.Seek([]byte(prefix)); len(k) != 0 && strings.HasPrefix(string(k), prefix); k, _ = cur.Next() {
+		var id influxdb.ID
+		if err := id.Decode(k); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// FindDocumentsByName retrieves the IDs of the documents in the namespace with the name provided.
+func (i *DocumentIndex) FindDocumentsByName(name string) ([]influxdb.ID, error) {
+	b, err := i.tx.Bucket([]byte(path.Join(i.namespace, documentMetaBucket)))
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := b.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []influxdb.ID{}
+	for k, v := cur.First(); len(k) != 0; k, v = cur.Next() {
+		m := &influxdb.DocumentMeta{}
+		if err := json.Unmarshal(v, m); err != nil {
+			return nil, err
+		}
+		if m.Name != name {
+			continue
+		}
+
+		var id influxdb.ID
+		if err := id.Decode(k); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
 // FindLabelByName retrieves a label by name.
 func (i *DocumentIndex) FindLabelByName(name string) (influxdb.ID, error) {
 	// TODO(desa): this should be scoped by organization eventually. As of now labels are
@@ -200,12 +433,20 @@ func (i *DocumentIndex) AddDocumentOwner(id influxdb.ID, ownerType string, owner
 		ResourceType: influxdb.DocumentsResourceType,
 		ResourceID:   id,
 	}
-	return i.service.createUserResourceMapping(i.ctx, i.tx, m)
+	if err := i.service.createUserResourceMapping(i.ctx, i.tx, m); err != nil {
+		return err
+	}
+
+	return i.service.indexDocumentOrg(i.ctx, i.tx, i.namespace, id, ownerID)
 }
 
 // RemoveDocumentOwner deletes the urm for the document id and owner id provided.
 func (i *DocumentIndex) RemoveDocumentOwner(id influxdb.ID, ownerType string, ownerID influxdb.ID) error {
-	return i.service.removeDocumentOwner(i.ctx, i.tx, ownerID, id)
+	if err := i.service.removeDocumentOwner(i.ctx, i.tx, ownerID, id); err != nil {
+		return err
+	}
+
+	return i.service.deindexDocumentOrg(i.ctx, i.tx, i.namespace, id, ownerID)
 }
 
 // WithoutOwners removes all owners from a document. In particular it is used to cleanup urms on document delete.
@@ -297,13 +538,40 @@ func (i *DocumentIndex) ownerExists(ownerType string, ownerID influxdb.ID) error
 	return nil
 }
 
-// FindOrganizationByName retrieves the organization ID of the org provided.
+// FindOrganizationByName retrieves the organization ID of the org provided. If no org
+// has exactly the name provided, the name is matched case-insensitively as long as
+// only one org matches.
 func (i *DocumentIndex) FindOrganizationByName(org string) (influxdb.ID, error) {
 	o, err := i.service.findOrganizationByName(i.ctx, i.tx, org)
-	if err != nil {
+	if err == nil {
+		return o.ID, nil
+	}
+	if influxdb.ErrorCode(err) != influxdb.ENotFound {
+		return influxdb.InvalidID(), err
+	}
+
+	var ids []influxdb.ID
+	ferr := forEachOrganization(i.ctx, i.tx, func(o *influxdb.Organization) bool {
+		if strings.EqualFold(o.Name, org) {
+			ids = append(ids, o.ID)
+		}
+		return true
+	})
+	if ferr != nil {
+		return influxdb.InvalidID(), ferr
+	}
+
+	switch len(ids) {
+	case 0:
 		return influxdb.InvalidID(), err
+	case 1:
+		return ids[0], nil
+	default:
+		return influxdb.InvalidID(), &influxdb.Error{
+			Code: influxdb.EConflict,
+			Msg:  fmt.Sprintf("organization name %q matches multiple organizations; please use the exact name", org),
+		}
 	}
-	return o.ID, nil
 }
 
 // FindOrganizationByID checks if the org existence by the org id provided.
@@ -347,6 +615,12 @@ func (i *DocumentIndex) GetAccessorsDocuments(ownerType string, ownerID influxdb
 		return nil, err
 	}
 
+	// the documents owned by an organization are indexed by namespace, rather than found by
+	// scanning the ownership mappings of every document.
+	if ownerType == "org" {
+		return i.service.findDocumentIDsByOrg(i.ctx, i.tx, i.namespace, ownerID)
+	}
+
 	f := influxdb.UserResourceMappingFilter{
 		UserID:       ownerID,
 		ResourceType: influxdb.DocumentsResourceType,
@@ -359,8 +633,25 @@ func (i *DocumentIndex) GetAccessorsDocuments(ownerType string, ownerID influxdb
 		return nil, err
 	}
 
+	// documents in every namespace share the same ownership mappings, so only
+	// those in the namespace of the index are returned.
+	b, err := i.tx.Bucket([]byte(path.Join(i.namespace, documentMetaBucket)))
+	if err != nil {
+		return nil, err
+	}
+
 	ids := make([]influxdb.ID, 0, len(ms))
 	for _, m := range ms {
+		k, err := m.ResourceID.Encode()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := b.Get(k); IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
 		ids = append(ids, m.ResourceID)
 	}
 
@@ -368,11 +659,19 @@ func (i *DocumentIndex) GetAccessorsDocuments(ownerType string, ownerID influxdb
 }
 
 func (s *Service) createDocument(ctx context.Context, tx Tx, ns string, d *influxdb.Document) error {
-	d.ID = s.IDGenerator.ID()
+	id, err := s.nextDocumentID(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	d.ID = id
+	d.Meta.CreatedAt = s.time()
+	d.Meta.UpdatedAt = d.Meta.CreatedAt
 
 	if err := s.putDocument(ctx, tx, ns, d); err != nil {
 		return err
 	}
+	recordDocumentEvent(tx, ns, d.ID, influxdb.DocumentCreated)
 
 	return nil
 }
@@ -414,33 +713,18 @@ func (s *Service) putAtID(ctx context.Context, tx Tx, bucket string, id influxdb
 	return nil
 }
 
-func (s *Service) putDocumentContent(ctx context.Context, tx Tx, ns string, id influxdb.ID, data interface{}) error {
-	return s.putAtID(ctx, tx, path.Join(ns, documentContentBucket), id, data)
-}
-
 func (s *Service) putDocumentMeta(ctx context.Context, tx Tx, ns string, id influxdb.ID, m *influxdb.DocumentMeta) error {
 	return s.putAtID(ctx, tx, path.Join(ns, documentMetaBucket), id, m)
 }
 
 func (s *DocumentStore) PutDocument(ctx context.Context, d *influxdb.Document) error {
-	return s.service.kv.Update(ctx, func(tx Tx) error {
-		return s.service.putDocument(ctx, tx, s.namespace, d)
-	})
-}
-
-func (s *Service) findDocumentsByID(ctx context.Context, tx Tx, ns string, ids ...influxdb.ID) ([]*influxdb.Document, error) {
-	ds := make([]*influxdb.Document, 0, len(ids))
-
-	for _, id := range ids {
-		d, err := s.findDocumentByID(ctx, tx, ns, id)
-		if err != nil {
-			return nil, err
+	return s.service.updateDocuments(ctx, func(tx Tx) error {
+		if err := s.service.putDocument(ctx, tx, s.namespace, d); err != nil {
+			return err
 		}
-
-		ds = append(ds, d)
-	}
-
-	return ds, nil
+		recordDocumentEvent(tx, s.namespace, d.ID, influxdb.DocumentUpdated)
+		return nil
+	})
 }
 
 func (s *Service) findDocumentByID(ctx context.Context, tx Tx, ns string, id influxdb.ID) (*influxdb.Document, error) {
@@ -488,22 +772,16 @@ func (s *Service) findDocumentMetaByID(ctx context.Context, tx Tx, ns string, id
 	return m, nil
 }
 
-func (s *Service) findDocumentContentByID(ctx context.Context, tx Tx, ns string, id influxdb.ID) (interface{}, error) {
-	var data interface{}
-	if err := s.findByID(ctx, tx, path.Join(ns, documentContentBucket), id, &data); err != nil {
-		return nil, err
-	}
-
-	return data, nil
-}
-
 // DocumentDecorator is used to communication the decoration of documents to the
 // document store.
 type DocumentDecorator struct {
-	data   bool
-	labels bool
+	data    bool
+	labels  bool
+	filters []influxdb.DocumentFilter
 
-	writable bool
+	// decorated is set whenever an option decorates rather than selects documents.
+	decorated bool
+	writable  bool
 }
 
 // IncludeContent signals that the document should include its content when returned.
@@ -516,6 +794,7 @@ func (d *DocumentDecorator) IncludeContent() error {
 	}
 
 	d.data = true
+	d.decorated = true
 
 	return nil
 }
@@ -530,13 +809,33 @@ func (d *DocumentDecorator) IncludeLabels() error {
 	}
 
 	d.labels = true
+	d.decorated = true
+
+	return nil
+}
+
+// Filter adds a filter that each document must match in order to be returned.
+func (d *DocumentDecorator) Filter(fn influxdb.DocumentFilter) error {
+	d.filters = append(d.filters, fn)
+	d.decorated = true
 
 	return nil
 }
 
-// FindDocuments retrieves all documenst returned by the document find options.
+func (d *DocumentDecorator) match(doc *influxdb.Document) bool {
+	for _, fn := range d.filters {
+		if !fn(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+// FindDocuments retrieves all documenst returned by the document find options. Without
+// options it retrieves every document; otherwise at least one option must select documents.
 func (s *DocumentStore) FindDocuments(ctx context.Context, opts ...influxdb.DocumentFindOptions) ([]*influxdb.Document, error) {
 	var ds []*influxdb.Document
+	var contentRead bool
 	err := s.service.kv.View(ctx, func(tx Tx) error {
 		if len(opts) == 0 {
 			// TODO(desa): might be a better way to do get all.
@@ -548,47 +847,77 @@ func (s *DocumentStore) FindDocuments(ctx context.Context, opts ...influxdb.Docu
 		}
 
 		idx := &DocumentIndex{
-			service: s.service,
-			tx:      tx,
-			ctx:     ctx,
+			service:   s.service,
+			namespace: s.namespace,
+			tx:        tx,
+			ctx:       ctx,
 		}
 
 		dd := &DocumentDecorator{}
 
 		var ids []influxdb.ID
+		var selected bool
 		for _, opt := range opts {
+			dd.decorated = false
 			is, err := opt(idx, dd)
 			if err != nil {
 				return err
 			}
+			if !dd.decorated {
+				selected = true
+			}
 
 			ids = append(ids, is...)
 		}
+		contentRead = dd.data
 
-		docs, err := s.service.findDocumentsByID(ctx, tx, s.namespace, ids...)
-		if err != nil {
-			return err
-		}
+		visit := func(doc *influxdb.Document) error {
+			if len(dd.filters) > 0 {
+				if err := s.decorateDocumentWithLabels(ctx, tx, doc); err != nil {
+					return err
+				}
+				if !dd.match(doc) {
+					return nil
+				}
+				if !dd.labels {
+					doc.Labels = nil
+				}
+			} else if dd.labels {
+				if err := s.decorateDocumentWithLabels(ctx, tx, doc); err != nil {
+					return err
+				}
+			}
 
-		if dd.data {
-			for _, doc := range docs {
+			if dd.data {
 				d, err := s.service.findDocumentContentByID(ctx, tx, s.namespace, doc.ID)
 				if err != nil {
 					return err
 				}
 				doc.Content = d
 			}
+
+			ds = append(ds, doc)
+			return nil
 		}
 
-		if dd.labels {
-			for _, doc := range docs {
-				if err := s.decorateDocumentWithLabels(ctx, tx, doc); err != nil {
-					return err
-				}
+		if !selected {
+			// options that only decorate or filter would otherwise retrieve every document.
+			return &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "at least one option must select the documents to find",
 			}
 		}
 
-		ds = append(ds, docs...)
+		for _, id := range ids {
+			doc, err := s.service.findDocumentByID(ctx, tx, s.namespace, id)
+			if err != nil {
+				return err
+			}
+
+			if err := visit(doc); err != nil {
+				return err
+			}
+		}
 
 		return nil
 	})
@@ -604,10 +933,142 @@ func (s *DocumentStore) FindDocuments(ctx context.Context, opts ...influxdb.Docu
 		return nil, err
 	}
 
+	if t := s.service.documentAccess; t != nil && contentRead {
+		ids := make([]influxdb.ID, 0, len(ds))
+		for _, d := range ds {
+			ids = append(ids, d.ID)
+		}
+		t.record(s.namespace, s.service.time(), ids...)
+	}
+
+	return ds, nil
+}
+
+// FindDocumentByID retrieves the document with the ID provided, with its content and labels, if
+// it is selected by every option that selects documents and matches every filter.
+func (s *DocumentStore) FindDocumentByID(ctx context.Context, id influxdb.ID, opts ...influxdb.DocumentFindOptions) (*influxdb.Document, error) {
+	var d *influxdb.Document
+	err := s.service.kv.View(ctx, func(tx Tx) error {
+		var err error
+		if d, err = s.service.findDocumentByID(ctx, tx, s.namespace, id); err != nil {
+			return err
+		}
+
+		idx := &DocumentIndex{
+			service:   s.service,
+			namespace: s.namespace,
+			tx:        tx,
+			ctx:       ctx,
+		}
+
+		dd := &DocumentDecorator{}
+		for _, opt := range opts {
+			dd.decorated = false
+			ids, err := opt(idx, dd)
+			if err != nil {
+				return err
+			}
+			if !dd.decorated && !containsDocumentID(ids, id) {
+				return ErrKeyNotFound
+			}
+		}
+
+		if err := s.decorateDocumentWithLabels(ctx, tx, d); err != nil {
+			return err
+		}
+		if !dd.match(d) {
+			return ErrKeyNotFound
+		}
+
+		d.Content, err = s.service.findDocumentContentByID(ctx, tx, s.namespace, id)
+		return err
+	})
+
+	if IsNotFound(err) {
+		return nil, &influxdb.Error{
+			Code: influxdb.ENotFound,
+			Msg:  influxdb.ErrDocumentNotFound,
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t := s.service.documentAccess; t != nil {
+		t.record(s.namespace, s.service.time(), d.ID)
+	}
+
+	return d, nil
+}
+
+func containsDocumentID(ids []influxdb.ID, id influxdb.ID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// FindDocumentsByLabel retrieves the documents carrying the label provided using the label index,
+// the same index used by influxdb.WhereLabelID. The documents include their content and labels.
+func (s *DocumentStore) FindDocumentsByLabel(ctx context.Context, labelID influxdb.ID) ([]*influxdb.Document, error) {
+	ds := []*influxdb.Document{}
+	err := s.service.kv.View(ctx, func(tx Tx) error {
+		ids, err := s.service.findDocumentIDsByLabel(ctx, tx, s.namespace, labelID)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			d, err := s.service.findDocumentByID(ctx, tx, s.namespace, id)
+			if err != nil {
+				return err
+			}
+
+			if d.Content, err = s.service.findDocumentContentByID(ctx, tx, s.namespace, id); err != nil {
+				return err
+			}
+
+			if err := s.decorateDocumentWithLabels(ctx, tx, d); err != nil {
+				return err
+			}
+
+			ds = append(ds, d)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.ErrorCode(err),
+			Msg:  fmt.Sprintf("failed to find documents with label %s", labelID),
+			Op:   OpPrefix + "FindDocumentsByLabel",
+			Err:  err,
+		}
+	}
+
+	if t := s.service.documentAccess; t != nil {
+		ids := make([]influxdb.ID, 0, len(ds))
+		for _, d := range ds {
+			ids = append(ids, d.ID)
+		}
+		t.record(s.namespace, s.service.time(), ids...)
+	}
+
 	return ds, nil
 }
 
 func (s *Service) findDocuments(ctx context.Context, tx Tx, ns string, ds *[]*influxdb.Document) error {
+	return s.forEachDocument(ctx, tx, ns, func(d *influxdb.Document) error {
+		*ds = append(*ds, d)
+		return nil
+	})
+}
+
+// forEachDocument calls fn with the meta of each document in the namespace provided.
+func (s *Service) forEachDocument(ctx context.Context, tx Tx, ns string, fn func(d *influxdb.Document) error) error {
 	metab, err := tx.Bucket([]byte(path.Join(ns, documentMetaBucket)))
 	if err != nil {
 		return err
@@ -628,31 +1089,29 @@ func (s *Service) findDocuments(ctx context.Context, tx Tx, ns string, ds *[]*in
 			return err
 		}
 
-		*ds = append(*ds, d)
+		if err := fn(d); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// DeleteDocuments removes all documents returned by the options.
+// DeleteDocuments removes all documents returned by the options, along with their owners
+// and label mappings.
 func (s *DocumentStore) DeleteDocuments(ctx context.Context, opts ...influxdb.DocumentFindOptions) error {
-	return s.service.kv.Update(ctx, func(tx Tx) error {
+	return s.service.updateDocuments(ctx, func(tx Tx) error {
 		idx := &DocumentIndex{
-			service:  s.service,
-			tx:       tx,
-			ctx:      ctx,
-			writable: true,
+			service:   s.service,
+			namespace: s.namespace,
+			tx:        tx,
+			ctx:       ctx,
+			writable:  true,
 		}
-		dd := &DocumentDecorator{writable: true}
 
-		ids := []influxdb.ID{}
-		for _, opt := range opts {
-			dids, err := opt(idx, dd)
-			if err != nil {
-				return err
-			}
-
-			ids = append(ids, dids...)
+		ids, err := s.findDocumentIDs(ctx, tx, opts...)
+		if err != nil {
+			return err
 		}
 
 		for _, id := range ids {
@@ -664,6 +1123,10 @@ func (s *DocumentStore) DeleteDocuments(ctx context.Context, opts ...influxdb.Do
 			if err := s.service.deleteDocument(ctx, tx, s.namespace, id); err != nil {
 				return err
 			}
+
+			if err := s.service.deleteDocumentLabelMappings(ctx, tx, s.namespace, id); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
@@ -687,6 +1150,10 @@ func (s *Service) deleteDocument(ctx context.Context, tx Tx, ns string, id influ
 		return err
 	}
 
+	if err := s.deindexDocument(ctx, tx, ns, id); err != nil {
+		return err
+	}
+
 	if err := s.deleteDocumentMeta(ctx, tx, ns, id); err != nil {
 		return err
 	}
@@ -695,6 +1162,11 @@ func (s *Service) deleteDocument(ctx context.Context, tx Tx, ns string, id influ
 		return err
 	}
 
+	if err := s.deleteDocumentLock(ctx, tx, ns, id); err != nil {
+		return err
+	}
+	recordDocumentEvent(tx, ns, id, influxdb.DocumentDeleted)
+
 	// TODO(desa): deindex document meta
 
 	return nil
@@ -718,23 +1190,25 @@ func (s *Service) deleteAtID(ctx context.Context, tx Tx, bucket string, id influ
 	return nil
 }
 
-func (s *Service) deleteDocumentContent(ctx context.Context, tx Tx, ns string, id influxdb.ID) error {
-	return s.deleteAtID(ctx, tx, path.Join(ns, documentContentBucket), id)
-}
-
 func (s *Service) deleteDocumentMeta(ctx context.Context, tx Tx, ns string, id influxdb.ID) error {
 	return s.deleteAtID(ctx, tx, path.Join(ns, documentMetaBucket), id)
 }
 
-// UpdateDocument updates the document.
+// UpdateDocument updates the document. The labels of the document are ignored, so that
+// documents read with their labels can be written back without changing them; the options,
+// such as influxdb.WithExactLabelIDs, change them. The labels of the document are then set to
+// those it is mapped to once the update is applied, within the same transaction, so that they
+// match the mappings even when labels are attached or detached concurrently.
 func (s *DocumentStore) UpdateDocument(ctx context.Context, d *influxdb.Document, opts ...influxdb.DocumentOptions) error {
-	return s.service.kv.Update(ctx, func(tx Tx) error {
+	return s.service.updateDocuments(ctx, func(tx Tx) error {
 		idx := &DocumentIndex{
-			service:  s.service,
-			tx:       tx,
-			ctx:      ctx,
-			writable: true,
+			service:   s.service,
+			namespace: s.namespace,
+			tx:        tx,
+			ctx:       ctx,
+			writable:  true,
 		}
+
 		for _, opt := range opts {
 			if err := opt(d.ID, idx); err != nil {
 				return err
@@ -745,6 +1219,7 @@ func (s *DocumentStore) UpdateDocument(ctx context.Context, d *influxdb.Document
 			return err
 		}
 
+		d.Labels = nil
 		if err := s.decorateDocumentWithLabels(ctx, tx, d); err != nil {
 			return err
 		}
@@ -756,13 +1231,95 @@ func (s *DocumentStore) UpdateDocument(ctx context.Context, d *influxdb.Document
 func (s *Service) updateDocument(ctx context.Context, tx Tx, ns string, d *influxdb.Document) error {
 	// TODO(desa): deindex meta
 
+	m, err := s.findDocumentMetaByID(ctx, tx, ns, d.ID)
+	if err != nil {
+		return err
+	}
+	d.Meta.CreatedAt = m.CreatedAt
+	d.Meta.UpdatedAt = s.time()
+	d.Meta.LastAccessedAt = m.LastAccessedAt
+	d.Meta.Pinned = m.Pinned
+	if !d.Meta.LastWriterID.Valid() {
+		d.Meta.LastWriterID = m.LastWriterID
+	}
+	d.Meta.ContentType = m.ContentType
+
 	if err := s.putDocument(ctx, tx, ns, d); err != nil {
 		return err
 	}
+	recordDocumentEvent(tx, ns, d.ID, influxdb.DocumentUpdated)
 
 	return nil
 }
 
+// AppendContent appends data to the array content of a document within a single transaction,
+// so that concurrent appends are never lost. Options are applied before the content is read.
+func (s *DocumentStore) AppendContent(ctx context.Context, id influxdb.ID, data []interface{}, opts ...influxdb.DocumentOptions) (*influxdb.Document, error) {
+	var d *influxdb.Document
+	err := s.service.updateDocuments(ctx, func(tx Tx) error {
+		idx := &DocumentIndex{
+			service:   s.service,
+			namespace: s.namespace,
+			tx:        tx,
+			ctx:       ctx,
+			writable:  true,
+		}
+		for _, opt := range opts {
+			if err := opt(id, idx); err != nil {
+				return err
+			}
+		}
+
+		doc, err := s.service.findDocumentByID(ctx, tx, s.namespace, id)
+		if IsNotFound(err) {
+			return &influxdb.Error{
+				Code: influxdb.ENotFound,
+				Msg:  influxdb.ErrDocumentNotFound,
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		content, err := s.service.findDocumentContentByID(ctx, tx, s.namespace, id)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+
+		var entries []interface{}
+		switch c := content.(type) {
+		case nil:
+		case []interface{}:
+			entries = c
+		default:
+			return &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  "document content must be an array to append to",
+			}
+		}
+		doc.Content = append(entries, data...)
+
+		if err := s.service.updateDocument(ctx, tx, s.namespace, doc); err != nil {
+			return err
+		}
+
+		if err := s.decorateDocumentWithLabels(ctx, tx, doc); err != nil {
+			return err
+		}
+
+		d = doc
+		return nil
+	})
+	if err != nil {
+		return nil, &influxdb.Error{
+			Op:  "kv/AppendContent",
+			Err: err,
+		}
+	}
+
+	return d, nil
+}
+
 func (s *DocumentStore) decorateDocumentWithLabels(ctx context.Context, tx Tx, d *influxdb.Document) error {
 	ls := []*influxdb.Label{}
 	f := influxdb.LabelMappingFilter{
@@ -773,6 +1330,96 @@ func (s *DocumentStore) decorateDocumentWithLabels(ctx context.Context, tx Tx, d
 		return err
 	}
 
+	// documents without labels have an empty rather than a nil list of labels.
+	if d.Labels == nil {
+		d.Labels = []*influxdb.Label{}
+	}
 	d.Labels = append(d.Labels, ls...)
 	return nil
 }
+
+// registerDocumentNamespaces registers the namespaces created before namespaces were registered,
+// found by their meta bucket, so that the migrations iterating the namespaces convert their
+// documents too. Stores that cannot list their buckets only have their registered namespaces.
+func (s *Service) registerDocumentNamespaces(ctx context.Context, tx Tx) error {
+	l, ok := tx.(BucketLister)
+	if !ok {
+		return nil
+	}
+
+	names, err := l.BucketNames()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		ns := strings.TrimSuffix(string(name), documentMetaBucket)
+		if ns == string(name) || ns == "" {
+			continue
+		}
+		if _, err := s.createDocumentStore(ctx, tx, ns); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// documentNamespaces returns every namespace that a document store has been created for.
+func (s *Service) documentNamespaces(ctx context.Context, tx Tx) ([]string, error) {
+	b, err := tx.Bucket(documentNamespaceBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := b.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	var nss []string
+	for k, _ := cur.First(); len(k) != 0; k, _ = cur.Next() {
+		nss = append(nss, string(k))
+	}
+
+	return nss, nil
+}
+
+// backfillDocumentTimestamps sets the CreatedAt and UpdatedAt fields of documents
+// stored before the fields existed. Documents that already have timestamps are left untouched.
+// It returns the number of documents updated.
+func (s *Service) backfillDocumentTimestamps(ctx context.Context, tx Tx) (int, error) {
+	nss, err := s.documentNamespaces(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	now := s.time()
+	for _, ns := range nss {
+		var ds []*influxdb.Document
+		if err := s.findDocuments(ctx, tx, ns, &ds); err != nil {
+			return 0, err
+		}
+
+		for _, d := range ds {
+			if !d.Meta.CreatedAt.IsZero() && !d.Meta.UpdatedAt.IsZero() {
+				continue
+			}
+
+			if d.Meta.CreatedAt.IsZero() {
+				d.Meta.CreatedAt = now
+			}
+			if d.Meta.UpdatedAt.IsZero() {
+				d.Meta.UpdatedAt = d.Meta.CreatedAt
+			}
+
+			if err := s.putDocumentMeta(ctx, tx, ns, d.ID, &d.Meta); err != nil {
+				return 0, err
+			}
+			n++
+		}
+	}
+
+	return n, nil
+}
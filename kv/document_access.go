@@ -0,0 +1,140 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb"
+	"go.uber.org/zap"
+)
+
+// TrackDocumentAccess configures the service to record the last time the content of
+// a document was read. Accesses are buffered and written at most once per flush interval
+// so that reads do not each incur a write. The closer returned stops tracking accesses and
+// writes those still buffered; it must be closed before the store of the service is.
+func (s *Service) TrackDocumentAccess(flushInterval time.Duration) io.Closer {
+	t := &documentAccessTracker{
+		service:  s,
+		interval: flushInterval,
+		pending:  map[string]map[influxdb.ID]time.Time{},
+	}
+	s.documentAccess = t
+	return t
+}
+
+// documentAccessTracker buffers document accesses and lazily persists them.
+type documentAccessTracker struct {
+	service  *Service
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]map[influxdb.ID]time.Time
+	timer   *time.Timer
+	closed  bool
+	// flushes counts the flushes scheduled or running, so that Close can wait for them.
+	flushes sync.WaitGroup
+}
+
+// record buffers an access to the documents provided, scheduling a flush if one is not
+// already scheduled.
+func (t *documentAccessTracker) record(ns string, at time.Time, ids ...influxdb.ID) {
+	if len(ids) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return
+	}
+
+	if _, ok := t.pending[ns]; !ok {
+		t.pending[ns] = map[influxdb.ID]time.Time{}
+	}
+	for _, id := range ids {
+		t.pending[ns][id] = at
+	}
+
+	if t.timer == nil {
+		t.flushes.Add(1)
+		t.timer = time.AfterFunc(t.interval, func() {
+			defer t.flushes.Done()
+			t.flush()
+		})
+	}
+}
+
+// Close stops the scheduled flush, waits for a flush that is running, and writes the accesses
+// still buffered. Accesses are no longer recorded once it is closed.
+func (t *documentAccessTracker) Close() error {
+	t.mu.Lock()
+	t.closed = true
+	if t.timer != nil && t.timer.Stop() {
+		t.timer = nil
+		t.flushes.Done()
+	}
+	t.mu.Unlock()
+
+	t.flushes.Wait()
+	t.flush()
+	return nil
+}
+
+func (t *documentAccessTracker) flush() {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = map[string]map[influxdb.ID]time.Time{}
+	t.timer = nil
+	t.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	err := t.service.kv.Update(ctx, func(tx Tx) error {
+		for ns, accesses := range pending {
+			for id, at := range accesses {
+				if err := t.service.putDocumentLastAccessedAt(ctx, tx, ns, id, at); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.service.Logger.Info("Failed to record document access", zap.Error(err))
+	}
+}
+
+func (s *Service) putDocumentLastAccessedAt(ctx context.Context, tx Tx, ns string, id influxdb.ID, at time.Time) error {
+	k, err := id.Encode()
+	if err != nil {
+		return err
+	}
+
+	err = s.updateJSON(tx, []byte(path.Join(ns, documentMetaBucket)), k, func(v []byte) ([]byte, error) {
+		m := &influxdb.DocumentMeta{}
+		if err := json.Unmarshal(v, m); err != nil {
+			return nil, err
+		}
+
+		if m.LastAccessedAt != nil && !m.LastAccessedAt.Before(at) {
+			return nil, nil
+		}
+		m.LastAccessedAt = &at
+
+		return json.Marshal(m)
+	})
+	if IsNotFound(err) {
+		// the document was deleted after it was read.
+		return nil
+	}
+
+	return err
+}
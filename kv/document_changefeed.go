@@ -0,0 +1,136 @@
+package kv
+
+import (
+	"context"
+	"sync"
+
+	"github.com/influxdata/influxdb"
+)
+
+var _ influxdb.DocumentChangefeed = (*Service)(nil)
+
+// documentChangefeed publishes the document events recorded by committed transactions.
+type documentChangefeed struct {
+	// commitMu is held from the start of a transaction until its events are queued, so that
+	// events are queued in the order their transactions were committed.
+	commitMu sync.Mutex
+
+	mu          sync.Mutex
+	subscribers []documentSubscriber
+	nextID      int
+	queue       []influxdb.DocumentEvent
+	delivering  bool
+}
+
+type documentSubscriber struct {
+	id int
+	fn func(influxdb.DocumentEvent)
+}
+
+// documentEventTx records the document events of the transaction it wraps.
+type documentEventTx struct {
+	Tx
+	events []influxdb.DocumentEvent
+}
+
+// SubscribeDocumentEvents calls fn with each change made to documents once it has been committed,
+// in the order the changes were committed, until unsubscribe is called. Events are delivered
+// synchronously by the writer that committed them, so fn should hand them off rather than block.
+func (s *Service) SubscribeDocumentEvents(fn func(influxdb.DocumentEvent)) (unsubscribe func()) {
+	f := s.documentFeed
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := f.nextID
+	f.nextID++
+	f.subscribers = append(f.subscribers, documentSubscriber{id: id, fn: fn})
+
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		for i, sub := range f.subscribers {
+			if sub.id == id {
+				f.subscribers = append(f.subscribers[:i:i], f.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// updateDocuments runs fn in an update transaction and publishes the document events it records
+// once the transaction has been committed.
+func (s *Service) updateDocuments(ctx context.Context, fn func(tx Tx) error) error {
+	f := s.documentFeed
+
+	var events []influxdb.DocumentEvent
+	f.commitMu.Lock()
+	err := s.kv.Update(ctx, func(tx Tx) error {
+		etx := &documentEventTx{Tx: tx}
+		if err := fn(etx); err != nil {
+			return err
+		}
+		events = etx.events
+		return nil
+	})
+	if err == nil {
+		f.mu.Lock()
+		f.queue = append(f.queue, events...)
+		f.mu.Unlock()
+	}
+	f.commitMu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	f.deliver()
+	return nil
+}
+
+// deliver calls the subscribers with every queued event. A subscriber that changes documents
+// queues further events, which are delivered by the call already in progress.
+func (f *documentChangefeed) deliver() {
+	f.mu.Lock()
+	if f.delivering {
+		f.mu.Unlock()
+		return
+	}
+	f.delivering = true
+
+	for len(f.queue) > 0 {
+		e := f.queue[0]
+		f.queue = f.queue[1:]
+		subs := f.subscribers
+
+		f.mu.Unlock()
+		for _, sub := range subs {
+			sub.fn(e)
+		}
+		f.mu.Lock()
+	}
+
+	f.delivering = false
+	f.mu.Unlock()
+}
+
+// recordDocumentEvent records a change made to a document within the transaction provided, to be
+// published once the transaction commits.
+func recordDocumentEvent(tx Tx, ns string, id influxdb.ID, op influxdb.DocumentOperation) {
+	recordDocumentLabelEvent(tx, ns, id, 0, op)
+}
+
+// recordDocumentLabelEvent records a label being attached to or detached from a document
+// within the transaction provided. Label mappings made through the LabelService directly
+// rather than through a document store are not recorded.
+func recordDocumentLabelEvent(tx Tx, ns string, id, labelID influxdb.ID, op influxdb.DocumentOperation) {
+	if etx, ok := tx.(*documentEventTx); ok {
+		etx.events = append(etx.events, influxdb.DocumentEvent{
+			Namespace: ns,
+			ID:        id,
+			Operation: op,
+			LabelID:   labelID,
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package kv_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestService_SubscribeDocumentEvents(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	var events []influxdb.DocumentEvent
+	unsubscribe := svc.SubscribeDocumentEvents(func(e influxdb.DocumentEvent) {
+		// the change must have been committed by the time it is published.
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(e.ID))
+		if visible := err == nil && len(ds) == 1; visible != (e.Operation != influxdb.DocumentDeleted) {
+			t.Errorf("event %v published before its change was committed", e)
+		}
+		events = append(events, e)
+	})
+
+	d1 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: []interface{}{"a"}}
+	d2 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d2"}, Content: "content2"}
+	if err := s.CreateDocument(ctx, d1); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	if err := s.CreateDocument(ctx, d2); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	d1.Meta.Name = "d1 renamed"
+	if err := s.UpdateDocument(ctx, d1); err != nil {
+		t.Fatalf("failed to update document: %v", err)
+	}
+	if _, err := s.AppendContent(ctx, d1.ID, []interface{}{"b"}); err != nil {
+		t.Fatalf("failed to append content: %v", err)
+	}
+
+	// changes that are not committed are not published.
+	missing := &influxdb.Document{ID: influxdb.ID(1), Meta: influxdb.DocumentMeta{Name: "missing"}}
+	if err := s.UpdateDocument(ctx, missing); err == nil {
+		t.Fatal("expected updating a missing document to fail")
+	}
+
+	if err := s.DeleteDocuments(ctx, influxdb.WhereID(d1.ID)); err != nil {
+		t.Fatalf("failed to delete document: %v", err)
+	}
+
+	want := []influxdb.DocumentEvent{
+		{Namespace: "testing", ID: d1.ID, Operation: influxdb.DocumentCreated},
+		{Namespace: "testing", ID: d2.ID, Operation: influxdb.DocumentCreated},
+		{Namespace: "testing", ID: d1.ID, Operation: influxdb.DocumentUpdated},
+		{Namespace: "testing", ID: d1.ID, Operation: influxdb.DocumentUpdated},
+		{Namespace: "testing", ID: d1.ID, Operation: influxdb.DocumentDeleted},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("expected events %v, got %v", want, events)
+	}
+
+	unsubscribe()
+	if err := s.DeleteDocuments(ctx, influxdb.WhereID(d2.ID)); err != nil {
+		t.Fatalf("failed to delete document: %v", err)
+	}
+	if len(events) != len(want) {
+		t.Errorf("expected no events once unsubscribed, got %v", events[len(want):])
+	}
+}
+
+func TestService_SubscribeDocumentEvents_Labels(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatalf("failed to create label: %v", err)
+	}
+
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "content"}
+	if err := s.CreateDocument(ctx, d); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	var events []influxdb.DocumentEvent
+	defer svc.SubscribeDocumentEvents(func(e influxdb.DocumentEvent) {
+		events = append(events, e)
+	})()
+
+	if err := s.UpdateDocument(ctx, d, influxdb.WithLabelID(l.ID)); err != nil {
+		t.Fatalf("failed to attach label: %v", err)
+	}
+	if err := s.UpdateDocument(ctx, d, influxdb.WithoutLabelID(l.ID)); err != nil {
+		t.Fatalf("failed to detach label: %v", err)
+	}
+
+	// a label still attached when the document is deleted is detached along with it.
+	if err := s.UpdateDocument(ctx, d, influxdb.WithLabelID(l.ID)); err != nil {
+		t.Fatalf("failed to attach label: %v", err)
+	}
+	if err := s.DeleteDocuments(ctx, influxdb.WhereID(d.ID)); err != nil {
+		t.Fatalf("failed to delete document: %v", err)
+	}
+
+	want := []influxdb.DocumentEvent{
+		{Namespace: "testing", ID: d.ID, Operation: influxdb.DocumentLabelAttached, LabelID: l.ID},
+		{Namespace: "testing", ID: d.ID, Operation: influxdb.DocumentUpdated},
+		{Namespace: "testing", ID: d.ID, Operation: influxdb.DocumentLabelDetached, LabelID: l.ID},
+		{Namespace: "testing", ID: d.ID, Operation: influxdb.DocumentUpdated},
+		{Namespace: "testing", ID: d.ID, Operation: influxdb.DocumentLabelAttached, LabelID: l.ID},
+		{Namespace: "testing", ID: d.ID, Operation: influxdb.DocumentUpdated},
+		{Namespace: "testing", ID: d.ID, Operation: influxdb.DocumentDeleted},
+		{Namespace: "testing", ID: d.ID, Operation: influxdb.DocumentLabelDetached, LabelID: l.ID},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("expected events %v, got %v", want, events)
+	}
+}
@@ -0,0 +1,150 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/influxdata/influxdb"
+)
+
+// documentChecksumBucket holds the checksum of the stored content of each document in a namespace.
+const documentChecksumBucket = "/documents/checksums"
+
+func documentChecksum(v []byte) []byte {
+	sum := sha256.Sum256(v)
+	return sum[:]
+}
+
+// putDocumentContent stores the content of a document along with its checksum. The checksum
+// is of the content as stored, after it is compressed.
+func (s *Service) putDocumentContent(ctx context.Context, tx Tx, ns string, id influxdb.ID, data interface{}) error {
+	v, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if v, err = s.encodeDocumentContent(ns, v); err != nil {
+		return err
+	}
+
+	k, err := id.Encode()
+	if err != nil {
+		return err
+	}
+
+	b, err := tx.Bucket([]byte(path.Join(ns, documentContentBucket)))
+	if err != nil {
+		return err
+	}
+	if err := b.Put(k, v); err != nil {
+		return err
+	}
+
+	cb, err := tx.Bucket([]byte(path.Join(ns, documentChecksumBucket)))
+	if err != nil {
+		return err
+	}
+	return cb.Put(k, documentChecksum(v))
+}
+
+// findDocumentContentByID retrieves the content of a document, verifying it against its checksum.
+// Content stored before checksums were kept is not verified.
+func (s *Service) findDocumentContentByID(ctx context.Context, tx Tx, ns string, id influxdb.ID) (interface{}, error) {
+	k, err := id.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := tx.Bucket([]byte(path.Join(ns, documentContentBucket)))
+	if err != nil {
+		return nil, err
+	}
+	v, err := b.Get(k)
+	if err != nil {
+		return nil, err
+	}
+
+	cb, err := tx.Bucket([]byte(path.Join(ns, documentChecksumBucket)))
+	if err != nil {
+		return nil, err
+	}
+	sum, err := cb.Get(k)
+	if err != nil && !IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil && !bytes.Equal(sum, documentChecksum(v)) {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  fmt.Sprintf("%s: content of document %s does not match its checksum", influxdb.ErrDocumentContentCorrupt, id),
+		}
+	}
+
+	if v, err = decodeDocumentContent(v); err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  fmt.Sprintf("%s: content of document %s cannot be decompressed", influxdb.ErrDocumentContentCorrupt, id),
+			Err:  err,
+		}
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(v, &data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *Service) deleteDocumentContent(ctx context.Context, tx Tx, ns string, id influxdb.ID) error {
+	if err := s.deleteAtID(ctx, tx, path.Join(ns, documentContentBucket), id); err != nil {
+		return err
+	}
+
+	return s.deleteAtID(ctx, tx, path.Join(ns, documentChecksumBucket), id)
+}
+
+// backfillDocumentChecksums stores the checksum of the content of documents stored before
+// checksums were kept. Documents that already have a checksum are left untouched, so the
+// backfill may be run again after being interrupted. It returns the number of checksums stored.
+func (s *Service) backfillDocumentChecksums(ctx context.Context, tx Tx) (int, error) {
+	nss, err := s.documentNamespaces(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, ns := range nss {
+		b, err := tx.Bucket([]byte(path.Join(ns, documentContentBucket)))
+		if err != nil {
+			return 0, err
+		}
+		cb, err := tx.Bucket([]byte(path.Join(ns, documentChecksumBucket)))
+		if err != nil {
+			return 0, err
+		}
+
+		cur, err := b.Cursor()
+		if err != nil {
+			return 0, err
+		}
+
+		for k, v := cur.First(); len(k) != 0; k, v = cur.Next() {
+			if _, err := cb.Get(k); err == nil {
+				continue
+			} else if !IsNotFound(err) {
+				return 0, err
+			}
+
+			if err := cb.Put(k, documentChecksum(v)); err != nil {
+				return 0, err
+			}
+			n++
+		}
+	}
+
+	return n, nil
+}
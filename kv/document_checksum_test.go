@@ -0,0 +1,193 @@
+package kv_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestDocumentStore_ContentChecksum(t *testing.T) {
+	store, closeStore, err := NewTestBoltStore()
+	if err != nil {
+		t.Fatalf("failed to create new bolt kv store: %v", err)
+	}
+	defer closeStore()
+
+	ctx := context.Background()
+	svc := kv.NewService(store)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	create := func(name string) *influxdb.Document {
+		d := &influxdb.Document{
+			Meta:    influxdb.DocumentMeta{Name: name},
+			Content: map[string]interface{}{"name": name},
+		}
+		if err := s.CreateDocument(ctx, d); err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+		return d
+	}
+	putRaw := func(bucket string, id influxdb.ID, v []byte) {
+		err := store.Update(ctx, func(tx kv.Tx) error {
+			b, err := tx.Bucket([]byte("testing" + bucket))
+			if err != nil {
+				return err
+			}
+			k, err := id.Encode()
+			if err != nil {
+				return err
+			}
+			if v == nil {
+				return b.Delete(k)
+			}
+			return b.Put(k, v)
+		})
+		if err != nil {
+			t.Fatalf("failed to write to %s: %v", bucket, err)
+		}
+	}
+	findContent := func(id influxdb.ID) (interface{}, error) {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(id), influxdb.IncludeContent)
+		if err != nil {
+			return nil, err
+		}
+		return ds[0].Content, nil
+	}
+
+	t.Run("intact content is read", func(t *testing.T) {
+		d := create("intact")
+		content, err := findContent(d.ID)
+		if err != nil {
+			t.Fatalf("unexpected error reading document: %v", err)
+		}
+		if content.(map[string]interface{})["name"] != "intact" {
+			t.Errorf("unexpected content %v", content)
+		}
+	})
+
+	t.Run("tampered content is reported as corrupt", func(t *testing.T) {
+		d := create("tampered")
+		putRaw("/documents/content", d.ID, []byte(`{"name":"tampered!"}`))
+
+		_, err := findContent(d.ID)
+		if code := influxdb.ErrorCode(err); code != influxdb.EInternal {
+			t.Fatalf("expected error code %q, got %q: %v", influxdb.EInternal, code, err)
+		}
+		if !strings.Contains(influxdb.ErrorMessage(err), influxdb.ErrDocumentContentCorrupt) {
+			t.Errorf("expected a corruption error, got %v", err)
+		}
+	})
+
+	t.Run("content without a checksum is read", func(t *testing.T) {
+		d := create("legacy")
+		putRaw("/documents/checksums", d.ID, nil)
+
+		if _, err := findContent(d.ID); err != nil {
+			t.Errorf("unexpected error reading document without a checksum: %v", err)
+		}
+	})
+}
+
+func TestService_ConvertToNew_DocumentChecksums(t *testing.T) {
+	store, closeStore, err := NewTestBoltStore()
+	if err != nil {
+		t.Fatalf("failed to create new bolt kv store: %v", err)
+	}
+	defer closeStore()
+
+	ctx := context.Background()
+	svc := kv.NewService(store)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	legacy := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "legacy"}, Content: "legacy"}
+	current := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "current"}, Content: "current"}
+	for _, d := range []*influxdb.Document{legacy, current} {
+		if err := s.CreateDocument(ctx, d); err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+	}
+
+	// the legacy document predates checksums, and the current checksum is marked so that
+	// rewriting it can be detected.
+	marker := []byte("existing checksum")
+	err = store.Update(ctx, func(tx kv.Tx) error {
+		b, err := tx.Bucket([]byte("testing/documents/checksums"))
+		if err != nil {
+			return err
+		}
+		if err := b.Delete(mustEncodeID(t, legacy.ID)); err != nil {
+			return err
+		}
+		return b.Put(mustEncodeID(t, current.ID), marker)
+	})
+	if err != nil {
+		t.Fatalf("failed to prepare checksums: %v", err)
+	}
+
+	if err := svc.ConvertToNew(ctx); err != nil {
+		t.Fatalf("unexpected error migrating: %v", err)
+	}
+
+	err = store.View(ctx, func(tx kv.Tx) error {
+		cb, err := tx.Bucket([]byte("testing/documents/checksums"))
+		if err != nil {
+			return err
+		}
+		b, err := tx.Bucket([]byte("testing/documents/content"))
+		if err != nil {
+			return err
+		}
+
+		content, err := b.Get(mustEncodeID(t, legacy.ID))
+		if err != nil {
+			return err
+		}
+		sum, err := cb.Get(mustEncodeID(t, legacy.ID))
+		if err != nil {
+			t.Errorf("expected the missing checksum to be filled: %v", err)
+		} else if want := sha256.Sum256(content); !bytes.Equal(sum, want[:]) {
+			t.Errorf("expected the checksum of the content, got %x", sum)
+		}
+
+		sum, err = cb.Get(mustEncodeID(t, current.ID))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(sum, marker) {
+			t.Errorf("expected the present checksum to be untouched, got %q", sum)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read checksums: %v", err)
+	}
+}
+
+func mustEncodeID(t *testing.T, id influxdb.ID) []byte {
+	t.Helper()
+
+	k, err := id.Encode()
+	if err != nil {
+		t.Fatalf("failed to encode id: %v", err)
+	}
+	return k
+}
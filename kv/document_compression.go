@@ -0,0 +1,56 @@
+package kv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+// gzipMagic starts all gzip compressed content. JSON never starts with it, so compressed and
+// uncompressed content can be told apart when read.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// CompressDocumentContent configures the service to gzip the content of the documents of the
+// namespaces provided before storing it. Content is decompressed when read whether or not its
+// namespace is compressed, so compression may be turned on and off for a namespace that already
+// holds documents. It must be configured before the service is used.
+func (s *Service) CompressDocumentContent(nss ...string) {
+	if s.compressedNamespaces == nil {
+		s.compressedNamespaces = map[string]bool{}
+	}
+	for _, ns := range nss {
+		s.compressedNamespaces[ns] = true
+	}
+}
+
+// encodeDocumentContent compresses the encoded content of a document if its namespace is compressed.
+func (s *Service) encodeDocumentContent(ns string, v []byte) ([]byte, error) {
+	if !s.compressedNamespaces[ns] {
+		return v, nil
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(v); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeDocumentContent decompresses the stored content of a document if it was compressed.
+func decodeDocumentContent(v []byte) ([]byte, error) {
+	if !bytes.HasPrefix(v, gzipMagic) {
+		return v, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(v))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
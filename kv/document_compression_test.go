@@ -0,0 +1,173 @@
+package kv_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+// largeTemplate returns template content with many similar cells, as dashboard templates have.
+func largeTemplate(cells int) map[string]interface{} {
+	cs := make([]interface{}, 0, cells)
+	for i := 0; i < cells; i++ {
+		cs = append(cs, map[string]interface{}{
+			"type": "cell",
+			"attributes": map[string]interface{}{
+				"x": float64(i % 12), "y": float64(i / 12), "w": float64(4), "h": float64(4),
+			},
+			"relationships": map[string]interface{}{
+				"view": map[string]interface{}{
+					"data": map[string]interface{}{"type": "view", "id": fmt.Sprintf("%d", i)},
+				},
+			},
+		})
+	}
+	return map[string]interface{}{
+		"meta":    map[string]interface{}{"version": "1", "name": "large"},
+		"content": map[string]interface{}{"data": map[string]interface{}{"type": "dashboard"}, "included": cs},
+	}
+}
+
+func storedDocumentContent(t testing.TB, store kv.Store, ns string, id influxdb.ID) []byte {
+	var v []byte
+	err := store.View(context.Background(), func(tx kv.Tx) error {
+		b, err := tx.Bucket([]byte(ns + "/documents/content"))
+		if err != nil {
+			return err
+		}
+		k, err := id.Encode()
+		if err != nil {
+			return err
+		}
+		v, err = b.Get(k)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to read stored content: %v", err)
+	}
+	return v
+}
+
+func TestDocumentStore_CompressDocumentContent(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	svc.CompressDocumentContent("compressed")
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	content := largeTemplate(100)
+	uncompressed, err := json.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		ns         string
+		compressed bool
+	}{
+		{ns: "compressed", compressed: true},
+		{ns: "plain", compressed: false},
+	} {
+		t.Run(tt.ns, func(t *testing.T) {
+			s, err := svc.CreateDocumentStore(ctx, tt.ns)
+			if err != nil {
+				t.Fatalf("failed to create document store: %v", err)
+			}
+			d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "large"}, Content: content}
+			if err := s.CreateDocument(ctx, d); err != nil {
+				t.Fatalf("failed to create document: %v", err)
+			}
+
+			v := storedDocumentContent(t, inmemStore, tt.ns, d.ID)
+			if got := bytes.HasPrefix(v, []byte{0x1f, 0x8b}); got != tt.compressed {
+				t.Errorf("expected stored content to be compressed %v, got %v", tt.compressed, got)
+			}
+			if tt.compressed && len(v) >= len(uncompressed) {
+				t.Errorf("expected compressed content to be smaller than %d bytes, got %d", len(uncompressed), len(v))
+			}
+
+			ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeContent)
+			if err != nil {
+				t.Fatalf("failed to find document: %v", err)
+			}
+			if !reflect.DeepEqual(ds[0].Content, content) {
+				t.Errorf("expected the original content, got %v", ds[0].Content)
+			}
+		})
+	}
+
+	t.Run("content stored before compression is read", func(t *testing.T) {
+		plain, err := svc.FindDocumentStore(ctx, "plain")
+		if err != nil {
+			t.Fatalf("failed to find document store: %v", err)
+		}
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "before"}, Content: "content"}
+		if err := plain.CreateDocument(ctx, d); err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+
+		svc.CompressDocumentContent("plain")
+		ds, err := plain.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeContent)
+		if err != nil {
+			t.Fatalf("failed to find document: %v", err)
+		}
+		if ds[0].Content != "content" {
+			t.Errorf("expected the original content, got %v", ds[0].Content)
+		}
+	})
+}
+
+func BenchmarkDocumentStore_CompressDocumentContent(b *testing.B) {
+	for _, compressed := range []bool{false, true} {
+		b.Run(fmt.Sprintf("compressed=%v", compressed), func(b *testing.B) {
+			inmemStore, closeInmem, err := NewTestInmemStore()
+			if err != nil {
+				b.Fatalf("failed to create new inmem kv store: %v", err)
+			}
+			defer closeInmem()
+
+			ctx := context.Background()
+			svc := kv.NewService(inmemStore)
+			if compressed {
+				svc.CompressDocumentContent("templates")
+			}
+			if err := svc.Initialize(ctx); err != nil {
+				b.Fatalf("failed to initialize service: %v", err)
+			}
+			s, err := svc.CreateDocumentStore(ctx, "templates")
+			if err != nil {
+				b.Fatalf("failed to create document store: %v", err)
+			}
+
+			content := largeTemplate(1000)
+			var id influxdb.ID
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "large"}, Content: content}
+				if err := s.CreateDocument(ctx, d); err != nil {
+					b.Fatalf("failed to create document: %v", err)
+				}
+				if _, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeContent); err != nil {
+					b.Fatalf("failed to find document: %v", err)
+				}
+				id = d.ID
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(len(storedDocumentContent(b, inmemStore, "templates", id))), "stored-bytes")
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/influxdata/influxdb"
+)
+
+var _ influxdb.DocumentDuplicateFinder = (*DocumentStore)(nil)
+
+// FindDuplicates returns the sets of documents owned by the organization that share identical
+// content. Content is compared in its canonical form, so documents whose content only differs
+// in how it is stored, such as its compression, whitespace or the order of its keys, are
+// duplicates. Documents are sorted by ID within a set, and sets by the ID of their first document.
+func (s *DocumentStore) FindDuplicates(ctx context.Context, orgID influxdb.ID) ([][]*influxdb.Document, error) {
+	var dups [][]*influxdb.Document
+	err := s.service.kv.View(ctx, func(tx Tx) error {
+		idx := &DocumentIndex{
+			service:   s.service,
+			namespace: s.namespace,
+			tx:        tx,
+			ctx:       ctx,
+		}
+		ids, err := idx.GetAccessorsDocuments("org", orgID)
+		if err != nil {
+			return err
+		}
+
+		byContent := map[string][]*influxdb.Document{}
+		for _, id := range ids {
+			d, err := s.service.findDocumentByID(ctx, tx, s.namespace, id)
+			if err != nil {
+				return err
+			}
+
+			content, err := s.service.findDocumentContentByID(ctx, tx, s.namespace, id)
+			if err != nil {
+				return err
+			}
+			v, err := influxdb.CanonicalDocumentContent(content)
+			if err != nil {
+				return err
+			}
+
+			sum := string(documentChecksum(v))
+			byContent[sum] = append(byContent[sum], d)
+		}
+
+		for _, ds := range byContent {
+			if len(ds) < 2 {
+				continue
+			}
+			sort.Slice(ds, func(i, j int) bool { return ds[i].ID < ds[j].ID })
+			dups = append(dups, ds)
+		}
+		sort.Slice(dups, func(i, j int) bool { return dups[i][0].ID < dups[j][0].ID })
+
+		return nil
+	})
+	if err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.ErrorCode(err),
+			Msg:  fmt.Sprintf("failed to find duplicate documents of organization %s", orgID),
+			Op:   OpPrefix + "FindDuplicates",
+			Err:  err,
+		}
+	}
+
+	return dups, nil
+}
@@ -0,0 +1,107 @@
+package kv_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestDocumentStore_FindDuplicates(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	o1 := &influxdb.Organization{Name: "o1"}
+	o2 := &influxdb.Organization{Name: "o2"}
+	for _, o := range []*influxdb.Organization{o1, o2} {
+		if err := svc.CreateOrganization(ctx, o); err != nil {
+			t.Fatalf("failed to create organization: %v", err)
+		}
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	create := func(name string, orgID influxdb.ID, content interface{}) *influxdb.Document {
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: name}, Content: content}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(orgID)); err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+		return d
+	}
+	d1 := create("d1", o1.ID, map[string]interface{}{"a": 1, "b": []interface{}{"x"}})
+	d2 := create("d2", o1.ID, map[string]interface{}{"b": []interface{}{"x"}, "a": 1})
+	create("unique", o1.ID, map[string]interface{}{"a": 2})
+	// documents of other organizations are not compared.
+	create("other", o2.ID, map[string]interface{}{"a": 1, "b": []interface{}{"x"}})
+
+	dups, err := s.(influxdb.DocumentDuplicateFinder).FindDuplicates(ctx, o1.ID)
+	if err != nil {
+		t.Fatalf("failed to find duplicates: %v", err)
+	}
+
+	if len(dups) != 1 {
+		t.Fatalf("expected one set of duplicates, got %d", len(dups))
+	}
+	if len(dups[0]) != 2 || dups[0][0].ID != d1.ID || dups[0][1].ID != d2.ID {
+		t.Errorf("expected documents %s and %s to be duplicates, got %v", d1.ID, d2.ID, dups[0])
+	}
+	if dups[0][0].Meta.Name != "d1" || dups[0][0].Content != nil {
+		t.Errorf("expected duplicates to be returned with their meta only, got %+v", dups[0][0])
+	}
+}
+
+func TestDocumentStore_FindDuplicates_Formatting(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	for i, content := range []string{
+		`{"name": "cpu", "tags": ["host"]}`,
+		"{\n\t\"tags\": [\"host\"],\n\t\"name\": \"cpu\"\n}",
+		"name: cpu\ntags:\n  - host\n",
+	} {
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: fmt.Sprintf("d%d", i)}, Content: content}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+	}
+
+	dups, err := s.(influxdb.DocumentDuplicateFinder).FindDuplicates(ctx, o.ID)
+	if err != nil {
+		t.Fatalf("failed to find duplicates: %v", err)
+	}
+	if len(dups) != 1 || len(dups[0]) != 3 {
+		t.Errorf("expected content differing only in formatting to be duplicates, got %v", dups)
+	}
+}
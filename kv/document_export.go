@@ -0,0 +1,265 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/influxdata/influxdb"
+)
+
+// documentExportVersion is the version of the stream format written by ExportDocuments.
+const documentExportVersion = 1
+
+// documentExport is the stream written by ExportDocuments. The labels of each document
+// are exported in full so that they can be recreated on import.
+type documentExport struct {
+	Version   int                  `json:"version"`
+	Namespace string               `json:"namespace"`
+	Documents []*influxdb.Document `json:"documents"`
+}
+
+// ExportDocuments writes the documents of the namespace that belong to the organization
+// provided to w, including their content and labels.
+func (s *Service) ExportDocuments(ctx context.Context, ns string, orgID influxdb.ID, w io.Writer) error {
+	exp := &documentExport{
+		Version:   documentExportVersion,
+		Namespace: ns,
+		Documents: []*influxdb.Document{},
+	}
+
+	err := s.kv.View(ctx, func(tx Tx) error {
+		if err := s.findDocumentNamespace(ctx, tx, ns); err != nil {
+			return err
+		}
+
+		idx := &DocumentIndex{
+			service:   s,
+			namespace: ns,
+			ctx:       ctx,
+			tx:        tx,
+		}
+		ids, err := idx.GetAccessorsDocuments("org", orgID)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			d, err := s.findDocumentByID(ctx, tx, ns, id)
+			if err != nil {
+				return err
+			}
+
+			if d.Content, err = s.findDocumentContentByID(ctx, tx, ns, id); err != nil {
+				return err
+			}
+
+			ls := []*influxdb.Label{}
+			f := influxdb.LabelMappingFilter{
+				ResourceID:   id,
+				ResourceType: influxdb.DocumentsResourceType,
+			}
+			if err := s.findResourceLabels(ctx, tx, f, &ls); err != nil {
+				return err
+			}
+			d.Labels = ls
+
+			exp.Documents = append(exp.Documents, d)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return &influxdb.Error{
+			Code: influxdb.ErrorCode(err),
+			Msg:  fmt.Sprintf("failed to export documents from %q", ns),
+			Op:   OpPrefix + "ExportDocuments",
+			Err:  err,
+		}
+	}
+
+	return json.NewEncoder(w).Encode(exp)
+}
+
+// ImportDocuments reads documents written by ExportDocuments into the namespace of the
+// export, making the organization provided their owner. Documents and labels keep their IDs
+// where they can so that label mappings are preserved: documents the organization already
+// owns are overwritten, and labels of the organization or of no organization are reused.
+// Documents and labels that exist but belong to other organizations are imported under new
+// IDs rather than shared with them. Labels created by the import belong to the organization
+// provided. The options are applied to each document once it is imported, such as
+// WithUniqueDocumentName or WithDocumentQuota. The import happens within a single transaction.
+func (s *Service) ImportDocuments(ctx context.Context, orgID influxdb.ID, r io.Reader, opts ...influxdb.DocumentOptions) error {
+	exp := &documentExport{}
+	if err := json.NewDecoder(r).Decode(exp); err != nil {
+		return &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "invalid document export",
+			Op:   OpPrefix + "ImportDocuments",
+			Err:  err,
+		}
+	}
+	if exp.Version != documentExportVersion {
+		return &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  fmt.Sprintf("unsupported document export version %d", exp.Version),
+			Op:   OpPrefix + "ImportDocuments",
+		}
+	}
+	if exp.Namespace == "" {
+		return &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "document export is missing a namespace",
+			Op:   OpPrefix + "ImportDocuments",
+		}
+	}
+
+	err := s.updateDocuments(ctx, func(tx Tx) error {
+		if _, err := s.createDocumentStore(ctx, tx, exp.Namespace); err != nil {
+			return err
+		}
+
+		idx := &DocumentIndex{
+			service:   s,
+			namespace: exp.Namespace,
+			ctx:       ctx,
+			tx:        tx,
+			writable:  true,
+		}
+
+		// labels are imported once even when several documents carry them.
+		labelIDs := map[influxdb.ID]influxdb.ID{}
+		for _, d := range exp.Documents {
+			if err := s.importDocument(ctx, tx, idx, orgID, d, labelIDs); err != nil {
+				return err
+			}
+
+			for _, opt := range opts {
+				if err := opt(d.ID, idx); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return &influxdb.Error{
+			Code: influxdb.ErrorCode(err),
+			Msg:  fmt.Sprintf("failed to import documents into %q", exp.Namespace),
+			Op:   OpPrefix + "ImportDocuments",
+			Err:  err,
+		}
+	}
+
+	return nil
+}
+
+// importDocument writes the document and maps it to its labels, importing the labels it
+// carries first. The ID of the document is replaced if a document of another organization
+// already has it.
+func (s *Service) importDocument(ctx context.Context, tx Tx, idx *DocumentIndex, orgID influxdb.ID, d *influxdb.Document, labelIDs map[influxdb.ID]influxdb.ID) error {
+	for _, l := range d.Labels {
+		if _, ok := labelIDs[l.ID]; ok {
+			continue
+		}
+		id, err := s.importLabel(ctx, tx, orgID, l)
+		if err != nil {
+			return err
+		}
+		labelIDs[l.ID] = id
+	}
+
+	op := influxdb.DocumentCreated
+	owned := false
+	_, err := s.findDocumentMetaByID(ctx, tx, idx.namespace, d.ID)
+	switch {
+	case IsNotFound(err):
+	case err != nil:
+		return err
+	default:
+		orgIDs, err := s.documentOrgIDs(ctx, tx, d.ID)
+		if err != nil {
+			return err
+		}
+		for _, id := range orgIDs {
+			if id == orgID {
+				owned = true
+				break
+			}
+		}
+
+		if owned {
+			op = influxdb.DocumentUpdated
+		} else if d.ID, err = s.nextDocumentID(ctx, tx); err != nil {
+			return err
+		}
+	}
+
+	if err := s.putDocument(ctx, tx, idx.namespace, d); err != nil {
+		return err
+	}
+	recordDocumentEvent(tx, idx.namespace, d.ID, op)
+
+	if !owned {
+		if err := idx.AddDocumentOwner(d.ID, "org", orgID); err != nil {
+			return err
+		}
+	}
+
+	mapped, err := s.documentLabelIDs(ctx, tx, d.ID)
+	if err != nil {
+		return err
+	}
+	has := make(map[influxdb.ID]bool, len(mapped))
+	for _, id := range mapped {
+		has[id] = true
+	}
+
+	for _, l := range d.Labels {
+		id := labelIDs[l.ID]
+		if has[id] {
+			continue
+		}
+		has[id] = true
+		if err := idx.AddDocumentLabel(d.ID, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// importLabel returns the ID of the label to map imported documents to. A label with the ID
+// provided is reused if it belongs to the organization or to none; otherwise the label is
+// created within the organization, under a new ID if its ID is taken.
+func (s *Service) importLabel(ctx context.Context, tx Tx, orgID influxdb.ID, l *influxdb.Label) (influxdb.ID, error) {
+	existing, err := s.findLabelByID(ctx, tx, l.ID)
+	if err == nil {
+		if !existing.OrganizationID.Valid() || existing.OrganizationID == orgID {
+			return existing.ID, nil
+		}
+	} else if influxdb.ErrorCode(err) != influxdb.ENotFound {
+		return influxdb.InvalidID(), err
+	}
+
+	nl := &influxdb.Label{
+		ID:             l.ID,
+		OrganizationID: orgID,
+		Name:           l.Name,
+		Properties:     l.Properties,
+	}
+	if existing != nil {
+		nl.ID = s.IDGenerator.ID()
+	}
+	if err := s.putLabel(ctx, tx, nl); err != nil {
+		return influxdb.InvalidID(), err
+	}
+
+	if err := s.createLabelUserResourceMappings(ctx, tx, nl); err != nil {
+		return influxdb.InvalidID(), err
+	}
+
+	return nl.ID, nil
+}
@@ -0,0 +1,209 @@
+package kv_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestService_ExportImportDocuments(t *testing.T) {
+	ctx := context.Background()
+
+	newService := func() (*kv.Service, func()) {
+		store, closeStore, err := NewTestInmemStore()
+		if err != nil {
+			t.Fatalf("failed to create new inmem kv store: %v", err)
+		}
+		svc := kv.NewService(store)
+		if err := svc.Initialize(ctx); err != nil {
+			t.Fatalf("failed to initialize service: %v", err)
+		}
+		return svc, closeStore
+	}
+
+	src, closeSrc := newService()
+	defer closeSrc()
+
+	o1 := &influxdb.Organization{Name: "o1"}
+	other := &influxdb.Organization{Name: "other"}
+	for _, o := range []*influxdb.Organization{o1, other} {
+		if err := src.CreateOrganization(ctx, o); err != nil {
+			t.Fatalf("failed to create organization: %v", err)
+		}
+	}
+	l1 := &influxdb.Label{Name: "l1", OrganizationID: o1.ID, Properties: map[string]string{"color": "blue"}}
+	l2 := &influxdb.Label{Name: "l2", OrganizationID: o1.ID}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := src.CreateLabel(ctx, l); err != nil {
+			t.Fatalf("failed to create label: %v", err)
+		}
+	}
+
+	ss, err := src.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatalf("failed to find document store: %v", err)
+	}
+	d1 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: map[string]interface{}{"a": "b"}}
+	d2 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d2"}, Content: "content"}
+	d3 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d3"}, Content: "other"}
+	if err := ss.CreateDocument(ctx, d1, influxdb.WithOrgID(o1.ID), influxdb.WithLabelID(l1.ID), influxdb.WithLabelID(l2.ID)); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	if err := ss.CreateDocument(ctx, d2, influxdb.WithOrgID(o1.ID), influxdb.WithLabelID(l2.ID)); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	if err := ss.CreateDocument(ctx, d3, influxdb.WithOrgID(other.ID)); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.ExportDocuments(ctx, "templates", o1.ID, &buf); err != nil {
+		t.Fatalf("failed to export documents: %v", err)
+	}
+
+	dst, closeDst := newService()
+	defer closeDst()
+
+	o2 := &influxdb.Organization{Name: "o2"}
+	if err := dst.CreateOrganization(ctx, o2); err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+
+	exported := buf.Bytes()
+	if err := dst.ImportDocuments(ctx, o2.ID, bytes.NewReader(exported)); err != nil {
+		t.Fatalf("failed to import documents: %v", err)
+	}
+
+	ds, err := dst.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatalf("failed to find document store: %v", err)
+	}
+	docs, err := ds.FindDocuments(ctx, influxdb.WhereOrg("o2"), influxdb.IncludeContent, influxdb.IncludeLabels)
+	if err != nil {
+		t.Fatalf("failed to find imported documents: %v", err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 imported documents, got %d", len(docs))
+	}
+
+	want := map[influxdb.ID]*influxdb.Document{d1.ID: d1, d2.ID: d2}
+	for _, got := range docs {
+		w, ok := want[got.ID]
+		if !ok {
+			t.Fatalf("unexpected document %s imported", got.ID)
+		}
+		if got.Meta.Name != w.Meta.Name || !reflect.DeepEqual(got.Content, w.Content) {
+			t.Errorf("expected imported document %v, got %v", w, got)
+		}
+		if len(got.Labels) != len(w.Labels) {
+			t.Fatalf("expected document %s to have %d labels, got %d", got.ID, len(w.Labels), len(got.Labels))
+		}
+		for i, l := range got.Labels {
+			if l.ID != w.Labels[i].ID || l.Name != w.Labels[i].Name || !reflect.DeepEqual(l.Properties, w.Labels[i].Properties) {
+				t.Errorf("expected document %s label %v, got %v", got.ID, w.Labels[i], l)
+			}
+			if l.OrganizationID != o2.ID {
+				t.Errorf("expected imported label to belong to %s, got %s", o2.ID, l.OrganizationID)
+			}
+		}
+	}
+
+	labeled, err := ds.FindDocuments(ctx, influxdb.WhereOrg("o2"), influxdb.WhereLabelID(l2.ID))
+	if err != nil {
+		t.Fatalf("failed to find imported documents by label: %v", err)
+	}
+	if len(labeled) != 2 {
+		t.Errorf("expected 2 imported documents with label l2, got %d", len(labeled))
+	}
+
+	t.Run("importing again into the organization overwrites its documents", func(t *testing.T) {
+		if err := dst.ImportDocuments(ctx, o2.ID, bytes.NewReader(exported)); err != nil {
+			t.Fatalf("failed to import documents again: %v", err)
+		}
+
+		docs, err := ds.FindDocuments(ctx, influxdb.WhereOrg("o2"), influxdb.IncludeLabels)
+		if err != nil {
+			t.Fatalf("failed to find imported documents: %v", err)
+		}
+		if len(docs) != 2 {
+			t.Fatalf("expected 2 imported documents, got %d", len(docs))
+		}
+		for _, got := range docs {
+			if len(got.Labels) != len(want[got.ID].Labels) {
+				t.Errorf("expected document %s to have %d labels, got %v", got.ID, len(want[got.ID].Labels), got.Labels)
+			}
+		}
+	})
+
+	t.Run("documents and labels of other organizations are not shared", func(t *testing.T) {
+		o3 := &influxdb.Organization{Name: "o3"}
+		if err := dst.CreateOrganization(ctx, o3); err != nil {
+			t.Fatalf("failed to create organization: %v", err)
+		}
+
+		if err := dst.ImportDocuments(ctx, o3.ID, bytes.NewReader(exported)); err != nil {
+			t.Fatalf("failed to import documents: %v", err)
+		}
+
+		docs, err := ds.FindDocuments(ctx, influxdb.WhereOrg("o3"), influxdb.IncludeContent, influxdb.IncludeLabels)
+		if err != nil {
+			t.Fatalf("failed to find imported documents: %v", err)
+		}
+		if len(docs) != 2 {
+			t.Fatalf("expected 2 imported documents, got %d", len(docs))
+		}
+		for _, got := range docs {
+			if _, ok := want[got.ID]; ok {
+				t.Errorf("expected document %s of o2 to be imported under a new id", got.ID)
+			}
+			for _, l := range got.Labels {
+				if l.OrganizationID != o3.ID {
+					t.Errorf("expected label %s of document %s to belong to %s, got %s", l.Name, got.Meta.Name, o3.ID, l.OrganizationID)
+				}
+			}
+		}
+
+		for id := range want {
+			ms, _, err := dst.FindUserResourceMappings(ctx, influxdb.UserResourceMappingFilter{
+				ResourceType: influxdb.DocumentsResourceType,
+				ResourceID:   id,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ms) != 1 || ms[0].UserID != o2.ID {
+				t.Errorf("expected document %s to be owned by o2 only, got %v", id, ms)
+			}
+		}
+	})
+
+	t.Run("options are applied to imported documents", func(t *testing.T) {
+		tests := []struct {
+			org  string
+			opt  influxdb.DocumentOptions
+			code string
+		}{
+			{org: "o4", opt: influxdb.WithUniqueDocumentName, code: influxdb.EConflict},
+			{org: "o5", opt: influxdb.WithDocumentQuota(1), code: influxdb.EForbidden},
+		}
+		for _, tt := range tests {
+			o := &influxdb.Organization{Name: tt.org}
+			if err := dst.CreateOrganization(ctx, o); err != nil {
+				t.Fatalf("failed to create organization: %v", err)
+			}
+			named := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "content"}
+			if err := ds.CreateDocument(ctx, named, influxdb.WithOrgID(o.ID)); err != nil {
+				t.Fatalf("failed to create document: %v", err)
+			}
+
+			err := dst.ImportDocuments(ctx, o.ID, bytes.NewReader(exported), tt.opt)
+			if code := influxdb.ErrorCode(err); code != tt.code {
+				t.Errorf("expected importing into %s to fail with code %q, got %q: %v", tt.org, tt.code, code, err)
+			}
+		}
+	})
+}
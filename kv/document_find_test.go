@@ -0,0 +1,65 @@
+package kv_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestDocumentStore_FindDocumentByID(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatalf("failed to create label: %v", err)
+	}
+
+	ds, err := svc.CreateDocumentStore(ctx, "notes")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+	s := ds.(influxdb.DocumentByIDFinder)
+
+	d1 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "d1 content"}
+	if err := ds.CreateDocument(ctx, d1, influxdb.WithLabelID(l.ID)); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	d2 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d2"}, Content: "d2 content"}
+	if err := ds.CreateDocument(ctx, d2); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	d, err := s.FindDocumentByID(ctx, d1.ID)
+	if err != nil {
+		t.Fatalf("failed to find document: %v", err)
+	}
+	if d.ID != d1.ID || d.Meta.Name != "d1" || d.Content != "d1 content" {
+		t.Errorf("expected d1 with its content, got %s named %q with content %v", d.ID, d.Meta.Name, d.Content)
+	}
+	if len(d.Labels) != 1 || d.Labels[0].ID != l.ID {
+		t.Errorf("expected d1 to carry label l1, got %v", d.Labels)
+	}
+
+	if _, err := s.FindDocumentByID(ctx, influxdb.ID(1000)); influxdb.ErrorCode(err) != influxdb.ENotFound {
+		t.Errorf("expected missing document not to be found, got %v", err)
+	}
+
+	if _, err := s.FindDocumentByID(ctx, d2.ID, influxdb.WhereLabelID(l.ID)); influxdb.ErrorCode(err) != influxdb.ENotFound {
+		t.Errorf("expected document without the label not to be found, got %v", err)
+	}
+	if _, err := s.FindDocumentByID(ctx, d1.ID, influxdb.WhereLabelID(l.ID)); err != nil {
+		t.Errorf("expected document with the label to be found, got %v", err)
+	}
+}
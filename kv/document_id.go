@@ -0,0 +1,108 @@
+package kv
+
+import (
+	"context"
+	"encoding/binary"
+	"path"
+
+	"github.com/influxdata/influxdb"
+)
+
+const (
+	// documentIDPrefixShift is the position of the prefix of prefixed document IDs, which
+	// takes their 16 high bits.
+	documentIDPrefixShift = 48
+	// maxDocumentIDSequence is the last document ID that may be allocated for a prefix.
+	maxDocumentIDSequence = 1<<documentIDPrefixShift - 1
+)
+
+// documentIDSequenceBucket holds the last document ID allocated for each prefix.
+var documentIDSequenceBucket = []byte("documentidsequencesv1")
+
+// PrefixDocumentIDs configures the service to allocate the IDs of the documents it creates
+// from the range of IDs whose 16 high bits are the prefix provided, rather than from its
+// IDGenerator. Instances configured with different prefixes never allocate the same document
+// ID, so that documents exported from one can be imported into another without their IDs
+// clashing. It must be configured before the service is used.
+func (s *Service) PrefixDocumentIDs(prefix uint16) {
+	s.documentIDPrefix = &prefix
+}
+
+// nextDocumentID returns the ID of a document about to be created.
+func (s *Service) nextDocumentID(ctx context.Context, tx Tx) (influxdb.ID, error) {
+	if s.documentIDPrefix == nil {
+		return s.IDGenerator.ID(), nil
+	}
+
+	b, err := tx.Bucket(documentIDSequenceBucket)
+	if err != nil {
+		return 0, err
+	}
+
+	key := make([]byte, 2)
+	binary.BigEndian.PutUint16(key, *s.documentIDPrefix)
+
+	var seq uint64
+	v, err := b.Get(key)
+	if err != nil && !IsNotFound(err) {
+		return 0, err
+	}
+	if err == nil {
+		seq = binary.BigEndian.Uint64(v)
+	}
+
+	nss, err := s.documentNamespaces(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	// documents created before the prefix was configured may already use an ID of the range.
+	for {
+		if seq >= maxDocumentIDSequence {
+			return 0, &influxdb.Error{
+				Code: influxdb.EInternal,
+				Msg:  "document IDs of the configured prefix are exhausted",
+			}
+		}
+		seq++
+
+		id := influxdb.ID(uint64(*s.documentIDPrefix)<<documentIDPrefixShift | seq)
+		used, err := s.documentIDUsed(ctx, tx, nss, id)
+		if err != nil {
+			return 0, err
+		}
+		if used {
+			continue
+		}
+
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, seq)
+		if err := b.Put(key, v); err != nil {
+			return 0, err
+		}
+
+		return id, nil
+	}
+}
+
+// documentIDUsed returns whether a document of any of the namespaces provided has the ID.
+func (s *Service) documentIDUsed(ctx context.Context, tx Tx, nss []string, id influxdb.ID) (bool, error) {
+	k, err := id.Encode()
+	if err != nil {
+		return false, err
+	}
+
+	for _, ns := range nss {
+		b, err := tx.Bucket([]byte(path.Join(ns, documentMetaBucket)))
+		if err != nil {
+			return false, err
+		}
+		if _, err := b.Get(k); err == nil {
+			return true, nil
+		} else if !IsNotFound(err) {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
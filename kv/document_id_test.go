@@ -0,0 +1,185 @@
+package kv_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestService_PrefixDocumentIDs(t *testing.T) {
+	ctx := context.Background()
+
+	newStore := func(prefix uint16) (*kv.Service, influxdb.DocumentStore, func()) {
+		t.Helper()
+		store, closeStore, err := NewTestInmemStore()
+		if err != nil {
+			t.Fatalf("failed to create new inmem kv store: %v", err)
+		}
+		svc := kv.NewService(store)
+		svc.PrefixDocumentIDs(prefix)
+		if err := svc.Initialize(ctx); err != nil {
+			t.Fatalf("failed to initialize service: %v", err)
+		}
+		s, err := svc.CreateDocumentStore(ctx, "testing")
+		if err != nil {
+			t.Fatalf("failed to create document store: %v", err)
+		}
+		return svc, s, closeStore
+	}
+
+	create := func(s influxdb.DocumentStore, n int) []influxdb.ID {
+		t.Helper()
+		var ids []influxdb.ID
+		for i := 0; i < n; i++ {
+			d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d"}, Content: "content"}
+			if err := s.CreateDocument(ctx, d); err != nil {
+				t.Fatalf("failed to create document: %v", err)
+			}
+			ids = append(ids, d.ID)
+		}
+		return ids
+	}
+
+	_, s1, close1 := newStore(1)
+	defer close1()
+	_, s2, close2 := newStore(2)
+	defer close2()
+
+	ids1, ids2 := create(s1, 10), create(s2, 10)
+	for _, ids := range [][]influxdb.ID{ids1, ids2} {
+		for i := 1; i < len(ids); i++ {
+			if ids[i] <= ids[i-1] {
+				t.Fatalf("expected increasing document ids, got %v", ids)
+			}
+		}
+	}
+	if max1, min2 := ids1[len(ids1)-1], ids2[0]; max1 >= min2 {
+		t.Errorf("expected id ranges not to overlap, got up to %s and from %s", max1, min2)
+	}
+	for _, id := range ids1 {
+		if prefix := uint64(id) >> 48; prefix != 1 {
+			t.Errorf("expected id %s to have prefix 1, got %d", id, prefix)
+		}
+	}
+
+	// documents of one instance can be merged into the other without their ids clashing.
+	whereIDs := func(influxdb.DocumentIndex, influxdb.DocumentDecorator) ([]influxdb.ID, error) {
+		return ids1, nil
+	}
+	ds, err := s1.FindDocuments(ctx, whereIDs, influxdb.IncludeContent)
+	if err != nil {
+		t.Fatalf("failed to find documents: %v", err)
+	}
+	for _, d := range ds {
+		if err := s2.(influxdb.DocumentRestorer).RestoreDocument(ctx, d); err != nil {
+			t.Errorf("failed to restore document %s: %v", d.ID, err)
+		}
+	}
+}
+
+func TestService_PrefixDocumentIDs_SkipsUsedIDs(t *testing.T) {
+	store, closeStore, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeStore()
+
+	ctx := context.Background()
+	svc := kv.NewService(store)
+	svc.PrefixDocumentIDs(1)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	// a document restored from elsewhere already uses the first id of the range.
+	used := &influxdb.Document{ID: influxdb.ID(1<<48 | 1), Meta: influxdb.DocumentMeta{Name: "used"}, Content: "content"}
+	if err := s.(influxdb.DocumentRestorer).RestoreDocument(ctx, used); err != nil {
+		t.Fatalf("failed to restore document: %v", err)
+	}
+
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d"}, Content: "content"}
+	if err := s.CreateDocument(ctx, d); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+	if want := influxdb.ID(1<<48 | 2); d.ID != want {
+		t.Errorf("expected document id %s, got %s", want, d.ID)
+	}
+}
+
+func TestService_PrefixDocumentIDs_Import(t *testing.T) {
+	ctx := context.Background()
+
+	newService := func(prefix uint16) (*kv.Service, func()) {
+		t.Helper()
+		store, closeStore, err := NewTestInmemStore()
+		if err != nil {
+			t.Fatalf("failed to create new inmem kv store: %v", err)
+		}
+		svc := kv.NewService(store)
+		svc.PrefixDocumentIDs(prefix)
+		if err := svc.Initialize(ctx); err != nil {
+			t.Fatalf("failed to initialize service: %v", err)
+		}
+		return svc, closeStore
+	}
+
+	src, closeSrc := newService(1)
+	defer closeSrc()
+	dst, closeDst := newService(2)
+	defer closeDst()
+
+	o1 := &influxdb.Organization{Name: "o1"}
+	if err := src.CreateOrganization(ctx, o1); err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+	ss, err := src.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatalf("failed to find document store: %v", err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d"}, Content: "content"}
+	if err := ss.CreateDocument(ctx, d, influxdb.WithOrgID(o1.ID)); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.ExportDocuments(ctx, "templates", o1.ID, &buf); err != nil {
+		t.Fatalf("failed to export documents: %v", err)
+	}
+
+	o2 := &influxdb.Organization{Name: "o2"}
+	o3 := &influxdb.Organization{Name: "o3"}
+	for _, o := range []*influxdb.Organization{o2, o3} {
+		if err := dst.CreateOrganization(ctx, o); err != nil {
+			t.Fatalf("failed to create organization: %v", err)
+		}
+	}
+
+	// the second import clashes with the document of the first and is imported under a new id.
+	for _, o := range []*influxdb.Organization{o2, o3} {
+		if err := dst.ImportDocuments(ctx, o.ID, bytes.NewReader(buf.Bytes())); err != nil {
+			t.Fatalf("failed to import documents: %v", err)
+		}
+	}
+
+	ds, err := dst.FindDocumentStore(ctx, "templates")
+	if err != nil {
+		t.Fatalf("failed to find document store: %v", err)
+	}
+	docs, err := ds.FindDocuments(ctx, influxdb.WhereOrg(o3.Name))
+	if err != nil {
+		t.Fatalf("failed to find imported documents: %v", err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("expected 1 imported document, got %d", len(docs))
+	}
+	if id := docs[0].ID; id == d.ID || uint64(id)>>48 != 2 {
+		t.Errorf("expected the clashing document to be imported under an id with prefix 2, got %s", id)
+	}
+}
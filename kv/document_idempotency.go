@@ -0,0 +1,226 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/influxdata/influxdb"
+)
+
+// documentIdempotencyBucket maps the idempotency keys of document creates in a namespace
+// to the documents they created.
+const documentIdempotencyBucket = "/documents/idempotency"
+
+// DocumentIdempotencyKeyTTL is how long an idempotency key refers to the document it created.
+const DocumentIdempotencyKeyTTL = 24 * time.Hour
+
+var _ influxdb.DocumentIdempotentCreator = (*DocumentStore)(nil)
+
+type documentIdempotencyKey struct {
+	ID        influxdb.ID `json:"id"`
+	ExpiresAt time.Time   `json:"expiresAt"`
+}
+
+// CreateDocumentOnce creates the document unless a document was created with the idempotency key
+// provided within DocumentIdempotencyKeyTTL, in which case d is replaced by that document, including
+// its content and labels, and false is returned. Keys are scoped to the organizations the options
+// make owners of the document, so that organizations cannot reuse each others keys. Expired keys,
+// and keys of documents that have since been deleted, are replaced when they are reused.
+func (s *DocumentStore) CreateDocumentOnce(ctx context.Context, key string, d *influxdb.Document, opts ...influxdb.DocumentOptions) (bool, error) {
+	if key == "" {
+		return false, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "idempotency key must not be empty",
+		}
+	}
+
+	var created bool
+	err := s.service.updateDocuments(ctx, func(tx Tx) error {
+		idx := uniqueIndex(path.Join(s.namespace, documentIdempotencyBucket))
+		now := s.service.time()
+
+		// the options are applied without writing to authorize the create and find its owners.
+		orgIDs, err := s.dryRunDocumentOptions(ctx, tx, s.service.IDGenerator.ID(), opts...)
+		if err != nil {
+			return err
+		}
+		scopedKey := documentIdempotencyIndexKey(orgIDs, key)
+
+		v, err := idx.lookup(tx, scopedKey)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			k := &documentIdempotencyKey{}
+			if err := json.Unmarshal(v, k); err != nil {
+				return err
+			}
+
+			// the document may have been deleted since, in which case it is created again.
+			if now.Before(k.ExpiresAt) {
+				found := &influxdb.Document{}
+				err := s.findDocumentWithContent(ctx, tx, k.ID, found)
+				if err == nil {
+					if err := s.checkDocumentOwners(ctx, tx, k.ID, key, opts...); err != nil {
+						return err
+					}
+					*d = *found
+					return nil
+				}
+				if !IsNotFound(err) {
+					return err
+				}
+			}
+		}
+
+		if err := s.createDocument(ctx, tx, d, opts...); err != nil {
+			return err
+		}
+		created = true
+
+		v, err = json.Marshal(&documentIdempotencyKey{
+			ID:        d.ID,
+			ExpiresAt: now.Add(DocumentIdempotencyKeyTTL),
+		})
+		if err != nil {
+			return err
+		}
+
+		return idx.put(tx, scopedKey, v)
+	})
+	if err != nil {
+		return false, &influxdb.Error{
+			Code: influxdb.ErrorCode(err),
+			Msg:  fmt.Sprintf("failed to create document with idempotency key %q", key),
+			Op:   OpPrefix + "CreateDocumentOnce",
+			Err:  err,
+		}
+	}
+
+	return created, nil
+}
+
+// documentIdempotencyIndexKey scopes the idempotency key provided to the organizations owning
+// the document.
+func documentIdempotencyIndexKey(orgIDs []influxdb.ID, key string) []byte {
+	sort.Slice(orgIDs, func(i, j int) bool { return orgIDs[i] < orgIDs[j] })
+
+	var k []byte
+	for _, orgID := range orgIDs {
+		k = append(k, orgID.String()...)
+		k = append(k, '/')
+	}
+	return append(k, key...)
+}
+
+// checkDocumentOwners applies the options of a repeated create to the document created with the
+// idempotency key, without writing, and ensures that the organizations they make owners of the
+// document already own it.
+func (s *DocumentStore) checkDocumentOwners(ctx context.Context, tx Tx, id influxdb.ID, key string, opts ...influxdb.DocumentOptions) error {
+	orgIDs, err := s.dryRunDocumentOptions(ctx, tx, id, opts...)
+	if err != nil {
+		return err
+	}
+
+	idx := &DocumentIndex{
+		service:   s.service,
+		namespace: s.namespace,
+		tx:        tx,
+		ctx:       ctx,
+		writable:  true,
+	}
+	owners, err := idx.GetDocumentsAccessors(id)
+	if err != nil {
+		return err
+	}
+
+	for _, orgID := range orgIDs {
+		if !containsDocumentID(owners, orgID) {
+			return &influxdb.Error{
+				Code: influxdb.EConflict,
+				Msg:  fmt.Sprintf("idempotency key %q was used by another organization", key),
+			}
+		}
+	}
+
+	return nil
+}
+
+// dryRunDocumentOptions applies the options to the document with the id provided without writing
+// and returns the organizations they make owners of the document. The options still authorize the
+// write, but the owners and labels they add or remove are not visible to the options that follow.
+func (s *DocumentStore) dryRunDocumentOptions(ctx context.Context, tx Tx, id influxdb.ID, opts ...influxdb.DocumentOptions) ([]influxdb.ID, error) {
+	idx := &documentDryRunIndex{
+		DocumentIndex: &DocumentIndex{
+			service:   s.service,
+			namespace: s.namespace,
+			tx:        tx,
+			ctx:       ctx,
+			writable:  true,
+		},
+	}
+	for _, opt := range opts {
+		if err := opt(id, idx); err != nil {
+			return nil, err
+		}
+	}
+
+	return idx.orgIDs, nil
+}
+
+// documentDryRunIndex is a DocumentIndex that records the organizations added as owners of a
+// document instead of writing them, and ignores the owners and labels removed or added.
+type documentDryRunIndex struct {
+	*DocumentIndex
+	orgIDs []influxdb.ID
+}
+
+// AddDocumentOwner records the owner provided if it exists.
+func (i *documentDryRunIndex) AddDocumentOwner(_ influxdb.ID, ownerType string, ownerID influxdb.ID) error {
+	if err := i.ownerExists(ownerType, ownerID); err != nil {
+		return err
+	}
+	if ownerType == "org" {
+		i.orgIDs = append(i.orgIDs, ownerID)
+	}
+	return nil
+}
+
+// RemoveDocumentOwner does nothing.
+func (i *documentDryRunIndex) RemoveDocumentOwner(influxdb.ID, string, influxdb.ID) error {
+	return nil
+}
+
+// AddDocumentLabel does nothing.
+func (i *documentDryRunIndex) AddDocumentLabel(influxdb.ID, influxdb.ID) error {
+	return nil
+}
+
+// RemoveDocumentLabel does nothing.
+func (i *documentDryRunIndex) RemoveDocumentLabel(influxdb.ID, influxdb.ID) error {
+	return nil
+}
+
+// findDocumentWithContent replaces d with the document with the id provided, including its
+// content and labels.
+func (s *DocumentStore) findDocumentWithContent(ctx context.Context, tx Tx, id influxdb.ID, d *influxdb.Document) error {
+	found, err := s.service.findDocumentByID(ctx, tx, s.namespace, id)
+	if err != nil {
+		return err
+	}
+
+	if found.Content, err = s.service.findDocumentContentByID(ctx, tx, s.namespace, id); err != nil {
+		return err
+	}
+
+	if err := s.decorateDocumentWithLabels(ctx, tx, found); err != nil {
+		return err
+	}
+
+	*d = *found
+	return nil
+}
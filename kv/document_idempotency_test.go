@@ -0,0 +1,132 @@
+package kv_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestDocumentStore_CreateDocumentOnce(t *testing.T) {
+	store, closeStore, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeStore()
+
+	ctx := context.Background()
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	svc := kv.NewService(store)
+	svc.WithTime(func() time.Time { return now })
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	ds, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+	s := ds.(influxdb.DocumentIdempotentCreator)
+
+	create := func(key string) (bool, *influxdb.Document) {
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d"}, Content: "content"}
+		created, err := s.CreateDocumentOnce(ctx, key, d)
+		if err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+		return created, d
+	}
+
+	created, d := create("k")
+	if !created {
+		t.Fatal("expected the first create to create a document")
+	}
+
+	created, again := create("k")
+	if created {
+		t.Error("expected a repeated create not to create a document")
+	}
+	if again.ID != d.ID || again.Content != "content" {
+		t.Errorf("expected the repeated create to return document %s with its content, got %+v", d.ID, again)
+	}
+
+	now = now.Add(kv.DocumentIdempotencyKeyTTL)
+	created, expired := create("k")
+	if !created || expired.ID == d.ID {
+		t.Errorf("expected an expired key to create a new document, got %s", expired.ID)
+	}
+}
+
+func TestDocumentStore_CreateDocumentOnce_Orgs(t *testing.T) {
+	store, closeStore, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeStore()
+
+	ctx := context.Background()
+	svc := kv.NewService(store)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	o1 := &influxdb.Organization{Name: "o1"}
+	o2 := &influxdb.Organization{Name: "o2"}
+	for _, o := range []*influxdb.Organization{o1, o2} {
+		if err := svc.CreateOrganization(ctx, o); err != nil {
+			t.Fatalf("failed to create organization: %v", err)
+		}
+	}
+
+	ds, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+	s := ds.(influxdb.DocumentIdempotentCreator)
+
+	create := func(key string, orgID influxdb.ID) (bool, *influxdb.Document, error) {
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d"}, Content: "content"}
+		created, err := s.CreateDocumentOnce(ctx, key, d, influxdb.WithOrgID(orgID))
+		return created, d, err
+	}
+
+	_, d1, err := create("k", o1.ID)
+	if err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	t.Run("keys are scoped to the organization", func(t *testing.T) {
+		created, d2, err := create("k", o2.ID)
+		if err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+		if !created || d2.ID == d1.ID {
+			t.Errorf("expected o2 to create its own document, got %s", d2.ID)
+		}
+		if d2.Content != "content" {
+			t.Errorf("expected the content of the new document, got %v", d2.Content)
+		}
+	})
+
+	t.Run("documents no longer owned by the organization conflict", func(t *testing.T) {
+		moveToO2 := func(id influxdb.ID, idx influxdb.DocumentIndex) error {
+			if err := idx.RemoveDocumentOwner(id, "org", o1.ID); err != nil {
+				return err
+			}
+			return idx.AddDocumentOwner(id, "org", o2.ID)
+		}
+		if err := ds.UpdateDocument(ctx, d1, moveToO2); err != nil {
+			t.Fatalf("failed to update document: %v", err)
+		}
+
+		created, d, err := create("k", o1.ID)
+		if influxdb.ErrorCode(err) != influxdb.EConflict {
+			t.Fatalf("expected a conflict, got %v", err)
+		}
+		if created || d.ID == d1.ID {
+			t.Errorf("expected the document not to be returned, got %s", d.ID)
+		}
+	})
+}
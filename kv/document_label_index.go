@@ -0,0 +1,436 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/influxdata/influxdb"
+)
+
+// documentLabelIndexBucket is the reverse of the label mapping index for documents. It
+// maps a label to the documents in a namespace that carry it.
+const documentLabelIndexBucket = "/documents/labels"
+
+var (
+	_ influxdb.DocumentLabelIndexer          = (*Service)(nil)
+	_ influxdb.DocumentLabelAttachedAtFinder = (*DocumentStore)(nil)
+	_ influxdb.DocumentLabeler               = (*DocumentStore)(nil)
+)
+
+func documentLabelIndexKey(labelID, docID influxdb.ID) ([]byte, error) {
+	lk, err := labelID.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	dk, err := docID.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(lk, dk...), nil
+}
+
+func (s *Service) indexDocumentLabel(ctx context.Context, tx Tx, ns string, docID, labelID influxdb.ID) error {
+	k, err := documentLabelIndexKey(labelID, docID)
+	if err != nil {
+		return err
+	}
+
+	b, err := tx.Bucket([]byte(path.Join(ns, documentLabelIndexBucket)))
+	if err != nil {
+		return err
+	}
+
+	return b.Put(k, k[influxdb.IDLength:])
+}
+
+func (s *Service) deindexDocumentLabel(ctx context.Context, tx Tx, ns string, docID, labelID influxdb.ID) error {
+	k, err := documentLabelIndexKey(labelID, docID)
+	if err != nil {
+		return err
+	}
+
+	b, err := tx.Bucket([]byte(path.Join(ns, documentLabelIndexBucket)))
+	if err != nil {
+		return err
+	}
+
+	return b.Delete(k)
+}
+
+// deindexDocument removes every reverse index entry for the labels mapped to the document.
+func (s *Service) deindexDocument(ctx context.Context, tx Tx, ns string, docID influxdb.ID) error {
+	labelIDs, err := s.documentLabelIDs(ctx, tx, docID)
+	if err != nil {
+		return err
+	}
+
+	for _, labelID := range labelIDs {
+		if err := s.deindexDocumentLabel(ctx, tx, ns, docID, labelID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// documentLabelIDs returns the IDs of the labels mapped to the document, including
+// those of labels that no longer exist.
+func (s *Service) documentLabelIDs(ctx context.Context, tx Tx, docID influxdb.ID) ([]influxdb.ID, error) {
+	prefix, err := docID.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := tx.Bucket(labelMappingBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := idx.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []influxdb.ID
+	for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+		_, labelID, err := decodeLabelMappingKey(k)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, labelID)
+	}
+
+	return ids, nil
+}
+
+// FindDocumentLabelsAttachedAt returns when each label of the document was attached, as
+// recorded in its label mappings.
+func (s *DocumentStore) FindDocumentLabelsAttachedAt(ctx context.Context, id influxdb.ID) (map[influxdb.ID]time.Time, error) {
+	prefix, err := id.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	times := map[influxdb.ID]time.Time{}
+	err = s.service.kv.View(ctx, func(tx Tx) error {
+		idx, err := tx.Bucket(labelMappingBucket)
+		if err != nil {
+			return err
+		}
+
+		cur, err := idx.Cursor()
+		if err != nil {
+			return err
+		}
+
+		for k, v := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, v = cur.Next() {
+			m := &influxdb.LabelMapping{}
+			if err := json.Unmarshal(v, m); err != nil {
+				return err
+			}
+			if m.AttachedAt != nil {
+				times[m.LabelID] = *m.AttachedAt
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return times, nil
+}
+
+// UpdateDocumentLabels applies the options to the document, such as attaching or detaching
+// labels, and returns the labels of the document once they are applied. Unlike UpdateDocument,
+// the meta and content of the document are not written, so that changes made to them
+// concurrently are preserved.
+func (s *DocumentStore) UpdateDocumentLabels(ctx context.Context, id influxdb.ID, opts ...influxdb.DocumentOptions) ([]*influxdb.Label, error) {
+	d := &influxdb.Document{ID: id}
+	err := s.service.updateDocuments(ctx, func(tx Tx) error {
+		if _, err := s.service.findDocumentMetaByID(ctx, tx, s.namespace, id); err != nil {
+			if IsNotFound(err) {
+				return &influxdb.Error{
+					Code: influxdb.ENotFound,
+					Msg:  influxdb.ErrDocumentNotFound,
+				}
+			}
+			return err
+		}
+
+		idx := &DocumentIndex{
+			service:   s.service,
+			namespace: s.namespace,
+			tx:        tx,
+			ctx:       ctx,
+			writable:  true,
+		}
+		for _, opt := range opts {
+			if err := opt(id, idx); err != nil {
+				return err
+			}
+		}
+
+		return s.decorateDocumentWithLabels(ctx, tx, d)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Labels, nil
+}
+
+// findDocumentIDsByLabel returns the IDs of the documents in the namespace that carry the label.
+func (s *Service) findDocumentIDsByLabel(ctx context.Context, tx Tx, ns string, labelID influxdb.ID) ([]influxdb.ID, error) {
+	prefix, err := labelID.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := tx.Bucket([]byte(path.Join(ns, documentLabelIndexBucket)))
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := b.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []influxdb.ID{}
+	for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+		var id influxdb.ID
+		if err := id.Decode(k[influxdb.IDLength:]); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// ReindexDocumentLabels rebuilds the reverse label index of the namespace provided from the
+// label mappings of the documents it holds. The index is cleared and then rebuilt in batches of
+// documentLabelReindexBatchSize keys, each in its own transaction, so that large namespaces do not
+// hold a single long write transaction. Documents may be missing from the index while it is
+// rebuilt. It returns the number of index entries written.
+func (s *Service) ReindexDocumentLabels(ctx context.Context, ns string) (int, error) {
+	err := s.kv.View(ctx, func(tx Tx) error {
+		return s.findDocumentNamespace(ctx, tx, ns)
+	})
+	if influxdb.ErrorCode(err) == influxdb.ENotFound {
+		return 0, err
+	}
+
+	var n int
+	if err == nil {
+		err = s.clearDocumentLabelIndex(ctx, ns)
+	}
+	if err == nil {
+		n, err = s.reindexDocumentLabels(ctx, ns)
+	}
+	if err != nil {
+		return 0, &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  fmt.Sprintf("failed to reindex labels of documents in %q", ns),
+			Op:   OpPrefix + "ReindexDocumentLabels",
+			Err:  err,
+		}
+	}
+
+	return n, nil
+}
+
+// documentLabelReindexBatchSize is the number of keys handled by each transaction of a reindex.
+const documentLabelReindexBatchSize = 100
+
+func (s *Service) findDocumentNamespace(ctx context.Context, tx Tx, ns string) error {
+	b, err := tx.Bucket(documentNamespaceBucket)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.Get([]byte(ns)); err != nil {
+		if IsNotFound(err) {
+			return &influxdb.Error{
+				Code: influxdb.ENotFound,
+				Msg:  fmt.Sprintf("document store %q not found", ns),
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// clearDocumentLabelIndex removes every entry of the label index of the namespace, a batch of
+// entries per transaction.
+func (s *Service) clearDocumentLabelIndex(ctx context.Context, ns string) error {
+	var after []byte
+	for {
+		var keys [][]byte
+		err := s.kv.Update(ctx, func(tx Tx) error {
+			b, err := tx.Bucket([]byte(path.Join(ns, documentLabelIndexBucket)))
+			if err != nil {
+				return err
+			}
+
+			keys, err = bucketKeysAfter(b, after, documentLabelReindexBatchSize)
+			if err != nil {
+				return err
+			}
+
+			for _, k := range keys {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(keys) < documentLabelReindexBatchSize {
+			return nil
+		}
+		after = keys[len(keys)-1]
+	}
+}
+
+// reindexDocumentLabels indexes the labels of every document of the namespace, a batch of
+// documents per transaction. It returns the number of index entries written.
+func (s *Service) reindexDocumentLabels(ctx context.Context, ns string) (int, error) {
+	var n int
+	var after []byte
+	for {
+		var keys [][]byte
+		err := s.kv.Update(ctx, func(tx Tx) error {
+			b, err := tx.Bucket([]byte(path.Join(ns, documentMetaBucket)))
+			if err != nil {
+				return err
+			}
+
+			keys, err = bucketKeysAfter(b, after, documentLabelReindexBatchSize)
+			if err != nil {
+				return err
+			}
+
+			i, err := s.indexLabelsOfDocuments(ctx, tx, ns, keys)
+			if err != nil {
+				return err
+			}
+			n += i
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		if len(keys) < documentLabelReindexBatchSize {
+			return n, nil
+		}
+		after = keys[len(keys)-1]
+	}
+}
+
+// bucketKeysAfter returns up to limit keys of the bucket, beginning with the key that follows
+// after, or with the first key if after is nil.
+func bucketKeysAfter(b Bucket, after []byte, limit int) ([][]byte, error) {
+	cur, err := b.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	var k []byte
+	if after == nil {
+		k, _ = cur.First()
+	} else if sk, _ := cur.Seek(after); bytes.Equal(sk, after) {
+		k, _ = cur.Next()
+	} else {
+		// the key has been removed since, and not every store seeks past a missing key.
+		for k, _ = cur.First(); len(k) != 0 && bytes.Compare(k, after) <= 0; k, _ = cur.Next() {
+		}
+	}
+
+	var keys [][]byte
+	for ; len(k) != 0 && len(keys) < limit; k, _ = cur.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+
+	return keys, nil
+}
+
+// indexDocumentLabels indexes the labels of every document of the namespace. It returns the
+// number of index entries written.
+func (s *Service) indexDocumentLabels(ctx context.Context, tx Tx, ns string) (int, error) {
+	b, err := tx.Bucket([]byte(path.Join(ns, documentMetaBucket)))
+	if err != nil {
+		return 0, err
+	}
+
+	cur, err := b.Cursor()
+	if err != nil {
+		return 0, err
+	}
+
+	// collect the keys before writing, as writing during iteration is not supported by all stores.
+	var keys [][]byte
+	for k, _ := cur.First(); len(k) != 0; k, _ = cur.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+
+	return s.indexLabelsOfDocuments(ctx, tx, ns, keys)
+}
+
+// indexLabelsOfDocuments indexes the labels of the documents with the meta keys provided. It
+// returns the number of index entries written.
+func (s *Service) indexLabelsOfDocuments(ctx context.Context, tx Tx, ns string, keys [][]byte) (int, error) {
+	var n int
+	for _, k := range keys {
+		var docID influxdb.ID
+		if err := docID.Decode(k); err != nil {
+			return 0, err
+		}
+
+		labelIDs, err := s.documentLabelIDs(ctx, tx, docID)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, labelID := range labelIDs {
+			if err := s.indexDocumentLabel(ctx, tx, ns, docID, labelID); err != nil {
+				return 0, err
+			}
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// indexAllDocumentLabels builds the reverse label index of every namespace. It returns the
+// number of index entries written.
+func (s *Service) indexAllDocumentLabels(ctx context.Context, tx Tx) (int, error) {
+	nss, err := s.documentNamespaces(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, ns := range nss {
+		i, err := s.indexDocumentLabels(ctx, tx, ns)
+		if err != nil {
+			return 0, err
+		}
+		n += i
+	}
+
+	return n, nil
+}
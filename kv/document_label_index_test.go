@@ -0,0 +1,111 @@
+package kv_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestDocumentStore_UpdateDocumentLabels(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	o1 := &influxdb.Organization{Name: "o1"}
+	o2 := &influxdb.Organization{Name: "o2"}
+	for _, o := range []*influxdb.Organization{o1, o2} {
+		if err := svc.CreateOrganization(ctx, o); err != nil {
+			t.Fatalf("failed to create organization: %v", err)
+		}
+	}
+	l1 := &influxdb.Label{Name: "l1", OrganizationID: o1.ID}
+	l2 := &influxdb.Label{Name: "l2", OrganizationID: o2.ID}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatalf("failed to create label: %v", err)
+		}
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: []interface{}{"a"}}
+	if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o1.ID)); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	// a document read before its content is appended to.
+	stale, err := s.(influxdb.DocumentByIDFinder).FindDocumentByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("failed to find document: %v", err)
+	}
+	if _, err := s.AppendContent(ctx, d.ID, []interface{}{"b"}); err != nil {
+		t.Fatalf("failed to append content: %v", err)
+	}
+
+	var events []influxdb.DocumentEvent
+	unsubscribe := svc.SubscribeDocumentEvents(func(e influxdb.DocumentEvent) {
+		events = append(events, e)
+	})
+	defer unsubscribe()
+
+	ls, err := s.(influxdb.DocumentLabeler).UpdateDocumentLabels(ctx, stale.ID, influxdb.WithLabelID(l1.ID))
+	if err != nil {
+		t.Fatalf("failed to attach label: %v", err)
+	}
+	if len(ls) != 1 || ls[0].ID != l1.ID {
+		t.Errorf("expected the document to carry l1, got %v", ls)
+	}
+
+	got, err := s.(influxdb.DocumentByIDFinder).FindDocumentByID(ctx, d.ID)
+	if err != nil {
+		t.Fatalf("failed to find document: %v", err)
+	}
+	if want := []interface{}{"a", "b"}; !reflect.DeepEqual(got.Content, want) {
+		t.Errorf("expected the appended content %v to be kept, got %v", want, got.Content)
+	}
+
+	want := []influxdb.DocumentEvent{
+		{Namespace: "testing", ID: d.ID, LabelID: l1.ID, Operation: influxdb.DocumentLabelAttached},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("expected events %v, got %v", want, events)
+	}
+
+	t.Run("labels of other organizations are not attached", func(t *testing.T) {
+		_, err := s.(influxdb.DocumentLabeler).UpdateDocumentLabels(ctx, d.ID, influxdb.WithLabelID(l2.ID))
+		if code := influxdb.ErrorCode(err); code != influxdb.ENotFound {
+			t.Fatalf("expected error code %q, got %q: %v", influxdb.ENotFound, code, err)
+		}
+
+		ls, err := svc.FindResourceLabels(ctx, influxdb.LabelMappingFilter{
+			ResourceID:   d.ID,
+			ResourceType: influxdb.DocumentsResourceType,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ls) != 1 || ls[0].ID != l1.ID {
+			t.Errorf("expected the document to carry l1 only, got %v", ls)
+		}
+	})
+
+	t.Run("missing documents are not found", func(t *testing.T) {
+		_, err := s.(influxdb.DocumentLabeler).UpdateDocumentLabels(ctx, influxdb.ID(1), influxdb.WithLabelID(l1.ID))
+		if code := influxdb.ErrorCode(err); code != influxdb.ENotFound {
+			t.Fatalf("expected error code %q, got %q: %v", influxdb.ENotFound, code, err)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+
+	"github.com/influxdata/influxdb"
+	"go.uber.org/zap"
+)
+
+// legacyDocumentMeta is the org of a document as it was stored by early versions, by name in
+// the meta of the document rather than as an owner of the document.
+type legacyDocumentMeta struct {
+	Org          string `json:"org"`
+	Organization string `json:"organization"`
+}
+
+// orgName returns the name of the org of the document, preferring org over organization.
+func (m *legacyDocumentMeta) orgName() string {
+	if m.Org != "" {
+		return m.Org
+	}
+	return m.Organization
+}
+
+// legacyDocumentOrg is a document naming its org in its meta.
+type legacyDocumentOrg struct {
+	id      influxdb.ID
+	meta    influxdb.DocumentMeta
+	orgName string
+}
+
+// backfillDocumentOrgIDs is the migration making the org named in the meta of early documents
+// an owner of the document, as documents are found and authorized by the ID of their owners.
+// The name is dropped from the meta once resolved. Documents already owned by an org keep their
+// owners, and documents naming an org that does not exist are left as they are. It returns the
+// number of documents updated.
+func (s *Service) backfillDocumentOrgIDs(ctx context.Context, tx Tx) (int, error) {
+	nss, err := s.documentNamespaces(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, ns := range nss {
+		ds, err := s.findLegacyDocumentOrgs(ctx, tx, ns)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, d := range ds {
+			o, err := s.findOrganizationByName(ctx, tx, d.orgName)
+			if influxdb.ErrorCode(err) == influxdb.ENotFound {
+				s.Logger.Warn("Document names an org that does not exist",
+					zap.String("namespace", ns),
+					zap.Stringer("id", d.id),
+					zap.String("org", d.orgName))
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+
+			orgIDs, err := s.documentOrgIDs(ctx, tx, d.id)
+			if err != nil {
+				return 0, err
+			}
+			if len(orgIDs) == 0 {
+				m := &influxdb.UserResourceMapping{
+					UserID:       o.ID,
+					UserType:     influxdb.Owner,
+					MappingType:  influxdb.OrgMappingType,
+					ResourceType: influxdb.DocumentsResourceType,
+					ResourceID:   d.id,
+				}
+				if err := s.createUserResourceMapping(ctx, tx, m); err != nil {
+					return 0, err
+				}
+				if err := s.indexDocumentOrg(ctx, tx, ns, d.id, o.ID); err != nil {
+					return 0, err
+				}
+			}
+
+			if err := s.putDocumentMeta(ctx, tx, ns, d.id, &d.meta); err != nil {
+				return 0, err
+			}
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// findLegacyDocumentOrgs returns the documents of the namespace naming their org in their meta.
+func (s *Service) findLegacyDocumentOrgs(ctx context.Context, tx Tx, ns string) ([]legacyDocumentOrg, error) {
+	b, err := tx.Bucket([]byte(path.Join(ns, documentMetaBucket)))
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := b.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	// collect the documents before writing, as writing during iteration is not supported by all stores.
+	var ds []legacyDocumentOrg
+	for k, v := cur.First(); len(k) != 0; k, v = cur.Next() {
+		legacy := &legacyDocumentMeta{}
+		if err := json.Unmarshal(v, legacy); err != nil {
+			return nil, err
+		}
+		if legacy.orgName() == "" {
+			continue
+		}
+
+		d := legacyDocumentOrg{orgName: legacy.orgName()}
+		if err := d.id.Decode(k); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(v, &d.meta); err != nil {
+			return nil, err
+		}
+		ds = append(ds, d)
+	}
+
+	return ds, nil
+}
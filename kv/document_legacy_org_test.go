@@ -0,0 +1,107 @@
+package kv_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestService_BackfillDocumentOrgIDs(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	o1 := &influxdb.Organization{Name: "o1"}
+	o2 := &influxdb.Organization{Name: "o2"}
+	for _, o := range []*influxdb.Organization{o1, o2} {
+		if err := svc.CreateOrganization(ctx, o); err != nil {
+			t.Fatalf("failed to create organization: %v", err)
+		}
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	// legacy stores the document as early versions did, naming its org in its meta.
+	legacy := func(name, field, org string, opts ...influxdb.DocumentOptions) *influxdb.Document {
+		t.Helper()
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: name}, Content: "content"}
+		if err := s.CreateDocument(ctx, d, opts...); err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+		key, err := d.ID.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = svc.UpdateJSON(ctx, []byte("testing/documents/meta"), key, func(raw []byte) ([]byte, error) {
+			m := map[string]interface{}{}
+			if err := json.Unmarshal(raw, &m); err != nil {
+				return nil, err
+			}
+			m[field] = org
+			return json.Marshal(m)
+		})
+		if err != nil {
+			t.Fatalf("failed to name the org of the document: %v", err)
+		}
+		return d
+	}
+	d1 := legacy("d1", "org", "o1")
+	d2 := legacy("d2", "organization", "o2")
+	d3 := legacy("d3", "org", "o1", influxdb.WithOrgID(o2.ID))
+	d4 := legacy("d4", "org", "missing")
+
+	if err := svc.BackfillDocumentOrgIDs(ctx); err != nil {
+		t.Fatalf("failed to backfill org ids: %v", err)
+	}
+
+	orgIDs := func(d *influxdb.Document) []influxdb.ID {
+		t.Helper()
+		ms, _, err := svc.FindUserResourceMappings(ctx, influxdb.UserResourceMappingFilter{
+			ResourceType: influxdb.DocumentsResourceType,
+			ResourceID:   d.ID,
+		})
+		if err != nil {
+			t.Fatalf("failed to find mappings: %v", err)
+		}
+		ids := []influxdb.ID{}
+		for _, m := range ms {
+			ids = append(ids, m.UserID)
+		}
+		return ids
+	}
+
+	if ids := orgIDs(d1); len(ids) != 1 || ids[0] != o1.ID {
+		t.Errorf("expected d1 to be owned by o1, got %v", ids)
+	}
+	if ids := orgIDs(d2); len(ids) != 1 || ids[0] != o2.ID {
+		t.Errorf("expected d2 to be owned by o2, got %v", ids)
+	}
+	if ids := orgIDs(d3); len(ids) != 1 || ids[0] != o2.ID {
+		t.Errorf("expected d3 to keep its owner o2, got %v", ids)
+	}
+	if ids := orgIDs(d4); len(ids) != 0 {
+		t.Errorf("expected d4 naming a missing org to have no owner, got %v", ids)
+	}
+
+	ds, err := s.FindDocuments(ctx, influxdb.WhereOrg("o1"))
+	if err != nil {
+		t.Fatalf("failed to find documents: %v", err)
+	}
+	if len(ds) != 1 || ds[0].ID != d1.ID {
+		t.Errorf("expected d1 to be found by its org, got %v", ds)
+	}
+}
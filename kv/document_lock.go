@@ -0,0 +1,200 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"time"
+
+	"github.com/influxdata/influxdb"
+)
+
+// documentLockBucket maps the IDs of the documents in a namespace to the advisory locks held
+// on them.
+const documentLockBucket = "/documents/locks"
+
+var _ influxdb.DocumentLocker = (*DocumentStore)(nil)
+
+// FindDocumentLock retrieves the unexpired lock held on the document, or nil if there is none.
+func (i *DocumentIndex) FindDocumentLock(docID influxdb.ID) (*influxdb.DocumentLock, error) {
+	return i.service.findDocumentLock(i.ctx, i.tx, i.namespace, docID)
+}
+
+// LockDocument acquires the lock of the document for the owner until ttl has elapsed, or
+// renews it if the owner already holds it. Options are applied before the lock is acquired.
+func (s *DocumentStore) LockDocument(ctx context.Context, id, ownerID influxdb.ID, ttl time.Duration, opts ...influxdb.DocumentOptions) (*influxdb.DocumentLock, error) {
+	if ttl <= 0 {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "document lock ttl must be positive",
+		}
+	}
+	if !ownerID.Valid() {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "document lock owner must be a valid id",
+		}
+	}
+
+	var l *influxdb.DocumentLock
+	err := s.service.kv.Update(ctx, func(tx Tx) error {
+		if err := s.applyLockOptions(ctx, tx, id, opts); err != nil {
+			return err
+		}
+
+		held, err := s.service.findDocumentLock(ctx, tx, s.namespace, id)
+		if err != nil {
+			return err
+		}
+		if held != nil && held.OwnerID != ownerID {
+			return &influxdb.Error{
+				Code: influxdb.EConflict,
+				Msg:  influxdb.ErrDocumentLocked,
+			}
+		}
+
+		l = &influxdb.DocumentLock{
+			OwnerID:   ownerID,
+			ExpiresAt: s.service.time().Add(ttl),
+		}
+		return s.service.putDocumentLock(ctx, tx, s.namespace, id, l)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// UnlockDocument releases the lock held by the owner. It is not found if the document is
+// not locked. Options are applied before the lock is released.
+func (s *DocumentStore) UnlockDocument(ctx context.Context, id, ownerID influxdb.ID, opts ...influxdb.DocumentOptions) error {
+	return s.service.kv.Update(ctx, func(tx Tx) error {
+		if err := s.applyLockOptions(ctx, tx, id, opts); err != nil {
+			return err
+		}
+
+		l, err := s.service.findDocumentLock(ctx, tx, s.namespace, id)
+		if err != nil {
+			return err
+		}
+		if l == nil {
+			return &influxdb.Error{
+				Code: influxdb.ENotFound,
+				Msg:  "document is not locked",
+			}
+		}
+		if l.OwnerID != ownerID {
+			return &influxdb.Error{
+				Code: influxdb.EConflict,
+				Msg:  influxdb.ErrDocumentLocked,
+			}
+		}
+
+		return s.service.deleteDocumentLock(ctx, tx, s.namespace, id)
+	})
+}
+
+// applyLockOptions ensures the document exists and applies the options provided to it. It is
+// also used when pinning documents.
+func (s *DocumentStore) applyLockOptions(ctx context.Context, tx Tx, id influxdb.ID, opts []influxdb.DocumentOptions) error {
+	_, err := s.service.findDocumentMetaByID(ctx, tx, s.namespace, id)
+	if IsNotFound(err) {
+		return &influxdb.Error{
+			Code: influxdb.ENotFound,
+			Msg:  influxdb.ErrDocumentNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	idx := &DocumentIndex{
+		service:   s.service,
+		namespace: s.namespace,
+		tx:        tx,
+		ctx:       ctx,
+		writable:  true,
+	}
+	for _, opt := range opts {
+		if err := opt(id, idx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) findDocumentLock(ctx context.Context, tx Tx, ns string, id influxdb.ID) (*influxdb.DocumentLock, error) {
+	b, err := tx.Bucket([]byte(path.Join(ns, documentLockBucket)))
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := b.Get([]byte(id.String()))
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l := &influxdb.DocumentLock{}
+	if err := json.Unmarshal(v, l); err != nil {
+		return nil, err
+	}
+
+	if !s.time().Before(l.ExpiresAt) {
+		return nil, nil
+	}
+
+	return l, nil
+}
+
+func (s *Service) putDocumentLock(ctx context.Context, tx Tx, ns string, id influxdb.ID, l *influxdb.DocumentLock) error {
+	b, err := tx.Bucket([]byte(path.Join(ns, documentLockBucket)))
+	if err != nil {
+		return err
+	}
+
+	v, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(id.String()), v)
+}
+
+func (s *Service) deleteDocumentLock(ctx context.Context, tx Tx, ns string, id influxdb.ID) error {
+	b, err := tx.Bucket([]byte(path.Join(ns, documentLockBucket)))
+	if err != nil {
+		return err
+	}
+
+	return b.Delete([]byte(id.String()))
+}
+
+// moveDocumentLock copies the lock held on the document, if any, from one namespace to the
+// other, so that it follows the document when it is moved. The lock left in the namespace the
+// document is moved from is removed with the document.
+func (s *Service) moveDocumentLock(ctx context.Context, tx Tx, from, to string, id influxdb.ID) error {
+	b, err := tx.Bucket([]byte(path.Join(from, documentLockBucket)))
+	if err != nil {
+		return err
+	}
+
+	v, err := b.Get([]byte(id.String()))
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tb, err := tx.Bucket([]byte(path.Join(to, documentLockBucket)))
+	if err != nil {
+		return err
+	}
+
+	return tb.Put([]byte(id.String()), v)
+}
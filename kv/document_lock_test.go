@@ -0,0 +1,102 @@
+package kv_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestDocumentStore_LockDocument(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+	now := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	svc.WithTime(func() time.Time { return now })
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "content"}
+	if err := s.CreateDocument(ctx, d); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	locker := s.(influxdb.DocumentLocker)
+	owner, other := influxdb.ID(1), influxdb.ID(2)
+
+	l, err := locker.LockDocument(ctx, d.ID, owner, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to lock document: %v", err)
+	}
+	if want := now.Add(time.Minute); l.OwnerID != owner || !l.ExpiresAt.Equal(want) {
+		t.Errorf("expected lock held by %s until %s, got %+v", owner, want, l)
+	}
+
+	if _, err := locker.LockDocument(ctx, d.ID, other, time.Minute); influxdb.ErrorCode(err) != influxdb.EConflict {
+		t.Errorf("expected locking a locked document to conflict, got %v", err)
+	}
+	if err := s.UpdateDocument(ctx, d, influxdb.WithLockOwner(other)); influxdb.ErrorCode(err) != influxdb.EConflict {
+		t.Errorf("expected updating a document locked by another owner to conflict, got %v", err)
+	}
+	if err := s.UpdateDocument(ctx, d, influxdb.WithLockOwner(owner)); err != nil {
+		t.Errorf("expected the owner to update the document, got %v", err)
+	}
+
+	if err := locker.UnlockDocument(ctx, d.ID, other); influxdb.ErrorCode(err) != influxdb.EConflict {
+		t.Errorf("expected unlocking a document locked by another owner to conflict, got %v", err)
+	}
+	if err := locker.UnlockDocument(ctx, d.ID, owner); err != nil {
+		t.Fatalf("failed to unlock document: %v", err)
+	}
+	if err := locker.UnlockDocument(ctx, d.ID, owner); influxdb.ErrorCode(err) != influxdb.ENotFound {
+		t.Errorf("expected unlocking an unlocked document to be not found, got %v", err)
+	}
+
+	// expired locks are released.
+	if _, err := locker.LockDocument(ctx, d.ID, owner, time.Minute); err != nil {
+		t.Fatalf("failed to lock document: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := locker.LockDocument(ctx, d.ID, other, time.Minute); err != nil {
+		t.Errorf("expected an expired lock to be acquired by another owner, got %v", err)
+	}
+
+	if _, err := locker.LockDocument(ctx, influxdb.ID(42), owner, time.Minute); influxdb.ErrorCode(err) != influxdb.ENotFound {
+		t.Errorf("expected locking a missing document to be not found, got %v", err)
+	}
+
+	// the lock follows the document when it is moved.
+	moved, err := svc.CreateDocumentStore(ctx, "moved")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+	if err := s.(influxdb.DocumentMover).MoveDocument(ctx, d.ID, "moved", nil, nil); err != nil {
+		t.Fatalf("failed to move document: %v", err)
+	}
+	if _, err := moved.(influxdb.DocumentLocker).LockDocument(ctx, d.ID, owner, time.Minute); influxdb.ErrorCode(err) != influxdb.EConflict {
+		t.Errorf("expected the moved document to stay locked, got %v", err)
+	}
+
+	// the lock is removed with the document, so that a document restored with its id is unlocked.
+	if err := moved.DeleteDocuments(ctx, influxdb.WhereID(d.ID)); err != nil {
+		t.Fatalf("failed to delete document: %v", err)
+	}
+	if err := moved.(influxdb.DocumentRestorer).RestoreDocument(ctx, d); err != nil {
+		t.Fatalf("failed to restore document: %v", err)
+	}
+	if _, err := moved.(influxdb.DocumentLocker).LockDocument(ctx, d.ID, owner, time.Minute); err != nil {
+		t.Errorf("expected the restored document to be unlocked, got %v", err)
+	}
+}
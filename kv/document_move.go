@@ -0,0 +1,84 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/influxdata/influxdb"
+)
+
+var _ influxdb.DocumentMover = (*DocumentStore)(nil)
+
+// MoveDocument moves the document into the namespace provided in a single transaction. Owners
+// and label mappings are kept, as they refer to the document by its ID. Documents are moved to
+// the trash with TrashDocuments instead. The options targetOpts are applied once the document
+// is moved, so that they see it among the documents of the namespace it is moved to.
+func (s *DocumentStore) MoveDocument(ctx context.Context, id influxdb.ID, ns string, opts, targetOpts []influxdb.DocumentOptions) error {
+	switch ns {
+	case s.namespace:
+		return &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  fmt.Sprintf("document is already in namespace %q", ns),
+		}
+	case DocumentTrashNamespace:
+		return &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "documents cannot be moved to the trash; trash them instead",
+		}
+	}
+
+	err := s.service.updateDocuments(ctx, func(tx Tx) error {
+		if err := s.applyLockOptions(ctx, tx, id, opts); err != nil {
+			return err
+		}
+
+		if err := s.service.findDocumentNamespace(ctx, tx, ns); err != nil {
+			if influxdb.ErrorCode(err) == influxdb.ENotFound {
+				return &influxdb.Error{
+					Code: influxdb.EInvalid,
+					Msg:  fmt.Sprintf("namespace %q does not exist", ns),
+				}
+			}
+			return err
+		}
+
+		if _, err := s.service.findDocumentMetaByID(ctx, tx, ns, id); !IsNotFound(err) {
+			if err != nil {
+				return err
+			}
+			return &influxdb.Error{
+				Code: influxdb.EConflict,
+				Msg:  fmt.Sprintf("document %s already exists in namespace %q", id, ns),
+			}
+		}
+
+		if err := s.service.moveDocument(ctx, tx, s.namespace, ns, id); err != nil {
+			return err
+		}
+
+		idx := &DocumentIndex{
+			service:   s.service,
+			namespace: ns,
+			tx:        tx,
+			ctx:       ctx,
+			writable:  true,
+		}
+		for _, opt := range targetOpts {
+			if err := opt(id, idx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return &influxdb.Error{
+			Code: influxdb.ErrorCode(err),
+			Msg:  fmt.Sprintf("failed to move document %s to namespace %q", id, ns),
+			Op:   OpPrefix + "MoveDocument",
+			Err:  err,
+		}
+	}
+
+	return nil
+}
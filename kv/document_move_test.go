@@ -0,0 +1,88 @@
+package kv_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestDocumentStore_MoveDocument(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatalf("failed to create label: %v", err)
+	}
+
+	src, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+	dst, err := svc.CreateDocumentStore(ctx, "other")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "content"}
+	if err := src.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(l.ID)); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	mover := src.(influxdb.DocumentMover)
+	if err := mover.MoveDocument(ctx, d.ID, "other", nil, nil); err != nil {
+		t.Fatalf("failed to move document: %v", err)
+	}
+
+	t.Run("moved documents are removed from their namespace", func(t *testing.T) {
+		ds, err := src.FindDocuments(ctx, influxdb.WhereOrg("o1"))
+		if err != nil {
+			t.Fatalf("failed to find documents: %v", err)
+		}
+		if len(ds) != 0 {
+			t.Errorf("expected no documents to remain, got %v", ds)
+		}
+	})
+
+	t.Run("moved documents keep their content, owners and labels", func(t *testing.T) {
+		ds, err := dst.FindDocuments(ctx, influxdb.WhereOrg("o1"), influxdb.WhereLabelID(l.ID), influxdb.IncludeContent, influxdb.IncludeLabels)
+		if err != nil {
+			t.Fatalf("failed to find documents: %v", err)
+		}
+		if len(ds) != 1 {
+			t.Fatalf("expected 1 moved document, got %d", len(ds))
+		}
+		if m := ds[0]; m.ID != d.ID || m.Meta.Name != "d1" || m.Content != "content" || len(m.Labels) != 1 || m.Labels[0].ID != l.ID {
+			t.Errorf("expected moved document to match d1, got %v", m)
+		}
+	})
+
+	t.Run("documents cannot be moved to disallowed namespaces", func(t *testing.T) {
+		other := dst.(influxdb.DocumentMover)
+		for _, ns := range []string{"other", kv.DocumentTrashNamespace, "missing"} {
+			if err := other.MoveDocument(ctx, d.ID, ns, nil, nil); influxdb.ErrorCode(err) != influxdb.EInvalid {
+				t.Errorf("expected moving to %q to be invalid, got %v", ns, err)
+			}
+		}
+	})
+
+	t.Run("missing documents are not found", func(t *testing.T) {
+		if err := mover.MoveDocument(ctx, d.ID, "other", nil, nil); influxdb.ErrorCode(err) != influxdb.ENotFound {
+			t.Errorf("expected moving a missing document to be not found, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package kv
+
+import (
+	"context"
+
+	"github.com/influxdata/influxdb"
+)
+
+var _ influxdb.DocumentCrossNamespaceFinder = (*Service)(nil)
+
+// FindDocumentsAcrossNamespaces returns the documents with the IDs provided from every namespace,
+// within a single transaction. Each ID is looked up in the meta bucket of each namespace rather
+// than through the document store of the namespace.
+func (s *Service) FindDocumentsAcrossNamespaces(ctx context.Context, ids []influxdb.ID) ([]*influxdb.NamespacedDocument, error) {
+	ds := []*influxdb.NamespacedDocument{}
+	err := s.kv.View(ctx, func(tx Tx) error {
+		nss, err := s.documentNamespaces(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			d, err := s.findNamespacedDocument(ctx, tx, nss, id)
+			if err != nil {
+				return err
+			}
+			if d != nil {
+				ds = append(ds, d)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &influxdb.Error{
+			Err: err,
+		}
+	}
+
+	return ds, nil
+}
+
+// findNamespacedDocument returns the document with the ID from the first of the namespaces that
+// has it, or nil if none does.
+func (s *Service) findNamespacedDocument(ctx context.Context, tx Tx, nss []string, id influxdb.ID) (*influxdb.NamespacedDocument, error) {
+	for _, ns := range nss {
+		d, err := s.findDocumentByID(ctx, tx, ns, id)
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if d.Content, err = s.findDocumentContentByID(ctx, tx, ns, id); err != nil {
+			return nil, err
+		}
+
+		ds := &DocumentStore{service: s, namespace: ns}
+		if err := ds.decorateDocumentWithLabels(ctx, tx, d); err != nil {
+			return nil, err
+		}
+
+		return &influxdb.NamespacedDocument{Namespace: ns, Document: d}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,64 @@
+package kv_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestService_FindDocumentsAcrossNamespaces(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatalf("failed to create label: %v", err)
+	}
+
+	create := func(ns, name string, opts ...influxdb.DocumentOptions) *influxdb.Document {
+		t.Helper()
+		s, err := svc.CreateDocumentStore(ctx, ns)
+		if err != nil {
+			t.Fatalf("failed to create document store: %v", err)
+		}
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: name}, Content: name + " content"}
+		if err := s.CreateDocument(ctx, d, opts...); err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+		return d
+	}
+	d1 := create("notes", "d1", influxdb.WithLabelID(l.ID))
+	d2 := create("files", "d2")
+
+	ds, err := svc.FindDocumentsAcrossNamespaces(ctx, []influxdb.ID{d2.ID, influxdb.ID(1000), d1.ID})
+	if err != nil {
+		t.Fatalf("failed to find documents: %v", err)
+	}
+
+	if len(ds) != 2 {
+		t.Fatalf("expected 2 documents, got %d", len(ds))
+	}
+	if ds[0].ID != d2.ID || ds[0].Namespace != "files" || ds[0].Content != "d2 content" {
+		t.Errorf("expected d2 from files, got %s from %s with content %v", ds[0].ID, ds[0].Namespace, ds[0].Content)
+	}
+	if ds[1].ID != d1.ID || ds[1].Namespace != "notes" || ds[1].Content != "d1 content" {
+		t.Errorf("expected d1 from notes, got %s from %s with content %v", ds[1].ID, ds[1].Namespace, ds[1].Content)
+	}
+	if len(ds[1].Labels) != 1 || ds[1].Labels[0].ID != l.ID {
+		t.Errorf("expected d1 to carry label l1, got %v", ds[1].Labels)
+	}
+	if ds[0].Labels == nil || len(ds[0].Labels) != 0 {
+		t.Errorf("expected d2 to carry no labels, got %v", ds[0].Labels)
+	}
+}
@@ -0,0 +1,164 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"path"
+
+	"github.com/influxdata/influxdb"
+)
+
+// documentOrgIndexBucket maps an organization to the documents in a namespace that it owns,
+// so that the documents of an organization are found without scanning every user resource
+// mapping.
+const documentOrgIndexBucket = "/documents/orgs"
+
+func documentOrgIndexKey(orgID, docID influxdb.ID) ([]byte, error) {
+	ok, err := orgID.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	dk, err := docID.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(ok, dk...), nil
+}
+
+func (s *Service) indexDocumentOrg(ctx context.Context, tx Tx, ns string, docID, orgID influxdb.ID) error {
+	k, err := documentOrgIndexKey(orgID, docID)
+	if err != nil {
+		return err
+	}
+
+	b, err := tx.Bucket([]byte(path.Join(ns, documentOrgIndexBucket)))
+	if err != nil {
+		return err
+	}
+
+	return b.Put(k, k[influxdb.IDLength:])
+}
+
+func (s *Service) deindexDocumentOrg(ctx context.Context, tx Tx, ns string, docID, orgID influxdb.ID) error {
+	k, err := documentOrgIndexKey(orgID, docID)
+	if err != nil {
+		return err
+	}
+
+	b, err := tx.Bucket([]byte(path.Join(ns, documentOrgIndexBucket)))
+	if err != nil {
+		return err
+	}
+
+	return b.Delete(k)
+}
+
+// findDocumentIDsByOrg returns the IDs of the documents in the namespace that the organization owns.
+func (s *Service) findDocumentIDsByOrg(ctx context.Context, tx Tx, ns string, orgID influxdb.ID) ([]influxdb.ID, error) {
+	prefix, err := orgID.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := tx.Bucket([]byte(path.Join(ns, documentOrgIndexBucket)))
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := b.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []influxdb.ID{}
+	for k, _ := cur.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = cur.Next() {
+		var id influxdb.ID
+		if err := id.Decode(k[influxdb.IDLength:]); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// documentOrgIDs returns the IDs of the organizations that own the document.
+func (s *Service) documentOrgIDs(ctx context.Context, tx Tx, docID influxdb.ID) ([]influxdb.ID, error) {
+	ms, err := s.findUserResourceMappings(ctx, tx, influxdb.UserResourceMappingFilter{
+		ResourceType: influxdb.DocumentsResourceType,
+		ResourceID:   docID,
+		UserType:     influxdb.Owner,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []influxdb.ID
+	for _, m := range ms {
+		if m.MappingType == influxdb.OrgMappingType {
+			ids = append(ids, m.UserID)
+		}
+	}
+
+	return ids, nil
+}
+
+// moveDocumentOrgs moves the org index entries of a document between namespaces.
+func (s *Service) moveDocumentOrgs(ctx context.Context, tx Tx, from, to string, docID influxdb.ID) error {
+	orgIDs, err := s.documentOrgIDs(ctx, tx, docID)
+	if err != nil {
+		return err
+	}
+
+	for _, orgID := range orgIDs {
+		if err := s.deindexDocumentOrg(ctx, tx, from, docID, orgID); err != nil {
+			return err
+		}
+		if err := s.indexDocumentOrg(ctx, tx, to, docID, orgID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexAllDocumentOrgs builds the org index of every namespace from the user resource mappings
+// of the organizations owning documents. It returns the number of index entries written.
+func (s *Service) indexAllDocumentOrgs(ctx context.Context, tx Tx) (int, error) {
+	nss, err := s.documentNamespaces(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	// collect the mappings before writing, as writing during iteration is not supported by all stores.
+	var ms []*influxdb.UserResourceMapping
+	err = s.forEachUserResourceMapping(ctx, tx, func(m *influxdb.UserResourceMapping) bool {
+		if m.ResourceType == influxdb.DocumentsResourceType && m.MappingType == influxdb.OrgMappingType && m.UserType == influxdb.Owner {
+			ms = append(ms, m)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, ns := range nss {
+		for _, m := range ms {
+			if _, err := s.findDocumentMetaByID(ctx, tx, ns, m.ResourceID); IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return 0, err
+			}
+
+			if err := s.indexDocumentOrg(ctx, tx, ns, m.ResourceID, m.UserID); err != nil {
+				return 0, err
+			}
+			n++
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,146 @@
+package kv_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestDocumentStore_FindDocuments_OrgIndex(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	o1 := &influxdb.Organization{Name: "o1"}
+	o2 := &influxdb.Organization{Name: "o2"}
+	for _, o := range []*influxdb.Organization{o1, o2} {
+		if err := svc.CreateOrganization(ctx, o); err != nil {
+			t.Fatalf("failed to create organization: %v", err)
+		}
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+	for i, o := range []*influxdb.Organization{o1, o1, o2} {
+		d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: fmt.Sprintf("d%d", i)}, Content: "content"}
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+	}
+
+	findNames := func(org string) []string {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereOrg(org))
+		if err != nil {
+			t.Fatalf("failed to find documents: %v", err)
+		}
+		names := make([]string, 0, len(ds))
+		for _, d := range ds {
+			names = append(names, d.Meta.Name)
+		}
+		return names
+	}
+
+	t.Run("documents of an organization are found by the index", func(t *testing.T) {
+		if got := findNames("o1"); len(got) != 2 || got[0] != "d0" || got[1] != "d1" {
+			t.Errorf("expected d0 and d1, got %v", got)
+		}
+		if got := findNames("o2"); len(got) != 1 || got[0] != "d2" {
+			t.Errorf("expected d2, got %v", got)
+		}
+	})
+
+	t.Run("the index is rebuilt by its backfill", func(t *testing.T) {
+		if err := svc.ClearDocumentOrgIndex(ctx, "testing"); err != nil {
+			t.Fatalf("failed to clear index: %v", err)
+		}
+		if got := findNames("o1"); len(got) != 0 {
+			t.Fatalf("expected no documents without the index, got %v", got)
+		}
+
+		if err := svc.IndexAllDocumentOrgs(ctx); err != nil {
+			t.Fatalf("failed to backfill index: %v", err)
+		}
+		if got := findNames("o1"); len(got) != 2 {
+			t.Errorf("expected d0 and d1 after the backfill, got %v", got)
+		}
+	})
+
+	t.Run("documents of other organizations are not read", func(t *testing.T) {
+		err := svc.CorruptUserResourceMappings(ctx, influxdb.UserResourceMappingFilter{
+			UserID:       o2.ID,
+			ResourceType: influxdb.DocumentsResourceType,
+		})
+		if err != nil {
+			t.Fatalf("failed to corrupt mappings: %v", err)
+		}
+		if _, _, err := svc.FindUserResourceMappings(ctx, influxdb.UserResourceMappingFilter{}); err == nil {
+			t.Fatal("expected scanning the corrupt mappings to fail")
+		}
+
+		if got := findNames("o1"); len(got) != 2 {
+			t.Errorf("expected d0 and d1, got %v", got)
+		}
+	})
+}
+
+func BenchmarkDocumentStore_FindDocuments_Org(b *testing.B) {
+	for _, orgs := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("orgs=%d", orgs), func(b *testing.B) {
+			boltStore, closeBolt, err := NewTestBoltStore()
+			if err != nil {
+				b.Fatalf("failed to create new bolt kv store: %v", err)
+			}
+			defer closeBolt()
+
+			ctx := context.Background()
+			svc := kv.NewService(boltStore)
+			if err := svc.Initialize(ctx); err != nil {
+				b.Fatalf("failed to initialize service: %v", err)
+			}
+
+			s, err := svc.CreateDocumentStore(ctx, "testing")
+			if err != nil {
+				b.Fatalf("failed to create document store: %v", err)
+			}
+
+			// the time to list the documents of one organization should not grow with the
+			// documents of the others.
+			for i := 0; i < orgs; i++ {
+				o := &influxdb.Organization{Name: fmt.Sprintf("o%d", i)}
+				if err := svc.CreateOrganization(ctx, o); err != nil {
+					b.Fatalf("failed to create organization: %v", err)
+				}
+				for j := 0; j < 20; j++ {
+					d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: fmt.Sprintf("d%d", j)}, Content: j}
+					if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+						b.Fatalf("failed to create document: %v", err)
+					}
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ds, err := s.FindDocuments(ctx, influxdb.WhereOrg("o0"))
+				if err != nil {
+					b.Fatalf("failed to find documents: %v", err)
+				}
+				if len(ds) != 20 {
+					b.Fatalf("expected 20 documents, got %d", len(ds))
+				}
+			}
+		})
+	}
+}
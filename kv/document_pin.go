@@ -0,0 +1,36 @@
+package kv
+
+import (
+	"context"
+
+	"github.com/influxdata/influxdb"
+)
+
+var _ influxdb.DocumentPinner = (*DocumentStore)(nil)
+
+// PinDocument pins or unpins the document. Options are applied before the document is
+// changed.
+func (s *DocumentStore) PinDocument(ctx context.Context, id influxdb.ID, pinned bool, opts ...influxdb.DocumentOptions) error {
+	return s.service.updateDocuments(ctx, func(tx Tx) error {
+		if err := s.applyLockOptions(ctx, tx, id, opts); err != nil {
+			return err
+		}
+
+		m, err := s.service.findDocumentMetaByID(ctx, tx, s.namespace, id)
+		if err != nil {
+			return err
+		}
+		if m.Pinned == pinned {
+			return nil
+		}
+
+		m.Pinned = pinned
+		m.UpdatedAt = s.service.time()
+		if err := s.service.putDocumentMeta(ctx, tx, s.namespace, id, m); err != nil {
+			return err
+		}
+		recordDocumentEvent(tx, s.namespace, id, influxdb.DocumentUpdated)
+
+		return nil
+	})
+}
@@ -0,0 +1,68 @@
+package kv_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestDocumentStore_PinDocument(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "content"}
+	if err := s.CreateDocument(ctx, d); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	pinned := func() bool {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID))
+		if err != nil || len(ds) != 1 {
+			t.Fatalf("failed to find document: %v", err)
+		}
+		return ds[0].Meta.Pinned
+	}
+
+	pinner := s.(influxdb.DocumentPinner)
+	if err := pinner.PinDocument(ctx, d.ID, true); err != nil {
+		t.Fatalf("failed to pin document: %v", err)
+	}
+	if !pinned() {
+		t.Error("expected document to be pinned")
+	}
+
+	// updates do not change whether the document is pinned.
+	update := &influxdb.Document{ID: d.ID, Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "updated"}
+	if err := s.UpdateDocument(ctx, update); err != nil {
+		t.Fatalf("failed to update document: %v", err)
+	}
+	if !pinned() {
+		t.Error("expected document to remain pinned after an update")
+	}
+
+	if err := pinner.PinDocument(ctx, d.ID, false); err != nil {
+		t.Fatalf("failed to unpin document: %v", err)
+	}
+	if pinned() {
+		t.Error("expected document to be unpinned")
+	}
+
+	if err := pinner.PinDocument(ctx, influxdb.ID(1), true); influxdb.ErrorCode(err) != influxdb.ENotFound {
+		t.Errorf("expected pinning a missing document to be not found, got %v", err)
+	}
+}
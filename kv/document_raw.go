@@ -0,0 +1,67 @@
+package kv
+
+import (
+	"context"
+	"path"
+
+	"github.com/influxdata/influxdb"
+)
+
+var _ influxdb.DocumentRawFinder = (*Service)(nil)
+
+// FindRawDocument returns the bytes stored for the meta and content of the document, without
+// decoding or decompressing them.
+func (s *Service) FindRawDocument(ctx context.Context, ns string, id influxdb.ID) (*influxdb.RawDocument, error) {
+	d := &influxdb.RawDocument{ID: id}
+	err := s.kv.View(ctx, func(tx Tx) error {
+		if err := s.findDocumentNamespace(ctx, tx, ns); err != nil {
+			return err
+		}
+
+		var err error
+		if d.Meta, err = s.findRawAtID(tx, path.Join(ns, documentMetaBucket), id); err != nil {
+			return err
+		}
+
+		d.Content, err = s.findRawAtID(tx, path.Join(ns, documentContentBucket), id)
+		return err
+	})
+
+	if IsNotFound(err) {
+		return nil, &influxdb.Error{
+			Code: influxdb.ENotFound,
+			Msg:  influxdb.ErrDocumentNotFound,
+		}
+	}
+
+	if err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.ErrorCode(err),
+			Op:   OpPrefix + "FindRawDocument",
+			Err:  err,
+		}
+	}
+
+	return d, nil
+}
+
+// findRawAtID returns a copy of the value stored for the ID, as values are only valid for the
+// life of the transaction.
+func (s *Service) findRawAtID(tx Tx, bucket string, id influxdb.ID) ([]byte, error) {
+	b, err := tx.Bucket([]byte(bucket))
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := id.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := b.Get(k)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(nil), v...), nil
+}
@@ -0,0 +1,85 @@
+package kv_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestService_FindRawDocument(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	svc.CompressDocumentContent("compressed")
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	for _, ns := range []string{"plain", "compressed"} {
+		t.Run(ns, func(t *testing.T) {
+			s, err := svc.CreateDocumentStore(ctx, ns)
+			if err != nil {
+				t.Fatalf("failed to create document store: %v", err)
+			}
+			d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: map[string]interface{}{"a": "b"}}
+			if err := s.CreateDocument(ctx, d); err != nil {
+				t.Fatalf("failed to create document: %v", err)
+			}
+
+			var meta, content []byte
+			err = inmemStore.View(ctx, func(tx kv.Tx) error {
+				k, err := d.ID.Encode()
+				if err != nil {
+					return err
+				}
+				get := func(bucket string) ([]byte, error) {
+					b, err := tx.Bucket([]byte(ns + bucket))
+					if err != nil {
+						return nil, err
+					}
+					return b.Get(k)
+				}
+				if meta, err = get("/documents/meta"); err != nil {
+					return err
+				}
+				content, err = get("/documents/content")
+				return err
+			})
+			if err != nil {
+				t.Fatalf("failed to read stored document: %v", err)
+			}
+
+			raw, err := svc.FindRawDocument(ctx, ns, d.ID)
+			if err != nil {
+				t.Fatalf("failed to find raw document: %v", err)
+			}
+			if raw.ID != d.ID {
+				t.Errorf("expected id %s, got %s", d.ID, raw.ID)
+			}
+			if !bytes.Equal(raw.Meta, meta) {
+				t.Errorf("expected meta %q, got %q", meta, raw.Meta)
+			}
+			if !bytes.Equal(raw.Content, content) {
+				t.Errorf("expected content %q, got %q", content, raw.Content)
+			}
+			if compressed := bytes.HasPrefix(raw.Content, []byte{0x1f, 0x8b}); compressed != (ns == "compressed") {
+				t.Errorf("expected content to be returned as stored, got %q", raw.Content)
+			}
+		})
+	}
+
+	if _, err := svc.FindRawDocument(ctx, "plain", influxdb.ID(1000)); influxdb.ErrorCode(err) != influxdb.ENotFound {
+		t.Errorf("expected missing document not to be found, got %v", err)
+	}
+	if _, err := svc.FindRawDocument(ctx, "missing", influxdb.ID(1000)); influxdb.ErrorCode(err) != influxdb.ENotFound {
+		t.Errorf("expected document of a missing namespace not to be found, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/influxdata/influxdb"
+)
+
+var _ influxdb.DocumentRestorer = (*DocumentStore)(nil)
+
+// RestoreDocument writes the document provided, including its content, keeping its ID and
+// timestamps. The options are applied once the document is written, in the same transaction.
+func (s *DocumentStore) RestoreDocument(ctx context.Context, d *influxdb.Document, opts ...influxdb.DocumentOptions) error {
+	if !d.ID.Valid() {
+		return &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "document to restore must have a valid id",
+		}
+	}
+
+	return s.service.updateDocuments(ctx, func(tx Tx) error {
+		if _, err := s.service.findDocumentMetaByID(ctx, tx, s.namespace, d.ID); !IsNotFound(err) {
+			if err != nil {
+				return err
+			}
+			return &influxdb.Error{
+				Code: influxdb.EConflict,
+				Msg:  fmt.Sprintf("document %s already exists", d.ID),
+			}
+		}
+
+		if err := s.service.putDocument(ctx, tx, s.namespace, d); err != nil {
+			return err
+		}
+		recordDocumentEvent(tx, s.namespace, d.ID, influxdb.DocumentCreated)
+
+		idx := &DocumentIndex{
+			service:   s.service,
+			namespace: s.namespace,
+			tx:        tx,
+			ctx:       ctx,
+			writable:  true,
+		}
+		for _, opt := range opts {
+			if err := opt(d.ID, idx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,65 @@
+package kv_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestDocumentStore_RestoreDocument(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	created := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	d := &influxdb.Document{
+		ID: 0x0a0b0c0d0e0f0102,
+		Meta: influxdb.DocumentMeta{
+			Name:      "d1",
+			CreatedAt: created,
+			UpdatedAt: created.Add(time.Hour),
+		},
+		Content: "content1",
+	}
+	if err := s.(influxdb.DocumentRestorer).RestoreDocument(ctx, d, influxdb.WithOrgID(o.ID)); err != nil {
+		t.Fatalf("failed to restore document: %v", err)
+	}
+
+	ds, err := s.FindDocuments(ctx, influxdb.WhereOrg("o1"), influxdb.IncludeContent)
+	if err != nil {
+		t.Fatalf("failed to find documents: %v", err)
+	}
+	if len(ds) != 1 {
+		t.Fatalf("expected 1 document, got %d", len(ds))
+	}
+	if ds[0].ID != d.ID || !reflect.DeepEqual(ds[0].Meta, d.Meta) || ds[0].Content != "content1" {
+		t.Errorf("expected the restored document %v, got %v", d, ds[0])
+	}
+
+	err = s.(influxdb.DocumentRestorer).RestoreDocument(ctx, d)
+	if influxdb.ErrorCode(err) != influxdb.EConflict {
+		t.Errorf("expected restoring an existing document to be a conflict, got %v", err)
+	}
+}
@@ -1,8 +1,18 @@
 package kv_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+	"github.com/influxdata/influxdb/mock"
 	influxdbtesting "github.com/influxdata/influxdb/testing"
 )
 
@@ -27,3 +37,628 @@ func TestInmemDocumentStore(t *testing.T) {
 	t.Run("inmem", influxdbtesting.NewDocumentIntegrationTest(inmemStore))
 
 }
+
+func BenchmarkDocumentStore_FindDocuments_Filters(b *testing.B) {
+	boltStore, closeBolt, err := NewTestBoltStore()
+	if err != nil {
+		b.Fatalf("failed to create new bolt kv store: %v", err)
+	}
+	defer closeBolt()
+
+	ctx := context.Background()
+	svc := kv.NewService(boltStore)
+	if err := svc.Initialize(ctx); err != nil {
+		b.Fatalf("failed to initialize service: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		b.Fatalf("failed to create document store: %v", err)
+	}
+
+	labels := []string{"l0", "l1", "l2", "l3"}
+	for _, l := range labels {
+		if err := svc.CreateLabel(ctx, &influxdb.Label{Name: l}); err != nil {
+			b.Fatalf("failed to create label: %v", err)
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		d := &influxdb.Document{
+			Meta: influxdb.DocumentMeta{
+				Name: fmt.Sprintf("doc%d", i%10),
+			},
+			Content: map[string]interface{}{
+				"i": i,
+			},
+		}
+		if err := s.CreateDocument(ctx, d, influxdb.WithLabel(labels[i%len(labels)])); err != nil {
+			b.Fatalf("failed to create document: %v", err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ds, err := s.FindDocuments(ctx,
+			influxdb.WhereName("doc2"),
+			influxdb.WhereLabel("l2"),
+			influxdb.WhereLabel("l2"),
+			influxdb.IncludeContent,
+		)
+		if err != nil {
+			b.Fatalf("failed to find documents: %v", err)
+		}
+		if len(ds) != 50 {
+			b.Fatalf("expected 50 documents, got %d", len(ds))
+		}
+	}
+}
+
+func TestDocumentStore_TrackDocumentAccess(t *testing.T) {
+	boltStore, closeBolt, err := NewTestBoltStore()
+	if err != nil {
+		t.Fatalf("failed to create new bolt kv store: %v", err)
+	}
+	defer closeBolt()
+
+	ctx := context.Background()
+	now := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	svc := kv.NewService(boltStore)
+	svc.WithTime(func() time.Time { return now })
+	svc.TrackDocumentAccess(time.Millisecond)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	d := &influxdb.Document{
+		Meta:    influxdb.DocumentMeta{Name: "d1"},
+		Content: "content",
+	}
+	if err := s.CreateDocument(ctx, d); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	lastAccessedAt := func() *time.Time {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID))
+		if err != nil {
+			t.Fatalf("failed to find document: %v", err)
+		}
+		return ds[0].Meta.LastAccessedAt
+	}
+
+	if at := lastAccessedAt(); at != nil {
+		t.Fatalf("expected listing a document to not record an access, got %v", at)
+	}
+
+	if _, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeContent); err != nil {
+		t.Fatalf("failed to find document: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		at := lastAccessedAt()
+		if at != nil {
+			if !at.Equal(now) {
+				t.Fatalf("expected last accessed at to be %v, got %v", now, at)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected reading a document to record an access")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	update := &influxdb.Document{
+		ID:      d.ID,
+		Meta:    influxdb.DocumentMeta{Name: "d2"},
+		Content: "content2",
+	}
+	if err := s.UpdateDocument(ctx, update); err != nil {
+		t.Fatalf("failed to update document: %v", err)
+	}
+	if at := lastAccessedAt(); at == nil || !at.Equal(now) {
+		t.Errorf("expected update to preserve last accessed at, got %v", at)
+	}
+}
+
+func TestDocumentStore_TrackDocumentAccess_Close(t *testing.T) {
+	boltStore, closeBolt, err := NewTestBoltStore()
+	if err != nil {
+		t.Fatalf("failed to create new bolt kv store: %v", err)
+	}
+	defer closeBolt()
+
+	ctx := context.Background()
+	now := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	svc := kv.NewService(boltStore)
+	svc.WithTime(func() time.Time { return now })
+	tracker := svc.TrackDocumentAccess(time.Hour)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	d := &influxdb.Document{
+		Meta:    influxdb.DocumentMeta{Name: "d1"},
+		Content: "content",
+	}
+	if err := s.CreateDocument(ctx, d); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	if _, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeContent); err != nil {
+		t.Fatalf("failed to find document: %v", err)
+	}
+
+	// closing writes the buffered access without waiting for the flush interval.
+	if err := tracker.Close(); err != nil {
+		t.Fatalf("failed to close tracker: %v", err)
+	}
+
+	ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID))
+	if err != nil {
+		t.Fatalf("failed to find document: %v", err)
+	}
+	if at := ds[0].Meta.LastAccessedAt; at == nil || !at.Equal(now) {
+		t.Fatalf("expected close to flush last accessed at %v, got %v", now, at)
+	}
+
+	// accesses after closing are no longer recorded.
+	now = now.Add(time.Minute)
+	if _, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeContent); err != nil {
+		t.Fatalf("failed to find document: %v", err)
+	}
+	if err := tracker.Close(); err != nil {
+		t.Fatalf("failed to close tracker: %v", err)
+	}
+
+	ds, err = s.FindDocuments(ctx, influxdb.WhereID(d.ID))
+	if err != nil {
+		t.Fatalf("failed to find document: %v", err)
+	}
+	if at := ds[0].Meta.LastAccessedAt; at == nil || !at.Equal(now.Add(-time.Minute)) {
+		t.Fatalf("expected access after close to not be recorded, got %v", at)
+	}
+}
+
+func TestService_ReindexDocumentLabels_WithBolt(t *testing.T) {
+	testReindexDocumentLabels(NewTestBoltStore, t)
+}
+
+func TestService_ReindexDocumentLabels_WithInMem(t *testing.T) {
+	testReindexDocumentLabels(NewTestInmemStore, t)
+}
+
+func testReindexDocumentLabels(newStore StoreFn, t *testing.T) {
+	store, closeStore, err := newStore()
+	if err != nil {
+		t.Fatalf("failed to create new kv store: %v", err)
+	}
+	defer closeStore()
+
+	ctx := context.Background()
+	svc := kv.NewService(store)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+	l1 := &influxdb.Label{Name: "l1"}
+	l2 := &influxdb.Label{Name: "l2"}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatalf("failed to create label: %v", err)
+		}
+	}
+
+	// more documents than fit in a single reindex batch.
+	var labeled []influxdb.ID
+	for i := 0; i < 250; i++ {
+		d := &influxdb.Document{
+			Meta:    influxdb.DocumentMeta{Name: fmt.Sprintf("d%d", i)},
+			Content: "content",
+		}
+		opts := []influxdb.DocumentOptions{influxdb.WithOrgID(o.ID), influxdb.WithLabelID(l1.ID)}
+		if i%10 == 0 {
+			opts = append(opts, influxdb.WithLabelID(l2.ID))
+		}
+		if err := s.CreateDocument(ctx, d, opts...); err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+		if i%10 == 0 {
+			labeled = append(labeled, d.ID)
+		}
+	}
+
+	findLabeled := func() []influxdb.ID {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereOrg(o.Name), influxdb.WhereLabelID(l2.ID))
+		if err != nil {
+			t.Fatalf("failed to find documents: %v", err)
+		}
+		ids := []influxdb.ID{}
+		for _, d := range ds {
+			ids = append(ids, d.ID)
+		}
+		return ids
+	}
+
+	if ids := findLabeled(); len(ids) != len(labeled) {
+		t.Fatalf("expected %d labeled documents before clearing the index, got %d", len(labeled), len(ids))
+	}
+
+	err = store.Update(ctx, func(tx kv.Tx) error {
+		b, err := tx.Bucket([]byte("testing/documents/labels"))
+		if err != nil {
+			return err
+		}
+		cur, err := b.Cursor()
+		if err != nil {
+			return err
+		}
+		var keys [][]byte
+		for k, _ := cur.First(); len(k) != 0; k, _ = cur.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to clear label index: %v", err)
+	}
+
+	if ids := findLabeled(); len(ids) != 0 {
+		t.Fatalf("expected no labeled documents after clearing the index, got %d", len(ids))
+	}
+
+	n, err := svc.ReindexDocumentLabels(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to reindex: %v", err)
+	}
+	if want := 250 + len(labeled); n != want {
+		t.Errorf("expected %d index entries to be rebuilt, got %d", want, n)
+	}
+
+	ids := findLabeled()
+	if len(ids) != len(labeled) {
+		t.Fatalf("expected %d labeled documents after reindexing, got %d", len(labeled), len(ids))
+	}
+	for i := range ids {
+		if ids[i] != labeled[i] {
+			t.Errorf("expected document %s at %d, got %s", labeled[i], i, ids[i])
+		}
+	}
+
+	if _, err := svc.ReindexDocumentLabels(ctx, "missing"); influxdb.ErrorCode(err) != influxdb.ENotFound {
+		t.Errorf("expected reindexing a missing namespace to be not found, got %v", err)
+	}
+}
+
+func TestDocumentStore_FindDocumentsByLabel(t *testing.T) {
+	boltStore, closeBolt, err := NewTestBoltStore()
+	if err != nil {
+		t.Fatalf("failed to create new bolt kv store: %v", err)
+	}
+	defer closeBolt()
+
+	ctx := context.Background()
+	svc := kv.NewService(boltStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	l1 := &influxdb.Label{Name: "l1"}
+	l2 := &influxdb.Label{Name: "l2"}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatalf("failed to create label: %v", err)
+		}
+	}
+
+	create := func(name string, opts ...influxdb.DocumentOptions) *influxdb.Document {
+		d := &influxdb.Document{
+			Meta:    influxdb.DocumentMeta{Name: name},
+			Content: name,
+		}
+		if err := s.CreateDocument(ctx, d, opts...); err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+		return d
+	}
+	d1 := create("d1", influxdb.WithLabelID(l1.ID))
+	d2 := create("d2", influxdb.WithLabelID(l1.ID), influxdb.WithLabelID(l2.ID))
+	create("d3", influxdb.WithLabelID(l2.ID))
+	// mapped outside of the document store, so it is missing from the label index.
+	d4 := create("d4")
+	m := &influxdb.LabelMapping{LabelID: l1.ID, ResourceType: influxdb.DocumentsResourceType, ResourceID: d4.ID}
+	if err := svc.CreateLabelMapping(ctx, m); err != nil {
+		t.Fatalf("failed to create label mapping: %v", err)
+	}
+
+	findByLabel := func() []string {
+		ds, err := s.FindDocumentsByLabel(ctx, l1.ID)
+		if err != nil {
+			t.Fatalf("failed to find documents by label: %v", err)
+		}
+		names := []string{}
+		for _, d := range ds {
+			if d.Content != d.Meta.Name {
+				t.Errorf("expected content of %s to be included, got %v", d.Meta.Name, d.Content)
+			}
+			names = append(names, d.Meta.Name)
+		}
+		return names
+	}
+
+	if got, want := findByLabel(), []string{d1.Meta.Name, d2.Meta.Name}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected documents %v from the label index, got %v", want, got)
+	}
+
+	if _, err := svc.ReindexDocumentLabels(ctx, "testing"); err != nil {
+		t.Fatalf("failed to reindex document labels: %v", err)
+	}
+
+	if got, want := findByLabel(), []string{d1.Meta.Name, d2.Meta.Name, d4.Meta.Name}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected documents %v after reindexing, got %v", want, got)
+	}
+}
+
+func TestDocumentStore_AppendContent(t *testing.T) {
+	boltStore, closeBolt, err := NewTestBoltStore()
+	if err != nil {
+		t.Fatalf("failed to create new bolt kv store: %v", err)
+	}
+	defer closeBolt()
+
+	ctx := context.Background()
+	svc := kv.NewService(boltStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	d := &influxdb.Document{
+		Meta:    influxdb.DocumentMeta{Name: "log"},
+		Content: []interface{}{"start"},
+	}
+	if err := s.CreateDocument(ctx, d); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.AppendContent(ctx, d.ID, []interface{}{fmt.Sprintf("entry-%02d-a", i), fmt.Sprintf("entry-%02d-b", i)})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("failed to append content: %v", err)
+		}
+	}
+
+	ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeContent)
+	if err != nil {
+		t.Fatalf("failed to find document: %v", err)
+	}
+	entries, ok := ds[0].Content.([]interface{})
+	if !ok {
+		t.Fatalf("expected array content, got %T", ds[0].Content)
+	}
+	if len(entries) != 2*n+1 {
+		t.Fatalf("expected %d entries, got %d", 2*n+1, len(entries))
+	}
+	if entries[0] != "start" {
+		t.Errorf("expected existing content to be kept first, got %v", entries[0])
+	}
+
+	// each append must be kept whole and in order, whatever order the appends ran in.
+	got := make([]string, 0, n)
+	for i := 1; i < len(entries); i += 2 {
+		a, b := entries[i].(string), entries[i+1].(string)
+		if a[:len(a)-1] != b[:len(b)-1] || a[len(a)-1] != 'a' || b[len(b)-1] != 'b' {
+			t.Fatalf("appended entries %q and %q were interleaved", a, b)
+		}
+		got = append(got, a)
+	}
+	sort.Strings(got)
+	for i, e := range got {
+		if want := fmt.Sprintf("entry-%02d-a", i); e != want {
+			t.Errorf("expected entry %q, got %q", want, e)
+		}
+	}
+
+	t.Run("non array content cannot be appended to", func(t *testing.T) {
+		d := &influxdb.Document{
+			Meta:    influxdb.DocumentMeta{Name: "object"},
+			Content: map[string]interface{}{"a": "b"},
+		}
+		if err := s.CreateDocument(ctx, d); err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+
+		_, err := s.AppendContent(ctx, d.ID, []interface{}{"c"})
+		if code := influxdb.ErrorCode(err); code != influxdb.EInvalid {
+			t.Errorf("expected error code %q, got %q: %v", influxdb.EInvalid, code, err)
+		}
+	})
+
+	t.Run("missing document is not found", func(t *testing.T) {
+		_, err := s.AppendContent(ctx, influxdb.ID(1), []interface{}{"c"})
+		if code := influxdb.ErrorCode(err); code != influxdb.ENotFound {
+			t.Errorf("expected error code %q, got %q: %v", influxdb.ENotFound, code, err)
+		}
+	})
+}
+
+func TestDocumentStore_FindDocuments_EmptyLabels(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "content1"}
+	if err := s.CreateDocument(ctx, d); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeLabels)
+	if err != nil {
+		t.Fatalf("failed to find document: %v", err)
+	}
+	if ds[0].Labels == nil || len(ds[0].Labels) != 0 {
+		t.Errorf("expected an empty list of labels, got %#v", ds[0].Labels)
+	}
+}
+
+func TestService_Ping(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+	if err := svc.Ping(ctx); err != nil {
+		t.Errorf("expected ping to succeed, got %v", err)
+	}
+
+	failing := &mock.Store{
+		ViewFn: func(func(kv.Tx) error) error {
+			return errors.New("connection refused")
+		},
+	}
+	err = kv.NewService(failing).Ping(ctx)
+	if code := influxdb.ErrorCode(err); code != influxdb.EUnavailable {
+		t.Errorf("expected ping of a failing store to be %s, got %v", influxdb.EUnavailable, err)
+	}
+}
+
+func TestDocumentStore_UpdateDocument_Labels(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	var ls []*influxdb.Label
+	for _, name := range []string{"kept", "removed", "added"} {
+		l := &influxdb.Label{Name: name}
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatalf("failed to create label: %v", err)
+		}
+		ls = append(ls, l)
+	}
+	kept, removed, added := ls[0], ls[1], ls[2]
+
+	d := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "content"}
+	if err := s.CreateDocument(ctx, d, influxdb.WithLabelID(kept.ID), influxdb.WithLabelID(removed.ID)); err != nil {
+		t.Fatalf("failed to create document: %v", err)
+	}
+
+	var events []influxdb.DocumentEvent
+	defer svc.SubscribeDocumentEvents(func(e influxdb.DocumentEvent) {
+		if e.Operation == influxdb.DocumentLabelAttached || e.Operation == influxdb.DocumentLabelDetached {
+			events = append(events, e)
+		}
+	})()
+
+	if err := s.UpdateDocument(ctx, d, influxdb.WithExactLabelIDs(kept.ID, added.ID)); err != nil {
+		t.Fatalf("failed to update document: %v", err)
+	}
+
+	want := []influxdb.DocumentEvent{
+		{Namespace: "testing", ID: d.ID, Operation: influxdb.DocumentLabelDetached, LabelID: removed.ID},
+		{Namespace: "testing", ID: d.ID, Operation: influxdb.DocumentLabelAttached, LabelID: added.ID},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("expected only the changed label mappings to be updated, got %v", events)
+	}
+
+	ds, err := s.FindDocuments(ctx, influxdb.WhereID(d.ID), influxdb.IncludeLabels)
+	if err != nil {
+		t.Fatalf("failed to find document: %v", err)
+	}
+	var names []string
+	for _, l := range ds[0].Labels {
+		names = append(names, l.Name)
+	}
+	sort.Strings(names)
+	if exp := []string{"added", "kept"}; !reflect.DeepEqual(names, exp) {
+		t.Errorf("expected labels %v, got %v", exp, names)
+	}
+
+	// the labels of the document are ignored without the option, so that documents read
+	// without their labels are written back without losing them.
+	events = nil
+	d.Labels = []*influxdb.Label{}
+	if err := s.UpdateDocument(ctx, d); err != nil {
+		t.Fatalf("failed to update document: %v", err)
+	}
+	if len(events) != 0 || len(d.Labels) != 2 {
+		t.Errorf("expected labels to be untouched, got events %v and labels %v", events, d.Labels)
+	}
+}
@@ -0,0 +1,189 @@
+package kv
+
+import (
+	"context"
+
+	"github.com/influxdata/influxdb"
+)
+
+// DocumentTrashNamespace is the namespace that trashed documents are moved to.
+const DocumentTrashNamespace = "trash"
+
+var _ influxdb.DocumentTrasher = (*DocumentStore)(nil)
+
+// TrashDocuments moves all documents returned by the options into the trash namespace.
+// Trashed documents keep their ID, owners and label mappings, and can be found through the
+// trash document store until they are purged.
+func (s *DocumentStore) TrashDocuments(ctx context.Context, opts ...influxdb.DocumentFindOptions) error {
+	if s.namespace == DocumentTrashNamespace {
+		return &influxdb.Error{
+			Code: influxdb.EInvalid,
+			Msg:  "documents in the trash cannot be trashed; purge them instead",
+		}
+	}
+
+	return s.service.updateDocuments(ctx, func(tx Tx) error {
+		ids, err := s.findDocumentIDs(ctx, tx, opts...)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.service.createDocumentStore(ctx, tx, DocumentTrashNamespace); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if err := s.service.moveDocument(ctx, tx, s.namespace, DocumentTrashNamespace, id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// findDocumentIDs returns the IDs of the documents selected by the options provided and
+// matching their filters, within a writable transaction.
+func (s *DocumentStore) findDocumentIDs(ctx context.Context, tx Tx, opts ...influxdb.DocumentFindOptions) ([]influxdb.ID, error) {
+	idx := &DocumentIndex{
+		service:   s.service,
+		namespace: s.namespace,
+		tx:        tx,
+		ctx:       ctx,
+		writable:  true,
+	}
+	dd := &DocumentDecorator{writable: true}
+
+	ids := []influxdb.ID{}
+	for _, opt := range opts {
+		dids, err := opt(idx, dd)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, dids...)
+	}
+
+	if len(dd.filters) == 0 {
+		return ids, nil
+	}
+
+	matched := ids[:0]
+	for _, id := range ids {
+		d, err := s.service.findDocumentByID(ctx, tx, s.namespace, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.decorateDocumentWithLabels(ctx, tx, d); err != nil {
+			return nil, err
+		}
+		if dd.match(d) {
+			matched = append(matched, id)
+		}
+	}
+
+	return matched, nil
+}
+
+// moveDocument moves the meta, content, org and label index entries of a document between namespaces.
+func (s *Service) moveDocument(ctx context.Context, tx Tx, from, to string, id influxdb.ID) error {
+	d, err := s.findDocumentByID(ctx, tx, from, id)
+	if err != nil {
+		return err
+	}
+
+	if d.Content, err = s.findDocumentContentByID(ctx, tx, from, id); err != nil {
+		return err
+	}
+
+	if err := s.moveDocumentLock(ctx, tx, from, to, id); err != nil {
+		return err
+	}
+
+	if err := s.deleteDocument(ctx, tx, from, id); err != nil {
+		return err
+	}
+
+	if err := s.putDocument(ctx, tx, to, d); err != nil {
+		return err
+	}
+	recordDocumentEvent(tx, to, id, influxdb.DocumentCreated)
+
+	if err := s.moveDocumentOrgs(ctx, tx, from, to, id); err != nil {
+		return err
+	}
+
+	labelIDs, err := s.documentLabelIDs(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, labelID := range labelIDs {
+		if err := s.indexDocumentLabel(ctx, tx, to, id, labelID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteDocumentLabelMappings removes every label mapping of the document in the namespace
+// provided.
+func (s *Service) deleteDocumentLabelMappings(ctx context.Context, tx Tx, ns string, id influxdb.ID) error {
+	labelIDs, err := s.documentLabelIDs(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, labelID := range labelIDs {
+		m := &influxdb.LabelMapping{
+			LabelID:      labelID,
+			ResourceType: influxdb.DocumentsResourceType,
+			ResourceID:   id,
+		}
+		if err := s.deleteLabelMapping(ctx, tx, m); err != nil {
+			return err
+		}
+		recordDocumentLabelEvent(tx, ns, id, labelID, influxdb.DocumentLabelDetached)
+	}
+
+	return nil
+}
+
+// PurgeDocumentTrash permanently deletes all documents in the trash returned by the options,
+// along with their owners and label mappings.
+func (s *Service) PurgeDocumentTrash(ctx context.Context, opts ...influxdb.DocumentFindOptions) error {
+	return s.updateDocuments(ctx, func(tx Tx) error {
+		trash := &DocumentStore{
+			service:   s,
+			namespace: DocumentTrashNamespace,
+		}
+		ids, err := trash.findDocumentIDs(ctx, tx, opts...)
+		if err != nil {
+			return err
+		}
+
+		idx := &DocumentIndex{
+			service:   s,
+			namespace: DocumentTrashNamespace,
+			tx:        tx,
+			ctx:       ctx,
+			writable:  true,
+		}
+		for _, id := range ids {
+			if err := WithoutOwners(id, idx); err != nil {
+				return err
+			}
+
+			if err := s.deleteDocument(ctx, tx, DocumentTrashNamespace, id); err != nil {
+				return err
+			}
+
+			if err := s.deleteDocumentLabelMappings(ctx, tx, DocumentTrashNamespace, id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,116 @@
+package kv_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestDocumentStore_TrashDocuments(t *testing.T) {
+	boltStore, closeBolt, err := NewTestBoltStore()
+	if err != nil {
+		t.Fatalf("failed to create new bolt kv store: %v", err)
+	}
+	defer closeBolt()
+
+	ctx := context.Background()
+	svc := kv.NewService(boltStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	o := &influxdb.Organization{Name: "o1"}
+	if err := svc.CreateOrganization(ctx, o); err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+	l := &influxdb.Label{Name: "l1"}
+	if err := svc.CreateLabel(ctx, l); err != nil {
+		t.Fatalf("failed to create label: %v", err)
+	}
+
+	s, err := svc.CreateDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+	d1 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d1"}, Content: "content1"}
+	d2 := &influxdb.Document{Meta: influxdb.DocumentMeta{Name: "d2"}, Content: "content2"}
+	for _, d := range []*influxdb.Document{d1, d2} {
+		if err := s.CreateDocument(ctx, d, influxdb.WithOrgID(o.ID), influxdb.WithLabelID(l.ID)); err != nil {
+			t.Fatalf("failed to create document: %v", err)
+		}
+	}
+
+	if err := s.(influxdb.DocumentTrasher).TrashDocuments(ctx, influxdb.WhereID(d1.ID)); err != nil {
+		t.Fatalf("failed to trash document: %v", err)
+	}
+
+	t.Run("trashed documents are removed from their namespace", func(t *testing.T) {
+		ds, err := s.FindDocuments(ctx, influxdb.WhereOrg("o1"), influxdb.WhereLabelID(l.ID))
+		if err != nil {
+			t.Fatalf("failed to find documents: %v", err)
+		}
+		if len(ds) != 1 || ds[0].ID != d2.ID {
+			t.Errorf("expected only d2 to remain, got %v", ds)
+		}
+	})
+
+	trash, err := svc.FindDocumentStore(ctx, kv.DocumentTrashNamespace)
+	if err != nil {
+		t.Fatalf("failed to find trash: %v", err)
+	}
+
+	t.Run("trashed documents keep their content, owners and labels", func(t *testing.T) {
+		ds, err := trash.FindDocuments(ctx, influxdb.WhereOrg("o1"), influxdb.WhereLabelID(l.ID), influxdb.IncludeContent, influxdb.IncludeLabels)
+		if err != nil {
+			t.Fatalf("failed to list trash: %v", err)
+		}
+		if len(ds) != 1 {
+			t.Fatalf("expected 1 document in the trash, got %d", len(ds))
+		}
+		if d := ds[0]; d.ID != d1.ID || d.Meta.Name != "d1" || d.Content != "content1" || len(d.Labels) != 1 || d.Labels[0].ID != l.ID {
+			t.Errorf("expected trashed document to match d1, got %v", d)
+		}
+	})
+
+	t.Run("trashed documents cannot be trashed again", func(t *testing.T) {
+		err := trash.(influxdb.DocumentTrasher).TrashDocuments(ctx, influxdb.WhereID(d1.ID))
+		if influxdb.ErrorCode(err) != influxdb.EInvalid {
+			t.Errorf("expected trashing from the trash to be invalid, got %v", err)
+		}
+	})
+
+	t.Run("purge permanently deletes the document and its mappings", func(t *testing.T) {
+		if err := svc.PurgeDocumentTrash(ctx, influxdb.WhereID(d1.ID)); err != nil {
+			t.Fatalf("failed to purge trash: %v", err)
+		}
+
+		ds, err := trash.FindDocuments(ctx)
+		if err != nil {
+			t.Fatalf("failed to list trash: %v", err)
+		}
+		if len(ds) != 0 {
+			t.Errorf("expected the trash to be empty, got %v", ds)
+		}
+
+		ls, err := svc.FindResourceLabels(ctx, influxdb.LabelMappingFilter{
+			ResourceID:   d1.ID,
+			ResourceType: influxdb.DocumentsResourceType,
+		})
+		if err != nil {
+			t.Fatalf("failed to find labels: %v", err)
+		}
+		if len(ls) != 0 {
+			t.Errorf("expected purged document to have no label mappings, got %v", ls)
+		}
+
+		ms, _, err := svc.FindUserResourceMappings(ctx, influxdb.UserResourceMappingFilter{ResourceID: d1.ID})
+		if err != nil {
+			t.Fatalf("failed to find owners: %v", err)
+		}
+		if len(ms) != 0 {
+			t.Errorf("expected purged document to have no owners, got %v", ms)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+
+	"github.com/influxdata/influxdb"
+)
+
+// UpdateJSON exposes updateJSON to tests, running it in its own transaction.
+func (s *Service) UpdateJSON(ctx context.Context, bucket, key []byte, fn func(raw []byte) ([]byte, error)) error {
+	return s.kv.Update(ctx, func(tx Tx) error {
+		return s.updateJSON(tx, bucket, key, fn)
+	})
+}
+
+// InsertUnique exposes uniqueIndex.insert to tests, running it in its own transaction.
+func (s *Service) InsertUnique(ctx context.Context, index, key, value []byte) error {
+	return s.kv.Update(ctx, func(tx Tx) error {
+		return uniqueIndex(index).insert(tx, key, value)
+	})
+}
+
+// LookupUnique exposes uniqueIndex.lookup to tests, running it in its own transaction.
+func (s *Service) LookupUnique(ctx context.Context, index, key []byte) ([]byte, error) {
+	var v []byte
+	err := s.kv.View(ctx, func(tx Tx) error {
+		var err error
+		v, err = uniqueIndex(index).lookup(tx, key)
+		return err
+	})
+	return v, err
+}
+
+// RemoveUnique exposes uniqueIndex.remove to tests, running it in its own transaction.
+func (s *Service) RemoveUnique(ctx context.Context, index, key []byte) error {
+	return s.kv.Update(ctx, func(tx Tx) error {
+		return uniqueIndex(index).remove(tx, key)
+	})
+}
+
+// CorruptUserResourceMappings overwrites the user resource mappings matching the filter with
+// invalid JSON, so that any scan of the mappings fails.
+func (s *Service) CorruptUserResourceMappings(ctx context.Context, filter influxdb.UserResourceMappingFilter) error {
+	return s.kv.Update(ctx, func(tx Tx) error {
+		ms, err := s.findUserResourceMappings(ctx, tx, filter)
+		if err != nil {
+			return err
+		}
+
+		b, err := tx.Bucket(urmBucket)
+		if err != nil {
+			return err
+		}
+		for _, m := range ms {
+			k, err := userResourceKey(m)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(k, []byte("{")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ClearDocumentOrgIndex removes every entry of the org index of the namespace, as in stores
+// written before the index existed.
+func (s *Service) ClearDocumentOrgIndex(ctx context.Context, ns string) error {
+	return s.kv.Update(ctx, func(tx Tx) error {
+		b, err := tx.Bucket([]byte(path.Join(ns, documentOrgIndexBucket)))
+		if err != nil {
+			return err
+		}
+
+		cur, err := b.Cursor()
+		if err != nil {
+			return err
+		}
+
+		var keys [][]byte
+		for k, _ := cur.First(); len(k) != 0; k, _ = cur.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// IndexAllDocumentOrgs exposes indexAllDocumentOrgs to tests, running it in its own transaction.
+func (s *Service) IndexAllDocumentOrgs(ctx context.Context) error {
+	return s.kv.Update(ctx, func(tx Tx) error {
+		_, err := s.indexAllDocumentOrgs(ctx, tx)
+		return err
+	})
+}
+
+// PutLabelMappingAt stores the label mapping under the key provided rather than the key derived
+// from it, as the bugs that stored duplicate mappings did.
+func (s *Service) PutLabelMappingAt(ctx context.Context, key []byte, m *influxdb.LabelMapping) error {
+	return s.kv.Update(ctx, func(tx Tx) error {
+		v, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+
+		b, err := tx.Bucket(labelMappingBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, v)
+	})
+}
+
+// LabelMappingKey exposes labelMappingKey to tests.
+func LabelMappingKey(m *influxdb.LabelMapping) ([]byte, error) {
+	return labelMappingKey(m)
+}
+
+// RemoveDuplicateLabelMappings exposes removeDuplicateLabelMappings to tests, running it in its
+// own transaction.
+func (s *Service) RemoveDuplicateLabelMappings(ctx context.Context) (int, error) {
+	var n int
+	err := s.kv.Update(ctx, func(tx Tx) error {
+		var err error
+		n, err = s.removeDuplicateLabelMappings(ctx, tx)
+		return err
+	})
+	return n, err
+}
+
+// BackfillDocumentOrgIDs exposes backfillDocumentOrgIDs to tests, running it in its own
+// transaction.
+func (s *Service) BackfillDocumentOrgIDs(ctx context.Context) error {
+	return s.kv.Update(ctx, func(tx Tx) error {
+		_, err := s.backfillDocumentOrgIDs(ctx, tx)
+		return err
+	})
+}
@@ -0,0 +1,55 @@
+package kv
+
+import (
+	"fmt"
+
+	"github.com/influxdata/influxdb"
+)
+
+// updateJSON performs a read-modify-write of the value stored at key in the bucket provided.
+// fn is called with the currently stored value and returns the value to store. If fn returns
+// a nil value, nothing is written. ErrKeyNotFound is returned unwrapped if the key does not
+// exist so that callers may check for it with IsNotFound.
+func (s *Service) updateJSON(tx Tx, bucket, key []byte, fn func(raw []byte) ([]byte, error)) error {
+	b, err := tx.Bucket(bucket)
+	if err != nil {
+		return &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  fmt.Sprintf("unable to open bucket %q", bucket),
+			Op:   OpPrefix + "updateJSON",
+			Err:  err,
+		}
+	}
+
+	v, err := b.Get(key)
+	if IsNotFound(err) {
+		return err
+	}
+	if err != nil {
+		return &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  fmt.Sprintf("unable to read key in bucket %q", bucket),
+			Op:   OpPrefix + "updateJSON",
+			Err:  err,
+		}
+	}
+
+	nv, err := fn(v)
+	if err != nil {
+		return err
+	}
+	if nv == nil {
+		return nil
+	}
+
+	if err := b.Put(key, nv); err != nil {
+		return &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  fmt.Sprintf("unable to write key in bucket %q", bucket),
+			Op:   OpPrefix + "updateJSON",
+			Err:  err,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package kv_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestService_UpdateJSON(t *testing.T) {
+	store, closeStore, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeStore()
+
+	ctx := context.Background()
+	bucket := []byte("testingv1")
+	s := kv.NewService(store)
+
+	err = store.Update(ctx, func(tx kv.Tx) error {
+		b, err := tx.Bucket(bucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("k1"), []byte(`{"v":1}`))
+	})
+	if err != nil {
+		t.Fatalf("failed to seed store: %v", err)
+	}
+
+	get := func(t *testing.T) string {
+		t.Helper()
+
+		var v []byte
+		err := store.View(ctx, func(tx kv.Tx) error {
+			b, err := tx.Bucket(bucket)
+			if err != nil {
+				return err
+			}
+			v, err = b.Get([]byte("k1"))
+			return err
+		})
+		if err != nil {
+			t.Fatalf("failed to get key: %v", err)
+		}
+		return string(v)
+	}
+
+	t.Run("missing key returns not found", func(t *testing.T) {
+		var called bool
+		err := s.UpdateJSON(ctx, bucket, []byte("missing"), func(v []byte) ([]byte, error) {
+			called = true
+			return v, nil
+		})
+		if !kv.IsNotFound(err) {
+			t.Errorf("expected not found error, got %v", err)
+		}
+		if called {
+			t.Errorf("expected modify function to not be called")
+		}
+	})
+
+	t.Run("modified value is stored", func(t *testing.T) {
+		err := s.UpdateJSON(ctx, bucket, []byte("k1"), func(v []byte) ([]byte, error) {
+			if string(v) != `{"v":1}` {
+				t.Errorf("unexpected stored value %s", v)
+			}
+			return []byte(`{"v":2}`), nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error updating value: %v", err)
+		}
+
+		if v := get(t); v != `{"v":2}` {
+			t.Errorf("expected modified value to be stored, got %s", v)
+		}
+	})
+
+	t.Run("modify errors abort the write", func(t *testing.T) {
+		exp := errors.New("bad value")
+		err := s.UpdateJSON(ctx, bucket, []byte("k1"), func(v []byte) ([]byte, error) {
+			return nil, exp
+		})
+		if err != exp {
+			t.Errorf("expected modify error to be returned, got %v", err)
+		}
+
+		if v := get(t); v != `{"v":2}` {
+			t.Errorf("expected value to be unchanged, got %s", v)
+		}
+	})
+}
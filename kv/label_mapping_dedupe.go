@@ -0,0 +1,93 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/influxdata/influxdb"
+)
+
+// labelMappingEntry is a label mapping as it is stored, under the key it is stored at.
+type labelMappingEntry struct {
+	key   []byte
+	value []byte
+}
+
+// deduplicateLabelMappings is the migration collapsing the label mappings stored more than once
+// for the same resource and label into a single mapping. It returns the number of mappings removed.
+func (s *Service) deduplicateLabelMappings(ctx context.Context, tx Tx) (int, error) {
+	return s.removeDuplicateLabelMappings(ctx, tx)
+}
+
+// removeDuplicateLabelMappings removes every label mapping that maps a resource to a label
+// already mapped by another, and returns how many were removed. Mappings are identified by their
+// value, as a mapping stored under a key other than the one derived from it is a duplicate of the
+// mapping stored under that key. The mapping kept is the one under that key if there is one, and
+// is otherwise moved there so that it can be found.
+func (s *Service) removeDuplicateLabelMappings(ctx context.Context, tx Tx) (int, error) {
+	idx, err := tx.Bucket(labelMappingBucket)
+	if err != nil {
+		return 0, err
+	}
+
+	cur, err := idx.Cursor()
+	if err != nil {
+		return 0, err
+	}
+
+	var canonical [][]byte
+	entries := map[string][]labelMappingEntry{}
+	for k, v := cur.First(); len(k) != 0; k, v = cur.Next() {
+		m := &influxdb.LabelMapping{}
+		if err := json.Unmarshal(v, m); err != nil {
+			// mappings that cannot be decoded are not known to be duplicates.
+			continue
+		}
+
+		key, err := labelMappingKey(m)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := entries[string(key)]; !ok {
+			canonical = append(canonical, key)
+		}
+		entries[string(key)] = append(entries[string(key)], labelMappingEntry{
+			key:   append([]byte(nil), k...),
+			value: append([]byte(nil), v...),
+		})
+	}
+
+	var removed int
+	for _, key := range canonical {
+		es := entries[string(key)]
+
+		keep := -1
+		for i, e := range es {
+			if bytes.Equal(e.key, key) {
+				keep = i
+				break
+			}
+		}
+		if keep < 0 {
+			if err := idx.Put(key, es[0].value); err != nil {
+				return 0, err
+			}
+		}
+
+		for i, e := range es {
+			if i == keep {
+				continue
+			}
+			if err := idx.Delete(e.key); err != nil {
+				return 0, err
+			}
+			if keep >= 0 || i > 0 {
+				removed++
+			}
+		}
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,87 @@
+package kv_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestService_RemoveDuplicateLabelMappings(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	l1 := &influxdb.Label{Name: "l1"}
+	l2 := &influxdb.Label{Name: "l2"}
+	for _, l := range []*influxdb.Label{l1, l2} {
+		if err := svc.CreateLabel(ctx, l); err != nil {
+			t.Fatalf("failed to create label: %v", err)
+		}
+	}
+
+	resourceID := influxdb.ID(10)
+	mapping := func(labelID influxdb.ID) *influxdb.LabelMapping {
+		return &influxdb.LabelMapping{
+			LabelID:      labelID,
+			ResourceID:   resourceID,
+			ResourceType: influxdb.DocumentsResourceType,
+		}
+	}
+	if err := svc.CreateLabelMapping(ctx, mapping(l1.ID)); err != nil {
+		t.Fatalf("failed to create label mapping: %v", err)
+	}
+
+	// l1 is mapped three times, and l2 twice without the mapping under its own key.
+	for _, m := range []*influxdb.LabelMapping{mapping(l1.ID), mapping(l2.ID)} {
+		key, err := kv.LabelMappingKey(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, suffix := range []string{"a", "b"} {
+			if err := svc.PutLabelMappingAt(ctx, append(key, suffix...), m); err != nil {
+				t.Fatalf("failed to put label mapping: %v", err)
+			}
+		}
+	}
+
+	t.Run("duplicates are collapsed to one mapping", func(t *testing.T) {
+		n, err := svc.RemoveDuplicateLabelMappings(ctx)
+		if err != nil {
+			t.Fatalf("failed to remove duplicate label mappings: %v", err)
+		}
+		if n != 3 {
+			t.Errorf("expected 3 duplicates to be removed, got %d", n)
+		}
+
+		ls, err := svc.FindResourceLabels(ctx, influxdb.LabelMappingFilter{
+			ResourceID:   resourceID,
+			ResourceType: influxdb.DocumentsResourceType,
+		})
+		if err != nil {
+			t.Fatalf("failed to find resource labels: %v", err)
+		}
+		if len(ls) != 2 || ls[0].ID != l1.ID || ls[1].ID != l2.ID {
+			t.Errorf("expected labels l1 and l2 once each, got %v", ls)
+		}
+	})
+
+	t.Run("removing duplicates again removes nothing", func(t *testing.T) {
+		n, err := svc.RemoveDuplicateLabelMappings(ctx)
+		if err != nil {
+			t.Fatalf("failed to remove duplicate label mappings: %v", err)
+		}
+		if n != 0 {
+			t.Errorf("expected no duplicates to be removed, got %d", n)
+		}
+	})
+}
@@ -0,0 +1,304 @@
+package kv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb"
+	"go.uber.org/zap"
+)
+
+var (
+	migrationBucket = []byte("migrationsv1")
+)
+
+var _ influxdb.DataMigrationService = (*Service)(nil)
+
+// Migration is a named, one time conversion of the data held in the kv store.
+type Migration struct {
+	// Name uniquely identifies the migration. It is recorded once the migration
+	// has been applied so that it is never run twice.
+	Name string
+	// Up converts the data within the transaction provided, and returns the number of
+	// records it converted.
+	Up func(ctx context.Context, tx Tx) (int, error)
+}
+
+// MigrationHook runs custom logic alongside a migration, such as converting additional state.
+type MigrationHook func(ctx context.Context, tx Tx, m Migration) error
+
+// migrationRecord is stored for each migration that has been applied.
+type migrationRecord struct {
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"appliedAt"`
+	Processed int       `json:"processed"`
+}
+
+func (s *Service) initializeMigrations(ctx context.Context, tx Tx) error {
+	if _, err := tx.Bucket(migrationBucket); err != nil {
+		return err
+	}
+	return nil
+}
+
+// defaultMigrations returns the migrations known to the service, in the order
+// that they must be applied.
+func (s *Service) defaultMigrations() []Migration {
+	return []Migration{
+		{
+			Name: "document timestamps",
+			Up:   s.backfillDocumentTimestamps,
+		},
+		{
+			Name: "document label index",
+			Up:   s.indexAllDocumentLabels,
+		},
+		{
+			Name: "document content checksums",
+			Up:   s.backfillDocumentChecksums,
+		},
+		{
+			Name: "document org index",
+			Up:   s.indexAllDocumentOrgs,
+		},
+		{
+			Name: "deduplicate label mappings",
+			Up:   s.deduplicateLabelMappings,
+		},
+		{
+			Name: "document org ids",
+			Up:   s.backfillDocumentOrgIDs,
+		},
+	}
+}
+
+// RegisterMigration appends a migration to the set of migrations run by ConvertToNew.
+func (s *Service) RegisterMigration(m Migration) {
+	s.migrations = append(s.migrations, m)
+}
+
+// IsMigrated returns true if every registered migration has been applied.
+func (s *Service) IsMigrated(ctx context.Context) (bool, error) {
+	migrated := true
+	err := s.kv.View(ctx, func(tx Tx) error {
+		for _, m := range s.migrations {
+			applied, err := s.isMigrationApplied(ctx, tx, m.Name)
+			if err != nil {
+				return err
+			}
+			if !applied {
+				migrated = false
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return migrated, nil
+}
+
+// ConvertToNew applies every registered migration that has not yet been applied.
+// Each migration runs in its own transaction and is recorded in the same transaction.
+// A conflict is returned if the migrations are already being applied.
+func (s *Service) ConvertToNew(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&s.migrating, 0, 1) {
+		return &influxdb.Error{
+			Code: influxdb.EConflict,
+			Msg:  "migrations are already running",
+			Op:   OpPrefix + "ConvertToNew",
+		}
+	}
+	defer atomic.StoreInt32(&s.migrating, 0)
+
+	for _, m := range s.migrations {
+		err := s.kv.Update(ctx, func(tx Tx) error {
+			applied, err := s.isMigrationApplied(ctx, tx, m.Name)
+			if err != nil {
+				return err
+			}
+			if applied {
+				return nil
+			}
+
+			if s.BeforeMigration != nil {
+				if err := s.BeforeMigration(ctx, tx, m); err != nil {
+					return err
+				}
+			}
+
+			n, err := m.Up(ctx, tx)
+			if err != nil {
+				return err
+			}
+
+			if s.AfterMigration != nil {
+				if err := s.AfterMigration(ctx, tx, m); err != nil {
+					return err
+				}
+			}
+
+			s.Logger.Info("Applied kv migration", zap.String("migration", m.Name), zap.Int("processed", n))
+			return s.putMigrationRecord(ctx, tx, &migrationRecord{
+				Name:      m.Name,
+				AppliedAt: s.time(),
+				Processed: n,
+			})
+		})
+		if err != nil {
+			return &influxdb.Error{
+				Code: influxdb.EInternal,
+				Msg:  fmt.Sprintf("failed to apply migration %q", m.Name),
+				Op:   OpPrefix + "ConvertToNew",
+				Err:  err,
+			}
+		}
+	}
+
+	return nil
+}
+
+// FindMigrationStatus reports whether each registered migration has been applied, when, and how
+// many records it converted.
+func (s *Service) FindMigrationStatus(ctx context.Context) ([]*influxdb.MigrationStatus, error) {
+	ss := make([]*influxdb.MigrationStatus, 0, len(s.migrations))
+	err := s.kv.View(ctx, func(tx Tx) error {
+		b, err := tx.Bucket(migrationBucket)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range s.migrations {
+			st := &influxdb.MigrationStatus{Name: m.Name}
+
+			v, err := b.Get([]byte(m.Name))
+			if IsNotFound(err) {
+				ss = append(ss, st)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			r := &migrationRecord{}
+			if err := json.Unmarshal(v, r); err != nil {
+				return err
+			}
+			st.Applied = true
+			st.AppliedAt = &r.AppliedAt
+			st.Processed = r.Processed
+
+			ss = append(ss, st)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  "failed to find migration status",
+			Op:   OpPrefix + "FindMigrationStatus",
+			Err:  err,
+		}
+	}
+
+	return ss, nil
+}
+
+// SetMigrated records the migration with the name provided as applied, or as not applied so that
+// ConvertToNew applies it again. A conflict is returned if the migrations are being applied.
+func (s *Service) SetMigrated(ctx context.Context, name string, applied bool) error {
+	op := OpPrefix + "SetMigrated"
+	if !atomic.CompareAndSwapInt32(&s.migrating, 0, 1) {
+		return &influxdb.Error{
+			Code: influxdb.EConflict,
+			Msg:  "migrations are already running",
+			Op:   op,
+		}
+	}
+	defer atomic.StoreInt32(&s.migrating, 0)
+
+	if !s.isRegisteredMigration(name) {
+		return &influxdb.Error{
+			Code: influxdb.ENotFound,
+			Msg:  fmt.Sprintf("migration %q not found", name),
+			Op:   op,
+		}
+	}
+
+	err := s.kv.Update(ctx, func(tx Tx) error {
+		if applied {
+			return s.putMigrationRecord(ctx, tx, &migrationRecord{
+				Name:      name,
+				AppliedAt: s.time(),
+			})
+		}
+
+		b, err := tx.Bucket(migrationBucket)
+		if err != nil {
+			return err
+		}
+		return b.Delete([]byte(name))
+	})
+	if err != nil {
+		return &influxdb.Error{
+			Code: influxdb.EInternal,
+			Msg:  fmt.Sprintf("failed to record migration %q as applied=%t", name, applied),
+			Op:   op,
+			Err:  err,
+		}
+	}
+
+	return nil
+}
+
+// ClearMigrated records the migration with the name provided as not applied, so that
+// ConvertToNew applies it again.
+func (s *Service) ClearMigrated(ctx context.Context, name string) error {
+	return s.SetMigrated(ctx, name, false)
+}
+
+func (s *Service) isRegisteredMigration(name string) bool {
+	for _, m := range s.migrations {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) isMigrationApplied(ctx context.Context, tx Tx, name string) (bool, error) {
+	b, err := tx.Bucket(migrationBucket)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = b.Get([]byte(name))
+	if IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *Service) putMigrationRecord(ctx context.Context, tx Tx, r *migrationRecord) error {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	b, err := tx.Bucket(migrationBucket)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(r.Name), v)
+}
@@ -0,0 +1,314 @@
+package kv_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+	influxdbtesting "github.com/influxdata/influxdb/testing"
+)
+
+func TestService_ConvertToNew_DocumentTimestamps(t *testing.T) {
+	store, closeStore, err := NewTestBoltStore()
+	if err != nil {
+		t.Fatalf("failed to create new bolt kv store: %v", err)
+	}
+	defer closeStore()
+
+	ctx := context.Background()
+	now := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	created := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	svc := kv.NewService(store)
+	svc.WithTime(func() time.Time { return now })
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+	if _, err := svc.CreateDocumentStore(ctx, "testing"); err != nil {
+		t.Fatalf("failed to create document store: %v", err)
+	}
+
+	legacyID := influxdbtesting.MustIDBase16("020f755c3c082000")
+	currentID := influxdbtesting.MustIDBase16("020f755c3c082001")
+	mustPutDocumentMeta(t, store, "testing", legacyID, map[string]interface{}{
+		"name": "legacy",
+	})
+	mustPutDocumentMeta(t, store, "testing", currentID, &influxdb.DocumentMeta{
+		Name:      "current",
+		CreatedAt: created,
+		UpdatedAt: updated,
+	})
+
+	if migrated, err := svc.IsMigrated(ctx); err != nil {
+		t.Fatalf("unexpected error checking migration: %v", err)
+	} else if migrated {
+		t.Fatalf("expected service to not be migrated")
+	}
+
+	if err := svc.ConvertToNew(ctx); err != nil {
+		t.Fatalf("unexpected error migrating: %v", err)
+	}
+
+	if migrated, err := svc.IsMigrated(ctx); err != nil {
+		t.Fatalf("unexpected error checking migration: %v", err)
+	} else if !migrated {
+		t.Fatalf("expected service to be migrated")
+	}
+
+	ds, err := svc.FindDocumentStore(ctx, "testing")
+	if err != nil {
+		t.Fatalf("failed to find document store: %v", err)
+	}
+	docs, err := ds.FindDocuments(ctx)
+	if err != nil {
+		t.Fatalf("failed to find documents: %v", err)
+	}
+
+	metas := map[influxdb.ID]influxdb.DocumentMeta{}
+	for _, d := range docs {
+		metas[d.ID] = d.Meta
+	}
+
+	if m := metas[legacyID]; !m.CreatedAt.Equal(now) || !m.UpdatedAt.Equal(now) {
+		t.Errorf("expected legacy document timestamps to be backfilled with %v, got %v and %v", now, m.CreatedAt, m.UpdatedAt)
+	}
+	if m := metas[currentID]; !m.CreatedAt.Equal(created) || !m.UpdatedAt.Equal(updated) {
+		t.Errorf("expected current document timestamps to be untouched, got %v and %v", m.CreatedAt, m.UpdatedAt)
+	}
+
+	ss, err := svc.FindMigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("failed to find migration status: %v", err)
+	}
+	for _, st := range ss {
+		if st.Name == "document timestamps" && st.Processed != 1 {
+			t.Errorf("expected 1 document to be processed, got %d", st.Processed)
+		}
+	}
+}
+
+func TestService_ConvertToNew_UnregisteredDocumentNamespace(t *testing.T) {
+	store, closeStore, err := NewTestBoltStore()
+	if err != nil {
+		t.Fatalf("failed to create new bolt kv store: %v", err)
+	}
+	defer closeStore()
+
+	ctx := context.Background()
+	now := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// a namespace created before namespaces were registered only has its content and meta buckets.
+	err = store.Update(ctx, func(tx kv.Tx) error {
+		_, err := tx.Bucket([]byte("legacy/documents/content"))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to create legacy document store: %v", err)
+	}
+	legacyID := influxdbtesting.MustIDBase16("020f755c3c082000")
+	mustPutDocumentMeta(t, store, "legacy", legacyID, map[string]interface{}{
+		"name": "legacy",
+	})
+
+	svc := kv.NewService(store)
+	svc.WithTime(func() time.Time { return now })
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+	if err := svc.ConvertToNew(ctx); err != nil {
+		t.Fatalf("unexpected error migrating: %v", err)
+	}
+
+	ds, err := svc.FindDocumentStore(ctx, "legacy")
+	if err != nil {
+		t.Fatalf("failed to find document store: %v", err)
+	}
+	docs, err := ds.FindDocuments(ctx)
+	if err != nil {
+		t.Fatalf("failed to find documents: %v", err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("expected 1 document, got %d", len(docs))
+	}
+	if m := docs[0].Meta; !m.CreatedAt.Equal(now) || !m.UpdatedAt.Equal(now) {
+		t.Errorf("expected legacy document timestamps to be backfilled with %v, got %v and %v", now, m.CreatedAt, m.UpdatedAt)
+	}
+}
+
+func TestService_ConvertToNew_Hooks(t *testing.T) {
+	ctx := context.Background()
+
+	newService := func(t *testing.T, events *[]string) *kv.Service {
+		store, _, err := NewTestInmemStore()
+		if err != nil {
+			t.Fatalf("failed to create new inmem kv store: %v", err)
+		}
+		svc := kv.NewService(store)
+		if err := svc.Initialize(ctx); err != nil {
+			t.Fatalf("failed to initialize service: %v", err)
+		}
+		svc.RegisterMigration(kv.Migration{
+			Name: "custom",
+			Up: func(ctx context.Context, tx kv.Tx) (int, error) {
+				*events = append(*events, "up custom")
+				return 0, nil
+			},
+		})
+		svc.BeforeMigration = func(ctx context.Context, tx kv.Tx, m kv.Migration) error {
+			*events = append(*events, "before "+m.Name)
+			return nil
+		}
+		return svc
+	}
+
+	t.Run("hooks run around each migration in order", func(t *testing.T) {
+		var events []string
+		svc := newService(t, &events)
+		svc.AfterMigration = func(ctx context.Context, tx kv.Tx, m kv.Migration) error {
+			events = append(events, "after "+m.Name)
+			return nil
+		}
+
+		if err := svc.ConvertToNew(ctx); err != nil {
+			t.Fatalf("unexpected error migrating: %v", err)
+		}
+
+		want := []string{
+			"before document timestamps", "after document timestamps",
+			"before document label index", "after document label index",
+			"before document content checksums", "after document content checksums",
+			"before document org index", "after document org index",
+			"before deduplicate label mappings", "after deduplicate label mappings",
+			"before document org ids", "after document org ids",
+			"before custom", "up custom", "after custom",
+		}
+		if !reflect.DeepEqual(events, want) {
+			t.Errorf("expected events %v, got %v", want, events)
+		}
+	})
+
+	t.Run("hook error aborts the migration", func(t *testing.T) {
+		var events []string
+		svc := newService(t, &events)
+		svc.AfterMigration = func(ctx context.Context, tx kv.Tx, m kv.Migration) error {
+			if m.Name == "custom" {
+				return errors.New("retention policy could not be created")
+			}
+			return nil
+		}
+
+		err := svc.ConvertToNew(ctx)
+		if err == nil {
+			t.Fatal("expected the hook error to be returned")
+		}
+		if !strings.Contains(err.Error(), "retention policy could not be created") {
+			t.Errorf("expected the hook error to be surfaced, got %v", err)
+		}
+
+		ss, err := svc.FindMigrationStatus(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error finding migration status: %v", err)
+		}
+		for _, st := range ss {
+			if applied := st.Name != "custom"; st.Applied != applied {
+				t.Errorf("expected migration %q applied to be %v, got %v", st.Name, applied, st.Applied)
+			}
+		}
+	})
+}
+
+func mustPutDocumentMeta(t *testing.T, store kv.Store, ns string, id influxdb.ID, meta interface{}) {
+	t.Helper()
+
+	err := store.Update(context.Background(), func(tx kv.Tx) error {
+		b, err := tx.Bucket([]byte(ns + "/documents/meta"))
+		if err != nil {
+			return err
+		}
+
+		k, err := id.Encode()
+		if err != nil {
+			return err
+		}
+
+		v, err := json.Marshal(meta)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(k, v)
+	})
+	if err != nil {
+		t.Fatalf("failed to put document meta: %v", err)
+	}
+}
+
+func TestService_SetMigrated(t *testing.T) {
+	ctx := context.Background()
+	store, closeStore, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeStore()
+
+	svc := kv.NewService(store)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+	runs := 0
+	svc.RegisterMigration(kv.Migration{
+		Name: "custom",
+		Up: func(ctx context.Context, tx kv.Tx) (int, error) {
+			runs++
+			return 0, nil
+		},
+	})
+	if err := svc.ConvertToNew(ctx); err != nil {
+		t.Fatalf("unexpected error migrating: %v", err)
+	}
+
+	isMigrated := func(want bool) {
+		t.Helper()
+		migrated, err := svc.IsMigrated(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error checking migrations: %v", err)
+		}
+		if migrated != want {
+			t.Errorf("IsMigrated() = %v, want %v", migrated, want)
+		}
+	}
+	isMigrated(true)
+
+	if err := svc.ClearMigrated(ctx, "custom"); err != nil {
+		t.Fatalf("unexpected error clearing migration: %v", err)
+	}
+	isMigrated(false)
+
+	if err := svc.SetMigrated(ctx, "custom", true); err != nil {
+		t.Fatalf("unexpected error setting migration: %v", err)
+	}
+	isMigrated(true)
+
+	if err := svc.SetMigrated(ctx, "custom", false); err != nil {
+		t.Fatalf("unexpected error setting migration: %v", err)
+	}
+	if err := svc.ConvertToNew(ctx); err != nil {
+		t.Fatalf("unexpected error migrating: %v", err)
+	}
+	isMigrated(true)
+	if runs != 2 {
+		t.Errorf("expected the cleared migration to be applied again, got %d runs", runs)
+	}
+
+	err = svc.SetMigrated(ctx, "unknown", true)
+	if code := influxdb.ErrorCode(err); code != influxdb.ENotFound {
+		t.Errorf("expected error code %q for an unknown migration, got %q: %v", influxdb.ENotFound, code, err)
+	}
+}
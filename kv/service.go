@@ -27,19 +27,39 @@ type Service struct {
 	TokenGenerator influxdb.TokenGenerator
 	Hash           Crypt
 
-	time func() time.Time
+	// BeforeMigration and AfterMigration are run within the transaction of each migration
+	// applied by ConvertToNew, before and after it converts the data. An error aborts the migration.
+	BeforeMigration MigrationHook
+	AfterMigration  MigrationHook
+
+	time       func() time.Time
+	migrations []Migration
+
+	documentAccess *documentAccessTracker
+	documentFeed   *documentChangefeed
+	// compressedNamespaces are the document namespaces whose content is compressed.
+	compressedNamespaces map[string]bool
+	// documentIDPrefix, if set, is the prefix of the IDs of the documents created.
+	documentIDPrefix *uint16
+
+	// migrating is set while ConvertToNew runs so that migrations never run concurrently.
+	migrating int32
 }
 
 // NewService returns an instance of a Service.
 func NewService(kv Store) *Service {
-	return &Service{
+	s := &Service{
 		Logger:         zap.NewNop(),
 		IDGenerator:    snowflake.NewIDGenerator(),
 		TokenGenerator: rand.NewTokenGenerator(64),
 		Hash:           &Bcrypt{},
 		kv:             kv,
 		time:           time.Now,
+		documentFeed:   &documentChangefeed{},
 	}
+	s.migrations = s.defaultMigrations()
+
+	return s
 }
 
 // Initialize creates Buckets needed.
@@ -69,6 +89,10 @@ func (s *Service) Initialize(ctx context.Context) error {
 			return err
 		}
 
+		if err := s.initializeMigrations(ctx, tx); err != nil {
+			return err
+		}
+
 		if err := s.initializeOnboarding(ctx, tx); err != nil {
 			return err
 		}
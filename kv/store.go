@@ -38,6 +38,12 @@ type Tx interface {
 	WithContext(ctx context.Context)
 }
 
+// BucketLister is implemented by transactions that can list the buckets of the store.
+type BucketLister interface {
+	// BucketNames returns the names of every bucket.
+	BucketNames() ([][]byte, error)
+}
+
 // Bucket is the abstraction used to perform get/put/delete/get-many operations
 // in a key value store.
 type Bucket interface {
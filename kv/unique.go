@@ -24,12 +24,16 @@ var NotUniqueError = &influxdb.Error{
 }
 
 func (s *Service) unique(ctx context.Context, tx Tx, indexBucket, indexKey []byte) error {
-	bucket, err := tx.Bucket(indexBucket)
-	if err != nil {
-		return UnexpectedIndexError(err)
-	}
+	return uniqueIndex(indexBucket).check(tx, indexKey)
+}
 
-	_, err = bucket.Get(indexKey)
+// uniqueIndex is a bucket of secondary keys, such as names or idempotency keys, each of which
+// refers to a single record, typically by its ID.
+type uniqueIndex []byte
+
+// check returns NotUniqueError if the key is already in the index.
+func (i uniqueIndex) check(tx Tx, key []byte) error {
+	_, err := i.lookup(tx, key)
 	// if not found then this is  _unique_.
 	if IsNotFound(err) {
 		return nil
@@ -43,3 +47,43 @@ func (s *Service) unique(ctx context.Context, tx Tx, indexBucket, indexKey []byt
 	// any other error is some sort of internal server error
 	return UnexpectedIndexError(err)
 }
+
+// lookup returns the value the key refers to, or a not found error if the key is not in the index.
+func (i uniqueIndex) lookup(tx Tx, key []byte) ([]byte, error) {
+	bucket, err := tx.Bucket(i)
+	if err != nil {
+		return nil, UnexpectedIndexError(err)
+	}
+
+	return bucket.Get(key)
+}
+
+// insert adds the key to the index, referring to value, unless it is already in the index, in
+// which case NotUniqueError is returned.
+func (i uniqueIndex) insert(tx Tx, key, value []byte) error {
+	if err := i.check(tx, key); err != nil {
+		return err
+	}
+
+	return i.put(tx, key, value)
+}
+
+// put adds the key to the index, replacing the value it referred to if any.
+func (i uniqueIndex) put(tx Tx, key, value []byte) error {
+	bucket, err := tx.Bucket(i)
+	if err != nil {
+		return UnexpectedIndexError(err)
+	}
+
+	return bucket.Put(key, value)
+}
+
+// remove removes the key from the index.
+func (i uniqueIndex) remove(tx Tx, key []byte) error {
+	bucket, err := tx.Bucket(i)
+	if err != nil {
+		return UnexpectedIndexError(err)
+	}
+
+	return bucket.Delete(key)
+}
@@ -0,0 +1,73 @@
+package kv_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/kv"
+)
+
+func TestService_UniqueIndex(t *testing.T) {
+	inmemStore, closeInmem, err := NewTestInmemStore()
+	if err != nil {
+		t.Fatalf("failed to create new inmem kv store: %v", err)
+	}
+	defer closeInmem()
+
+	ctx := context.Background()
+	svc := kv.NewService(inmemStore)
+	if err := svc.Initialize(ctx); err != nil {
+		t.Fatalf("error initializing kv service: %v", err)
+	}
+
+	index := []byte("uniquetestindexv1")
+	if err := svc.InsertUnique(ctx, index, []byte("name"), []byte("020f755c3c082000")); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("lookup by secondary key", func(t *testing.T) {
+		v, err := svc.LookupUnique(ctx, index, []byte("name"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(v) != "020f755c3c082000" {
+			t.Errorf("LookupUnique() = %q, want %q", v, "020f755c3c082000")
+		}
+
+		if _, err := svc.LookupUnique(ctx, index, []byte("other")); !kv.IsNotFound(err) {
+			t.Errorf("LookupUnique() of a missing key = %v, want not found", err)
+		}
+	})
+
+	t.Run("insert with conflict", func(t *testing.T) {
+		err := svc.InsertUnique(ctx, index, []byte("name"), []byte("020f755c3c082001"))
+		if err != kv.NotUniqueError {
+			t.Fatalf("InsertUnique() = %v, want %v", err, kv.NotUniqueError)
+		}
+		if code := influxdb.ErrorCode(err); code != influxdb.EConflict {
+			t.Errorf("InsertUnique() code = %s, want %s", code, influxdb.EConflict)
+		}
+
+		// the conflicting insert does not replace the value.
+		v, err := svc.LookupUnique(ctx, index, []byte("name"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(v) != "020f755c3c082000" {
+			t.Errorf("LookupUnique() = %q, want %q", v, "020f755c3c082000")
+		}
+	})
+
+	t.Run("insert after remove", func(t *testing.T) {
+		if err := svc.RemoveUnique(ctx, index, []byte("name")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.LookupUnique(ctx, index, []byte("name")); !kv.IsNotFound(err) {
+			t.Fatalf("LookupUnique() of a removed key = %v, want not found", err)
+		}
+		if err := svc.InsertUnique(ctx, index, []byte("name"), []byte("020f755c3c082001")); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
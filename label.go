@@ -2,6 +2,7 @@ package influxdb
 
 import (
 	"context"
+	"time"
 )
 
 // ErrLabelNotFound is the error for a missing Label.
@@ -78,6 +79,8 @@ type LabelMapping struct {
 	LabelID      ID `json:"labelID"`
 	ResourceID   ID `json:"resourceID"`
 	ResourceType `json:"resourceType"`
+	// AttachedAt is when the label was attached. It is only recorded for documents.
+	AttachedAt *time.Time `json:"attachedAt,omitempty"`
 }
 
 // Validate returns an error if the mapping is invalid.
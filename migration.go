@@ -0,0 +1,27 @@
+package influxdb
+
+import (
+	"context"
+	"time"
+)
+
+// DataMigrationService converts data persisted by earlier versions of the
+// application into its current representation.
+type DataMigrationService interface {
+	// IsMigrated reports whether all of the stored data has been converted.
+	IsMigrated(ctx context.Context) (bool, error)
+	// ConvertToNew converts any stored data that has not yet been converted.
+	ConvertToNew(ctx context.Context) error
+	// FindMigrationStatus reports whether each migration has been applied, in the
+	// order that the migrations are applied.
+	FindMigrationStatus(ctx context.Context) ([]*MigrationStatus, error)
+}
+
+// MigrationStatus is the state of a single migration.
+type MigrationStatus struct {
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+	// Processed is the number of records converted by the migration when it was applied.
+	Processed int `json:"processed"`
+}
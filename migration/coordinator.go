@@ -0,0 +1,188 @@
+// Package migration coordinates the data migrations of the services that persist data.
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/influxdb"
+)
+
+var _ influxdb.DataMigrationService = (*Coordinator)(nil)
+
+// Coordinator is a DataMigrationService that runs the migrations of the services registered
+// with it. Services are migrated after the services they depend on, and otherwise in the order
+// they were registered.
+type Coordinator struct {
+	mu    sync.Mutex
+	steps []*step
+}
+
+type step struct {
+	name      string
+	svc       influxdb.DataMigrationService
+	dependsOn []string
+}
+
+// NewCoordinator returns a Coordinator without any registered services.
+func NewCoordinator() *Coordinator {
+	return &Coordinator{}
+}
+
+// Register adds the migrations of a service under the name provided. The service is migrated
+// only once the services named by dependsOn have been migrated.
+func (c *Coordinator) Register(name string, svc influxdb.DataMigrationService, dependsOn ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.steps = append(c.steps, &step{
+		name:      name,
+		svc:       svc,
+		dependsOn: dependsOn,
+	})
+}
+
+// IsMigrated reports whether every registered service has been migrated.
+func (c *Coordinator) IsMigrated(ctx context.Context) (bool, error) {
+	steps, err := c.ordered("IsMigrated")
+	if err != nil {
+		return false, err
+	}
+
+	for _, s := range steps {
+		migrated, err := s.svc.IsMigrated(ctx)
+		if err != nil {
+			return false, &influxdb.Error{
+				Code: influxdb.ErrorCode(err),
+				Msg:  fmt.Sprintf("failed to check migrations of %q", s.name),
+				Op:   "migration/IsMigrated",
+				Err:  err,
+			}
+		}
+		if !migrated {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// ConvertToNew migrates every registered service in dependency order. It stops at the first
+// service that fails to migrate, so that no service is migrated before its dependencies.
+func (c *Coordinator) ConvertToNew(ctx context.Context) error {
+	steps, err := c.ordered("ConvertToNew")
+	if err != nil {
+		return err
+	}
+
+	for _, s := range steps {
+		if err := s.svc.ConvertToNew(ctx); err != nil {
+			return &influxdb.Error{
+				Code: influxdb.ErrorCode(err),
+				Msg:  fmt.Sprintf("failed to migrate %q", s.name),
+				Op:   "migration/ConvertToNew",
+				Err:  err,
+			}
+		}
+	}
+
+	return nil
+}
+
+// FindMigrationStatus reports the status of the migrations of every registered service, in the
+// order they are applied. The name of each migration is prefixed with the name of its service.
+func (c *Coordinator) FindMigrationStatus(ctx context.Context) ([]*influxdb.MigrationStatus, error) {
+	steps, err := c.ordered("FindMigrationStatus")
+	if err != nil {
+		return nil, err
+	}
+
+	var all []*influxdb.MigrationStatus
+	for _, s := range steps {
+		ss, err := s.svc.FindMigrationStatus(ctx)
+		if err != nil {
+			return nil, &influxdb.Error{
+				Code: influxdb.ErrorCode(err),
+				Msg:  fmt.Sprintf("failed to find migration status of %q", s.name),
+				Op:   "migration/FindMigrationStatus",
+				Err:  err,
+			}
+		}
+
+		for _, st := range ss {
+			st.Name = s.name + "/" + st.Name
+			all = append(all, st)
+		}
+	}
+
+	return all, nil
+}
+
+// ordered returns the registered services sorted so that each comes after its dependencies.
+// Services without a dependency between them keep the order they were registered in.
+func (c *Coordinator) ordered(op string) ([]*step, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	byName := make(map[string]*step, len(c.steps))
+	for _, s := range c.steps {
+		if _, ok := byName[s.name]; ok {
+			return nil, &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  fmt.Sprintf("migrations of %q are registered more than once", s.name),
+				Op:   "migration/" + op,
+			}
+		}
+		byName[s.name] = s
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(c.steps))
+	ordered := make([]*step, 0, len(c.steps))
+
+	var visit func(s *step, path []string) error
+	visit = func(s *step, path []string) error {
+		switch state[s.name] {
+		case visited:
+			return nil
+		case visiting:
+			return &influxdb.Error{
+				Code: influxdb.EInvalid,
+				Msg:  fmt.Sprintf("migrations have a dependency cycle: %s", strings.Join(append(path, s.name), " -> ")),
+				Op:   "migration/" + op,
+			}
+		}
+
+		state[s.name] = visiting
+		for _, dep := range s.dependsOn {
+			d, ok := byName[dep]
+			if !ok {
+				return &influxdb.Error{
+					Code: influxdb.EInvalid,
+					Msg:  fmt.Sprintf("migrations of %q depend on unregistered %q", s.name, dep),
+					Op:   "migration/" + op,
+				}
+			}
+			if err := visit(d, append(path, s.name)); err != nil {
+				return err
+			}
+		}
+		state[s.name] = visited
+
+		ordered = append(ordered, s)
+		return nil
+	}
+
+	for _, s := range c.steps {
+		if err := visit(s, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
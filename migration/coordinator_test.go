@@ -0,0 +1,134 @@
+package migration_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/migration"
+)
+
+// fakeMigrations is a DataMigrationService with a single migration that records when it runs.
+type fakeMigrations struct {
+	name     string
+	migrated bool
+	events   *[]string
+	// requires must be migrated before this service can be.
+	requires *fakeMigrations
+}
+
+func (f *fakeMigrations) IsMigrated(ctx context.Context) (bool, error) {
+	return f.migrated, nil
+}
+
+func (f *fakeMigrations) ConvertToNew(ctx context.Context) error {
+	if f.requires != nil && !f.requires.migrated {
+		return errors.New(f.name + " migrated before " + f.requires.name)
+	}
+	if !f.migrated {
+		*f.events = append(*f.events, f.name)
+		f.migrated = true
+	}
+	return nil
+}
+
+func (f *fakeMigrations) FindMigrationStatus(ctx context.Context) ([]*influxdb.MigrationStatus, error) {
+	return []*influxdb.MigrationStatus{{Name: "up", Applied: f.migrated}}, nil
+}
+
+func TestCoordinator_ConvertToNew(t *testing.T) {
+	ctx := context.Background()
+
+	var events []string
+	buckets := &fakeMigrations{name: "buckets", events: &events}
+	documents := &fakeMigrations{name: "documents", events: &events, requires: buckets}
+
+	c := migration.NewCoordinator()
+	c.Register("documents", documents, "buckets")
+	c.Register("buckets", buckets)
+
+	isMigrated := func(want bool) {
+		t.Helper()
+		migrated, err := c.IsMigrated(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error checking migrations: %v", err)
+		}
+		if migrated != want {
+			t.Errorf("IsMigrated() = %v, want %v", migrated, want)
+		}
+	}
+
+	isMigrated(false)
+
+	// only some of the services being migrated is not enough.
+	buckets.migrated = true
+	isMigrated(false)
+	buckets.migrated = false
+
+	if err := c.ConvertToNew(ctx); err != nil {
+		t.Fatalf("unexpected error migrating: %v", err)
+	}
+	if want := []string{"buckets", "documents"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("migrated %v, want %v", events, want)
+	}
+	isMigrated(true)
+
+	ss, err := c.FindMigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error finding migration status: %v", err)
+	}
+	want := []*influxdb.MigrationStatus{
+		{Name: "buckets/up", Applied: true},
+		{Name: "documents/up", Applied: true},
+	}
+	if !reflect.DeepEqual(ss, want) {
+		t.Errorf("FindMigrationStatus() = %v, want %v", ss, want)
+	}
+}
+
+func TestCoordinator_InvalidDependencies(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		register func(c *migration.Coordinator, svc influxdb.DataMigrationService)
+	}{
+		{
+			name: "unregistered dependency",
+			register: func(c *migration.Coordinator, svc influxdb.DataMigrationService) {
+				c.Register("documents", svc, "buckets")
+			},
+		},
+		{
+			name: "dependency cycle",
+			register: func(c *migration.Coordinator, svc influxdb.DataMigrationService) {
+				c.Register("documents", svc, "buckets")
+				c.Register("buckets", svc, "documents")
+			},
+		},
+		{
+			name: "duplicate registration",
+			register: func(c *migration.Coordinator, svc influxdb.DataMigrationService) {
+				c.Register("documents", svc)
+				c.Register("documents", svc)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []string
+			c := migration.NewCoordinator()
+			tt.register(c, &fakeMigrations{name: "svc", events: &events})
+
+			err := c.ConvertToNew(ctx)
+			if code := influxdb.ErrorCode(err); code != influxdb.EInvalid {
+				t.Errorf("expected error code %q, got %q: %v", influxdb.EInvalid, code, err)
+			}
+			if len(events) != 0 {
+				t.Errorf("expected nothing to be migrated, got %v", events)
+			}
+		})
+	}
+}
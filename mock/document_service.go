@@ -12,6 +12,7 @@ var _ influxdb.DocumentStore = &DocumentStore{}
 type DocumentService struct {
 	CreateDocumentStoreFn func(ctx context.Context, name string) (influxdb.DocumentStore, error)
 	FindDocumentStoreFn   func(ctx context.Context, name string) (influxdb.DocumentStore, error)
+	PingFn                func(ctx context.Context) error
 }
 
 // CreateDocumentStore calls the mocked CreateDocumentStoreFn.
@@ -24,6 +25,11 @@ func (s *DocumentService) FindDocumentStore(ctx context.Context, name string) (i
 	return s.FindDocumentStoreFn(ctx, name)
 }
 
+// Ping calls the mocked PingFn.
+func (s *DocumentService) Ping(ctx context.Context) error {
+	return s.PingFn(ctx)
+}
+
 // NewDocumentService returns a mock of DocumentService where its methods will return zero values.
 func NewDocumentService() *DocumentService {
 	return &DocumentService{
@@ -33,15 +39,20 @@ func NewDocumentService() *DocumentService {
 		FindDocumentStoreFn: func(ctx context.Context, name string) (influxdb.DocumentStore, error) {
 			return nil, nil
 		},
+		PingFn: func(ctx context.Context) error {
+			return nil
+		},
 	}
 }
 
 // DocumentStore is the mocked document store.
 type DocumentStore struct {
-	CreateDocumentFn  func(ctx context.Context, d *influxdb.Document, opts ...influxdb.DocumentOptions) error
-	UpdateDocumentFn  func(ctx context.Context, d *influxdb.Document, opts ...influxdb.DocumentOptions) error
-	FindDocumentsFn   func(ctx context.Context, opts ...influxdb.DocumentFindOptions) ([]*influxdb.Document, error)
-	DeleteDocumentsFn func(ctx context.Context, opts ...influxdb.DocumentFindOptions) error
+	CreateDocumentFn       func(ctx context.Context, d *influxdb.Document, opts ...influxdb.DocumentOptions) error
+	UpdateDocumentFn       func(ctx context.Context, d *influxdb.Document, opts ...influxdb.DocumentOptions) error
+	AppendContentFn        func(ctx context.Context, id influxdb.ID, data []interface{}, opts ...influxdb.DocumentOptions) (*influxdb.Document, error)
+	FindDocumentsFn        func(ctx context.Context, opts ...influxdb.DocumentFindOptions) ([]*influxdb.Document, error)
+	FindDocumentsByLabelFn func(ctx context.Context, labelID influxdb.ID) ([]*influxdb.Document, error)
+	DeleteDocumentsFn      func(ctx context.Context, opts ...influxdb.DocumentFindOptions) error
 }
 
 // NewDocumentStore returns a mock of DocumentStore where its methods will return zero values.
@@ -53,9 +64,15 @@ func NewDocumentStore() *DocumentStore {
 		UpdateDocumentFn: func(ctx context.Context, d *influxdb.Document, opts ...influxdb.DocumentOptions) error {
 			return nil
 		},
+		AppendContentFn: func(ctx context.Context, id influxdb.ID, data []interface{}, opts ...influxdb.DocumentOptions) (*influxdb.Document, error) {
+			return nil, nil
+		},
 		FindDocumentsFn: func(ctx context.Context, opts ...influxdb.DocumentFindOptions) ([]*influxdb.Document, error) {
 			return nil, nil
 		},
+		FindDocumentsByLabelFn: func(ctx context.Context, labelID influxdb.ID) ([]*influxdb.Document, error) {
+			return nil, nil
+		},
 		DeleteDocumentsFn: func(ctx context.Context, opts ...influxdb.DocumentFindOptions) error {
 			return nil
 		},
@@ -77,6 +94,16 @@ func (s *DocumentStore) FindDocuments(ctx context.Context, opts ...influxdb.Docu
 	return s.FindDocumentsFn(ctx, opts...)
 }
 
+// AppendContent will call the mocked AppendContentFn.
+func (s *DocumentStore) AppendContent(ctx context.Context, id influxdb.ID, data []interface{}, opts ...influxdb.DocumentOptions) (*influxdb.Document, error) {
+	return s.AppendContentFn(ctx, id, data, opts...)
+}
+
+// FindDocumentsByLabel will call the mocked FindDocumentsByLabelFn.
+func (s *DocumentStore) FindDocumentsByLabel(ctx context.Context, labelID influxdb.ID) ([]*influxdb.Document, error) {
+	return s.FindDocumentsByLabelFn(ctx, labelID)
+}
+
 // DeleteDocuments will call the mocked DeleteDocumentsFn.
 func (s *DocumentStore) DeleteDocuments(ctx context.Context, opts ...influxdb.DocumentFindOptions) error {
 	return s.DeleteDocumentsFn(ctx, opts...)
@@ -2,6 +2,7 @@ package query
 
 import (
 	"context"
+	"net/url"
 
 	"github.com/influxdata/flux"
 	platform "github.com/influxdata/influxdb"
@@ -17,13 +18,100 @@ type PreAuthorizer interface {
 	RequiredPermissions(ctx context.Context, spec *flux.Spec, orgID *platform.ID) ([]platform.Permission, error)
 }
 
+// UnknownBucketMode controls how a PreAuthorizer treats buckets that do not exist.
+type UnknownBucketMode int
+
+const (
+	// UnknownBucketError fails authorization when a bucket does not exist.
+	UnknownBucketError UnknownBucketMode = iota
+	// UnknownBucketRequiresCreate treats a bucket that does not exist as requiring
+	// permission to create buckets in its organization.
+	UnknownBucketRequiresCreate
+)
+
+// WriteBucketMode controls how a PreAuthorizer authorizes the buckets written by a query.
+type WriteBucketMode int
+
+const (
+	// WriteBucketMustExist requires each written bucket to exist and permits writes to it.
+	WriteBucketMustExist WriteBucketMode = iota
+	// WriteBucketOrgPermission requires only permission to write the buckets of the
+	// organization written to, without asserting that the bucket exists.
+	WriteBucketOrgPermission
+)
+
+// PreAuthorizerOption configures a PreAuthorizer.
+type PreAuthorizerOption func(*preAuthorizer)
+
+// WithUnknownBucketMode sets how the PreAuthorizer treats buckets that do not exist.
+// By default they are an error.
+func WithUnknownBucketMode(m UnknownBucketMode) PreAuthorizerOption {
+	return func(a *preAuthorizer) {
+		a.unknownBucketMode = m
+	}
+}
+
+// WithWriteBucketMode sets how the PreAuthorizer authorizes written buckets.
+// By default they must exist.
+func WithWriteBucketMode(m WriteBucketMode) PreAuthorizerOption {
+	return func(a *preAuthorizer) {
+		a.writeBucketMode = m
+	}
+}
+
 // NewPreAuthorizer creates a new PreAuthorizer
-func NewPreAuthorizer(bucketService platform.BucketService) PreAuthorizer {
-	return &preAuthorizer{bucketService: bucketService}
+func NewPreAuthorizer(bucketService platform.BucketService, opts ...PreAuthorizerOption) PreAuthorizer {
+	a := &preAuthorizer{bucketService: bucketService}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
 type preAuthorizer struct {
-	bucketService platform.BucketService
+	bucketService     platform.BucketService
+	unknownBucketMode UnknownBucketMode
+	writeBucketMode   WriteBucketMode
+}
+
+// createBucketPermission returns the permission required to create the bucket described by
+// the filter when the bucket does not exist and unknown buckets require create permission.
+// It returns nil if the error provided should not be treated that way.
+func (a *preAuthorizer) createBucketPermission(err error, filter platform.BucketFilter, orgID *platform.ID) (*platform.Permission, error) {
+	if a.unknownBucketMode != UnknownBucketRequiresCreate || platform.ErrorCode(err) != platform.ENotFound {
+		return nil, nil
+	}
+
+	return orgBucketsWritePermission(filter, orgID)
+}
+
+// orgBucketsWritePermission returns the permission to write the buckets of the organization
+// described by the filter, falling back to the organization provided.
+func orgBucketsWritePermission(filter platform.BucketFilter, orgID *platform.ID) (*platform.Permission, error) {
+	if filter.OrganizationID != nil {
+		orgID = filter.OrganizationID
+	}
+	if orgID == nil {
+		return platform.NewGlobalPermission(platform.WriteAction, platform.BucketsResourceType)
+	}
+
+	return platform.NewPermission(platform.WriteAction, platform.BucketsResourceType, *orgID)
+}
+
+// dedupBucketFilters returns the filters provided without duplicates, keeping the first
+// occurrence of each, so that a bucket referenced several times by a query is resolved once.
+func dedupBucketFilters(filters []platform.BucketFilter) []platform.BucketFilter {
+	seen := make(map[string]bool, len(filters))
+	deduped := filters[:0:0]
+	for _, f := range filters {
+		key := url.Values(f.QueryParams()).Encode()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped = append(deduped, f)
+	}
+	return deduped
 }
 
 // PreAuthorize finds all the buckets read and written by the given spec, and ensures that execution is allowed
@@ -34,11 +122,22 @@ func (a *preAuthorizer) PreAuthorize(ctx context.Context, spec *flux.Spec, auth
 	if err != nil {
 		return errors.Wrap(err, "could not retrieve buckets for query.Spec")
 	}
+	readBuckets, writeBuckets = dedupBucketFilters(readBuckets), dedupBucketFilters(writeBuckets)
 
 	for _, readBucketFilter := range readBuckets {
 		bucket, err := a.bucketService.FindBucket(ctx, readBucketFilter)
 		if err != nil {
-			return errors.Wrapf(err, "could not find read bucket with filter: %s", readBucketFilter)
+			createPerm, perr := a.createBucketPermission(err, readBucketFilter, orgID)
+			if perr != nil {
+				return errors.Wrapf(perr, "could not create bucket create permission")
+			}
+			if createPerm == nil {
+				return errors.Wrapf(err, "could not find read bucket with filter: %s", readBucketFilter)
+			}
+			if !auth.Allowed(*createPerm) {
+				return errors.New("no permission to create bucket with filter: " + readBucketFilter.String())
+			}
+			continue
 		}
 
 		if bucket == nil {
@@ -56,9 +155,30 @@ func (a *preAuthorizer) PreAuthorize(ctx context.Context, spec *flux.Spec, auth
 	}
 
 	for _, writeBucketFilter := range writeBuckets {
+		if a.writeBucketMode == WriteBucketOrgPermission {
+			reqPerm, err := orgBucketsWritePermission(writeBucketFilter, orgID)
+			if err != nil {
+				return errors.Wrapf(err, "could not create write buckets permission")
+			}
+			if !auth.Allowed(*reqPerm) {
+				return errors.New("no write permission for buckets with filter: " + writeBucketFilter.String())
+			}
+			continue
+		}
+
 		bucket, err := a.bucketService.FindBucket(ctx, writeBucketFilter)
 		if err != nil {
-			return errors.Wrapf(err, "could not find write bucket with filter: %s", writeBucketFilter)
+			createPerm, perr := a.createBucketPermission(err, writeBucketFilter, orgID)
+			if perr != nil {
+				return errors.Wrapf(perr, "could not create bucket create permission")
+			}
+			if createPerm == nil {
+				return errors.Wrapf(err, "could not find write bucket with filter: %s", writeBucketFilter)
+			}
+			if !auth.Allowed(*createPerm) {
+				return errors.New("no permission to create bucket with filter: " + writeBucketFilter.String())
+			}
+			continue
 		}
 
 		reqPerm, err := platform.NewPermissionAtID(bucket.ID, platform.WriteAction, platform.BucketsResourceType, bucket.OrganizationID)
@@ -74,19 +194,29 @@ func (a *preAuthorizer) PreAuthorize(ctx context.Context, spec *flux.Spec, auth
 }
 
 // RequiredPermissions returns a slice of permissions required for the query contained in spec.
-// This method also validates that the buckets exist.
+// This method also validates that the buckets exist, unless unknown buckets require create permission
+// or written buckets require only organization permission.
 func (a *preAuthorizer) RequiredPermissions(ctx context.Context, spec *flux.Spec, orgID *platform.ID) ([]platform.Permission, error) {
 	readBuckets, writeBuckets, err := BucketsAccessed(spec, orgID)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "could not retrieve buckets for query.Spec")
 	}
+	readBuckets, writeBuckets = dedupBucketFilters(readBuckets), dedupBucketFilters(writeBuckets)
 
 	ps := make([]platform.Permission, 0, len(readBuckets)+len(writeBuckets))
 	for _, readBucketFilter := range readBuckets {
 		bucket, err := a.bucketService.FindBucket(ctx, readBucketFilter)
 		if err != nil {
-			return nil, errors.Wrapf(err, "could not find read bucket with filter: %s", readBucketFilter)
+			createPerm, perr := a.createBucketPermission(err, readBucketFilter, orgID)
+			if perr != nil {
+				return nil, errors.Wrapf(perr, "could not create bucket create permission")
+			}
+			if createPerm == nil {
+				return nil, errors.Wrapf(err, "could not find read bucket with filter: %s", readBucketFilter)
+			}
+			ps = append(ps, *createPerm)
+			continue
 		}
 
 		if bucket == nil {
@@ -102,9 +232,26 @@ func (a *preAuthorizer) RequiredPermissions(ctx context.Context, spec *flux.Spec
 	}
 
 	for _, writeBucketFilter := range writeBuckets {
+		if a.writeBucketMode == WriteBucketOrgPermission {
+			reqPerm, err := orgBucketsWritePermission(writeBucketFilter, orgID)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not create write buckets permission")
+			}
+			ps = append(ps, *reqPerm)
+			continue
+		}
+
 		bucket, err := a.bucketService.FindBucket(ctx, writeBucketFilter)
 		if err != nil {
-			return nil, errors.Wrapf(err, "could not find write bucket with filter: %s", writeBucketFilter)
+			createPerm, perr := a.createBucketPermission(err, writeBucketFilter, orgID)
+			if perr != nil {
+				return nil, errors.Wrapf(perr, "could not create bucket create permission")
+			}
+			if createPerm == nil {
+				return nil, errors.Wrapf(err, "could not find write bucket with filter: %s", writeBucketFilter)
+			}
+			ps = append(ps, *createPerm)
+			continue
 		}
 
 		reqPerm, err := platform.NewPermissionAtID(bucket.ID, platform.WriteAction, platform.BucketsResourceType, bucket.OrganizationID)
@@ -138,3 +138,248 @@ func TestPreAuthorizer_RequiredPermissions(t *testing.T) {
 		t.Fatalf("unexpected permissions: %s", diff)
 	}
 }
+
+func TestPreAuthorizer_NoBuckets(t *testing.T) {
+	ctx := context.Background()
+
+	bucketService := mock.NewBucketService()
+	bucketService.FindBucketFn = func(ctx context.Context, bucketFilter platform.BucketFilter) (*platform.Bucket, error) {
+		t.Errorf("FindBucket should not be called for a script that accesses no buckets, called with filter %s", bucketFilter)
+		return nil, errors.New("unexpected call to FindBucket")
+	}
+	preAuthorizer := query.NewPreAuthorizer(bucketService)
+
+	const script = `import "csv"
+csv.from(csv: "#datatype,string,long,dateTime:RFC3339,double\n#group,false,false,false,false\n#default,_result,,,\n,result,table,_time,_value\n,,0,2018-05-22T19:53:26Z,1.0\n") |> yield()`
+	spec, err := flux.Compile(ctx, script, time.Now())
+	if err != nil {
+		t.Fatalf("Error compiling query: %v", err)
+	}
+
+	// an inactive authorization has no permissions at all.
+	auth := &platform.Authorization{Status: platform.Inactive}
+	if err := preAuthorizer.PreAuthorize(ctx, spec, auth, nil); err != nil {
+		t.Errorf("Expected a script that accesses no buckets to be authorized, but got error: %v", err)
+	}
+
+	perms, err := preAuthorizer.RequiredPermissions(ctx, spec, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error finding required permissions: %v", err)
+	}
+	if len(perms) != 0 {
+		t.Errorf("Expected a script that accesses no buckets to require no permissions, got %v", perms)
+	}
+}
+
+func TestPreAuthorizer_UnknownBucketMode(t *testing.T) {
+	ctx := context.Background()
+	orgID := platform.ID(1)
+
+	bucketService := mock.NewBucketService()
+	bucketService.FindBucketFn = func(ctx context.Context, bucketFilter platform.BucketFilter) (*platform.Bucket, error) {
+		return nil, &platform.Error{
+			Code: platform.ENotFound,
+			Msg:  "bucket not found",
+		}
+	}
+
+	spec, err := flux.Compile(ctx, `from(bucket:"missing") |> range(start:-2h) |> yield()`, time.Now())
+	if err != nil {
+		t.Fatalf("Error compiling query: %v", err)
+	}
+
+	createPerm, err := platform.NewPermission(platform.WriteAction, platform.BucketsResourceType, orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canCreate := &platform.Authorization{
+		Status:      platform.Active,
+		Permissions: []platform.Permission{*createPerm},
+	}
+	cannotCreate := &platform.Authorization{Status: platform.Active}
+
+	t.Run("missing buckets are an error by default", func(t *testing.T) {
+		preAuthorizer := query.NewPreAuthorizer(bucketService)
+
+		err := preAuthorizer.PreAuthorize(ctx, spec, canCreate, &orgID)
+		if err == nil {
+			t.Fatal("Expected an error authorizing a missing bucket")
+		}
+		if diagnostic := cmp.Diff(`could not find read bucket with filter: [Bucket Name: missing, Org ID: 0000000000000001]: <not found> bucket not found`, err.Error()); diagnostic != "" {
+			t.Errorf("Authorize message mismatch: -want/+got:\n%v", diagnostic)
+		}
+
+		if _, err := preAuthorizer.RequiredPermissions(ctx, spec, &orgID); err == nil {
+			t.Error("Expected an error finding the permissions required for a missing bucket")
+		}
+	})
+
+	t.Run("missing buckets can require create permission", func(t *testing.T) {
+		preAuthorizer := query.NewPreAuthorizer(bucketService, query.WithUnknownBucketMode(query.UnknownBucketRequiresCreate))
+
+		if err := preAuthorizer.PreAuthorize(ctx, spec, canCreate, &orgID); err != nil {
+			t.Errorf("Expected successful authorization, but got error: %v", err)
+		}
+
+		err := preAuthorizer.PreAuthorize(ctx, spec, cannotCreate, &orgID)
+		if err == nil {
+			t.Fatal("Expected an error authorizing a missing bucket without create permission")
+		}
+		if diagnostic := cmp.Diff(`no permission to create bucket with filter: [Bucket Name: missing, Org ID: 0000000000000001]`, err.Error()); diagnostic != "" {
+			t.Errorf("Authorize message mismatch: -want/+got:\n%v", diagnostic)
+		}
+
+		perms, err := preAuthorizer.RequiredPermissions(ctx, spec, &orgID)
+		if err != nil {
+			t.Fatalf("Unexpected error finding required permissions: %v", err)
+		}
+		if diff := cmp.Diff([]platform.Permission{*createPerm}, perms); diff != "" {
+			t.Errorf("unexpected permissions: %s", diff)
+		}
+	})
+}
+
+func TestPreAuthorizer_WriteBucketMode(t *testing.T) {
+	ctx := context.Background()
+
+	i := inmem.NewService()
+
+	o := platform.Organization{Name: "o"}
+	if err := i.CreateOrganization(ctx, &o); err != nil {
+		t.Fatal(err)
+	}
+	bFrom := platform.Bucket{Name: "b-from", OrganizationID: o.ID}
+	if err := i.CreateBucket(ctx, &bFrom); err != nil {
+		t.Fatal(err)
+	}
+
+	const script = `from(bucket:"b-from") |> range(start:-1m) |> to(bucket:"b-new", org:"o")`
+	spec, err := flux.Compile(ctx, script, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pRead, err := platform.NewPermissionAtID(bFrom.ID, platform.ReadAction, platform.BucketsResourceType, o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pWrite, err := platform.NewPermission(platform.WriteAction, platform.BucketsResourceType, o.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canWrite := &platform.Authorization{
+		Status:      platform.Active,
+		Permissions: []platform.Permission{*pRead, *pWrite},
+	}
+	cannotWrite := &platform.Authorization{
+		Status:      platform.Active,
+		Permissions: []platform.Permission{*pRead},
+	}
+
+	t.Run("missing write bucket is an error by default", func(t *testing.T) {
+		preAuthorizer := query.NewPreAuthorizer(i)
+
+		if err := preAuthorizer.PreAuthorize(ctx, spec, canWrite, &o.ID); err == nil {
+			t.Error("Expected an error authorizing a write to a missing bucket")
+		}
+		if _, err := preAuthorizer.RequiredPermissions(ctx, spec, &o.ID); err == nil {
+			t.Error("Expected an error finding the permissions required for a missing bucket")
+		}
+	})
+
+	t.Run("missing write bucket requires only org permission", func(t *testing.T) {
+		preAuthorizer := query.NewPreAuthorizer(i, query.WithWriteBucketMode(query.WriteBucketOrgPermission))
+
+		if err := preAuthorizer.PreAuthorize(ctx, spec, canWrite, &o.ID); err != nil {
+			t.Errorf("Expected successful authorization, but got error: %v", err)
+		}
+
+		err := preAuthorizer.PreAuthorize(ctx, spec, cannotWrite, &o.ID)
+		if err == nil {
+			t.Fatal("Expected an error authorizing a write without write permission")
+		}
+		if diagnostic := cmp.Diff(`no write permission for buckets with filter: [Bucket Name: b-new, Org Name: o]`, err.Error()); diagnostic != "" {
+			t.Errorf("Authorize message mismatch: -want/+got:\n%v", diagnostic)
+		}
+
+		perms, err := preAuthorizer.RequiredPermissions(ctx, spec, &o.ID)
+		if err != nil {
+			t.Fatalf("Unexpected error finding required permissions: %v", err)
+		}
+		if diff := cmp.Diff([]platform.Permission{*pRead, *pWrite}, perms); diff != "" {
+			t.Errorf("unexpected permissions: %s", diff)
+		}
+	})
+
+	t.Run("read buckets must still exist", func(t *testing.T) {
+		preAuthorizer := query.NewPreAuthorizer(i, query.WithWriteBucketMode(query.WriteBucketOrgPermission))
+
+		spec, err := flux.Compile(ctx, `from(bucket:"missing") |> range(start:-1m) |> to(bucket:"b-new", org:"o")`, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := preAuthorizer.PreAuthorize(ctx, spec, canWrite, &o.ID); err == nil {
+			t.Error("Expected an error authorizing a read of a missing bucket")
+		}
+	})
+}
+
+func TestPreAuthorizer_DuplicateBuckets(t *testing.T) {
+	ctx := context.Background()
+	orgID := platform.ID(1)
+	buckets := map[string]*platform.Bucket{
+		"b-from": {ID: platform.ID(10), Name: "b-from", OrganizationID: orgID},
+		"b-to":   {ID: platform.ID(11), Name: "b-to", OrganizationID: orgID},
+	}
+
+	var finds int
+	bs := mock.NewBucketService()
+	bs.FindBucketFn = func(ctx context.Context, f platform.BucketFilter) (*platform.Bucket, error) {
+		finds++
+		if b, ok := buckets[*f.Name]; ok {
+			return b, nil
+		}
+		return nil, errors.New("unknown bucket")
+	}
+
+	const script = `
+from(bucket:"b-from") |> range(start:-1m) |> to(bucket:"b-to", orgID:"0000000000000001")
+from(bucket:"b-from") |> range(start:-1h) |> to(bucket:"b-to", orgID:"0000000000000001")
+`
+	spec, err := flux.Compile(ctx, script, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	preAuthorizer := query.NewPreAuthorizer(bs)
+	perms, err := preAuthorizer.RequiredPermissions(ctx, spec, &orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pRead, err := platform.NewPermissionAtID(platform.ID(10), platform.ReadAction, platform.BucketsResourceType, orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pWrite, err := platform.NewPermissionAtID(platform.ID(11), platform.WriteAction, platform.BucketsResourceType, orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []platform.Permission{*pRead, *pWrite}
+	if diff := cmp.Diff(exp, perms); diff != "" {
+		t.Fatalf("unexpected permissions: %s", diff)
+	}
+	if finds != 2 {
+		t.Errorf("expected each bucket to be found once, found %d times", finds)
+	}
+
+	finds = 0
+	auth := &platform.Authorization{Status: platform.Active, Permissions: exp}
+	if err := preAuthorizer.PreAuthorize(ctx, spec, auth, &orgID); err != nil {
+		t.Fatalf("expected the query to be authorized, got %v", err)
+	}
+	if finds != 2 {
+		t.Errorf("expected each bucket to be found once, found %d times", finds)
+	}
+}
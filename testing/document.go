@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -152,6 +153,17 @@ func NewDocumentIntegrationTest(store kv.Store) func(t *testing.T) {
 			}
 		})
 
+		t.Run("org names are matched case-insensitively", func(t *testing.T) {
+			ds, err := ss.FindDocuments(ctx, influxdb.AuthorizedWhereOrg(s1, strings.ToUpper(o1.Name)), influxdb.IncludeContent, influxdb.IncludeLabels)
+			if err != nil {
+				t.Fatalf("failed to retrieve documents: %v", err)
+			}
+
+			if exp, got := []*influxdb.Document{dl1}, ds; !docsEqual(exp, got) {
+				t.Errorf("documents are different -got/+want\ndiff %s", docsDiff(exp, got))
+			}
+		})
+
 		t.Run("check not found err", func(t *testing.T) {
 			_, err := ss.FindDocuments(ctx, influxdb.WhereID(MustIDBase16(fourID)), influxdb.IncludeContent)
 			ErrorsEqual(t, err, &influxdb.Error{
@@ -171,6 +183,41 @@ func NewDocumentIntegrationTest(store kv.Store) func(t *testing.T) {
 			}
 		})
 
+		t.Run("u2 can filter documents by name and label", func(t *testing.T) {
+			ds, err := ss.FindDocuments(ctx, influxdb.AuthorizedWhere(s2), influxdb.WhereName(d1.Meta.Name), influxdb.WhereLabel(l1.Name), influxdb.IncludeContent, influxdb.IncludeLabels)
+			if err != nil {
+				t.Fatalf("failed to retrieve documents: %v", err)
+			}
+
+			if exp, got := []*influxdb.Document{dl1}, ds; !docsEqual(exp, got) {
+				t.Errorf("documents are different -got/+want\ndiff %s", docsDiff(exp, got))
+			}
+
+			ds, err = ss.FindDocuments(ctx, influxdb.AuthorizedWhere(s2), influxdb.WhereName(d1.Meta.Name), influxdb.WhereLabel(l2.Name))
+			if err != nil {
+				t.Fatalf("failed to retrieve documents: %v", err)
+			}
+
+			if len(ds) != 0 {
+				t.Errorf("expected no documents to match, got %d", len(ds))
+			}
+		})
+
+		t.Run("filters without a selector are rejected", func(t *testing.T) {
+			for _, opts := range [][]influxdb.DocumentFindOptions{
+				{influxdb.WhereName("i2")},
+				{influxdb.IncludeContent, influxdb.IncludeLabels},
+			} {
+				ds, err := ss.FindDocuments(ctx, opts...)
+				if influxdb.ErrorCode(err) != influxdb.EInvalid {
+					t.Errorf("expected an invalid error, got %v", err)
+				}
+				if len(ds) != 0 {
+					t.Errorf("expected no documents, got %d", len(ds))
+				}
+			}
+		})
+
 		t.Run("u2 cannot update document d1", func(t *testing.T) {
 			d := &influxdb.Document{
 				ID: d1.ID,